@@ -0,0 +1,312 @@
+package auth_proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+)
+
+// Claims of a verified id token
+type Claims map[string]interface{}
+
+func decodeHeaderPayload(header string, payload interface{}) error {
+	if header == "" {
+		return nil
+	}
+
+	bts, err := base64.RawURLEncoding.DecodeString(header)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bts, payload)
+}
+
+func DecodeAuthorizationRules(header string) ([]v1alpha1.ProtectedEndpointRule, error) {
+	var rules []v1alpha1.ProtectedEndpointRule
+
+	if err := decodeHeaderPayload(header, &rules); err != nil {
+		return nil, fmt.Errorf("decode authorization rules failed: %s", err.Error())
+	}
+
+	return rules, nil
+}
+
+func DecodeForwardedClaims(header string) ([]v1alpha1.ProtectedEndpointForwardedClaim, error) {
+	var forwardedClaims []v1alpha1.ProtectedEndpointForwardedClaim
+
+	if err := decodeHeaderPayload(header, &forwardedClaims); err != nil {
+		return nil, fmt.Errorf("decode forwarded claims failed: %s", err.Error())
+	}
+
+	return forwardedClaims, nil
+}
+
+// Get a claim by name. Nested claims are separated by dots.
+func (c Claims) Get(name string) (interface{}, bool) {
+	if value, ok := c[name]; ok {
+		return value, true
+	}
+
+	parts := strings.Split(name, ".")
+
+	if len(parts) == 1 {
+		return nil, false
+	}
+
+	var current interface{} = map[string]interface{}(c)
+
+	for _, part := range parts {
+		m, ok := current.(map[string]interface{})
+
+		if !ok {
+			return nil, false
+		}
+
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// Get the string values of a claim. Array claims have one value for each element.
+func (c Claims) GetStrings(name string) []string {
+	value, ok := c.Get(name)
+
+	if !ok || value == nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		res := make([]string, 0, len(v))
+
+		for _, item := range v {
+			if s, ok := claimValueToString(item); ok {
+				res = append(res, s)
+			}
+		}
+
+		return res
+	default:
+		if s, ok := claimValueToString(v); ok {
+			return []string{s}
+		}
+
+		return nil
+	}
+}
+
+func claimValueToString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case bool, float64, json.Number:
+		return fmt.Sprint(v), true
+	default:
+		bts, err := json.Marshal(v)
+
+		if err != nil {
+			return "", false
+		}
+
+		return string(bts), true
+	}
+}
+
+// The email claim is only trusted when it's not explicitly marked as unverified.
+func (c Claims) verifiedEmail() string {
+	if verified, ok := c["email_verified"].(bool); ok && !verified {
+		return ""
+	}
+
+	email, _ := c["email"].(string)
+	return strings.ToLower(email)
+}
+
+func ruleAppliesTo(rule *v1alpha1.ProtectedEndpointRule, method, path string) bool {
+	if len(rule.Methods) > 0 {
+		var matched bool
+
+		for _, m := range rule.Methods {
+			if strings.EqualFold(m, method) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(rule.Paths) == 0 {
+		return true
+	}
+
+	for _, p := range rule.Paths {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(path, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if p == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ruleMatchesClaims(rule *v1alpha1.ProtectedEndpointRule, claims Claims) bool {
+	if len(rule.EmailDomains) > 0 {
+		email := claims.verifiedEmail()
+		var matched bool
+
+		if i := strings.LastIndex(email, "@"); i >= 0 {
+			domain := email[i+1:]
+
+			for _, d := range rule.EmailDomains {
+				if strings.EqualFold(d, domain) {
+					matched = true
+					break
+				}
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(rule.Users) > 0 {
+		email := claims.verifiedEmail()
+		subject, _ := claims["sub"].(string)
+		var matched bool
+
+		for _, user := range rule.Users {
+			if (email != "" && strings.EqualFold(user, email)) || (subject != "" && user == subject) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(rule.Groups) > 0 && !containsAny(claims.GetStrings("groups"), rule.Groups) {
+		return false
+	}
+
+	for _, matcher := range rule.Claims {
+		if !containsAny(claims.GetStrings(matcher.Claim), matcher.Values) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsAny(values []string, candidates []string) bool {
+	for _, v := range values {
+		for _, c := range candidates {
+			if v == c {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// RequestPath returns the decoded and cleaned path of a request uri, rules are matched against it,
+// so "//admin", "/a/../admin" or "/%61dmin" can't go around a rule of "/admin/*".
+func RequestPath(requestURI string) string {
+	if i := strings.IndexAny(requestURI, "?#"); i >= 0 {
+		requestURI = requestURI[:i]
+	}
+
+	if unescaped, err := url.PathUnescape(requestURI); err == nil {
+		requestURI = unescaped
+	}
+
+	cleaned := path.Clean("/" + requestURI)
+
+	// "/admin/" is still under "/admin/*"
+	if strings.HasSuffix(requestURI, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
+
+// Authorize evaluates the rules of a protected endpoint against a request.
+// A matched Deny rule always wins. If any Allow rule applies to the request, at least one of them must match.
+// Requests no rule applies to are allowed.
+func Authorize(rules []v1alpha1.ProtectedEndpointRule, method, requestPath string, claims Claims) bool {
+	path := RequestPath(requestPath)
+	var hasAllowRules, allowed bool
+
+	for i := range rules {
+		rule := &rules[i]
+
+		if !ruleAppliesTo(rule, method, path) {
+			continue
+		}
+
+		matched := ruleMatchesClaims(rule, claims)
+
+		if rule.Effect == v1alpha1.ProtectedEndpointRuleEffectDeny {
+			if matched {
+				return false
+			}
+
+			continue
+		}
+
+		hasAllowRules = true
+
+		if matched {
+			allowed = true
+		}
+	}
+
+	return !hasAllowRules || allowed
+}
+
+// ForwardedClaimHeaders returns the headers set to the upstream.
+// Headers of missing claims are blank, so clients can't forge them.
+func ForwardedClaimHeaders(forwardedClaims []v1alpha1.ProtectedEndpointForwardedClaim, claims Claims) map[string]string {
+	headers := make(map[string]string, len(forwardedClaims))
+
+	for _, forwardedClaim := range forwardedClaims {
+		var value string
+
+		if claims != nil {
+			if v, ok := claims.Get(forwardedClaim.Claim); ok {
+				if _, isArray := v.([]interface{}); isArray {
+					value = strings.Join(claims.GetStrings(forwardedClaim.Claim), ",")
+				} else {
+					value, _ = claimValueToString(v)
+				}
+			}
+		}
+
+		headers[forwardedClaim.Header] = value
+	}
+
+	return headers
+}
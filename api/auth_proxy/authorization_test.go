@@ -0,0 +1,155 @@
+package auth_proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func testClaims() Claims {
+	return Claims{
+		"sub":            "user-id-1",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"groups":         []interface{}{"dev", "ops"},
+		"org": map[string]interface{}{
+			"team":  "payments",
+			"level": float64(3),
+		},
+	}
+}
+
+func TestAuthorizeWithoutRules(t *testing.T) {
+	assert.True(t, Authorize(nil, "GET", "/", testClaims()))
+}
+
+func TestAuthorizeAllowRules(t *testing.T) {
+	rules := []v1alpha1.ProtectedEndpointRule{
+		{
+			Paths:        []string{"/admin/*"},
+			EmailDomains: []string{"example.com"},
+			Groups:       []string{"ops"},
+		},
+		{
+			Paths:   []string{"/billing"},
+			Methods: []string{"POST"},
+			Users:   []string{"bob@example.com"},
+		},
+	}
+
+	claims := testClaims()
+
+	// no rule applies
+	assert.True(t, Authorize(rules, "GET", "/", claims))
+	assert.True(t, Authorize(rules, "GET", "/billing", claims))
+
+	assert.True(t, Authorize(rules, "GET", "/admin/users", claims))
+	assert.False(t, Authorize(rules, "POST", "/billing", claims))
+
+	claims["email"] = "alice@other.com"
+	assert.False(t, Authorize(rules, "GET", "/admin/users", claims))
+
+	// unverified emails are ignored
+	claims = testClaims()
+	claims["email_verified"] = false
+	assert.False(t, Authorize(rules, "GET", "/admin/users", claims))
+
+	claims = testClaims()
+	claims["email"] = "bob@example.com"
+	assert.True(t, Authorize(rules, "POST", "/billing", claims))
+}
+
+func TestAuthorizeDenyRules(t *testing.T) {
+	rules := []v1alpha1.ProtectedEndpointRule{
+		{
+			Groups: []string{"dev"},
+		},
+		{
+			Effect:  v1alpha1.ProtectedEndpointRuleEffectDeny,
+			Paths:   []string{"/danger/*"},
+			Methods: []string{"delete"},
+			Claims: []v1alpha1.ProtectedEndpointClaimMatcher{
+				{Claim: "org.team", Values: []string{"payments"}},
+			},
+		},
+	}
+
+	claims := testClaims()
+
+	assert.True(t, Authorize(rules, "GET", "/danger/zone", claims))
+	assert.False(t, Authorize(rules, "DELETE", "/danger/zone", claims))
+
+	claims["org"] = map[string]interface{}{"team": "infra"}
+	assert.True(t, Authorize(rules, "DELETE", "/danger/zone", claims))
+
+	claims["groups"] = []interface{}{"guest"}
+	assert.False(t, Authorize(rules, "GET", "/", claims))
+}
+
+func TestAuthorizeUncleanPaths(t *testing.T) {
+	rules := []v1alpha1.ProtectedEndpointRule{
+		{
+			Effect: v1alpha1.ProtectedEndpointRuleEffectDeny,
+			Paths:  []string{"/admin/*"},
+			Groups: []string{"dev"},
+		},
+	}
+
+	claims := testClaims()
+
+	for _, p := range []string{"/admin/", "//admin/users", "/a/../admin/users", "/./admin//users", "/%61dmin/users", "/admin/users?x=1"} {
+		assert.False(t, Authorize(rules, "GET", p, claims), p)
+	}
+
+	assert.True(t, Authorize(rules, "GET", "/administrator", claims))
+}
+
+func TestRequestPath(t *testing.T) {
+	assert.Equal(t, "/", RequestPath(""))
+	assert.Equal(t, "/admin", RequestPath("//admin"))
+	assert.Equal(t, "/admin/", RequestPath("/a/../admin/"))
+	assert.Equal(t, "/admin/users", RequestPath("/%61dmin/users?next=/"))
+	assert.Equal(t, "/", RequestPath("/../.."))
+}
+
+func TestForwardedClaimHeaders(t *testing.T) {
+	forwardedClaims := []v1alpha1.ProtectedEndpointForwardedClaim{
+		{Claim: "email", Header: "X-User-Email"},
+		{Claim: "groups", Header: "X-User-Groups"},
+		{Claim: "org.level", Header: "X-User-Level"},
+		{Claim: "missing", Header: "X-Missing"},
+	}
+
+	assert.Equal(t, map[string]string{
+		"X-User-Email":  "alice@example.com",
+		"X-User-Groups": "dev,ops",
+		"X-User-Level":  "3",
+		"X-Missing":     "",
+	}, ForwardedClaimHeaders(forwardedClaims, testClaims()))
+
+	assert.Equal(t, map[string]string{
+		"X-User-Email":  "",
+		"X-User-Groups": "",
+		"X-User-Level":  "",
+		"X-Missing":     "",
+	}, ForwardedClaimHeaders(forwardedClaims, nil))
+}
+
+func TestDecodeAuthorizationRules(t *testing.T) {
+	rules, err := DecodeAuthorizationRules("")
+	assert.Nil(t, err)
+	assert.Nil(t, rules)
+
+	expected := []v1alpha1.ProtectedEndpointRule{{Paths: []string{"/"}, Users: []string{"alice@example.com"}}}
+	bts, _ := json.Marshal(expected)
+
+	rules, err = DecodeAuthorizationRules(base64.RawURLEncoding.EncodeToString(bts))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, rules)
+
+	_, err = DecodeAuthorizationRules("%invalid%")
+	assert.NotNil(t, err)
+}
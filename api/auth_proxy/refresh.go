@@ -1,53 +1,167 @@
 package auth_proxy
 
 import (
-	"github.com/coreos/go-oidc"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
 
-type RefreshContext struct {
-	Cond *sync.Cond
+const (
+	// How long a refresh result is kept for concurrent requests holding the same refresh token
+	RefreshResultTTL = 60 * time.Second
 
-	// After broadcast, Either the error is nil or the tokens are nil
-	Error         error
-	IDToken       *oidc.IDToken
-	IDTokenString string
-	RefreshToken  string
+	// How long a request waits for the refresh result of another request
+	RefreshWaitTimeout = 10 * time.Second
+
+	// A lock without a result after this is taken over by a waiting request, its owner may have crashed.
+	// It's shorter than RefreshWaitTimeout, so waiting requests don't give up on a crashed owner.
+	RefreshLockTimeout = 5 * time.Second
+
+	// The owner of a lock gives up redeeming before the lock can be taken over
+	RefreshRedeemTimeout = 4 * time.Second
+
+	refreshPollInterval = 100 * time.Millisecond
+)
+
+// The result of redeeming a refresh token. Either Error is blank or the tokens are blank.
+type RefreshResult struct {
+	IDTokenString string `json:"i,omitempty"`
+	RefreshToken  string `json:"r,omitempty"`
+	Error         string `json:"e,omitempty"`
 }
 
-// A lock to protected getRefreshTokenCond
-var refreshLock *sync.Mutex
-var refreshCondMap map[string]*RefreshContext
+// When a user's id_token has expired, but the refresh_token is still valid, multiple requests may be received in a short time window.
+// But refresh_token is not allowed to be used twice. A RefreshStore makes sure only one request redeems the refresh token,
+// and other requests, maybe on other auth-proxy replicas, pick up the result.
+// Keys are hashes of refresh tokens, raw refresh tokens are never saved in stores.
+type RefreshStore interface {
+	// Acquire returns true if the caller wins the right to redeem the refresh token.
+	// It's called again by waiting requests, stores may let them take over a lock whose owner is gone.
+	Acquire(key string) (bool, error)
+
+	// SetResult saves the result for waiting requests.
+	SetResult(key string, result *RefreshResult) error
 
-func init() {
-	refreshLock = &sync.Mutex{}
-	refreshCondMap = make(map[string]*RefreshContext)
+	// GetResult returns nil if the result is not ready yet.
+	GetResult(key string) (*RefreshResult, error)
 }
 
-func GetRefreshTokenCond(token string) (*RefreshContext, bool) {
-	refreshLock.Lock()
-	defer refreshLock.Unlock()
+func getRefreshKey(refreshToken string) string {
+	hash := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(hash[:])
+}
+
+// RedeemRefreshToken calls redeem exactly once for each refresh token among all auth-proxy replicas sharing the store.
+// All callers get the same result.
+func RedeemRefreshToken(store RefreshStore, refreshToken string, redeem func() *RefreshResult) (*RefreshResult, error) {
+	key := getRefreshKey(refreshToken)
+
+	acquired, err := store.Acquire(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result *RefreshResult
+
+	if acquired {
+		result = redeem()
+
+		if err := store.SetResult(key, result); err != nil {
+			return nil, err
+		}
+	} else {
+		result, err = waitRefreshResult(store, key, redeem)
 
-	if cond, ok := refreshCondMap[token]; ok {
-		return cond, false
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	m := sync.Mutex{}
-	refreshCondMap[token] = &RefreshContext{Cond: sync.NewCond(&m)}
-	return refreshCondMap[token], true
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+
+	return result, nil
 }
 
-func RemoveRefreshTokenCond(token string, delaySeconds int) {
-	go func() {
-		time.Sleep(time.Duration(delaySeconds) * time.Second)
-		refreshLock.Lock()
-		defer refreshLock.Unlock()
+func waitRefreshResult(store RefreshStore, key string, redeem func() *RefreshResult) (*RefreshResult, error) {
+	deadline := time.Now().Add(RefreshWaitTimeout)
+
+	for time.Now().Before(deadline) {
+		result, err := store.GetResult(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if result != nil {
+			return result, nil
+		}
+
+		acquired, err := store.Acquire(key)
+
+		if err != nil {
+			return nil, err
+		}
 
-		if refreshCondMap == nil {
-			return
+		if acquired {
+			result = redeem()
+			return result, store.SetResult(key, result)
 		}
 
-		delete(refreshCondMap, token)
-	}()
+		time.Sleep(refreshPollInterval)
+	}
+
+	return nil, fmt.Errorf("wait for refresh result timeout")
+}
+
+// MemoryRefreshStore keeps refresh results in process.
+// It's enough if the auth-proxy service only has one replica.
+type MemoryRefreshStore struct {
+	mut     *sync.Mutex
+	results map[string]*RefreshResult
+}
+
+func NewMemoryRefreshStore() *MemoryRefreshStore {
+	return &MemoryRefreshStore{
+		mut:     &sync.Mutex{},
+		results: make(map[string]*RefreshResult),
+	}
+}
+
+func (s *MemoryRefreshStore) Acquire(key string) (bool, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if _, ok := s.results[key]; ok {
+		return false, nil
+	}
+
+	// nil means the refresh token is being redeemed
+	s.results[key] = nil
+
+	time.AfterFunc(RefreshResultTTL, func() {
+		s.mut.Lock()
+		defer s.mut.Unlock()
+		delete(s.results, key)
+	})
+
+	return true, nil
+}
+
+func (s *MemoryRefreshStore) SetResult(key string, result *RefreshResult) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.results[key] = result
+	return nil
+}
+
+func (s *MemoryRefreshStore) GetResult(key string) (*RefreshResult, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.results[key], nil
 }
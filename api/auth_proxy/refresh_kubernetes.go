@@ -0,0 +1,192 @@
+package auth_proxy
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/kalmhq/kalm/api/log"
+	"go.uber.org/zap"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// KubernetesRefreshStore shares refresh results between auth-proxy replicas through a single config map,
+// which is created by the controller, auth-proxy can only get and update it.
+// Keys of the config map are refresh keys. Adding a key works as a lock, only the replica who added it redeems the refresh token.
+// Writes are guarded by the resource version, so two replicas can't add the same key.
+// Results are encrypted with the same key as the cookies.
+type KubernetesRefreshStore struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+}
+
+type refreshEntry struct {
+	LockedAt int64  `json:"l"`
+	Result   []byte `json:"r,omitempty"`
+}
+
+func NewKubernetesRefreshStore(client kubernetes.Interface, namespace, name string) *KubernetesRefreshStore {
+	return &KubernetesRefreshStore{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+func parseRefreshEntry(data string) (*refreshEntry, bool) {
+	var entry refreshEntry
+
+	if data == "" || json.Unmarshal([]byte(data), &entry) != nil {
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+func (s *KubernetesRefreshStore) get() (*coreV1.ConfigMap, error) {
+	return s.client.CoreV1().ConfigMaps(s.namespace).Get(context.Background(), s.name, metaV1.GetOptions{})
+}
+
+// update applies the change to the latest data, expired entries are removed on the way.
+// The change returns false if nothing is changed.
+func (s *KubernetesRefreshStore) update(change func(data map[string]string) bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap, err := s.get()
+
+		if err != nil {
+			return err
+		}
+
+		if configMap.Data == nil {
+			configMap.Data = make(map[string]string)
+		}
+
+		removed := removeExpiredRefreshEntries(configMap.Data, time.Now())
+
+		if !change(configMap.Data) && !removed {
+			return nil
+		}
+
+		_, err = s.client.CoreV1().ConfigMaps(s.namespace).Update(context.Background(), configMap, metaV1.UpdateOptions{})
+
+		return err
+	})
+}
+
+// Acquire takes over a lock older than RefreshLockTimeout without a result, its owner is gone.
+func (s *KubernetesRefreshStore) Acquire(key string) (bool, error) {
+	var acquired bool
+
+	err := s.update(func(data map[string]string) bool {
+		acquired = false
+
+		if entry, ok := parseRefreshEntry(data[key]); ok {
+			if entry.Result != nil || time.Since(time.Unix(0, entry.LockedAt)) < RefreshLockTimeout {
+				return false
+			}
+		}
+
+		entryBytes, _ := json.Marshal(&refreshEntry{LockedAt: time.Now().UnixNano()})
+		data[key] = string(entryBytes)
+		acquired = true
+
+		return true
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	return acquired, nil
+}
+
+func (s *KubernetesRefreshStore) SetResult(key string, result *RefreshResult) error {
+	resultBytes, err := json.Marshal(result)
+
+	if err != nil {
+		return err
+	}
+
+	encryptedResult, err := AesEncrypt(resultBytes)
+
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(data map[string]string) bool {
+		entry, ok := parseRefreshEntry(data[key])
+
+		if !ok {
+			entry = &refreshEntry{LockedAt: time.Now().UnixNano()}
+		}
+
+		entry.Result = encryptedResult
+		entryBytes, _ := json.Marshal(entry)
+		data[key] = string(entryBytes)
+
+		return true
+	})
+}
+
+func (s *KubernetesRefreshStore) GetResult(key string) (*RefreshResult, error) {
+	configMap, err := s.get()
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := parseRefreshEntry(configMap.Data[key])
+
+	if !ok || entry.Result == nil {
+		return nil, nil
+	}
+
+	resultBytes, err := AesDecrypt(entry.Result)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var result RefreshResult
+
+	if err := json.Unmarshal(resultBytes, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func removeExpiredRefreshEntries(data map[string]string, now time.Time) bool {
+	var removed bool
+
+	for key, value := range data {
+		entry, ok := parseRefreshEntry(value)
+
+		if ok && now.Sub(time.Unix(0, entry.LockedAt)) < RefreshResultTTL {
+			continue
+		}
+
+		delete(data, key)
+		removed = true
+	}
+
+	return removed
+}
+
+// RemoveExpiredResults deletes entries older than RefreshResultTTL. It's safe to be run on every replica.
+func (s *KubernetesRefreshStore) RemoveExpiredResults() {
+	if err := s.update(func(map[string]string) bool { return false }); err != nil {
+		log.Error("remove expired refresh results failed", zap.Error(err))
+	}
+}
+
+func (s *KubernetesRefreshStore) RunCleanupLoop() {
+	for {
+		time.Sleep(RefreshResultTTL)
+		s.RemoveExpiredResults()
+	}
+}
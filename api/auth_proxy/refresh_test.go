@@ -0,0 +1,154 @@
+package auth_proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8sTesting "k8s.io/client-go/testing"
+)
+
+func testRedeemExactlyOnce(t *testing.T, stores ...RefreshStore) {
+	var redeemCount int32
+	var wg sync.WaitGroup
+
+	results := make([]*RefreshResult, 20)
+
+	for i := range results {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			result, err := RedeemRefreshToken(stores[i%len(stores)], "refresh-token", func() *RefreshResult {
+				atomic.AddInt32(&redeemCount, 1)
+				time.Sleep(200 * time.Millisecond)
+				return &RefreshResult{IDTokenString: "new-id-token", RefreshToken: "new-refresh-token"}
+			})
+
+			assert.Nil(t, err)
+			results[i] = result
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.EqualValues(t, 1, redeemCount)
+
+	for _, result := range results {
+		assert.Equal(t, "new-id-token", result.IDTokenString)
+		assert.Equal(t, "new-refresh-token", result.RefreshToken)
+	}
+}
+
+func TestMemoryRefreshStoreRedeemExactlyOnce(t *testing.T) {
+	testRedeemExactlyOnce(t, NewMemoryRefreshStore())
+}
+
+// genRefreshConfigMapClient returns a fake client with the config map, updates are guarded by resource versions
+// like the real api server.
+func genRefreshConfigMapClient() *fake.Clientset {
+	client := fake.NewSimpleClientset(&coreV1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "kalm-system", Name: "auth-proxy-refresh", ResourceVersion: "1"},
+	})
+
+	client.PrependReactor("update", "configmaps", func(action k8sTesting.Action) (bool, runtime.Object, error) {
+		configMap := action.(k8sTesting.UpdateAction).GetObject().(*coreV1.ConfigMap).DeepCopy()
+		current, err := client.Tracker().Get(coreV1.SchemeGroupVersion.WithResource("configmaps"), configMap.Namespace, configMap.Name)
+
+		if err != nil {
+			return true, nil, err
+		}
+
+		if current.(*coreV1.ConfigMap).ResourceVersion != configMap.ResourceVersion {
+			return true, nil, errors.NewConflict(coreV1.Resource("configmaps"), configMap.Name, fmt.Errorf("resource version changed"))
+		}
+
+		version, _ := strconv.Atoi(configMap.ResourceVersion)
+		configMap.ResourceVersion = strconv.Itoa(version + 1)
+
+		return true, configMap, client.Tracker().Update(coreV1.SchemeGroupVersion.WithResource("configmaps"), configMap, configMap.Namespace)
+	})
+
+	return client
+}
+
+func TestKubernetesRefreshStoreRedeemExactlyOnce(t *testing.T) {
+	InitEncryptKey(sha256.Sum256([]byte("test")))
+
+	// multiple replicas share the same kubernetes cluster
+	client := genRefreshConfigMapClient()
+	testRedeemExactlyOnce(t,
+		NewKubernetesRefreshStore(client, "kalm-system", "auth-proxy-refresh"),
+		NewKubernetesRefreshStore(client, "kalm-system", "auth-proxy-refresh"),
+	)
+
+	configMap, err := client.CoreV1().ConfigMaps("kalm-system").Get(context.Background(), "auth-proxy-refresh", metaV1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, configMap.Data, 1)
+
+	for _, data := range configMap.Data {
+		assert.NotContains(t, data, "new-refresh-token")
+	}
+}
+
+func TestKubernetesRefreshStoreTakeOverStaleLock(t *testing.T) {
+	InitEncryptKey(sha256.Sum256([]byte("test")))
+
+	client := genRefreshConfigMapClient()
+	store := NewKubernetesRefreshStore(client, "kalm-system", "auth-proxy-refresh")
+	key := getRefreshKey("refresh-token")
+
+	// the owner crashed before setting the result
+	entryBytes, _ := json.Marshal(&refreshEntry{LockedAt: time.Now().Add(-RefreshLockTimeout).UnixNano()})
+	assert.Nil(t, store.update(func(data map[string]string) bool {
+		data[key] = string(entryBytes)
+		return true
+	}))
+
+	result, err := RedeemRefreshToken(store, "refresh-token", func() *RefreshResult {
+		return &RefreshResult{IDTokenString: "new-id-token", RefreshToken: "new-refresh-token"}
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "new-id-token", result.IDTokenString)
+
+	// expired entries are removed
+	assert.Nil(t, store.update(func(data map[string]string) bool {
+		data["expired"] = `{"l":1}`
+		return true
+	}))
+	store.RemoveExpiredResults()
+
+	configMap, err := client.CoreV1().ConfigMaps("kalm-system").Get(context.Background(), "auth-proxy-refresh", metaV1.GetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, configMap.Data, 1)
+}
+
+func TestRedeemRefreshTokenError(t *testing.T) {
+	store := NewMemoryRefreshStore()
+
+	_, err := RedeemRefreshToken(store, "refresh-token", func() *RefreshResult {
+		return &RefreshResult{Error: "invalid refresh token"}
+	})
+	assert.EqualError(t, err, "invalid refresh token")
+
+	// other requests get the same error without redeeming again
+	_, err = RedeemRefreshToken(store, "refresh-token", func() *RefreshResult {
+		t.Fatal("refresh token should not be redeemed twice")
+		return nil
+	})
+	assert.EqualError(t, err, "invalid refresh token")
+}
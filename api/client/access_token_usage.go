@@ -0,0 +1,173 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/kalmhq/kalm/api/log"
+	"github.com/kalmhq/kalm/api/metrics"
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Usages are accumulated in memory and written to access token status once per interval.
+	// This keeps the number of status patches bounded no matter how busy a token is.
+	AccessTokenUsageFlushInterval = time.Minute
+
+	// Max number of recent activities kept for each access token
+	MaxAccessTokenActivities = 100
+)
+
+type AccessTokenActivity struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	IP     string    `json:"ip"`
+}
+
+func NewAccessTokenActivity(c echo.Context) AccessTokenActivity {
+	return AccessTokenActivity{
+		Time:   time.Now(),
+		Method: c.Request().Method,
+		Path:   c.Request().URL.Path,
+		IP:     c.RealIP(),
+	}
+}
+
+// Websocket clients are authorized by a message after the connection is established
+func NewWebsocketAccessTokenActivity(path string, remoteAddr net.Addr) AccessTokenActivity {
+	ip := remoteAddr.String()
+
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	return AccessTokenActivity{
+		Time:   time.Now(),
+		Method: "WEBSOCKET",
+		Path:   path,
+		IP:     ip,
+	}
+}
+
+type accessTokenUsage struct {
+	count      int
+	lastUsedAt int
+}
+
+// AccessTokenUsageRecorder tracks usages of access tokens.
+// Recent activities are kept in memory of the current api server instance only.
+type AccessTokenUsageRecorder struct {
+	mut             *sync.Mutex
+	resourceManager *resources.ResourceManager
+	pending         map[string]*accessTokenUsage
+	activities      map[string][]AccessTokenActivity
+}
+
+func NewAccessTokenUsageRecorder(resourceManager *resources.ResourceManager) *AccessTokenUsageRecorder {
+	return &AccessTokenUsageRecorder{
+		mut:             &sync.Mutex{},
+		resourceManager: resourceManager,
+		pending:         make(map[string]*accessTokenUsage),
+		activities:      make(map[string][]AccessTokenActivity),
+	}
+}
+
+func (r *AccessTokenUsageRecorder) Record(name string, activity AccessTokenActivity) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	usage, ok := r.pending[name]
+
+	if !ok {
+		usage = &accessTokenUsage{}
+		r.pending[name] = usage
+	}
+
+	usage.count += 1
+	usage.lastUsedAt = int(activity.Time.Unix())
+
+	metrics.AccessTokenRequests.Inc()
+	metrics.AccessTokenLastUsed.Set(float64(activity.Time.Unix()))
+
+	activities := append(r.activities[name], activity)
+
+	if len(activities) > MaxAccessTokenActivities {
+		activities = activities[len(activities)-MaxAccessTokenActivities:]
+	}
+
+	r.activities[name] = activities
+}
+
+// GetActivities returns recent activities of the access token, the latest comes first.
+func (r *AccessTokenUsageRecorder) GetActivities(name string) []AccessTokenActivity {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	activities := r.activities[name]
+	res := make([]AccessTokenActivity, len(activities))
+
+	for i := range activities {
+		res[len(activities)-1-i] = activities[i]
+	}
+
+	return res
+}
+
+// Forget drops everything recorded for a deleted access token
+func (r *AccessTokenUsageRecorder) Forget(name string) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	delete(r.pending, name)
+	delete(r.activities, name)
+}
+
+// Flush writes accumulated usages to access token status
+func (r *AccessTokenUsageRecorder) Flush() {
+	r.mut.Lock()
+	pending := r.pending
+	r.pending = make(map[string]*accessTokenUsage)
+	r.mut.Unlock()
+
+	for name, usage := range pending {
+		var accessToken v1alpha1.AccessToken
+
+		if err := r.resourceManager.Get("", name, &accessToken); err != nil {
+			if !errors.IsNotFound(err) {
+				log.Error("fail to get access token", zap.String("name", name), zap.Error(err))
+			}
+
+			continue
+		}
+
+		copied := accessToken.DeepCopy()
+		copied.Status.UsedCount += usage.count
+		copied.Status.LastUsedAt = usage.lastUsedAt
+
+		if err := r.resourceManager.PatchStatus(copied, client.MergeFrom(&accessToken)); err != nil {
+			log.Error("fail to update status of access token", zap.String("name", name), zap.Error(err))
+		}
+	}
+}
+
+func (r *AccessTokenUsageRecorder) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(AccessTokenUsageFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.Flush()
+		case <-stopCh:
+			r.Flush()
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessTokenUsageRecorder(t *testing.T) {
+	recorder := NewAccessTokenUsageRecorder(nil)
+
+	now := time.Now()
+
+	for i := 0; i < MaxAccessTokenActivities+10; i++ {
+		recorder.Record("foo", AccessTokenActivity{
+			Time:   now.Add(time.Duration(i) * time.Second),
+			Method: "GET",
+			Path:   fmt.Sprintf("/v1alpha1/applications/%d", i),
+		})
+	}
+
+	recorder.Record("bar", AccessTokenActivity{Time: now, Method: "POST", Path: "/webhook/components"})
+
+	activities := recorder.GetActivities("foo")
+	assert.Len(t, activities, MaxAccessTokenActivities)
+	assert.Equal(t, fmt.Sprintf("/v1alpha1/applications/%d", MaxAccessTokenActivities+9), activities[0].Path)
+	assert.Equal(t, "/v1alpha1/applications/10", activities[MaxAccessTokenActivities-1].Path)
+
+	assert.Equal(t, MaxAccessTokenActivities+10, recorder.pending["foo"].count)
+	assert.Equal(t, int(now.Add(time.Duration(MaxAccessTokenActivities+9)*time.Second).Unix()), recorder.pending["foo"].lastUsedAt)
+
+	recorder.Forget("foo")
+	assert.Len(t, recorder.GetActivities("foo"), 0)
+	assert.Len(t, recorder.GetActivities("bar"), 1)
+}
+
+func TestRecordAccessTokenUsageOnlyForAccessTokens(t *testing.T) {
+	manager := &BaseClientManager{UsageRecorder: NewAccessTokenUsageRecorder(nil)}
+
+	manager.RecordAccessTokenUsage(&ClientInfo{Name: "user@example.com"}, AccessTokenActivity{Time: time.Now()})
+	manager.RecordAccessTokenUsage(&ClientInfo{Name: "foo", AccessTokenName: "foo"}, AccessTokenActivity{Time: time.Now()})
+
+	assert.Len(t, manager.GetAccessTokenActivities("user@example.com"), 0)
+	assert.Len(t, manager.GetAccessTokenActivities("foo"), 1)
+}
@@ -26,6 +26,9 @@ type ClientInfo struct {
 	Groups            []string     `json:"groups"`
 	Impersonation     string       `json:"impersonation"`
 	ImpersonationType string       `json:"impersonationType"`
+
+	// Name of the access token if the client is authorized by an access token
+	AccessTokenName string `json:"-"`
 }
 
 type ClientManager interface {
@@ -34,6 +37,9 @@ type ClientManager interface {
 	GetClientInfoFromContext(c echo.Context) (*ClientInfo, error)
 	SetImpersonation(client *ClientInfo, impersonation string)
 
+	RecordAccessTokenUsage(client *ClientInfo, activity AccessTokenActivity)
+	GetAccessTokenActivities(name string) []AccessTokenActivity
+
 	Can(client *ClientInfo, verb, scope, obj string) bool
 	CanView(client *ClientInfo, scope string, obj string) bool
 	CanEdit(client *ClientInfo, scope string, obj string) bool
@@ -46,6 +52,9 @@ type ClientManager interface {
 	CanManageCluster(client *ClientInfo) bool
 
 	// special resources
+	CanViewPodLogs(c *ClientInfo, scope, podName string) bool
+	CanExecPod(c *ClientInfo, scope, podName string) bool
+	CanDeployComponent(c *ClientInfo, scope, componentName string) bool
 	CanOperateHttpRoute(c *ClientInfo, action string, route *resources.HttpRoute) bool
 	PermissionsGreaterThanOrEqualAccessToken(c *ClientInfo, accessToken *resources.AccessToken) bool
 	CanManageRoleBinding(c *ClientInfo, roleBinding *v1alpha1.RoleBinding) bool
@@ -59,6 +68,9 @@ var _ ClientManager = new(FakeClientManager)
 
 type BaseClientManager struct {
 	RBACEnforcer rbac.Enforcer
+
+	// Only set when clients can be authorized by access tokens
+	UsageRecorder *AccessTokenUsageRecorder
 }
 
 func NewBaseClientManager(adapter persist.Adapter) *BaseClientManager {
@@ -75,6 +87,22 @@ func (m *BaseClientManager) GetRBACEnforcer() rbac.Enforcer {
 	return m.RBACEnforcer
 }
 
+func (m *BaseClientManager) RecordAccessTokenUsage(client *ClientInfo, activity AccessTokenActivity) {
+	if m.UsageRecorder == nil || client == nil || client.AccessTokenName == "" {
+		return
+	}
+
+	m.UsageRecorder.Record(client.AccessTokenName, activity)
+}
+
+func (m *BaseClientManager) GetAccessTokenActivities(name string) []AccessTokenActivity {
+	if m.UsageRecorder == nil {
+		return []AccessTokenActivity{}
+	}
+
+	return m.UsageRecorder.GetActivities(name)
+}
+
 func (m *BaseClientManager) wrapper(client *ClientInfo, authFunc interface{}, args ...interface{}) bool {
 	if m.RBACEnforcer == nil {
 		return false
@@ -149,6 +177,24 @@ func (m *BaseClientManager) CanManageCluster(client *ClientInfo) bool {
 	return m.wrapper(client, m.RBACEnforcer.CanManageCluster)
 }
 
+// Viewers can read logs. The logs action is for custom roles.
+func (m *BaseClientManager) CanViewPodLogs(c *ClientInfo, scope, podName string) bool {
+	obj := "pods/" + podName
+	return m.CanView(c, scope, obj) || m.Can(c, rbac.ActionLogs, scope, obj)
+}
+
+// Editors can exec into pods. The exec action is for custom roles.
+func (m *BaseClientManager) CanExecPod(c *ClientInfo, scope, podName string) bool {
+	obj := "pods/" + podName
+	return m.CanEdit(c, scope, obj) || m.Can(c, rbac.ActionExec, scope, obj)
+}
+
+// Editors can deploy components. The deploy action is for custom roles.
+func (m *BaseClientManager) CanDeployComponent(c *ClientInfo, scope, componentName string) bool {
+	obj := "components/" + componentName
+	return m.CanEdit(c, scope, obj) || m.Can(c, rbac.ActionDeploy, scope, obj)
+}
+
 func (m *BaseClientManager) PermissionsGreaterThanOrEqualAccessToken(c *ClientInfo, accessToken *resources.AccessToken) bool {
 	policies := GetPoliciesFromAccessToken(accessToken)
 
@@ -193,6 +239,13 @@ func (m *BaseClientManager) CanManageRoleBinding(c *ClientInfo, roleBinding *v1a
 	switch roleBinding.Spec.Role {
 	case v1alpha1.ClusterRoleViewer, v1alpha1.ClusterRoleEditor, v1alpha1.ClusterRoleOwner:
 		return m.CanManageCluster(c)
+	case v1alpha1.RoleCustom:
+		// custom roles bound in kalm-system are granted in all applications
+		if roleBinding.Namespace == v1alpha1.KalmSystemNamespace {
+			return m.CanManageCluster(c)
+		}
+
+		return m.CanManageNamespace(c, roleBinding.Namespace)
 	default:
 		return m.CanManageNamespace(c, roleBinding.Namespace)
 	}
@@ -39,6 +39,7 @@ type StandardClientManager struct {
 	Applications  map[string]*coreV1.Namespace
 	AccessTokens  map[string]*v1alpha1.AccessToken
 	RoleBindings  map[string]*v1alpha1.RoleBinding
+	CustomRoles   map[string]*v1alpha1.CustomRole
 	StopWatchChan chan struct{}
 }
 
@@ -71,6 +72,61 @@ g, role_{{ .name }}Owner, role_{{ .name }}Editor
 	return strBuffer.String()
 }
 
+// Custom roles are cluster level definitions. The rules are granted in the namespace of the binding.
+// Bindings in kalm-system grant the rules in all applications.
+func customRoleScope(bindingNamespace string) string {
+	if bindingNamespace == v1alpha1.KalmSystemNamespace {
+		return rbac.AllScope
+	}
+
+	return bindingNamespace
+}
+
+func customRoleValueToPolicyValue(customRole, bindingNamespace string) string {
+	// namespace names can't contain upper case letters, so the cluster subject can't collide with namespace subjects
+	if bindingNamespace == v1alpha1.KalmSystemNamespace {
+		return fmt.Sprintf("role_clusterCustom_%s", customRole)
+	}
+
+	return fmt.Sprintf("role_%sCustom_%s", bindingNamespace, customRole)
+}
+
+func BuildCustomRolePolicies(customRole *v1alpha1.CustomRole, bindingNamespace string) string {
+	var sb strings.Builder
+
+	subject := customRoleValueToPolicyValue(customRole.Name, bindingNamespace)
+	scope := customRoleScope(bindingNamespace)
+
+	sb.WriteString(fmt.Sprintf("# custom role %s policies for %s\n", customRole.Name, scope))
+
+	for _, rule := range customRole.Spec.Rules {
+		var obj string
+
+		if rule.Kind == rbac.ResourceAll {
+			obj = rbac.ResourceAll
+		} else if rule.Name == "" {
+			obj = fmt.Sprintf("%s/*", rule.Kind)
+		} else {
+			obj = fmt.Sprintf("%s/%s", rule.Kind, rule.Name)
+		}
+
+		written := make(map[string]bool)
+
+		for _, verb := range rule.Verbs {
+			for _, action := range rbac.ImpliedActions(string(verb)) {
+				if written[action] {
+					continue
+				}
+
+				written[action] = true
+				sb.WriteString(fmt.Sprintf("p, %s, %s, %s, %s\n", subject, action, scope, obj))
+			}
+		}
+	}
+
+	return sb.String()
+}
+
 func GetPoliciesFromAccessToken(accessToken *resources.AccessToken) [][]string {
 	var res = [][]string{}
 	for _, rule := range accessToken.Rules {
@@ -140,16 +196,38 @@ func (m *StandardClientManager) UpdatePolicies() {
 		}
 	}
 
+	// custom role policies are only generated for namespaces they are bound in
+	customRolePoliciesGenerated := make(map[string]bool)
+
 	for _, roleBinding := range m.RoleBindings {
 		if roleBinding.Spec.ExpiredAt != nil && roleBinding.Spec.ExpiredAt.Time.Before(time.Now()) {
 			continue
 		}
 
+		var role string
+
+		if roleBinding.Spec.Role == v1alpha1.RoleCustom {
+			customRole, exist := m.CustomRoles[roleBinding.Spec.CustomRole]
+
+			if !exist {
+				continue
+			}
+
+			role = customRoleValueToPolicyValue(customRole.Name, roleBinding.Namespace)
+
+			if !customRolePoliciesGenerated[role] {
+				sb.WriteString(BuildCustomRolePolicies(customRole, roleBinding.Namespace))
+				customRolePoliciesGenerated[role] = true
+			}
+		} else {
+			role = roleValueToPolicyValue(roleBinding.Spec.Role, roleBinding.Namespace)
+		}
+
 		sb.WriteString(fmt.Sprintf("# policies for rolebinding %s\n", roleBinding.Name))
 		sb.WriteString(fmt.Sprintf(
 			"g, %s, %s\n",
 			ToSafeSubject(roleBinding.Spec.Subject, roleBinding.Spec.SubjectType),
-			roleValueToPolicyValue(roleBinding.Spec.Role, roleBinding.Namespace)),
+			role),
 		)
 	}
 
@@ -169,9 +247,18 @@ func (m *StandardClientManager) GetClientInfoFromToken(tokenString string) (*Cli
 	m.mut.RLock()
 	defer m.mut.RUnlock()
 
-	accessToken, ok := m.AccessTokens[v1alpha1.GetAccessTokenNameFromToken(tokenString)]
+	var accessToken *v1alpha1.AccessToken
+
+	// Tokens are stored as salted hashes, so they can't be looked up by value.
+	// MatchToken compares prefixes before hashing, which keeps this loop cheap.
+	for _, t := range m.AccessTokens {
+		if t.MatchToken(tokenString) {
+			accessToken = t
+			break
+		}
+	}
 
-	if !ok {
+	if accessToken == nil {
 		return nil, errors.NewUnauthorized("access token not exist")
 	}
 
@@ -180,11 +267,12 @@ func (m *StandardClientManager) GetClientInfoFromToken(tokenString string) (*Cli
 	}
 
 	clientInfo := &ClientInfo{
-		Cfg:           m.ClusterConfig,
-		Name:          accessToken.Name,
-		Email:         accessToken.Name,
-		EmailVerified: false,
-		Groups:        []string{},
+		Cfg:             m.ClusterConfig,
+		Name:            accessToken.Name,
+		Email:           accessToken.Name,
+		EmailVerified:   false,
+		Groups:          []string{},
+		AccessTokenName: accessToken.Name,
 	}
 
 	return clientInfo, nil
@@ -276,11 +364,15 @@ func NewStandardClientManager(cfg *rest.Config) *StandardClientManager {
 		Applications:      make(map[string]*coreV1.Namespace),
 		AccessTokens:      make(map[string]*v1alpha1.AccessToken),
 		RoleBindings:      make(map[string]*v1alpha1.RoleBinding),
+		CustomRoles:       make(map[string]*v1alpha1.CustomRole),
 		StopWatchChan:     make(chan struct{}),
 	}
 
+	manager.UsageRecorder = NewAccessTokenUsageRecorder(resources.NewResourceManager(cfg, log.DefaultLogger()))
+
 	go setupResourcesWatcher(cfg, manager)
 	go policyRegenerateLoop(manager)
+	go manager.UsageRecorder.Run(manager.StopWatchChan)
 
 	return manager
 }
@@ -367,6 +459,38 @@ func setupResourcesWatcher(cfg *rest.Config, manager *StandardClientManager) {
 		panic(err)
 	}
 
+	if informer, err := informerCache.GetInformer(context.Background(), &v1alpha1.CustomRole{}); err == nil {
+		informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				manager.mut.Lock()
+				defer manager.mut.Unlock()
+				if customRole, ok := obj.(*v1alpha1.CustomRole); ok {
+					manager.CustomRoles[customRole.Name] = customRole
+					manager.UpdatePolicies()
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				manager.mut.Lock()
+				defer manager.mut.Unlock()
+				if customRole, ok := obj.(*v1alpha1.CustomRole); ok {
+					delete(manager.CustomRoles, customRole.Name)
+					manager.UpdatePolicies()
+				}
+			},
+			UpdateFunc: func(oldObj, obj interface{}) {
+				manager.mut.Lock()
+				defer manager.mut.Unlock()
+				if customRole, ok := obj.(*v1alpha1.CustomRole); ok {
+					manager.CustomRoles[customRole.Name] = customRole
+					manager.UpdatePolicies()
+				}
+			},
+		})
+	} else {
+		log.Error("get informer error", zap.Error(err))
+		panic(err)
+	}
+
 	if informer, err := informerCache.GetInformer(context.Background(), &v1alpha1.AccessToken{}); err == nil {
 		informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
@@ -382,6 +506,7 @@ func setupResourcesWatcher(cfg *rest.Config, manager *StandardClientManager) {
 				defer manager.mut.Unlock()
 				if accessToken, ok := obj.(*v1alpha1.AccessToken); ok {
 					delete(manager.AccessTokens, accessToken.Name)
+					manager.UsageRecorder.Forget(accessToken.Name)
 					manager.UpdatePolicies()
 				}
 			},
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/kalmhq/kalm/api/rbac"
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/kalmhq/kalm/controller/controllers"
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func genStandardClientManager() *StandardClientManager {
+	policyAdapter := rbac.NewStringPolicyAdapter(``)
+
+	return &StandardClientManager{
+		BaseClientManager: NewBaseClientManager(policyAdapter),
+		PolicyAdapter:     policyAdapter,
+		Applications:      map[string]*coreV1.Namespace{},
+		AccessTokens:      map[string]*v1alpha1.AccessToken{},
+		RoleBindings:      map[string]*v1alpha1.RoleBinding{},
+		CustomRoles:       map[string]*v1alpha1.CustomRole{},
+	}
+}
+
+func addTestApplication(m *StandardClientManager, name string) {
+	m.Applications[name] = &coreV1.Namespace{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{controllers.KalmEnableLabelName: controllers.KalmEnableLabelValue},
+		},
+	}
+}
+
+func genRoleBinding(namespace, subject, role, customRole string) *v1alpha1.RoleBinding {
+	return &v1alpha1.RoleBinding{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: namespace,
+			Name:      subject,
+		},
+		Spec: v1alpha1.RoleBindingSpec{
+			Subject:     subject,
+			SubjectType: v1alpha1.SubjectTypeUser,
+			Role:        role,
+			CustomRole:  customRole,
+			Creator:     "admin",
+		},
+	}
+}
+
+func TestCustomRolePolicies(t *testing.T) {
+	m := genStandardClientManager()
+	addTestApplication(m, "app1")
+	addTestApplication(m, "app2")
+
+	m.CustomRoles["deployer"] = &v1alpha1.CustomRole{
+		ObjectMeta: metaV1.ObjectMeta{Name: "deployer"},
+		Spec: v1alpha1.CustomRoleSpec{
+			Rules: []v1alpha1.CustomRoleRule{
+				{Verbs: []v1alpha1.CustomRoleVerb{v1alpha1.CustomRoleVerbView}, Kind: "*"},
+				{Verbs: []v1alpha1.CustomRoleVerb{v1alpha1.CustomRoleVerbDeploy}, Kind: "components"},
+				{Verbs: []v1alpha1.CustomRoleVerb{v1alpha1.CustomRoleVerbLogs}, Kind: "pods"},
+			},
+		},
+	}
+
+	for _, binding := range []*v1alpha1.RoleBinding{
+		genRoleBinding("app1", "alice", v1alpha1.RoleCustom, "deployer"),
+		genRoleBinding(v1alpha1.KalmSystemNamespace, "bob", v1alpha1.RoleCustom, "deployer"),
+		genRoleBinding("app1", "carol", v1alpha1.RoleCustom, "not-exist"),
+		genRoleBinding("app1", "dave", v1alpha1.RoleEditor, ""),
+	} {
+		m.RoleBindings[getNamespacedName(binding.ObjectMeta)] = binding
+	}
+
+	m.UpdatePolicies()
+
+	alice := &ClientInfo{Email: "alice"}
+	bob := &ClientInfo{Email: "bob"}
+	carol := &ClientInfo{Email: "carol"}
+	dave := &ClientInfo{Email: "dave"}
+
+	assert.True(t, m.CanViewNamespace(alice, "app1"))
+	assert.True(t, m.CanDeployComponent(alice, "app1", "api"))
+	assert.True(t, m.CanViewPodLogs(alice, "app1", "api-xyz"))
+	assert.False(t, m.CanExecPod(alice, "app1", "api-xyz"))
+	assert.False(t, m.CanEditNamespace(alice, "app1"))
+	assert.False(t, m.CanViewNamespace(alice, "app2"))
+	assert.False(t, m.CanDeployComponent(alice, "app2", "api"))
+
+	assert.True(t, m.CanDeployComponent(bob, "app2", "api"))
+	assert.False(t, m.CanEditNamespace(bob, "app2"))
+	assert.False(t, m.CanExecPod(bob, "app2", "api-xyz"))
+
+	assert.False(t, m.CanViewNamespace(carol, "app1"))
+
+	// built-in roles imply the narrower actions
+	assert.True(t, m.CanDeployComponent(dave, "app1", "api"))
+	assert.True(t, m.CanExecPod(dave, "app1", "api-xyz"))
+	assert.True(t, m.CanViewPodLogs(dave, "app1", "api-xyz"))
+}
+
+func TestCustomRoleImpliedActions(t *testing.T) {
+	m := genStandardClientManager()
+	addTestApplication(m, "app1")
+
+	m.CustomRoles["developer"] = &v1alpha1.CustomRole{
+		ObjectMeta: metaV1.ObjectMeta{Name: "developer"},
+		Spec: v1alpha1.CustomRoleSpec{
+			Rules: []v1alpha1.CustomRoleRule{
+				{Verbs: []v1alpha1.CustomRoleVerb{v1alpha1.CustomRoleVerbEdit}, Kind: "*"},
+			},
+		},
+	}
+
+	binding := genRoleBinding("app1", "alice", v1alpha1.RoleCustom, "developer")
+	m.RoleBindings[getNamespacedName(binding.ObjectMeta)] = binding
+
+	m.UpdatePolicies()
+
+	alice := &ClientInfo{Email: "alice"}
+
+	assert.True(t, m.CanEditNamespace(alice, "app1"))
+	assert.True(t, m.CanViewNamespace(alice, "app1"))
+	assert.True(t, m.CanView(alice, "app1", "components/api"))
+	assert.True(t, m.Can(alice, rbac.ActionLogs, "app1", "pods/api-xyz"))
+	assert.True(t, m.Can(alice, rbac.ActionExec, "app1", "pods/api-xyz"))
+	assert.True(t, m.Can(alice, rbac.ActionDeploy, "app1", "components/api"))
+	assert.False(t, m.CanManageNamespace(alice, "app1"))
+}
+
+func TestCanManageCustomRoleBinding(t *testing.T) {
+	m := genStandardClientManager()
+
+	for _, binding := range []*v1alpha1.RoleBinding{
+		genRoleBinding("app1", "owner", v1alpha1.RoleOwner, ""),
+	} {
+		m.RoleBindings[getNamespacedName(binding.ObjectMeta)] = binding
+	}
+
+	addTestApplication(m, "app1")
+
+	m.UpdatePolicies()
+
+	owner := &ClientInfo{Email: "owner"}
+
+	assert.True(t, m.CanManageRoleBinding(owner, genRoleBinding("app1", "alice", v1alpha1.RoleCustom, "deployer")))
+	assert.False(t, m.CanManageRoleBinding(owner, genRoleBinding(v1alpha1.KalmSystemNamespace, "alice", v1alpha1.RoleCustom, "deployer")))
+}
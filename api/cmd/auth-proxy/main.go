@@ -20,11 +20,14 @@ import (
 	"github.com/kalmhq/kalm/api/log"
 	"github.com/kalmhq/kalm/api/server"
 	"github.com/kalmhq/kalm/api/utils"
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
 	"github.com/kalmhq/kalm/controller/controllers"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 	"golang.org/x/net/http2"
 	"golang.org/x/oauth2"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 )
 
 var oauth2Config *oauth2.Config
@@ -35,6 +38,8 @@ var oidcVerifier *oidc.IDTokenVerifier
 var authProxyURL string
 var clientSecret string
 
+var refreshStore auth_proxy.RefreshStore
+
 const KALM_TOKEN_KEY_NAME = "kalm-sso"
 const ENVOY_EXT_AUTH_PATH_PREFIX = "ext_authz"
 
@@ -184,10 +189,19 @@ func handleExtAuthz(c echo.Context) error {
 		return handleSetIDToken(c)
 	}
 
+	forwardedClaims, err := auth_proxy.DecodeForwardedClaims(c.Request().Header.Get(controllers.KALM_SSO_FORWARDED_CLAIMS_HEADER))
+
+	if err != nil {
+		contextLog.Error("invalid forwarded claims", zap.Error(err))
+		return c.String(500, err.Error())
+	}
+
 	// allow traffic to pass (AND semanteme)
 	//   - If `let-pass-if-has-bearer-token` header is explicitly declared
 	//   - There is a bearer token
 	if shouldLetPass(c) {
+		// make sure clients can't forge the forwarded claim headers
+		setForwardedClaimHeaders(c, forwardedClaims, nil)
 		return c.NoContent(200)
 	}
 
@@ -234,93 +248,87 @@ func handleExtAuthz(c echo.Context) error {
 		return c.JSON(401, "You don't in any granted groups. Contact you admin please.")
 	}
 
+	rules, err := auth_proxy.DecodeAuthorizationRules(c.Request().Header.Get(controllers.KALM_SSO_AUTHORIZATION_RULES_HEADER))
+
+	if err != nil {
+		contextLog.Error("invalid authorization rules", zap.Error(err))
+		return c.String(500, err.Error())
+	}
+
+	var claims auth_proxy.Claims
+
+	// without claims no Deny rule could match
+	if err := idToken.Claims(&claims); err != nil {
+		contextLog.Error("decode id token claims error", zap.Error(err))
+		clearTokenInCookie(c)
+		return c.JSON(401, "The jwt token claims are invalid.")
+	}
+
+	if !auth_proxy.Authorize(rules, c.Request().Method, getOriginalPath(c), claims) {
+		return c.JSON(403, "You are not allowed to access this resource. Contact you admin please.")
+	}
+
 	// Set user info in meta header
 	// if the verify returns no error. It's safe to get claims in this way
 	parts := strings.Split(token.IDTokenString, ".")
 	c.Response().Header().Set(controllers.KALM_SSO_USERINFO_HEADER, parts[1])
+	setForwardedClaimHeaders(c, forwardedClaims, claims)
 
 	return c.NoContent(200)
 }
 
-// When a user's id_token has expired, but the refresh_token is still valid, multiple requests may be received in a short time window.
-// But refresh_token is not allowed to be used twice. We can't let all the requests to refresh token at the same time.
-// So a condition variable is used to ensure that only one process sends a refresh request,
-// and other processes wait for the result. This is enough if there is the auth-proxy service only has one replica.
-// If you deploy auth-proxy with scaling, make sure use sticky load balancing strategy.
-func refreshIDToken(token *auth_proxy.ThinToken) (idToken *oidc.IDToken, err error) {
-	refreshContext, isProducer := auth_proxy.GetRefreshTokenCond(token.RefreshToken)
-
-	if isProducer {
-		log.Named("[refresh producer]").Debug("Do refresh", zap.String("token", token.RefreshToken[:10]))
-		err = doRefresh(token, refreshContext)
-		log.Named("[refresh producer]").Debug("Done", zap.Error(err))
-		auth_proxy.RemoveRefreshTokenCond(token.RefreshToken, 60)
-	} else {
-		refreshContext.Cond.L.Lock()
-		log.Named("[refresh consumer]").Debug("Wait", zap.String("token", token.RefreshToken[:10]))
-
-		for refreshContext.IDToken == nil && refreshContext.Error == nil {
-			refreshContext.Cond.Wait()
-		}
+// Refresh tokens are redeemed through the refresh store, so concurrent requests holding the same
+// refresh token, even if they are received by different replicas, share a single refresh.
+func refreshIDToken(token *auth_proxy.ThinToken) (*oidc.IDToken, error) {
+	result, err := auth_proxy.RedeemRefreshToken(refreshStore, token.RefreshToken, func() *auth_proxy.RefreshResult {
+		return doRefresh(token.RefreshToken)
+	})
 
-		err = refreshContext.Error
-		log.Named("[refresh consumer]").Debug("Got result", zap.Error(err))
-		refreshContext.Cond.L.Unlock()
+	if err != nil {
+		return nil, err
 	}
 
+	idToken, err := oidcVerifier.Verify(context.Background(), result.IDTokenString)
+
 	if err != nil {
-		return nil, err
+		log.Error("refreshed token verify error", zap.Error(err))
+		return nil, fmt.Errorf("The jwt token is invalid, expired, revoked, or was issued to another client. (After refresh)")
 	}
 
-	token.IDTokenString = refreshContext.IDTokenString
-	token.RefreshToken = refreshContext.RefreshToken
+	token.IDTokenString = result.IDTokenString
+	token.RefreshToken = result.RefreshToken
 
-	return refreshContext.IDToken, nil
+	return idToken, nil
 }
 
-func doRefresh(token *auth_proxy.ThinToken, refreshContext *auth_proxy.RefreshContext) (err error) {
-	// Tell other blocked routines the data is ready
-	defer func() {
-		if err != nil {
-			refreshContext.Error = err
-		}
-
-		refreshContext.Cond.Broadcast()
-	}()
+func doRefresh(refreshToken string) *auth_proxy.RefreshResult {
+	log.Named("refresh").Debug("Do refresh", zap.String("token", refreshToken[:10]))
 
 	t := &oauth2.Token{
-		RefreshToken: token.RefreshToken,
+		RefreshToken: refreshToken,
 		Expiry:       time.Now().Add(-time.Hour),
 	}
 
-	newOauth2Token, err := oauth2Config.TokenSource(context.Background(), t).Token()
+	ctx, cancel := context.WithTimeout(context.Background(), auth_proxy.RefreshRedeemTimeout)
+	defer cancel()
+
+	newOauth2Token, err := oauth2Config.TokenSource(ctx, t).Token()
 
 	if err != nil {
 		log.Error("Refresh token error", zap.Error(err))
-		return err
+		return &auth_proxy.RefreshResult{Error: err.Error()}
 	}
 
 	rawIDToken, ok := newOauth2Token.Extra("id_token").(string)
 
 	if !ok {
-		return fmt.Errorf("no id_token in refresh token response")
+		return &auth_proxy.RefreshResult{Error: "no id_token in refresh token response"}
 	}
 
-	IDToken, err := oidcVerifier.Verify(context.Background(), rawIDToken)
-
-	if err != nil {
-		log.Error("refreshed token verify error", zap.Error(err))
-		return fmt.Errorf("The jwt token is invalid, expired, revoked, or was issued to another client. (After refresh)")
+	return &auth_proxy.RefreshResult{
+		IDTokenString: rawIDToken,
+		RefreshToken:  newOauth2Token.RefreshToken,
 	}
-
-	refreshContext.Cond.L.Lock()
-	refreshContext.Cond.L.Unlock()
-
-	refreshContext.IDToken = IDToken
-	refreshContext.IDTokenString = rawIDToken
-	refreshContext.RefreshToken = newOauth2Token.RefreshToken
-
-	return nil
 }
 
 func getTokenFromRequest(c echo.Context) (*auth_proxy.ThinToken, error) {
@@ -359,6 +367,18 @@ func getTokenFromRequest(c echo.Context) (*auth_proxy.ThinToken, error) {
 	return token, nil
 }
 
+// getOriginalPath returns the path to authorize. Envoy checks the request before route rewrites,
+// so it's always the path of the ext_authz request, headers like X-Envoy-Original-Path can be set by clients.
+func getOriginalPath(c echo.Context) string {
+	return auth_proxy.RequestPath(removeExtAuthPathPrefix(c.Request().RequestURI))
+}
+
+func setForwardedClaimHeaders(c echo.Context, forwardedClaims []v1alpha1.ProtectedEndpointForwardedClaim, claims auth_proxy.Claims) {
+	for header, value := range auth_proxy.ForwardedClaimHeaders(forwardedClaims, claims) {
+		c.Response().Header().Set(header, value)
+	}
+}
+
 func shouldLetPass(c echo.Context) bool {
 	return c.Request().Header.Get(controllers.KALM_ALLOW_TO_PASS_IF_HAS_BEARER_TOKEN_HEADER) == "true" &&
 		strings.HasPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
@@ -373,7 +393,11 @@ func inGrantedGroups(c echo.Context, idToken *oidc.IDToken) bool {
 
 	groups := strings.Split(grantedGroups, "|")
 	var claim ClaimsWithGroups
-	_ = idToken.Claims(&claim)
+
+	if err := idToken.Claims(&claim); err != nil {
+		log.Error("decode id token groups error", zap.Error(err))
+		return false
+	}
 
 	gm := make(map[string]struct{}, len(groups))
 	for _, g := range groups {
@@ -601,8 +625,43 @@ func handleLog(c echo.Context) error {
 	return c.String(200, fmt.Sprintf("verbose: %t", verb))
 }
 
+// By default, refresh results are kept in memory, which requires sticky sessions if auth-proxy has multiple replicas.
+// Set KALM_AUTH_PROXY_REFRESH_STORE to "kubernetes" to share them between replicas through a config map.
+func initRefreshStore() auth_proxy.RefreshStore {
+	if os.Getenv("KALM_AUTH_PROXY_REFRESH_STORE") != "kubernetes" {
+		return auth_proxy.NewMemoryRefreshStore()
+	}
+
+	cfg, err := rest.InClusterConfig()
+
+	if err != nil {
+		panic(err)
+	}
+
+	k8sClient, err := kubernetes.NewForConfig(cfg)
+
+	if err != nil {
+		panic(err)
+	}
+
+	namespace := os.Getenv("KALM_AUTH_PROXY_NAMESPACE")
+
+	if namespace == "" {
+		namespace = controllers.KALM_DEX_NAMESPACE
+	}
+
+	store := auth_proxy.NewKubernetesRefreshStore(k8sClient, namespace, controllers.KALM_AUTH_PROXY_REFRESH_CONFIG_MAP_NAME)
+	go store.RunCleanupLoop()
+
+	log.Info(fmt.Sprintf("refresh store: kubernetes config map %s in namespace %s", controllers.KALM_AUTH_PROXY_REFRESH_CONFIG_MAP_NAME, namespace))
+
+	return store
+}
+
 func main() {
 	log.InitDefaultLogger(false)
+	refreshStore = initRefreshStore()
+
 	e := server.NewEchoInstance()
 
 	// oidc auth proxy handlers
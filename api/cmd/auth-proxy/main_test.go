@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOriginalPath(t *testing.T) {
+	e := echo.New()
+
+	newContext := func(requestURI string) echo.Context {
+		req := httptest.NewRequest("GET", requestURI, nil)
+		return e.NewContext(req, httptest.NewRecorder())
+	}
+
+	assert.Equal(t, "/admin/users", getOriginalPath(newContext("/ext_authz/admin/users?page=1")))
+	assert.Equal(t, "/admin/users", getOriginalPath(newContext("/ext_authz//admin/users")))
+	assert.Equal(t, "/admin", getOriginalPath(newContext("/ext_authz/public/../admin")))
+
+	// forged headers are ignored
+	c := newContext("/ext_authz/admin")
+	c.Request().Header.Set("X-Envoy-Original-Path", "/public")
+	assert.Equal(t, "/admin", getOriginalPath(c))
+}
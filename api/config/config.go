@@ -20,6 +20,11 @@ type Config struct {
 	KubernetesApiServerCAFilePath string
 	KubeConfigPath                string
 	CorsAllowedOrigins            cli.StringSlice
+	MetricStorage                 string
+	MetricStorageDSN              string
+	MetricTiers                   string
+	VolumeUsageWarningThreshold   int
+	MetricsBindAddress            string
 }
 
 // Built-time env
@@ -4,7 +4,6 @@ import (
 	"github.com/kalmhq/kalm/api/resources"
 	"github.com/kalmhq/kalm/controller/api/v1alpha1"
 	"github.com/labstack/echo/v4"
-	"k8s.io/apimachinery/pkg/util/rand"
 )
 
 func (h *ApiHandler) handleListAccessTokens(c echo.Context) error {
@@ -30,9 +29,8 @@ func (h *ApiHandler) handleCreateAccessToken(c echo.Context) error {
 	}
 
 	// Set sensitive fields
-	accessToken.Token = rand.String(128)
+	accessToken.Token, accessToken.Name = resources.GenerateAccessToken()
 	accessToken.Creator = getCurrentUser(c).Name
-	accessToken.Name = v1alpha1.GetAccessTokenNameFromToken(accessToken.Token)
 
 	accessToken, err = h.resourceManager.CreateAccessToken(accessToken)
 	if err != nil {
@@ -65,6 +63,20 @@ func (h *ApiHandler) handleDeleteAccessToken(c echo.Context) error {
 	return c.NoContent(200)
 }
 
+// Recent activities of an access token, for security review
+func (h *ApiHandler) handleListAccessTokenActivities(c echo.Context) error {
+	var fetched v1alpha1.AccessToken
+	if err := h.resourceManager.Get("", c.Param("name"), &fetched); err != nil {
+		return err
+	}
+
+	if !h.clientManager.PermissionsGreaterThanOrEqualAccessToken(getCurrentUser(c), resources.BuildAccessTokenFromResource(&fetched)) {
+		return resources.InsufficientPermissionsError
+	}
+
+	return c.JSON(200, h.clientManager.GetAccessTokenActivities(fetched.Name))
+}
+
 func getAccessTokenFromContext(c echo.Context) (*resources.AccessToken, error) {
 	var accessToken resources.AccessToken
 
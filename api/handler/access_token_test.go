@@ -4,6 +4,7 @@ import (
 	"github.com/kalmhq/kalm/api/resources"
 	"github.com/kalmhq/kalm/controller/api/v1alpha1"
 	"github.com/stretchr/testify/suite"
+	"k8s.io/apimachinery/pkg/types"
 	"net/http"
 	"testing"
 )
@@ -74,6 +75,15 @@ func (suite *AccessTokenTestSuite) TestCreateAndDelete() {
 			var res resources.AccessToken
 			rec.BodyAsJSON(&res)
 			suite.Equal(201, rec.Code)
+
+			// token is only shown once
+			suite.NotEmpty(res.Token)
+			suite.Equal(v1alpha1.GetAccessTokenPrefix(res.Token), res.TokenPrefix)
+
+			var accessToken v1alpha1.AccessToken
+			suite.Nil(suite.client.Get(suite.ctx, types.NamespacedName{Name: res.Name}, &accessToken))
+			suite.Empty(accessToken.Spec.Token)
+			suite.True(accessToken.MatchToken(res.Token))
 		},
 	})
 
@@ -89,6 +99,7 @@ func (suite *AccessTokenTestSuite) TestCreateAndDelete() {
 			rec.BodyAsJSON(&resList)
 			suite.Equal(200, rec.Code)
 			suite.Equal(1, len(resList))
+			suite.Empty(resList[0].Token)
 
 			// set name for delete
 			key.Name = resList[0].Name
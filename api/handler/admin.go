@@ -8,7 +8,6 @@ import (
 	"github.com/kalmhq/kalm/controller/api/v1alpha1"
 	"github.com/labstack/echo/v4"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/rand"
 )
 
 type Policies []string
@@ -48,11 +47,11 @@ func handleApiRoutes(c echo.Context) error {
 }
 
 func (h *ApiHandler) handleCreateTemporaryClusterOwnerAccessTokens(c echo.Context) error {
-	token := rand.String(128)
+	token, name := resources.GenerateAccessToken()
 
 	accessToken := &v1alpha1.AccessToken{
 		ObjectMeta: metaV1.ObjectMeta{
-			Name: v1alpha1.GetAccessTokenNameFromToken(token),
+			Name: name,
 		},
 		Spec: v1alpha1.AccessTokenSpec{
 			Token: token,
@@ -81,9 +80,16 @@ func (h *ApiHandler) handleCreateTemporaryClusterOwnerAccessTokens(c echo.Contex
 		},
 	}
 
+	if err := accessToken.SealToken(); err != nil {
+		return err
+	}
+
 	if err := h.resourceManager.Create(accessToken); err != nil {
 		return err
 	}
 
+	// The token is only shown once
+	accessToken.Spec.Token = token
+
 	return c.JSON(200, accessToken)
 }
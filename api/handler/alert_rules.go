@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/labstack/echo/v4"
+)
+
+func alertRuleObj(name string) string {
+	return "alertrules/" + name
+}
+
+func (h *ApiHandler) handleListAlertRules(c echo.Context) error {
+	if !h.clientManager.CanViewNamespace(getCurrentUser(c), c.Param("name")) {
+		return resources.NoNamespaceViewerRoleError(c.Param("name"))
+	}
+
+	alertRules, err := h.resourceManager.ListAlertRules(c.Param("name"))
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, alertRules)
+}
+
+func (h *ApiHandler) handleGetAlertRule(c echo.Context) error {
+	namespace := c.Param("name")
+
+	if !h.clientManager.CanView(getCurrentUser(c), namespace, alertRuleObj(c.Param("ruleName"))) {
+		return resources.NoObjectViewerRoleError(namespace, alertRuleObj(c.Param("ruleName")))
+	}
+
+	alertRule, err := h.resourceManager.GetAlertRule(namespace, c.Param("ruleName"))
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, alertRule)
+}
+
+func (h *ApiHandler) handleCreateAlertRule(c echo.Context) error {
+	alertRule, err := getAlertRuleFromContext(c)
+
+	if err != nil {
+		return err
+	}
+
+	namespace := c.Param("name")
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), namespace, alertRuleObj(alertRule.Name)) {
+		return resources.NoObjectEditorRoleError(namespace, alertRuleObj(alertRule.Name))
+	}
+
+	if alertRule, err = h.resourceManager.CreateAlertRule(namespace, alertRule); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, alertRule)
+}
+
+func (h *ApiHandler) handleUpdateAlertRule(c echo.Context) error {
+	alertRule, err := getAlertRuleFromContext(c)
+
+	if err != nil {
+		return err
+	}
+
+	namespace := c.Param("name")
+	alertRule.Name = c.Param("ruleName")
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), namespace, alertRuleObj(alertRule.Name)) {
+		return resources.NoObjectEditorRoleError(namespace, alertRuleObj(alertRule.Name))
+	}
+
+	if alertRule, err = h.resourceManager.UpdateAlertRule(namespace, alertRule); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, alertRule)
+}
+
+func (h *ApiHandler) handleDeleteAlertRule(c echo.Context) error {
+	namespace := c.Param("name")
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), namespace, alertRuleObj(c.Param("ruleName"))) {
+		return resources.NoObjectEditorRoleError(namespace, alertRuleObj(c.Param("ruleName")))
+	}
+
+	if err := h.resourceManager.DeleteAlertRule(namespace, c.Param("ruleName")); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *ApiHandler) handleSilenceAlertRule(c echo.Context) error {
+	namespace := c.Param("name")
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), namespace, alertRuleObj(c.Param("ruleName"))) {
+		return resources.NoObjectEditorRoleError(namespace, alertRuleObj(c.Param("ruleName")))
+	}
+
+	var silence resources.AlertRuleSilence
+
+	if err := c.Bind(&silence); err != nil {
+		return err
+	}
+
+	var until time.Time
+
+	if silence.Until != nil {
+		until = silence.Until.Time
+	} else if silence.Duration != "" {
+		duration, err := resources.ParseMetricDuration(silence.Duration)
+
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid duration %s", silence.Duration)
+		}
+
+		until = time.Now().Add(duration)
+	} else {
+		return fmt.Errorf("either until or duration is required")
+	}
+
+	alertRule, err := h.resourceManager.SilenceAlertRule(namespace, c.Param("ruleName"), &until)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, alertRule)
+}
+
+func (h *ApiHandler) handleUnsilenceAlertRule(c echo.Context) error {
+	namespace := c.Param("name")
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), namespace, alertRuleObj(c.Param("ruleName"))) {
+		return resources.NoObjectEditorRoleError(namespace, alertRuleObj(c.Param("ruleName")))
+	}
+
+	alertRule, err := h.resourceManager.SilenceAlertRule(namespace, c.Param("ruleName"), nil)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, alertRule)
+}
+
+func (h *ApiHandler) handleGetAlertRuleHistory(c echo.Context) error {
+	namespace := c.Param("name")
+
+	if !h.clientManager.CanView(getCurrentUser(c), namespace, alertRuleObj(c.Param("ruleName"))) {
+		return resources.NoObjectViewerRoleError(namespace, alertRuleObj(c.Param("ruleName")))
+	}
+
+	history, err := h.resourceManager.GetAlertRuleHistory(namespace, c.Param("ruleName"))
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, history)
+}
+
+func getAlertRuleFromContext(c echo.Context) (*resources.AlertRule, error) {
+	var alertRule resources.AlertRule
+
+	if err := c.Bind(&alertRule); err != nil {
+		return nil, err
+	}
+
+	if alertRule.AlertRuleSpec == nil {
+		return nil, fmt.Errorf("metric, threshold and receivers are required")
+	}
+
+	return &alertRule, nil
+}
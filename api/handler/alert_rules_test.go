@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/suite"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+type AlertRulesHandlerTestSuite struct {
+	WithControllerTestSuite
+	namespace string
+}
+
+func TestAlertRulesHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(AlertRulesHandlerTestSuite))
+}
+
+func (suite *AlertRulesHandlerTestSuite) SetupSuite() {
+	suite.WithControllerTestSuite.SetupSuite()
+	suite.namespace = "kalm-test-alert-rules"
+	suite.ensureNamespaceExist(suite.namespace)
+}
+
+func (suite *AlertRulesHandlerTestSuite) TeardownSuite() {
+	suite.ensureNamespaceDeleted(suite.namespace)
+}
+
+func (suite *AlertRulesHandlerTestSuite) TestAlertRules() {
+	path := fmt.Sprintf("/v1alpha1/applications/%s/alertrules", suite.namespace)
+
+	alertRule := resources.AlertRule{
+		Name: "high-memory",
+		AlertRuleSpec: &v1alpha1.AlertRuleSpec{
+			Component: "web",
+			Metric:    v1alpha1.AlertRuleMetricMemory,
+			Threshold: resource.MustParse("512Mi"),
+			Receivers: []v1alpha1.AlertReceiver{
+				{Type: v1alpha1.AlertReceiverTypeSlack, URL: "https://hooks.slack.com/services/test"},
+			},
+		},
+	}
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPost,
+		Path:      path,
+		Body:      alertRule,
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(201, rec.Code)
+
+			var rule v1alpha1.AlertRule
+			suite.Nil(suite.Get(suite.namespace, "high-memory", &rule))
+			suite.Equal(v1alpha1.AlertRuleMetricMemory, rule.Spec.Metric)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetViewerRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodGet,
+		Path:      path,
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "viewer", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var res []resources.AlertRule
+			rec.BodyAsJSON(&res)
+
+			suite.Equal(200, rec.Code)
+			suite.Len(res, 1)
+			suite.Equal("high-memory", res[0].Name)
+		},
+	})
+
+	alertRule.Threshold = resource.MustParse("1Gi")
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPut,
+		Path:      path + "/high-memory",
+		Body:      alertRule,
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(200, rec.Code)
+
+			var rule v1alpha1.AlertRule
+			suite.Nil(suite.Get(suite.namespace, "high-memory", &rule))
+			suite.Equal("1Gi", rule.Spec.Threshold.String())
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPost,
+		Path:      path + "/high-memory/silence",
+		Body:      resources.AlertRuleSilence{Duration: "2h"},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(200, rec.Code)
+
+			var rule v1alpha1.AlertRule
+			suite.Nil(suite.Get(suite.namespace, "high-memory", &rule))
+			suite.NotNil(rule.Spec.SilencedUntil)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodDelete,
+		Path:      path + "/high-memory/silence",
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(200, rec.Code)
+
+			var rule v1alpha1.AlertRule
+			suite.Nil(suite.Get(suite.namespace, "high-memory", &rule))
+			suite.Nil(rule.Spec.SilencedUntil)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetViewerRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodGet,
+		Path:      path + "/high-memory/history",
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "viewer", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var res []v1alpha1.AlertRecord
+			rec.BodyAsJSON(&res)
+
+			suite.Equal(200, rec.Code)
+			suite.Len(res, 0)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodDelete,
+		Path:      path + "/high-memory",
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(204, rec.Code)
+
+			var rule v1alpha1.AlertRule
+			suite.NotNil(suite.Get(suite.namespace, "high-memory", &rule))
+		},
+	})
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/labstack/echo/v4"
+)
+
+// Templates are visible to all users, so application editors can create components from them.
+func (h *ApiHandler) handleListComponentTemplates(c echo.Context) error {
+	templates, err := h.resourceManager.ListComponentTemplates()
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, templates)
+}
+
+func (h *ApiHandler) handleGetComponentTemplate(c echo.Context) error {
+	template, err := h.resourceManager.GetComponentTemplate(c.Param("name"))
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, template)
+}
+
+func (h *ApiHandler) handleCreateComponentTemplate(c echo.Context) error {
+	if !h.clientManager.CanEditCluster(getCurrentUser(c)) {
+		return resources.NoClusterEditorRoleError
+	}
+
+	spec, err := getComponentTemplateFromContext(c)
+
+	if err != nil {
+		return err
+	}
+
+	template, err := h.resourceManager.CreateComponentTemplate(spec)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, template)
+}
+
+func (h *ApiHandler) handleUpdateComponentTemplate(c echo.Context) error {
+	if !h.clientManager.CanEditCluster(getCurrentUser(c)) {
+		return resources.NoClusterEditorRoleError
+	}
+
+	spec, err := getComponentTemplateFromContext(c)
+
+	if err != nil {
+		return err
+	}
+
+	spec.Name = c.Param("name")
+
+	template, err := h.resourceManager.UpdateComponentTemplate(spec)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, template)
+}
+
+func (h *ApiHandler) handleDeleteComponentTemplate(c echo.Context) error {
+	if !h.clientManager.CanEditCluster(getCurrentUser(c)) {
+		return resources.NoClusterEditorRoleError
+	}
+
+	if err := h.resourceManager.DeleteComponentTemplate(c.Param("name")); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Derived components may live in any application, so previewing and rolling out require cluster level roles.
+func (h *ApiHandler) handlePreviewComponentTemplateRollout(c echo.Context) error {
+	if !h.clientManager.CanViewCluster(getCurrentUser(c)) {
+		return resources.NoClusterViewerRoleError
+	}
+
+	rollout, _, err := h.resourceManager.PreviewComponentTemplateRollout(c.Param("name"))
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rollout)
+}
+
+func (h *ApiHandler) handleRolloutComponentTemplate(c echo.Context) error {
+	if !h.clientManager.CanEditCluster(getCurrentUser(c)) {
+		return resources.NoClusterEditorRoleError
+	}
+
+	rollout, err := h.resourceManager.RolloutComponentTemplate(c.Param("name"))
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rollout)
+}
+
+func (h *ApiHandler) handleCreateComponentFromTemplate(c echo.Context) error {
+	namespace := c.Param("applicationName")
+
+	if !h.clientManager.CanEditNamespace(getCurrentUser(c), namespace) {
+		return resources.NoNamespaceEditorRoleError(namespace)
+	}
+
+	var req resources.CreateComponentFromTemplateRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if req.Name == "" || req.Template == "" {
+		return fmt.Errorf("name and template are required")
+	}
+
+	component, err := h.resourceManager.BuildComponentFromTemplate(namespace, &req)
+
+	if err != nil {
+		return err
+	}
+
+	if err := h.createCrdComponent(c, component, req.ProtectedEndpointSpec, req.Plugins); err != nil {
+		return err
+	}
+
+	res, err := h.componentResponse(component)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, res)
+}
+
+func getComponentTemplateFromContext(c echo.Context) (*resources.CreateOrUpdateComponentTemplateRequest, error) {
+	var spec resources.CreateOrUpdateComponentTemplateRequest
+
+	if err := c.Bind(&spec); err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
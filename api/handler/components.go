@@ -7,6 +7,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"net/http"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -176,26 +177,31 @@ func (h *ApiHandler) createComponent(c echo.Context) (*v1alpha1.Component, error
 
 	crdComponent := getCrdComponentFromResourcesComponentAndContext(c, component)
 
+	if err := h.createCrdComponent(c, crdComponent, component.ProtectedEndpointSpec, component.Plugins); err != nil {
+		return nil, err
+	}
+
+	return crdComponent, nil
+}
+
+// createCrdComponent creates the component in the application of the context, along with its protected endpoint and plugin bindings.
+func (h *ApiHandler) createCrdComponent(c echo.Context, crdComponent *v1alpha1.Component, protectedEndpoint *v1alpha1.ProtectedEndpointSpec, plugins []runtime.RawExtension) error {
 	//permission, check if component try to re-use disk from other ns
 	if err := h.checkPermissionOnVolume(getCurrentUser(c), crdComponent.Spec.Volumes); err != nil {
-		return nil, err
+		return err
 	}
 
 	crdComponent.Namespace = c.Param("applicationName")
 
 	if err := h.resourceManager.Create(crdComponent); err != nil {
-		return nil, err
-	}
-
-	if err := h.resourceManager.UpdateProtectedEndpointForComponent(crdComponent, component.ProtectedEndpointSpec); err != nil {
-		return nil, err
+		return err
 	}
 
-	if err := h.resourceManager.UpdateComponentPluginBindingsForObject(crdComponent.Namespace, crdComponent.Name, component.Plugins); err != nil {
-		return nil, err
+	if err := h.resourceManager.UpdateProtectedEndpointForComponent(crdComponent, protectedEndpoint); err != nil {
+		return err
 	}
 
-	return crdComponent, nil
+	return h.resourceManager.UpdateComponentPluginBindingsForObject(crdComponent.Namespace, crdComponent.Name, plugins)
 }
 
 func (h *ApiHandler) updateComponent(c echo.Context) (*v1alpha1.Component, error) {
@@ -211,6 +217,10 @@ func (h *ApiHandler) updateComponent(c echo.Context) (*v1alpha1.Component, error
 		return nil, resources.NoNamespaceEditorRoleError(crdComponent.Namespace)
 	}
 
+	if err := h.resourceManager.CheckComponentVolumeExpansion(crdComponent); err != nil {
+		return nil, err
+	}
+
 	if err := h.resourceManager.Apply(crdComponent); err != nil {
 		return nil, err
 	}
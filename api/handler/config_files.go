@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kalmhq/kalm/api/client"
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/kalmhq/kalm/controller/lib/files"
+	"github.com/labstack/echo/v4"
+)
+
+// Paths contain "/", so they are passed in query params or request bodies instead of url params.
+func configFileObj(path string) string {
+	return "files" + path
+}
+
+// configFileAuthor is recorded in revisions of files
+func configFileAuthor(user *client.ClientInfo) string {
+	if user.Email != "" {
+		return user.Email
+	}
+
+	if user.AccessTokenName != "" {
+		return user.AccessTokenName
+	}
+
+	return user.Name
+}
+
+func (h *ApiHandler) handleGetConfigFiles(c echo.Context) error {
+	namespace := c.Param("name")
+	path := c.QueryParam("path")
+
+	if path == "" {
+		path = "/"
+	}
+
+	if !h.clientManager.CanView(getCurrentUser(c), namespace, configFileObj(path)) {
+		return resources.NoObjectViewerRoleError(namespace, configFileObj(path))
+	}
+
+	root, err := h.resourceManager.GetConfigFileTree(namespace, path)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, root)
+}
+
+func (h *ApiHandler) handleCreateConfigFile(c echo.Context) error {
+	file, err := h.getConfigFileFromContext(c)
+
+	if err != nil {
+		return err
+	}
+
+	root, err := h.resourceManager.CreateConfigFile(c.Param("name"), configFileAuthor(getCurrentUser(c)), file)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, root)
+}
+
+func (h *ApiHandler) handleUpdateConfigFile(c echo.Context) error {
+	file, err := h.getConfigFileFromContext(c)
+
+	if err != nil {
+		return err
+	}
+
+	root, err := h.resourceManager.UpdateConfigFile(c.Param("name"), configFileAuthor(getCurrentUser(c)), file)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, root)
+}
+
+func (h *ApiHandler) handleMoveConfigFile(c echo.Context) error {
+	namespace := c.Param("name")
+
+	var move resources.ConfigFileMove
+
+	if err := c.Bind(&move); err != nil {
+		return err
+	}
+
+	for _, path := range []string{move.OldPath, move.NewPath} {
+		if !h.clientManager.CanEdit(getCurrentUser(c), namespace, configFileObj(path)) {
+			return resources.NoObjectEditorRoleError(namespace, configFileObj(path))
+		}
+
+		if err := resources.ValidateConfigFilePath(path); err != nil {
+			return err
+		}
+	}
+
+	root, err := h.resourceManager.MoveConfigFile(namespace, configFileAuthor(getCurrentUser(c)), &move)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, root)
+}
+
+func (h *ApiHandler) handleDeleteConfigFile(c echo.Context) error {
+	namespace := c.Param("name")
+	path := c.QueryParam("path")
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), namespace, configFileObj(path)) {
+		return resources.NoObjectEditorRoleError(namespace, configFileObj(path))
+	}
+
+	if err := resources.ValidateConfigFilePath(path); err != nil {
+		return err
+	}
+
+	if err := h.resourceManager.DeleteConfigFile(namespace, configFileAuthor(getCurrentUser(c)), path); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *ApiHandler) handleListConfigFileRevisions(c echo.Context) error {
+	namespace := c.Param("name")
+	path := c.QueryParam("path")
+
+	if !h.clientManager.CanView(getCurrentUser(c), namespace, configFileObj(path)) {
+		return resources.NoObjectViewerRoleError(namespace, configFileObj(path))
+	}
+
+	revisions, err := h.resourceManager.ListConfigFileRevisions(namespace, path)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, revisions)
+}
+
+func (h *ApiHandler) handleRollbackConfigFile(c echo.Context) error {
+	namespace := c.Param("name")
+
+	var rollback resources.ConfigFileRollback
+
+	if err := c.Bind(&rollback); err != nil {
+		return err
+	}
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), namespace, configFileObj(rollback.Path)) {
+		return resources.NoObjectEditorRoleError(namespace, configFileObj(rollback.Path))
+	}
+
+	if err := resources.ValidateConfigFilePath(rollback.Path); err != nil {
+		return err
+	}
+
+	if rollback.Time == nil && rollback.Revision <= 0 {
+		return fmt.Errorf("either revision or time is required")
+	}
+
+	root, err := h.resourceManager.RollbackConfigFile(namespace, configFileAuthor(getCurrentUser(c)), &rollback)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, root)
+}
+
+func (h *ApiHandler) getConfigFileFromContext(c echo.Context) (*files.File, error) {
+	var file files.File
+
+	if err := c.Bind(&file); err != nil {
+		return nil, err
+	}
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), c.Param("name"), configFileObj(file.Path)) {
+		return nil, resources.NoObjectEditorRoleError(c.Param("name"), configFileObj(file.Path))
+	}
+
+	if err := resources.ValidateConfigFilePath(file.Path); err != nil {
+		return nil, err
+	}
+
+	return &file, nil
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/kalmhq/kalm/controller/lib/files"
+	"github.com/stretchr/testify/suite"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+type ConfigFilesHandlerTestSuite struct {
+	WithControllerTestSuite
+	namespace string
+}
+
+func TestConfigFilesHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigFilesHandlerTestSuite))
+}
+
+func (suite *ConfigFilesHandlerTestSuite) SetupSuite() {
+	suite.WithControllerTestSuite.SetupSuite()
+	suite.namespace = "kalm-test-config-files"
+	suite.ensureNamespaceExist(suite.namespace)
+}
+
+func (suite *ConfigFilesHandlerTestSuite) TeardownSuite() {
+	suite.ensureNamespaceDeleted(suite.namespace)
+}
+
+func (suite *ConfigFilesHandlerTestSuite) TestConfigFiles() {
+	path := fmt.Sprintf("/v1alpha1/applications/%s/files", suite.namespace)
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetViewerRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodGet,
+		Path:      path,
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "viewer", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var root files.FileItem
+			rec.BodyAsJSON(&root)
+			suite.Equal(200, rec.Code)
+			suite.True(root.IsDir)
+			suite.Len(root.Children, 0)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPost,
+		Path:      path,
+		Body:      files.File{Path: "/nginx/nginx.conf", Content: "worker_processes 1;"},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(201, rec.Code)
+
+			// contents are stored in shards, the index only refers to them
+			var configMap coreV1.ConfigMap
+			suite.Nil(suite.Get(suite.namespace, "kalm-files-1", &configMap))
+			suite.Equal("worker_processes 1;", configMap.Data[files.EncodeFilePath("/nginx/nginx.conf")])
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPost,
+		Path:      path,
+		Body:      files.File{Path: "/tls/key.pem", Content: "private", Secret: true},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(201, rec.Code)
+			suite.NotContains(rec.BodyAsString(), "private")
+
+			var secret coreV1.Secret
+			suite.Nil(suite.Get(suite.namespace, "kalm-files-secret-1", &secret))
+			suite.Equal("private", string(secret.Data[files.EncodeFilePath("/tls/key.pem")]))
+		},
+	})
+
+	// files of direct configs are managed by the controller
+	rec := suite.NewRequestWithIdentity(http.MethodPost, path, files.File{Path: "/kalm-direct-configs/a/0", Content: "a"}, "foo@bar", GetEditorRoleOfNs(suite.namespace))
+	suite.Equal(500, rec.Code)
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPut,
+		Path:      path,
+		Body:      files.File{Path: "/nginx/nginx.conf", Content: "worker_processes 2;"},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var file files.FileItem
+			rec.BodyAsJSON(&file)
+			suite.Equal(200, rec.Code)
+			suite.Equal("worker_processes 2;", file.Content)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetViewerRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodGet,
+		Path:      path + "/revisions?path=/nginx/nginx.conf",
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "viewer", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var revisions []resources.ConfigFileRevision
+			rec.BodyAsJSON(&revisions)
+			suite.Equal(200, rec.Code)
+			suite.Len(revisions, 2)
+			suite.Equal(2, revisions[0].Revision)
+			suite.Contains(revisions[0].Diff, "-worker_processes 1;")
+			suite.Contains(revisions[0].Diff, "+worker_processes 2;")
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPost,
+		Path:      path + "/rollback",
+		Body:      resources.ConfigFileRollback{Path: "/nginx/nginx.conf", Revision: 1},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var file files.FileItem
+			rec.BodyAsJSON(&file)
+			suite.Equal(200, rec.Code)
+			suite.Equal("worker_processes 1;", file.Content)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPost,
+		Path:      path + "/move",
+		Body:      resources.ConfigFileMove{OldPath: "/nginx", NewPath: "/etc/nginx"},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var dir files.FileItem
+			rec.BodyAsJSON(&dir)
+			suite.Equal(200, rec.Code)
+			suite.True(dir.IsDir)
+			suite.Len(dir.Children, 1)
+			suite.Equal("/etc/nginx/nginx.conf", dir.Children[0].AbsPath)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetViewerRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodGet,
+		Path:      path + "?path=/etc",
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "viewer", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var dir files.FileItem
+			rec.BodyAsJSON(&dir)
+			suite.Equal(200, rec.Code)
+			suite.Equal("nginx", dir.Children[0].Name)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodDelete,
+		Path:      path + "?path=/etc",
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(204, rec.Code)
+
+			// the shard is removed once it's empty
+			var configMap coreV1.ConfigMap
+			suite.True(errors.IsNotFound(suite.Get(suite.namespace, "kalm-files-1", &configMap)))
+		},
+	})
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/labstack/echo/v4"
+)
+
+// Custom roles are not secrets. Application owners need to list them to bind roles in their applications.
+func (h *ApiHandler) handleListCustomRoles(c echo.Context) error {
+	customRoles, err := h.resourceManager.ListCustomRoles()
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, customRoles)
+}
+
+func (h *ApiHandler) handleCreateCustomRole(c echo.Context) error {
+	if !h.clientManager.CanManageCluster(getCurrentUser(c)) {
+		return resources.NoClusterOwnerRoleError
+	}
+
+	customRole, err := getCustomRoleFromContext(c)
+
+	if err != nil {
+		return err
+	}
+
+	if customRole, err = h.resourceManager.CreateCustomRole(customRole); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, customRole)
+}
+
+func (h *ApiHandler) handleUpdateCustomRole(c echo.Context) error {
+	if !h.clientManager.CanManageCluster(getCurrentUser(c)) {
+		return resources.NoClusterOwnerRoleError
+	}
+
+	customRole, err := getCustomRoleFromContext(c)
+
+	if err != nil {
+		return err
+	}
+
+	customRole.Name = c.Param("name")
+
+	if customRole, err = h.resourceManager.UpdateCustomRole(customRole); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, customRole)
+}
+
+func (h *ApiHandler) handleDeleteCustomRole(c echo.Context) error {
+	if !h.clientManager.CanManageCluster(getCurrentUser(c)) {
+		return resources.NoClusterOwnerRoleError
+	}
+
+	if err := h.resourceManager.DeleteCustomRole(c.Param("name")); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func getCustomRoleFromContext(c echo.Context) (*resources.CustomRole, error) {
+	var customRole resources.CustomRole
+
+	if err := c.Bind(&customRole); err != nil {
+		return nil, err
+	}
+
+	if customRole.CustomRoleSpec == nil {
+		return nil, fmt.Errorf("rules are required")
+	}
+
+	return &customRole, nil
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/suite"
+)
+
+type CustomRolesHandlerTestSuite struct {
+	WithControllerTestSuite
+}
+
+func TestCustomRolesHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(CustomRolesHandlerTestSuite))
+}
+
+func (suite *CustomRolesHandlerTestSuite) TestCreateUpdateDeleteCustomRole() {
+	customRole := resources.CustomRole{
+		Name: "deployer",
+		CustomRoleSpec: &v1alpha1.CustomRoleSpec{
+			Rules: []v1alpha1.CustomRoleRule{
+				{Verbs: []v1alpha1.CustomRoleVerb{v1alpha1.CustomRoleVerbView}, Kind: "*"},
+				{Verbs: []v1alpha1.CustomRoleVerb{v1alpha1.CustomRoleVerbDeploy}, Kind: "components"},
+			},
+		},
+	}
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetClusterOwnerRole(),
+		},
+		Method: http.MethodPost,
+		Path:   "/v1alpha1/customroles",
+		Body:   customRole,
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "owner", "cluster")
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(201, rec.Code)
+
+			var role v1alpha1.CustomRole
+			suite.Nil(suite.Get("", "deployer", &role))
+			suite.Len(role.Spec.Rules, 2)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Method: http.MethodGet,
+		Path:   "/v1alpha1/customroles",
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var res []resources.CustomRole
+			rec.BodyAsJSON(&res)
+
+			suite.Equal(200, rec.Code)
+			suite.Len(res, 1)
+			suite.Equal("deployer", res[0].Name)
+		},
+	})
+
+	customRole.Rules = customRole.Rules[:1]
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetClusterOwnerRole(),
+		},
+		Method: http.MethodPut,
+		Path:   "/v1alpha1/customroles/deployer",
+		Body:   customRole,
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "owner", "cluster")
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(200, rec.Code)
+
+			var role v1alpha1.CustomRole
+			suite.Nil(suite.Get("", "deployer", &role))
+			suite.Len(role.Spec.Rules, 1)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetClusterOwnerRole(),
+		},
+		Method: http.MethodDelete,
+		Path:   "/v1alpha1/customroles/deployer",
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "owner", "cluster")
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(200, rec.Code)
+
+			var role v1alpha1.CustomRole
+			suite.NotNil(suite.Get("", "deployer", &role))
+		},
+	})
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/kalmhq/kalm/controller/api/v1alpha1"
 	"github.com/labstack/echo/v4"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/util/rand"
 )
 
 func (h *ApiHandler) handleListDeployAccessTokens(c echo.Context) error {
@@ -41,9 +40,8 @@ func (h *ApiHandler) handleCreateDeployAccessToken(c echo.Context) error {
 	}
 
 	// Set sensitive fields
-	accessToken.Token = rand.String(128)
+	accessToken.Token, accessToken.Name = resources.GenerateAccessToken()
 	accessToken.Creator = getCurrentUser(c).Name
-	accessToken.Name = v1alpha1.GetAccessTokenNameFromToken(accessToken.Token)
 
 	accessToken, err = h.resourceManager.CreateDeployAccessToken(accessToken)
 	if err != nil {
@@ -51,6 +51,7 @@ func (h *ApiHandler) InstallMainRoutes(e *echo.Echo) {
 	gv1Alpha1 := e.Group("/v1alpha1")
 	gv1Alpha1.GET("/logs", h.logWebsocketHandler)
 	gv1Alpha1.GET("/exec", h.execWebsocketHandler)
+	gv1Alpha1.GET("/nodes/drain", h.nodeDrainWebsocketHandler)
 
 	gv1Alpha1WithAuth := gv1Alpha1.Group("", h.GetUserMiddleware, h.RequireUserMiddleware)
 
@@ -66,6 +67,28 @@ func (h *ApiHandler) InstallMainRoutes(e *echo.Echo) {
 	gv1Alpha1WithAuth.POST("/applications", h.handleCreateApplication)
 	gv1Alpha1WithAuth.GET("/applications/:name", h.handleGetApplicationDetails)
 	gv1Alpha1WithAuth.DELETE("/applications/:name", h.handleDeleteApplication)
+	gv1Alpha1WithAuth.GET("/applications/:name/sharedenv", h.handleGetSharedEnv)
+	gv1Alpha1WithAuth.PUT("/applications/:name/sharedenv", h.handleUpdateSharedEnv)
+	gv1Alpha1WithAuth.GET("/applications/:name/secretenvs", h.handleListSecretEnvKeys)
+	gv1Alpha1WithAuth.POST("/applications/:name/secretenvs", h.handleCreateSecretEnvKey)
+	gv1Alpha1WithAuth.PUT("/applications/:name/secretenvs/:key", h.handleRotateSecretEnvKey)
+	gv1Alpha1WithAuth.DELETE("/applications/:name/secretenvs/:key", h.handleDeleteSecretEnvKey)
+	gv1Alpha1WithAuth.GET("/applications/:name/files", h.handleGetConfigFiles)
+	gv1Alpha1WithAuth.POST("/applications/:name/files", h.handleCreateConfigFile)
+	gv1Alpha1WithAuth.PUT("/applications/:name/files", h.handleUpdateConfigFile)
+	gv1Alpha1WithAuth.DELETE("/applications/:name/files", h.handleDeleteConfigFile)
+	gv1Alpha1WithAuth.POST("/applications/:name/files/move", h.handleMoveConfigFile)
+	gv1Alpha1WithAuth.GET("/applications/:name/files/revisions", h.handleListConfigFileRevisions)
+	gv1Alpha1WithAuth.POST("/applications/:name/files/rollback", h.handleRollbackConfigFile)
+	gv1Alpha1WithAuth.GET("/applications/:name/metrics", h.handleGetApplicationMetrics)
+	gv1Alpha1WithAuth.GET("/applications/:name/alertrules", h.handleListAlertRules)
+	gv1Alpha1WithAuth.POST("/applications/:name/alertrules", h.handleCreateAlertRule)
+	gv1Alpha1WithAuth.GET("/applications/:name/alertrules/:ruleName", h.handleGetAlertRule)
+	gv1Alpha1WithAuth.PUT("/applications/:name/alertrules/:ruleName", h.handleUpdateAlertRule)
+	gv1Alpha1WithAuth.DELETE("/applications/:name/alertrules/:ruleName", h.handleDeleteAlertRule)
+	gv1Alpha1WithAuth.POST("/applications/:name/alertrules/:ruleName/silence", h.handleSilenceAlertRule)
+	gv1Alpha1WithAuth.DELETE("/applications/:name/alertrules/:ruleName/silence", h.handleUnsilenceAlertRule)
+	gv1Alpha1WithAuth.GET("/applications/:name/alertrules/:ruleName/history", h.handleGetAlertRuleHistory)
 
 	gv1Alpha1WithAuth.GET("/services", h.handleListClusterServices)
 
@@ -76,6 +99,21 @@ func (h *ApiHandler) InstallMainRoutes(e *echo.Echo) {
 	gv1Alpha1WithAuth.PUT("/applications/:applicationName/components/:name", h.handleUpdateComponent)
 	gv1Alpha1WithAuth.DELETE("/applications/:applicationName/components/:name", h.handleDeleteComponent)
 	gv1Alpha1WithAuth.POST("/applications/:applicationName/components", h.handleCreateComponent)
+	gv1Alpha1WithAuth.POST("/applications/:applicationName/components/fromtemplate", h.handleCreateComponentFromTemplate)
+	gv1Alpha1WithAuth.GET("/applications/:applicationName/components/:name/runs", h.handleListJobRuns)
+	gv1Alpha1WithAuth.POST("/applications/:applicationName/components/:name/runs", h.handleCreateJobRun)
+	gv1Alpha1WithAuth.GET("/applications/:applicationName/components/:name/runs/:jobName/logs", h.handleGetJobRunLogs)
+	gv1Alpha1WithAuth.POST("/applications/:applicationName/components/:name/suspend", h.handleSuspendCronJob)
+	gv1Alpha1WithAuth.POST("/applications/:applicationName/components/:name/resume", h.handleResumeCronJob)
+	gv1Alpha1WithAuth.GET("/applications/:applicationName/components/:name/metrics", h.handleGetComponentMetrics)
+
+	gv1Alpha1WithAuth.GET("/componenttemplates", h.handleListComponentTemplates)
+	gv1Alpha1WithAuth.GET("/componenttemplates/:name", h.handleGetComponentTemplate)
+	gv1Alpha1WithAuth.POST("/componenttemplates", h.handleCreateComponentTemplate)
+	gv1Alpha1WithAuth.PUT("/componenttemplates/:name", h.handleUpdateComponentTemplate)
+	gv1Alpha1WithAuth.DELETE("/componenttemplates/:name", h.handleDeleteComponentTemplate)
+	gv1Alpha1WithAuth.GET("/componenttemplates/:name/rollout", h.handlePreviewComponentTemplateRollout)
+	gv1Alpha1WithAuth.POST("/componenttemplates/:name/rollout", h.handleRolloutComponentTemplate)
 
 	gv1Alpha1WithAuth.GET("/registries", h.handleListRegistries)
 	gv1Alpha1WithAuth.GET("/registries/:name", h.handleGetRegistry)
@@ -90,11 +128,19 @@ func (h *ApiHandler) InstallMainRoutes(e *echo.Echo) {
 	gv1Alpha1WithAuth.PUT("/rolebindings", h.handleUpdateRoleBinding)
 	gv1Alpha1WithAuth.DELETE("/rolebindings/:namespace/:name", h.handleDeleteRoleBinding)
 
+	gv1Alpha1WithAuth.GET("/customroles", h.handleListCustomRoles)
+	gv1Alpha1WithAuth.POST("/customroles", h.handleCreateCustomRole)
+	gv1Alpha1WithAuth.PUT("/customroles/:name", h.handleUpdateCustomRole)
+	gv1Alpha1WithAuth.DELETE("/customroles/:name", h.handleDeleteCustomRole)
+
 	gv1Alpha1WithAuth.GET("/serviceaccounts/:name", h.handleGetServiceAccount)
 
 	gv1Alpha1WithAuth.GET("/nodes", h.handleListNodes)
+	gv1Alpha1WithAuth.GET("/nodes/metrics", h.handleGetNodesMetrics)
 	gv1Alpha1WithAuth.POST("/nodes/:name/cordon", h.handleCordonNode)
 	gv1Alpha1WithAuth.POST("/nodes/:name/uncordon", h.handleUncordonNode)
+	gv1Alpha1WithAuth.POST("/nodes/:name/taints", h.handleAddNodeTaint)
+	gv1Alpha1WithAuth.DELETE("/nodes/:name/taints", h.handleRemoveNodeTaint)
 
 	gv1Alpha1WithAuth.GET("/httproutes", h.handleListAllRoutes)
 	gv1Alpha1WithAuth.GET("/httproutes/:namespace", h.handleListRoutes)
@@ -119,6 +165,16 @@ func (h *ApiHandler) InstallMainRoutes(e *echo.Echo) {
 	gv1Alpha1WithAuth.GET("/volumes", h.handleListVolumes)
 	gv1Alpha1WithAuth.DELETE("/volumes/:namespace/:name", h.handleDeletePVC)
 
+	gv1Alpha1WithAuth.GET("/volume-snapshots", h.handleListVolumeSnapshots)
+	gv1Alpha1WithAuth.DELETE("/volume-snapshots/:namespace/:name", h.handleDeleteVolumeSnapshot)
+	gv1Alpha1WithAuth.POST("/volume-snapshots/:namespace/:name/restore", h.handleRestoreVolumeSnapshot)
+	gv1Alpha1WithAuth.GET("/volumes/:namespace/:name/snapshots", h.handleListVolumeSnapshotsOfPVC)
+	gv1Alpha1WithAuth.POST("/volumes/:namespace/:name/snapshots", h.handleCreateVolumeSnapshot)
+	gv1Alpha1WithAuth.GET("/volumes/:namespace/:name/metrics", h.handleGetVolumeMetrics)
+	gv1Alpha1WithAuth.GET("/volumes/:namespace/:name/files", h.handleListVolumeFiles)
+	gv1Alpha1WithAuth.GET("/volumes/:namespace/:name/files/stat", h.handleGetVolumeFile)
+	gv1Alpha1WithAuth.GET("/volumes/:namespace/:name/files/download", h.handleDownloadVolumeFile)
+
 	// deprecated
 	gv1Alpha1WithAuth.GET("/volumes/available/simple-workload", h.handleAvailableVolsForSimpleWorkload)
 	gv1Alpha1WithAuth.GET("/volumes/available/simple-workload/:namespace", h.handleAvailableVolsForSimpleWorkload)
@@ -129,11 +185,13 @@ func (h *ApiHandler) InstallMainRoutes(e *echo.Echo) {
 	gv1Alpha1WithAuth.GET("/access_tokens", h.handleListAccessTokens)
 	gv1Alpha1WithAuth.POST("/access_tokens", h.handleCreateAccessToken)
 	gv1Alpha1WithAuth.DELETE("/access_tokens", h.handleDeleteAccessToken)
+	gv1Alpha1WithAuth.GET("/access_tokens/:name/activities", h.handleListAccessTokenActivities)
 
 	// deploy access token is just access token that only has update component permissions
 	gv1Alpha1WithAuth.GET("/deploy_access_tokens", h.handleListDeployAccessTokens)
 	gv1Alpha1WithAuth.POST("/deploy_access_tokens", h.handleCreateDeployAccessToken)
 	gv1Alpha1WithAuth.DELETE("/deploy_access_tokens", h.handleDeleteAccessToken)
+	gv1Alpha1WithAuth.GET("/deploy_access_tokens/:name/activities", h.handleListAccessTokenActivities)
 
 	gv1Alpha1WithAuth.GET("/sso", h.handleGetSSOConfig)
 	gv1Alpha1WithAuth.DELETE("/sso", h.handleDeleteSSOConfig)
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultJobRunLogTailLines = 1000
+
+func (h *ApiHandler) handleListJobRuns(c echo.Context) error {
+	component, err := h.getComponent(c)
+
+	if err != nil {
+		return err
+	}
+
+	runs, err := h.resourceManager.ListJobRuns(component.Namespace, component.Name)
+
+	if err != nil {
+		return err
+	}
+
+	for _, run := range runs {
+		run.LogsPath = getJobRunLogsPath(component, run.Name)
+	}
+
+	return c.JSON(200, runs)
+}
+
+func getJobRunLogsPath(component *v1alpha1.Component, jobName string) string {
+	return fmt.Sprintf("/v1alpha1/applications/%s/components/%s/runs/%s/logs", component.Namespace, component.Name, jobName)
+}
+
+func (h *ApiHandler) checkCanDeployComponent(c echo.Context) error {
+	if !h.clientManager.CanDeployComponent(getCurrentUser(c), c.Param("applicationName"), c.Param("name")) {
+		return resources.NoObjectEditorRoleError(c.Param("applicationName"), "components/"+c.Param("name"))
+	}
+
+	return nil
+}
+
+// Start a new run of a job component, or trigger a cronjob component immediately.
+func (h *ApiHandler) handleCreateJobRun(c echo.Context) error {
+	if err := h.checkCanDeployComponent(c); err != nil {
+		return err
+	}
+
+	component, err := h.getComponent(c)
+
+	if err != nil {
+		return err
+	}
+
+	var run *resources.JobRun
+
+	switch component.Spec.WorkloadType {
+	case v1alpha1.WorkloadTypeJob:
+		run, err = h.resourceManager.RerunJobComponent(component)
+	case v1alpha1.WorkloadTypeCronjob:
+		run, err = h.resourceManager.TriggerCronJobComponent(component)
+	default:
+		return fmt.Errorf("component %s is not a job or cronjob", component.Name)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	run.LogsPath = getJobRunLogsPath(component, run.Name)
+
+	return c.JSON(http.StatusCreated, run)
+}
+
+func (h *ApiHandler) handleSuspendCronJob(c echo.Context) error {
+	return h.suspendCronJob(c, true)
+}
+
+func (h *ApiHandler) handleResumeCronJob(c echo.Context) error {
+	return h.suspendCronJob(c, false)
+}
+
+func (h *ApiHandler) suspendCronJob(c echo.Context, suspend bool) error {
+	if err := h.checkCanDeployComponent(c); err != nil {
+		return err
+	}
+
+	component, err := h.getComponent(c)
+
+	if err != nil {
+		return err
+	}
+
+	if component, err = h.resourceManager.SuspendCronJobComponent(component, suspend); err != nil {
+		return err
+	}
+
+	res, err := h.componentResponse(component)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, res)
+}
+
+func (h *ApiHandler) handleGetJobRunLogs(c echo.Context) error {
+	component, err := h.getComponent(c)
+
+	if err != nil {
+		return err
+	}
+
+	tailLines := int64(defaultJobRunLogTailLines)
+
+	if v := c.QueryParam("tailLines"); v != "" {
+		if tailLines, err = strconv.ParseInt(v, 10, 64); err != nil || tailLines < 1 {
+			return fmt.Errorf("invalid tailLines %s", v)
+		}
+	}
+
+	currentUser := getCurrentUser(c)
+
+	logs, err := h.resourceManager.GetJobRunLogs(component.Namespace, component.Name, c.Param("jobName"), tailLines, func(podName string) bool {
+		return h.clientManager.CanViewPodLogs(currentUser, component.Namespace, podName)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, logs)
+}
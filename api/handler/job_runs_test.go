@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/suite"
+	batchV1 "k8s.io/api/batch/v1"
+	batchV1Beta1 "k8s.io/api/batch/v1beta1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type JobRunsHandlerTestSuite struct {
+	WithControllerTestSuite
+	namespace string
+}
+
+func TestJobRunsHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(JobRunsHandlerTestSuite))
+}
+
+func (suite *JobRunsHandlerTestSuite) SetupSuite() {
+	suite.WithControllerTestSuite.SetupSuite()
+	suite.namespace = "kalm-test-job-runs"
+	suite.ensureNamespaceExist(suite.namespace)
+}
+
+func (suite *JobRunsHandlerTestSuite) TeardownSuite() {
+	suite.ensureNamespaceDeleted(suite.namespace)
+}
+
+func (suite *JobRunsHandlerTestSuite) TestRerunAndListJobRuns() {
+	component := &v1alpha1.Component{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: suite.namespace,
+			Name:      "migration",
+		},
+		Spec: v1alpha1.ComponentSpec{
+			Image:        "busybox",
+			Command:      "echo migrated",
+			WorkloadType: v1alpha1.WorkloadTypeJob,
+		},
+	}
+
+	suite.Nil(suite.Create(component))
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPost,
+		Path:      fmt.Sprintf("/v1alpha1/applications/%s/components/migration/runs", suite.namespace),
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var res resources.JobRun
+			rec.BodyAsJSON(&res)
+			suite.Equal(201, rec.Code)
+			suite.Equal(2, res.Run)
+			suite.Equal("migration-2", res.Name)
+
+			var updated v1alpha1.Component
+			suite.Nil(suite.Get(suite.namespace, "migration", &updated))
+			suite.Equal("2", updated.Annotations[v1alpha1.ComponentJobRunAnnotation])
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetViewerRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodGet,
+		Path:      fmt.Sprintf("/v1alpha1/applications/%s/components/migration/runs", suite.namespace),
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "viewer", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var res []resources.JobRun
+			rec.BodyAsJSON(&res)
+			suite.Equal(200, rec.Code)
+		},
+	})
+}
+
+func (suite *JobRunsHandlerTestSuite) TestTriggerAndSuspendCronJob() {
+	component := &v1alpha1.Component{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: suite.namespace,
+			Name:      "report",
+		},
+		Spec: v1alpha1.ComponentSpec{
+			Image:        "busybox",
+			Command:      "echo report",
+			WorkloadType: v1alpha1.WorkloadTypeCronjob,
+			Schedule:     "0 * * * *",
+		},
+	}
+
+	suite.Nil(suite.Create(component))
+
+	// the cronjob is created by the controller, which is not running in this suite
+	cronJob := &batchV1Beta1.CronJob{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: suite.namespace,
+			Name:      "report",
+		},
+		Spec: batchV1Beta1.CronJobSpec{
+			Schedule: "0 * * * *",
+			JobTemplate: batchV1Beta1.JobTemplateSpec{
+				Spec: batchV1.JobSpec{
+					Template: coreV1.PodTemplateSpec{
+						Spec: coreV1.PodSpec{
+							RestartPolicy: coreV1.RestartPolicyOnFailure,
+							Containers:    []coreV1.Container{{Name: "report", Image: "busybox"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	suite.Nil(suite.Create(cronJob))
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPost,
+		Path:      fmt.Sprintf("/v1alpha1/applications/%s/components/report/runs", suite.namespace),
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var res resources.JobRun
+			rec.BodyAsJSON(&res)
+			suite.Equal(201, rec.Code)
+			suite.Contains(res.Name, "report-manual-")
+
+			var job batchV1.Job
+			suite.Nil(suite.Get(suite.namespace, res.Name, &job))
+			suite.Equal("report", metaV1.GetControllerOf(&job).Name)
+		},
+	})
+
+	for _, action := range []string{"suspend", "resume"} {
+		suite.DoTestRequest(&TestRequestContext{
+			Roles: []string{
+				GetEditorRoleOfNs(suite.namespace),
+			},
+			Namespace: suite.namespace,
+			Method:    http.MethodPost,
+			Path:      fmt.Sprintf("/v1alpha1/applications/%s/components/report/%s", suite.namespace, action),
+			TestWithoutRoles: func(rec *ResponseRecorder) {
+				suite.IsMissingRoleError(rec, "editor", suite.namespace)
+			},
+			TestWithRoles: func(rec *ResponseRecorder) {
+				suite.Equal(200, rec.Code)
+
+				var updated v1alpha1.Component
+				suite.Nil(suite.Get(suite.namespace, "report", &updated))
+				suite.NotNil(updated.Spec.CronJob)
+				suite.Equal(action == "suspend", updated.Spec.CronJob.Suspend)
+			},
+		})
+	}
+}
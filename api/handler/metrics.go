@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/labstack/echo/v4"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+// getMetricRange reads the range query param like "1h" or "7d", samples are from the finest tier keeping the range
+func getMetricRange(c echo.Context) (time.Duration, error) {
+	if c.QueryParam("range") == "" {
+		return resources.DefaultMetricRange, nil
+	}
+
+	r, err := resources.ParseMetricDuration(c.QueryParam("range"))
+
+	if err != nil || r <= 0 {
+		return 0, fmt.Errorf("invalid metric range %s", c.QueryParam("range"))
+	}
+
+	return r, nil
+}
+
+func (h *ApiHandler) handleGetApplicationMetrics(c echo.Context) error {
+	if !h.clientManager.CanViewNamespace(getCurrentUser(c), c.Param("name")) {
+		return resources.NoNamespaceViewerRoleError(c.Param("name"))
+	}
+
+	r, err := getMetricRange(c)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, resources.GetApplicationMetricInRange(c.Param("name"), r))
+}
+
+func (h *ApiHandler) handleGetComponentMetrics(c echo.Context) error {
+	if !h.clientManager.CanViewNamespace(getCurrentUser(c), c.Param("applicationName")) {
+		return resources.NoNamespaceViewerRoleError(c.Param("applicationName"))
+	}
+
+	r, err := getMetricRange(c)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, resources.GetComponentMetricInRange(c.Param("name"), c.Param("applicationName"), r))
+}
+
+func (h *ApiHandler) handleGetVolumeMetrics(c echo.Context) error {
+	if !h.clientManager.CanViewNamespace(getCurrentUser(c), c.Param("namespace")) {
+		return resources.NoNamespaceViewerRoleError(c.Param("namespace"))
+	}
+
+	r, err := getMetricRange(c)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, resources.GetVolumeMetricInRange(c.Param("namespace"), c.Param("name"), r))
+}
+
+func (h *ApiHandler) handleGetNodesMetrics(c echo.Context) error {
+	if !h.clientManager.CanViewCluster(getCurrentUser(c)) {
+		return resources.NoClusterViewerRoleError
+	}
+
+	r, err := getMetricRange(c)
+
+	if err != nil {
+		return err
+	}
+
+	var nodes coreV1.NodeList
+
+	if err := h.resourceManager.List(&nodes); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(nodes.Items))
+
+	for _, node := range nodes.Items {
+		names = append(names, node.Name)
+	}
+
+	return c.JSON(200, resources.GetFilteredNodeMetricsInRange(names, r))
+}
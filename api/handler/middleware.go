@@ -31,6 +31,7 @@ func (h *ApiHandler) GetUserMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		}
 
 		c.Set(CURRENT_USER_KEY, clientInfo)
+		h.clientManager.RecordAccessTokenUsage(clientInfo, client.NewAccessTokenActivity(c))
 
 		return next(c)
 	}
@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"fmt"
+
 	"github.com/kalmhq/kalm/api/resources"
 	"github.com/labstack/echo/v4"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 func (h *ApiHandler) handleListNodes(c echo.Context) error {
@@ -54,3 +58,75 @@ func (h *ApiHandler) handleUncordonNode(c echo.Context) error {
 
 	return c.JSON(200, h.resourceManager.BuildNodeResponse(node))
 }
+
+func validateNodeTaint(taint *coreV1.Taint) error {
+	if errs := validation.IsQualifiedName(taint.Key); len(errs) > 0 {
+		return fmt.Errorf("invalid taint key %s: %s", taint.Key, errs[0])
+	}
+
+	if errs := validation.IsValidLabelValue(taint.Value); len(errs) > 0 {
+		return fmt.Errorf("invalid taint value %s: %s", taint.Value, errs[0])
+	}
+
+	switch taint.Effect {
+	case coreV1.TaintEffectNoSchedule, coreV1.TaintEffectPreferNoSchedule, coreV1.TaintEffectNoExecute:
+	default:
+		return fmt.Errorf("invalid taint effect %s, must be NoSchedule, PreferNoSchedule or NoExecute", taint.Effect)
+	}
+
+	return nil
+}
+
+func (h *ApiHandler) handleAddNodeTaint(c echo.Context) error {
+	if !h.clientManager.CanEditCluster(getCurrentUser(c)) {
+		return resources.NoClusterEditorRoleError
+	}
+
+	var taint coreV1.Taint
+
+	if err := c.Bind(&taint); err != nil {
+		return err
+	}
+
+	if err := validateNodeTaint(&taint); err != nil {
+		return err
+	}
+
+	node, err := h.resourceManager.GetNode(c.Param("name"))
+
+	if err != nil {
+		return err
+	}
+
+	if node, err = h.resourceManager.AddNodeTaint(node, coreV1.Taint{Key: taint.Key, Value: taint.Value, Effect: taint.Effect}); err != nil {
+		return err
+	}
+
+	return c.JSON(200, h.resourceManager.BuildNodeResponse(node))
+}
+
+// Taint keys may contain slashes, so the key is passed as a query param.
+// The effect query param is optional. Taints of all effects with the key are removed if it's blank.
+func (h *ApiHandler) handleRemoveNodeTaint(c echo.Context) error {
+	if !h.clientManager.CanEditCluster(getCurrentUser(c)) {
+		return resources.NoClusterEditorRoleError
+	}
+
+	key := c.QueryParam("key")
+
+	if key == "" {
+		return fmt.Errorf("taint key is required")
+	}
+
+	node, err := h.resourceManager.GetNode(c.Param("name"))
+
+	if err != nil {
+		return err
+	}
+
+	if node, err = h.resourceManager.RemoveNodeTaint(node, key, coreV1.TaintEffect(c.QueryParam("effect"))); err != nil {
+		return err
+	}
+
+	return c.JSON(200, h.resourceManager.BuildNodeResponse(node))
+}
@@ -81,6 +81,49 @@ func (suite *NodesHandlerTestSuite) TestNodesHandler() {
 			suite.EqualValues(200, rec.Code)
 		},
 	})
+
+	// add taint
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetClusterEditorRole(),
+		},
+		Method: http.MethodPost,
+		Path:   "/v1alpha1/nodes/test-node/taints",
+		Body: map[string]string{
+			"key":    "dedicated",
+			"value":  "batch",
+			"effect": "NoSchedule",
+		},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", "cluster")
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var res resources.Node
+			rec.BodyAsJSON(&res)
+			suite.EqualValues(200, rec.Code)
+			suite.Equal(1, len(res.Taints))
+			suite.Equal("dedicated", res.Taints[0].Key)
+			suite.Equal(v1.TaintEffectNoSchedule, res.Taints[0].Effect)
+		},
+	})
+
+	// remove taint
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetClusterEditorRole(),
+		},
+		Method: http.MethodDelete,
+		Path:   "/v1alpha1/nodes/test-node/taints?key=dedicated",
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", "cluster")
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var res resources.Node
+			rec.BodyAsJSON(&res)
+			suite.EqualValues(200, rec.Code)
+			suite.Equal(0, len(res.Taints))
+		},
+	})
 }
 
 func TestNodesHandlerTestSuite(t *testing.T) {
@@ -81,6 +81,7 @@ func (h *ApiHandler) handleUpdateRoleBinding(c echo.Context) error {
 
 	copied := fetched.DeepCopy()
 	copied.Spec.Role = roleBinding.Spec.Role
+	copied.Spec.CustomRole = roleBinding.Spec.CustomRole
 
 	if err := h.resourceManager.Patch(copied, client.MergeFrom(&fetched)); err != nil {
 		return err
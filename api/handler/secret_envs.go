@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/labstack/echo/v4"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func secretEnvObj(key string) string {
+	return "secretenvs/" + key
+}
+
+// Only key names are listed, values are never returned.
+func (h *ApiHandler) handleListSecretEnvKeys(c echo.Context) error {
+	currentUser := getCurrentUser(c)
+	namespace := c.Param("name")
+
+	keys, err := h.resourceManager.ListSecretEnvKeys(namespace)
+
+	if err != nil {
+		return err
+	}
+
+	res := make([]*resources.SecretEnvKey, 0, len(keys))
+
+	for _, key := range keys {
+		if h.clientManager.CanView(currentUser, namespace, secretEnvObj(key.Name)) {
+			res = append(res, key)
+		}
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *ApiHandler) handleCreateSecretEnvKey(c echo.Context) error {
+	key, err := getSecretEnvKeyFromContext(c)
+
+	if err != nil {
+		return err
+	}
+
+	namespace := c.Param("name")
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), namespace, secretEnvObj(key.Name)) {
+		return resources.NoObjectEditorRoleError(namespace, secretEnvObj(key.Name))
+	}
+
+	if key, err = h.resourceManager.CreateSecretEnvKey(namespace, key); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, key)
+}
+
+func (h *ApiHandler) handleRotateSecretEnvKey(c echo.Context) error {
+	key, err := getSecretEnvKeyFromContext(c)
+
+	if err != nil {
+		return err
+	}
+
+	namespace := c.Param("name")
+	key.Name = c.Param("key")
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), namespace, secretEnvObj(key.Name)) {
+		return resources.NoObjectEditorRoleError(namespace, secretEnvObj(key.Name))
+	}
+
+	if key, err = h.resourceManager.RotateSecretEnvKey(namespace, key); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, key)
+}
+
+func (h *ApiHandler) handleDeleteSecretEnvKey(c echo.Context) error {
+	namespace := c.Param("name")
+	name := c.Param("key")
+
+	if !h.clientManager.CanEdit(getCurrentUser(c), namespace, secretEnvObj(name)) {
+		return resources.NoObjectEditorRoleError(namespace, secretEnvObj(name))
+	}
+
+	if err := h.resourceManager.DeleteSecretEnvKey(namespace, name); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func getSecretEnvKeyFromContext(c echo.Context) (*resources.SecretEnvKey, error) {
+	var key resources.SecretEnvKey
+
+	if err := c.Bind(&key); err != nil {
+		return nil, err
+	}
+
+	if c.Param("key") != "" {
+		key.Name = c.Param("key")
+	}
+
+	if errs := validation.IsConfigMapKey(key.Name); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid secret env key %s: %s", key.Name, errs[0])
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/suite"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+type SecretEnvsHandlerTestSuite struct {
+	WithControllerTestSuite
+	namespace string
+}
+
+func TestSecretEnvsHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(SecretEnvsHandlerTestSuite))
+}
+
+func (suite *SecretEnvsHandlerTestSuite) SetupSuite() {
+	suite.WithControllerTestSuite.SetupSuite()
+	suite.namespace = "kalm-test-secret-envs"
+	suite.ensureNamespaceExist(suite.namespace)
+}
+
+func (suite *SecretEnvsHandlerTestSuite) TeardownSuite() {
+	suite.ensureNamespaceDeleted(suite.namespace)
+}
+
+func (suite *SecretEnvsHandlerTestSuite) TestSecretEnvKeys() {
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPost,
+		Path:      fmt.Sprintf("/v1alpha1/applications/%s/secretenvs", suite.namespace),
+		Body:      resources.SecretEnvKey{Name: "db-password", Value: "foo"},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(201, rec.Code)
+			suite.NotContains(rec.BodyAsString(), "foo")
+
+			var secret coreV1.Secret
+			suite.Nil(suite.Get(suite.namespace, v1alpha1.SecretEnvSecretName, &secret))
+			suite.Equal("foo", string(secret.Data["db-password"]))
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetViewerRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodGet,
+		Path:      fmt.Sprintf("/v1alpha1/applications/%s/secretenvs", suite.namespace),
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var res []resources.SecretEnvKey
+			rec.BodyAsJSON(&res)
+
+			suite.Equal(200, rec.Code)
+			suite.Len(res, 1)
+			suite.Equal("db-password", res[0].Name)
+			suite.Equal("", res[0].Value)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPut,
+		Path:      fmt.Sprintf("/v1alpha1/applications/%s/secretenvs/db-password", suite.namespace),
+		Body:      resources.SecretEnvKey{Value: "bar"},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(200, rec.Code)
+
+			var secret coreV1.Secret
+			suite.Nil(suite.Get(suite.namespace, v1alpha1.SecretEnvSecretName, &secret))
+			suite.Equal("bar", string(secret.Data["db-password"]))
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodDelete,
+		Path:      fmt.Sprintf("/v1alpha1/applications/%s/secretenvs/db-password", suite.namespace),
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(204, rec.Code)
+
+			var secret coreV1.Secret
+			suite.Nil(suite.Get(suite.namespace, v1alpha1.SecretEnvSecretName, &secret))
+			suite.NotContains(secret.Data, "db-password")
+		},
+	})
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/labstack/echo/v4"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+func (h *ApiHandler) handleGetSharedEnv(c echo.Context) error {
+	if !h.clientManager.CanViewNamespace(getCurrentUser(c), c.Param("name")) {
+		return resources.NoNamespaceViewerRoleError(c.Param("name"))
+	}
+
+	sharedEnv, err := h.resourceManager.GetSharedEnv(c.Param("name"))
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, sharedEnv)
+}
+
+func (h *ApiHandler) handleUpdateSharedEnv(c echo.Context) error {
+	if !h.clientManager.CanEditNamespace(getCurrentUser(c), c.Param("name")) {
+		return resources.NoNamespaceEditorRoleError(c.Param("name"))
+	}
+
+	var sharedEnv resources.SharedEnv
+
+	if err := c.Bind(&sharedEnv); err != nil {
+		return err
+	}
+
+	for key := range sharedEnv {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return fmt.Errorf("invalid shared env name %s: %s", key, errs[0])
+		}
+	}
+
+	sharedEnv, err := h.resourceManager.UpdateSharedEnv(c.Param("name"), sharedEnv)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, sharedEnv)
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/suite"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+type SharedEnvHandlerTestSuite struct {
+	WithControllerTestSuite
+	namespace string
+}
+
+func TestSharedEnvHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(SharedEnvHandlerTestSuite))
+}
+
+func (suite *SharedEnvHandlerTestSuite) SetupSuite() {
+	suite.WithControllerTestSuite.SetupSuite()
+	suite.namespace = "kalm-test-shared-env"
+	suite.ensureNamespaceExist(suite.namespace)
+}
+
+func (suite *SharedEnvHandlerTestSuite) TeardownSuite() {
+	suite.ensureNamespaceDeleted(suite.namespace)
+}
+
+func (suite *SharedEnvHandlerTestSuite) TestGetAndUpdateSharedEnv() {
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetViewerRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodGet,
+		Path:      fmt.Sprintf("/v1alpha1/applications/%s/sharedenv", suite.namespace),
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "viewer", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			var res resources.SharedEnv
+			rec.BodyAsJSON(&res)
+			suite.Equal(200, rec.Code)
+			suite.Len(res, 0)
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPut,
+		Path:      fmt.Sprintf("/v1alpha1/applications/%s/sharedenv", suite.namespace),
+		Body:      resources.SharedEnv{"DATABASE_HOST": "db.example.com"},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(200, rec.Code)
+
+			var configMap coreV1.ConfigMap
+			suite.Nil(suite.Get(suite.namespace, v1alpha1.SharedEnvConfigMapName, &configMap))
+			suite.Equal("db.example.com", configMap.Data["DATABASE_HOST"])
+		},
+	})
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Permission: editors of a namespace can browse and download files of its volumes, the volume is mounted read-only.
+// Volumes may contain secrets, and browsing creates a helper pod with the volume, so viewers are not allowed.
+// Files are read by a helper pod running with the volume, it's deleted after being idle for a while.
+func (h *ApiHandler) handleListVolumeFiles(c echo.Context) error {
+	pvcNamespace := c.Param("namespace")
+
+	if !h.clientManager.CanEditNamespace(getCurrentUser(c), pvcNamespace) {
+		return resources.NoNamespaceEditorRoleError(pvcNamespace)
+	}
+
+	files, err := h.resourceManager.ListVolumeFiles(pvcNamespace, c.Param("name"), c.QueryParam("path"))
+
+	if err != nil {
+		return err
+	}
+
+	// directories first, then by name
+	sort.Slice(files, func(i, j int) bool {
+		if (files[i].Type == resources.VolumeFileTypeDir) != (files[j].Type == resources.VolumeFileTypeDir) {
+			return files[i].Type == resources.VolumeFileTypeDir
+		}
+
+		return files[i].Name < files[j].Name
+	})
+
+	return c.JSON(200, files)
+}
+
+func (h *ApiHandler) handleGetVolumeFile(c echo.Context) error {
+	pvcNamespace := c.Param("namespace")
+
+	if !h.clientManager.CanEditNamespace(getCurrentUser(c), pvcNamespace) {
+		return resources.NoNamespaceEditorRoleError(pvcNamespace)
+	}
+
+	file, err := h.resourceManager.GetVolumeFile(pvcNamespace, c.Param("name"), c.QueryParam("path"))
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(200, file)
+}
+
+func (h *ApiHandler) handleDownloadVolumeFile(c echo.Context) error {
+	pvcNamespace := c.Param("namespace")
+
+	if !h.clientManager.CanEditNamespace(getCurrentUser(c), pvcNamespace) {
+		return resources.NoNamespaceEditorRoleError(pvcNamespace)
+	}
+
+	file, err := h.resourceManager.GetVolumeFile(pvcNamespace, c.Param("name"), c.QueryParam("path"))
+
+	if err != nil {
+		return err
+	}
+
+	if file.Type != resources.VolumeFileTypeFile {
+		return fmt.Errorf("%s is not a regular file", file.Path)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", file.Name))
+	c.Response().Header().Set(echo.HeaderContentLength, fmt.Sprint(file.Size))
+	c.Response().WriteHeader(200)
+
+	// the status is sent already, a failed copy ends as a truncated download
+	if err := h.resourceManager.DownloadVolumeFile(pvcNamespace, c.Param("name"), file.Path, c.Response()); err != nil {
+		h.logger.Error("download volume file failed", zap.String("path", file.Path), zap.Error(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+type VolumeBrowserTestSuite struct {
+	WithControllerTestSuite
+	namespace string
+}
+
+func TestVolumeBrowserTestSuite(t *testing.T) {
+	suite.Run(t, new(VolumeBrowserTestSuite))
+}
+
+func (suite *VolumeBrowserTestSuite) SetupSuite() {
+	suite.WithControllerTestSuite.SetupSuite()
+	suite.namespace = "kalm-test-" + rand.String(10)
+	suite.ensureNamespaceExist(suite.namespace)
+}
+
+func (suite *VolumeBrowserTestSuite) TeardownSuite() {
+	suite.ensureNamespaceDeleted(suite.namespace)
+}
+
+func (suite *VolumeBrowserTestSuite) TestBrowseNotExistingVolume() {
+	for _, path := range []string{"files", "files/stat", "files/download"} {
+		suite.DoTestRequest(&TestRequestContext{
+			Roles: []string{
+				GetViewerRoleOfNs(suite.namespace),
+			},
+			Namespace: suite.namespace,
+			Method:    http.MethodGet,
+			Path:      fmt.Sprintf("/v1alpha1/volumes/%s/not-exist/%s?path=/", suite.namespace, path),
+			TestWithoutRoles: func(rec *ResponseRecorder) {
+				suite.IsMissingRoleError(rec, "viewer", suite.namespace)
+			},
+			TestWithRoles: func(rec *ResponseRecorder) {
+				suite.Equal(404, rec.Code)
+			},
+		})
+	}
+}
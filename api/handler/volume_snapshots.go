@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/kalmhq/kalm/controller/lib/snapshot"
+	"github.com/labstack/echo/v4"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Permission: viewers of a namespace can see its snapshots, editors can take, delete and restore them
+func (h *ApiHandler) handleListVolumeSnapshots(c echo.Context) error {
+	snapshots, err := h.resourceManager.ListVolumeSnapshots()
+
+	if err != nil {
+		return err
+	}
+
+	res := []*resources.VolumeSnapshot{}
+
+	for _, s := range snapshots {
+		if !h.clientManager.CanViewNamespace(getCurrentUser(c), s.Namespace) {
+			continue
+		}
+
+		res = append(res, s)
+	}
+
+	sortVolumeSnapshots(res)
+
+	return c.JSON(200, res)
+}
+
+func (h *ApiHandler) handleListVolumeSnapshotsOfPVC(c echo.Context) error {
+	pvcNamespace := c.Param("namespace")
+
+	if !h.clientManager.CanViewNamespace(getCurrentUser(c), pvcNamespace) {
+		return resources.NoNamespaceViewerRoleError(pvcNamespace)
+	}
+
+	snapshots, err := h.resourceManager.ListVolumeSnapshots(
+		client.InNamespace(pvcNamespace),
+		client.MatchingLabels{snapshot.LabelPVC: c.Param("name")},
+	)
+
+	if err != nil {
+		return err
+	}
+
+	sortVolumeSnapshots(snapshots)
+
+	return c.JSON(200, snapshots)
+}
+
+func (h *ApiHandler) handleCreateVolumeSnapshot(c echo.Context) error {
+	pvcNamespace := c.Param("namespace")
+
+	if !h.clientManager.CanEditNamespace(getCurrentUser(c), pvcNamespace) {
+		return resources.NoNamespaceEditorRoleError(pvcNamespace)
+	}
+
+	var req resources.VolumeSnapshotRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	volumeSnapshot, err := h.resourceManager.CreateVolumeSnapshot(pvcNamespace, c.Param("name"), &req)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(201, volumeSnapshot)
+}
+
+func (h *ApiHandler) handleDeleteVolumeSnapshot(c echo.Context) error {
+	namespace := c.Param("namespace")
+
+	if !h.clientManager.CanEditNamespace(getCurrentUser(c), namespace) {
+		return resources.NoNamespaceEditorRoleError(namespace)
+	}
+
+	if err := h.resourceManager.DeleteVolumeSnapshot(namespace, c.Param("name")); err != nil {
+		return err
+	}
+
+	return c.NoContent(200)
+}
+
+func (h *ApiHandler) handleRestoreVolumeSnapshot(c echo.Context) error {
+	namespace := c.Param("namespace")
+
+	if !h.clientManager.CanEditNamespace(getCurrentUser(c), namespace) {
+		return resources.NoNamespaceEditorRoleError(namespace)
+	}
+
+	var req resources.VolumeRestoreRequest
+
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+
+	if req.PVC == "" {
+		return fmt.Errorf("pvc is required")
+	}
+
+	volume, err := h.resourceManager.RestoreVolumeSnapshot(namespace, c.Param("name"), &req)
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(201, volume)
+}
+
+// latest first
+func sortVolumeSnapshots(snapshots []*resources.VolumeSnapshot) {
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[j].CreationTimestamp.Before(&snapshots[i].CreationTimestamp)
+	})
+}
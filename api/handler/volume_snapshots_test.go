@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kalmhq/kalm/api/resources"
+	"github.com/stretchr/testify/suite"
+	"k8s.io/apimachinery/pkg/util/rand"
+)
+
+type VolumeSnapshotsTestSuite struct {
+	WithControllerTestSuite
+	namespace string
+}
+
+func TestVolumeSnapshotsTestSuite(t *testing.T) {
+	suite.Run(t, new(VolumeSnapshotsTestSuite))
+}
+
+func (suite *VolumeSnapshotsTestSuite) SetupSuite() {
+	suite.WithControllerTestSuite.SetupSuite()
+	suite.namespace = "kalm-test-" + rand.String(10)
+	suite.ensureNamespaceExist(suite.namespace)
+}
+
+func (suite *VolumeSnapshotsTestSuite) TeardownSuite() {
+	suite.ensureNamespaceDeleted(suite.namespace)
+}
+
+// the test environment doesn't install the CSI VolumeSnapshot api
+func (suite *VolumeSnapshotsTestSuite) TestVolumeSnapshotsNotSupported() {
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetViewerRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodGet,
+		Path:      fmt.Sprintf("/v1alpha1/volumes/%s/data/snapshots", suite.namespace),
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "viewer", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(500, rec.Code)
+			suite.Contains(rec.BodyAsString(), "volume snapshots are not supported")
+		},
+	})
+
+	suite.DoTestRequest(&TestRequestContext{
+		Roles: []string{
+			GetEditorRoleOfNs(suite.namespace),
+		},
+		Namespace: suite.namespace,
+		Method:    http.MethodPost,
+		Path:      fmt.Sprintf("/v1alpha1/volume-snapshots/%s/data-1/restore", suite.namespace),
+		Body:      resources.VolumeRestoreRequest{PVC: "data-restored"},
+		TestWithoutRoles: func(rec *ResponseRecorder) {
+			suite.IsMissingRoleError(rec, "editor", suite.namespace)
+		},
+		TestWithRoles: func(rec *ResponseRecorder) {
+			suite.Equal(500, rec.Code)
+			suite.Contains(rec.BodyAsString(), "volume snapshots are not supported")
+		},
+	})
+}
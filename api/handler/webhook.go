@@ -8,8 +8,8 @@ import (
 	"time"
 
 	"github.com/kalmhq/kalm/api/auth"
+	kalmclient "github.com/kalmhq/kalm/api/client"
 	"github.com/kalmhq/kalm/api/resources"
-	"github.com/kalmhq/kalm/controller/api/v1alpha1"
 	"github.com/kalmhq/kalm/controller/controllers"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
@@ -54,13 +54,15 @@ func (h *ApiHandler) handleDeployWebhookCall(c echo.Context) error {
 		return err
 	}
 
+	h.clientManager.RecordAccessTokenUsage(clientInfo, kalmclient.NewAccessTokenActivity(c))
+
 	builder := resources.NewResourceManager(clientInfo.Cfg, h.logger)
 
 	if builder == nil {
 		return fmt.Errorf("invalid access token")
 	}
 
-	if !h.clientManager.CanEdit(clientInfo, callParams.Namespace, "components/"+callParams.ComponentName) {
+	if !h.clientManager.CanDeployComponent(clientInfo, callParams.Namespace, callParams.ComponentName) {
 		return resources.NoObjectEditorRoleError(callParams.Namespace, "components/"+callParams.ComponentName)
 	}
 
@@ -91,19 +93,6 @@ func (h *ApiHandler) handleDeployWebhookCall(c echo.Context) error {
 
 	h.logger.Info("updating component", zap.String("name", copiedComp.Name), zap.Int("time", updateTs))
 
-	var accessToken v1alpha1.AccessToken
-	if err := h.resourceManager.Get("", clientInfo.Name, &accessToken); err == nil {
-		copiedKey := accessToken.DeepCopy()
-		copiedKey.Status.UsedCount += 1
-		copiedKey.Status.LastUsedAt = updateTs
-
-		if err := h.resourceManager.Patch(copiedKey, client.MergeFrom(&accessToken)); err != nil {
-			h.logger.Error("fail update status of access token", zap.Error(err))
-		}
-	} else {
-		h.logger.Error("fail to get access token", zap.Error(err))
-	}
-
 	return c.JSON(http.StatusOK, map[string]string{
 		"status": "Success",
 	})
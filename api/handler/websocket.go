@@ -28,11 +28,13 @@ type WSConn struct {
 	*websocket.Conn
 	ctx      context.Context
 	stopFunc context.CancelFunc
+	path     string
 
 	clientInfo    *client.ClientInfo
 	clientManager client.ClientManager
 
 	podResourceRequest chan *WSPodResourceRequest
+	nodeDrainRequest   chan *WSNodeDrainRequest
 	writeLock          *sync.Mutex
 }
 
@@ -58,6 +60,10 @@ const (
 	WSRequestTypeExecEndSession   WSRequestType = "execEndSession"
 	WSRequestTypeExecStdin        WSRequestType = "stdin"
 	WSRequestTypeExecResize       WSRequestType = "resize"
+
+	// node drain
+	WSRequestTypeDrainNode       WSRequestType = "drainNode"
+	WSRequestTypeCancelNodeDrain WSRequestType = "cancelNodeDrain"
 )
 
 type WSRequest struct {
@@ -82,6 +88,12 @@ type WSPodResourceRequest struct {
 	Data       string `json:"data"`
 }
 
+type WSNodeDrainRequest struct {
+	WSRequest                  `json:",inline"`
+	NodeName                   string `json:"nodeName"`
+	resources.DrainNodeOptions `json:",inline"`
+}
+
 type StatusValue int
 
 const StatusOK StatusValue = 0
@@ -104,6 +116,9 @@ const (
 	// exec
 	WSResponseTypeExecStdout       WSResponseType = "execStreamUpdate"
 	WSResponseTypeExecDisconnected WSResponseType = "execStreamDisconnected"
+
+	// node drain
+	WSResponseTypeNodeDrainProgress WSResponseType = "nodeDrainProgress"
 )
 
 type WSResponse struct {
@@ -119,6 +134,11 @@ type WSPodDataResponse struct {
 	Data      string         `json:"data"`
 }
 
+type WSNodeDrainProgressResponse struct {
+	Type                         WSResponseType `json:"type"`
+	*resources.NodeDrainProgress `json:",inline"`
+}
+
 const END_OF_TRANSMISSION = "\u0004"
 
 // TerminalSession
@@ -228,6 +248,7 @@ func wsReadLoop(conn *WSConn, clientManager client.ClientManager) (err error) {
 
 			if clientInfo, err := clientManager.GetClientInfoFromToken(m.AuthToken); err == nil {
 				clientManager.SetImpersonation(clientInfo, m.Impersonation)
+				clientManager.RecordAccessTokenUsage(clientInfo, client.NewWebsocketAccessTokenActivity(conn.path, conn.RemoteAddr()))
 				conn.clientInfo = clientInfo
 				res.Status = StatusOK
 				res.Message = "Auth Successfully"
@@ -251,12 +272,12 @@ func wsReadLoop(conn *WSConn, clientManager client.ClientManager) (err error) {
 
 			switch m.Type {
 			case WSRequestTypeSubscribePodLog:
-				if !conn.clientManager.CanView(conn.clientInfo, m.Namespace, "pods/"+m.PodName) {
+				if !conn.clientManager.CanViewPodLogs(conn.clientInfo, m.Namespace, m.PodName) {
 					res.Message = resources.NoObjectViewerRoleError(m.Namespace, "pods/"+m.PodName).Error()
 					break OuterSwitch
 				}
 			case WSRequestTypeExecStartSession, WSRequestTypeExecStdin, WSRequestTypeExecResize:
-				if !conn.clientManager.CanEdit(conn.clientInfo, m.Namespace, "pods/"+m.PodName) {
+				if !conn.clientManager.CanExecPod(conn.clientInfo, m.Namespace, m.PodName) {
 					res.Message = resources.NoObjectEditorRoleError(m.Namespace, "pods/"+m.PodName).Error()
 					break OuterSwitch
 				}
@@ -268,6 +289,34 @@ func wsReadLoop(conn *WSConn, clientManager client.ClientManager) (err error) {
 			//res.Message = "Request Success"
 
 			// no need to return any value
+			continue
+		case WSRequestTypeDrainNode, WSRequestTypeCancelNodeDrain:
+			// only the node drain endpoint handles these requests
+			if conn.nodeDrainRequest == nil {
+				res.Message = "Unknown Message Type"
+				break
+			}
+
+			if conn.clientInfo == nil {
+				res.Message = "Unauthorized, Please verify yourself first."
+				break
+			}
+
+			if !conn.clientManager.CanEditCluster(conn.clientInfo) {
+				res.Message = resources.NoClusterEditorRoleError.Error()
+				break
+			}
+
+			var m WSNodeDrainRequest
+			err = json.Unmarshal(message, &m)
+
+			if err != nil {
+				log.Error("parse message error", zap.Error(err))
+				continue
+			}
+
+			conn.nodeDrainRequest <- &m
+
 			continue
 		case WSRequestTypeAuthStatus:
 			res.Type = WSResponseTypeAuthStatus
@@ -554,6 +603,71 @@ func handleExecRequests(conn *WSConn) {
 	}
 }
 
+func handleNodeDrainRequests(conn *WSConn, resourceManager *resources.ResourceManager) {
+	drains := make(map[string]context.CancelFunc)
+	mut := &sync.Mutex{}
+
+	defer func() {
+		mut.Lock()
+		defer mut.Unlock()
+
+		for _, cancelFunc := range drains {
+			cancelFunc()
+		}
+	}()
+
+	for {
+		select {
+		case <-conn.ctx.Done():
+			return
+		case m := <-conn.nodeDrainRequest:
+			if m.Type == WSRequestTypeCancelNodeDrain {
+				mut.Lock()
+				if stop, existing := drains[m.NodeName]; existing {
+					stop()
+					delete(drains, m.NodeName)
+				}
+				mut.Unlock()
+				continue
+			}
+
+			node, err := resourceManager.GetNode(m.NodeName)
+
+			if err != nil {
+				_ = conn.WriteJSON(&WSNodeDrainProgressResponse{
+					Type:              WSResponseTypeNodeDrainProgress,
+					NodeDrainProgress: &resources.NodeDrainProgress{Node: m.NodeName, Phase: resources.NodeDrainPhaseFailed, Message: err.Error()},
+				})
+				continue
+			}
+
+			ctx, stop := context.WithCancel(conn.ctx)
+
+			mut.Lock()
+			if oldStop, existing := drains[m.NodeName]; existing {
+				oldStop()
+			}
+			drains[m.NodeName] = stop
+			mut.Unlock()
+
+			go func(m *WSNodeDrainRequest) {
+				defer stop()
+
+				err := resourceManager.DrainNode(ctx, node, &m.DrainNodeOptions, func(progress *resources.NodeDrainProgress) {
+					_ = conn.WriteJSON(&WSNodeDrainProgressResponse{
+						Type:              WSResponseTypeNodeDrainProgress,
+						NodeDrainProgress: progress,
+					})
+				})
+
+				if err != nil {
+					log.Error("drain node error", zap.String("node", m.NodeName), zap.Error(err))
+				}
+			}(m)
+		}
+	}
+}
+
 func (h *ApiHandler) prepareWSConnection(c echo.Context) (*WSConn, error) {
 	ws, err := upgrader.Upgrade(c.Response(), c.Request(), nil)
 
@@ -567,6 +681,7 @@ func (h *ApiHandler) prepareWSConnection(c echo.Context) (*WSConn, error) {
 		Conn:               ws,
 		ctx:                ctx,
 		stopFunc:           stop,
+		path:               c.Request().URL.Path,
 		podResourceRequest: make(chan *WSPodResourceRequest),
 		writeLock:          &sync.Mutex{},
 		clientManager:      h.clientManager,
@@ -575,6 +690,7 @@ func (h *ApiHandler) prepareWSConnection(c echo.Context) (*WSConn, error) {
 	clientInfo, err := h.clientManager.GetClientInfoFromContext(c)
 
 	if err == nil && clientInfo != nil {
+		h.clientManager.RecordAccessTokenUsage(clientInfo, client.NewAccessTokenActivity(c))
 		conn.clientInfo = clientInfo
 	}
 
@@ -616,3 +732,23 @@ func (h *ApiHandler) execWebsocketHandler(c echo.Context) error {
 
 	return nil
 }
+
+func (h *ApiHandler) nodeDrainWebsocketHandler(c echo.Context) error {
+	conn, err := h.prepareWSConnection(c)
+
+	if err != nil {
+		return err
+	}
+
+	conn.nodeDrainRequest = make(chan *WSNodeDrainRequest)
+
+	defer func() {
+		conn.stopFunc()
+		_ = conn.Close()
+	}()
+
+	go handleNodeDrainRequests(conn, h.resourceManager)
+	_ = wsReadLoop(conn, h.clientManager)
+
+	return nil
+}
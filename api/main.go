@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/kalmhq/kalm/api/log"
+	"github.com/kalmhq/kalm/api/metrics"
 	"github.com/labstack/echo/v4/middleware"
 	"golang.org/x/net/http2"
 	"k8s.io/client-go/rest"
@@ -22,6 +23,7 @@ import (
 	"github.com/kalmhq/kalm/api/server"
 	"github.com/kalmhq/kalm/controller/api/v1alpha1"
 	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
@@ -83,6 +85,43 @@ func main() {
 				Destination: &runningConfig.KubeConfigPath,
 				EnvVars:     []string{"KUBE_CONFIG_PATH"},
 			},
+			&cli.StringFlag{
+				Name:        "metric-storage",
+				Usage:       "The storage of scraped metrics.",
+				Value:       "sqlite3",
+				Destination: &runningConfig.MetricStorage,
+				EnvVars:     []string{"METRIC_STORAGE"},
+			},
+			&cli.StringFlag{
+				Name: "metric-storage-dsn",
+				Usage: "The data source name of the metric storage, a file path for sqlite3. " +
+					"Put the file on a persistent volume to keep metrics across restarts.",
+				Value:       "/tmp/metric_scraper.db",
+				Destination: &runningConfig.MetricStorageDSN,
+				EnvVars:     []string{"METRIC_STORAGE_DSN"},
+			},
+			&cli.StringFlag{
+				Name: "metric-tiers",
+				Usage: "Resolutions and retentions of metrics, comma separated <resolution>:<retention> pairs. " +
+					"Metrics are scraped at the first resolution and downsampled into the later ones.",
+				Value:       resources.DefaultMetricTiers,
+				Destination: &runningConfig.MetricTiers,
+				EnvVars:     []string{"METRIC_TIERS"},
+			},
+			&cli.IntFlag{
+				Name:        "volume-usage-warning-threshold",
+				Usage:       "Percent of bytes or inodes used, above which a warning event is emitted on the volume.",
+				Value:       resources.DefaultVolumeUsageWarningThreshold,
+				Destination: &runningConfig.VolumeUsageWarningThreshold,
+				EnvVars:     []string{"VOLUME_USAGE_WARNING_THRESHOLD"},
+			},
+			&cli.StringFlag{
+				Name:        "metrics-bind-address",
+				Usage:       "The address the prometheus metrics endpoint binds to, it should not be exposed by the dashboard service. Set to 0 to disable.",
+				Value:       ":9090",
+				Destination: &runningConfig.MetricsBindAddress,
+				EnvVars:     []string{"METRICS_BIND_ADDRESS"},
+			},
 			&cli.BoolFlag{
 				Name:        "verbose",
 				Value:       false,
@@ -176,8 +215,30 @@ func startMainServer(runningConfig *config.Config, k8sClientConfig *rest.Config)
 	}
 }
 
-func startMetricServer(cfg *rest.Config) {
-	_ = resources.StartMetricScraper(context.Background(), cfg)
+func startMetricServer(runningConfig *config.Config, cfg *rest.Config) {
+	_ = resources.StartMetricScraper(context.Background(), cfg, &resources.MetricScraperOptions{
+		Storage: runningConfig.MetricStorage,
+		DSN:     runningConfig.MetricStorageDSN,
+		Tiers:   runningConfig.MetricTiers,
+
+		VolumeUsageWarningThreshold: runningConfig.VolumeUsageWarningThreshold,
+	})
+}
+
+func serveMetrics(runningConfig *config.Config) {
+	if runningConfig.MetricsBindAddress == "0" || runningConfig.MetricsBindAddress == "" {
+		return
+	}
+
+	if err := metrics.Serve(runningConfig.MetricsBindAddress); err != nil {
+		log.Error("serve metrics failed", zap.Error(err))
+	}
+}
+
+func migrateAccessTokens(cfg *rest.Config) {
+	if err := resources.NewResourceManager(cfg, log.DefaultLogger()).MigratePlaintextAccessTokens(); err != nil {
+		log.Error("migrate plaintext access tokens failed", zap.Error(err))
+	}
 }
 
 func run(runningConfig *config.Config) {
@@ -191,9 +252,14 @@ func run(runningConfig *config.Config) {
 		panic(err)
 	}
 
+	migrateAccessTokens(k8sClientConfig)
+
+	go resources.NewResourceManager(k8sClientConfig, log.DefaultLogger()).StartVolumeBrowserCleaner(context.Background())
+	go serveMetrics(runningConfig)
+
 	go func() {
 		if runningConfig.IsInCluster() {
-			startMetricServer(k8sClientConfig)
+			startMetricServer(runningConfig, k8sClientConfig)
 		} else {
 			log.Info("not running in cluster, skip running metric server")
 		}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Series of the api server, served at /metrics in the prometheus exposition format on an internal listener,
+// which is not exposed by the dashboard service. Series of applications, components and certs are served by the controller.
+// Usages of each access token are in its status, series are not labeled by tokens.
+var (
+	AccessTokenRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "kalm_api_access_token_requests_total",
+			Help: "Number of requests authorized by access tokens",
+		},
+	)
+
+	AccessTokenLastUsed = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "kalm_api_access_token_last_used_timestamp_seconds",
+			Help: "Unix time of the last request authorized by an access token",
+		},
+	)
+
+	WebsocketClients = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "kalm_api_websocket_clients",
+			Help: "Number of connected websocket clients",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(AccessTokenRequests, AccessTokenLastUsed, WebsocketClients)
+}
+
+// Serve blocks serving /metrics on the address
+func Serve(address string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	return http.ListenAndServe(address, mux)
+}
@@ -9,8 +9,47 @@ const (
 	ActionView   = "view"
 	ActionEdit   = "edit"
 	ActionManage = "manage"
+
+	// Narrower actions which can be granted by custom roles.
+	// Policies only match the exact action, see ImpliedActions for how the broader ones grant them.
+	ActionLogs   = "logs"
+	ActionExec   = "exec"
+	ActionDeploy = "deploy"
 )
 
+// manage implies edit, edit implies view, exec and deploy, view implies logs.
+var impliedActions = map[string][]string{
+	ActionManage: {ActionEdit},
+	ActionEdit:   {ActionView, ActionExec, ActionDeploy},
+	ActionView:   {ActionLogs},
+}
+
+// ImpliedActions returns the action and all actions implied by it.
+// The matcher compares actions by equality, so a policy is needed for each of them.
+func ImpliedActions(action string) []string {
+	res := []string{action}
+
+	for i := 0; i < len(res); i++ {
+		for _, implied := range impliedActions[res[i]] {
+			if !containsAction(res, implied) {
+				res = append(res, implied)
+			}
+		}
+	}
+
+	return res
+}
+
+func containsAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
+}
+
 const (
 	ResourceAll = "*"
 )
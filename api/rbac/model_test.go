@@ -81,3 +81,9 @@ g, dvd, clusterOwner
 	assert.Nil(t, err)
 	assert.True(t, canAccess)
 }
+
+func TestImpliedActions(t *testing.T) {
+	assert.Equal(t, []string{ActionManage, ActionEdit, ActionView, ActionExec, ActionDeploy, ActionLogs}, ImpliedActions(ActionManage))
+	assert.Equal(t, []string{ActionView, ActionLogs}, ImpliedActions(ActionView))
+	assert.Equal(t, []string{ActionDeploy}, ImpliedActions(ActionDeploy))
+}
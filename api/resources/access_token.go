@@ -1,8 +1,12 @@
 package resources
 
 import (
+	"fmt"
+
 	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"go.uber.org/zap"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -21,35 +25,86 @@ func (resourceManager *ResourceManager) DeleteAccessToken(name string) error {
 	)
 }
 
+// GenerateAccessToken returns a new random token and a resource name for it.
+// The name is not derived from the token, so it's safe to show it to anyone who can list tokens.
+func GenerateAccessToken() (token, name string) {
+	token = rand.String(128)
+	name = fmt.Sprintf("%s-%s", v1alpha1.GetAccessTokenPrefix(token), rand.String(16))
+	return
+}
+
 func (resourceManager *ResourceManager) CreateAccessToken(accessToken *AccessToken) (*AccessToken, error) {
+	return resourceManager.createAccessToken(accessToken, nil)
+}
+
+// The plaintext token is only stored as a salted hash.
+// It's returned in the result of creation and can't be retrieved again afterwards.
+func (resourceManager *ResourceManager) createAccessToken(accessToken *AccessToken, labels map[string]string) (*AccessToken, error) {
+	token := accessToken.Token
+
 	resAccessToken := &v1alpha1.AccessToken{
 		ObjectMeta: metaV1.ObjectMeta{
-			Name: accessToken.Name,
+			Name:   accessToken.Name,
+			Labels: labels,
 		},
 		Spec: *accessToken.AccessTokenSpec,
 	}
 
+	if err := resAccessToken.SealToken(); err != nil {
+		return nil, err
+	}
+
 	if err := resourceManager.Create(resAccessToken); err != nil {
 		return nil, err
 	}
 
-	return BuildAccessTokenFromResource(resAccessToken), nil
+	res := BuildAccessTokenFromResource(resAccessToken)
+	res.Token = token
+
+	return res, nil
 }
 
 func BuildAccessTokenFromResource(dk *v1alpha1.AccessToken) *AccessToken {
+	spec := dk.Spec.DeepCopy()
+
+	// Never reveal plaintext tokens of legacy records
+	spec.Token = ""
+
 	return &AccessToken{
 		Name:            dk.Name,
-		AccessTokenSpec: &dk.Spec,
+		AccessTokenSpec: spec,
 	}
 }
 
-func (resourceManager *ResourceManager) GetAccessTokenByToken(token string) (*v1alpha1.AccessToken, error) {
-	name := v1alpha1.GetAccessTokenNameFromToken(token)
-	var accessToken v1alpha1.AccessToken
-	if err := resourceManager.Get("", name, &accessToken); err != nil {
-		return nil, err
+// MigratePlaintextAccessTokens replaces plaintext tokens of legacy records with salted hashes.
+func (resourceManager *ResourceManager) MigratePlaintextAccessTokens() error {
+	var accessTokenList v1alpha1.AccessTokenList
+
+	if err := resourceManager.List(&accessTokenList); err != nil {
+		return err
 	}
-	return &accessToken, nil
+
+	for i := range accessTokenList.Items {
+		accessToken := &accessTokenList.Items[i]
+
+		if accessToken.Spec.Token == "" {
+			continue
+		}
+
+		copied := accessToken.DeepCopy()
+
+		if err := copied.SealToken(); err != nil {
+			return err
+		}
+
+		if err := resourceManager.Patch(copied, client.MergeFrom(accessToken)); err != nil {
+			return err
+		}
+
+		resourceManager.Logger.Info("plaintext access token migrated", zap.String("name", accessToken.Name))
+	}
+
+	return nil
 }
 
 func (resourceManager *ResourceManager) GetAccessTokens() ([]*AccessToken, error) {
@@ -93,17 +148,5 @@ func (resourceManager *ResourceManager) GetDeployAccessTokens() ([]*AccessToken,
 }
 
 func (resourceManager *ResourceManager) CreateDeployAccessToken(accessToken *AccessToken) (*AccessToken, error) {
-	resAccessToken := &v1alpha1.AccessToken{
-		ObjectMeta: metaV1.ObjectMeta{
-			Name:   accessToken.Name,
-			Labels: deployAccessTokenLabels,
-		},
-		Spec: *accessToken.AccessTokenSpec,
-	}
-
-	if err := resourceManager.Create(resAccessToken); err != nil {
-		return nil, err
-	}
-
-	return BuildAccessTokenFromResource(resAccessToken), nil
+	return resourceManager.createAccessToken(accessToken, deployAccessTokenLabels)
 }
@@ -0,0 +1,138 @@
+package resources
+
+import (
+	"time"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type AlertRule struct {
+	*v1alpha1.AlertRuleSpec `json:",inline"`
+	Name                    string `json:"name" validate:"required"`
+
+	// evaluated by the controller, ignored in requests
+	State           v1alpha1.AlertState `json:"state,omitempty"`
+	Value           string              `json:"value,omitempty"`
+	LastEvaluatedAt *metaV1.Time        `json:"lastEvaluatedAt,omitempty"`
+}
+
+// AlertRuleSilence silences a rule until the time, or for the duration from now
+type AlertRuleSilence struct {
+	Until    *metaV1.Time `json:"until,omitempty"`
+	Duration string       `json:"duration,omitempty"`
+}
+
+func BuildAlertRuleFromResource(alertRule *v1alpha1.AlertRule) *AlertRule {
+	return &AlertRule{
+		AlertRuleSpec:   &alertRule.Spec,
+		Name:            alertRule.Name,
+		State:           alertRule.Status.State,
+		Value:           alertRule.Status.Value,
+		LastEvaluatedAt: alertRule.Status.LastEvaluatedAt,
+	}
+}
+
+func (resourceManager *ResourceManager) ListAlertRules(namespace string) ([]*AlertRule, error) {
+	var alertRuleList v1alpha1.AlertRuleList
+
+	if err := resourceManager.List(&alertRuleList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	res := make([]*AlertRule, 0, len(alertRuleList.Items))
+
+	for i := range alertRuleList.Items {
+		res = append(res, BuildAlertRuleFromResource(&alertRuleList.Items[i]))
+	}
+
+	return res, nil
+}
+
+func (resourceManager *ResourceManager) GetAlertRule(namespace, name string) (*AlertRule, error) {
+	var alertRule v1alpha1.AlertRule
+
+	if err := resourceManager.Get(namespace, name, &alertRule); err != nil {
+		return nil, err
+	}
+
+	return BuildAlertRuleFromResource(&alertRule), nil
+}
+
+func (resourceManager *ResourceManager) CreateAlertRule(namespace string, alertRule *AlertRule) (*AlertRule, error) {
+	rule := &v1alpha1.AlertRule{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: namespace,
+			Name:      alertRule.Name,
+		},
+		Spec: *alertRule.AlertRuleSpec,
+	}
+
+	if err := resourceManager.Create(rule); err != nil {
+		return nil, err
+	}
+
+	return BuildAlertRuleFromResource(rule), nil
+}
+
+func (resourceManager *ResourceManager) UpdateAlertRule(namespace string, alertRule *AlertRule) (*AlertRule, error) {
+	var rule v1alpha1.AlertRule
+
+	if err := resourceManager.Get(namespace, alertRule.Name, &rule); err != nil {
+		return nil, err
+	}
+
+	rule.Spec = *alertRule.AlertRuleSpec
+
+	if err := resourceManager.Update(&rule); err != nil {
+		return nil, err
+	}
+
+	return BuildAlertRuleFromResource(&rule), nil
+}
+
+func (resourceManager *ResourceManager) DeleteAlertRule(namespace, name string) error {
+	return resourceManager.Delete(&v1alpha1.AlertRule{ObjectMeta: metaV1.ObjectMeta{Namespace: namespace, Name: name}})
+}
+
+// SilenceAlertRule stops notifications of the rule until the time, a nil time unsilences the rule
+func (resourceManager *ResourceManager) SilenceAlertRule(namespace, name string, until *time.Time) (*AlertRule, error) {
+	var rule v1alpha1.AlertRule
+
+	if err := resourceManager.Get(namespace, name, &rule); err != nil {
+		return nil, err
+	}
+
+	copied := rule.DeepCopy()
+	copied.Spec.SilencedUntil = nil
+
+	if until != nil {
+		t := metaV1.NewTime(*until)
+		copied.Spec.SilencedUntil = &t
+	}
+
+	if err := resourceManager.Patch(copied, client.MergeFrom(&rule)); err != nil {
+		return nil, err
+	}
+
+	return BuildAlertRuleFromResource(copied), nil
+}
+
+// GetAlertRuleHistory returns state changes of the rule, the latest first
+func (resourceManager *ResourceManager) GetAlertRuleHistory(namespace, name string) ([]v1alpha1.AlertRecord, error) {
+	var rule v1alpha1.AlertRule
+
+	if err := resourceManager.Get(namespace, name, &rule); err != nil {
+		return nil, err
+	}
+
+	history := rule.Status.History
+	res := make([]v1alpha1.AlertRecord, len(history))
+
+	for i := range history {
+		res[len(history)-1-i] = history[i]
+	}
+
+	return res, nil
+}
@@ -275,6 +275,10 @@ func (resourceManager *ResourceManager) Patch(obj runtime.Object, patch client.P
 	return resourceManager.Client.Patch(resourceManager.ctx, obj, patch, opts...)
 }
 
+func (resourceManager *ResourceManager) PatchStatus(obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return resourceManager.Client.Status().Patch(resourceManager.ctx, obj, patch, opts...)
+}
+
 // client Side apply
 func (resourceManager *ResourceManager) Apply(obj runtime.Object) error {
 	fetched, err := scheme.Scheme.New(obj.GetObjectKind().GroupVersionKind())
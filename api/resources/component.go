@@ -99,6 +99,8 @@ type ComponentDetails struct {
 	IstioMetricHistories *IstioMetricHistories `json:"istioMetricHistories"`
 	Services             []ServiceStatus       `json:"services"`
 	Pods                 []PodStatus           `json:"pods"`
+
+	Conditions []v1alpha1.ComponentCondition `json:"conditions,omitempty"`
 }
 
 func (resourceManager *ResourceManager) BuildComponentDetails(
@@ -199,6 +201,7 @@ func (resourceManager *ResourceManager) BuildComponentDetails(
 		},
 		IstioMetricHistories: istioMetricRst,
 		Pods:                 podsStatus,
+		Conditions:           component.Status.Conditions,
 	}
 
 	for i := range resources.ProtectedEndpoints {
@@ -1,5 +1,450 @@
 package resources
 
-import "github.com/kalmhq/kalm/controller/api/v1alpha1"
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
 
 type CreateOrUpdateComponentTemplateRequest = v1alpha1.ComponentTemplateSpec
+
+type ComponentTemplate struct {
+	*v1alpha1.ComponentTemplateSpec `json:",inline"`
+	Generation                      int64 `json:"generation"`
+}
+
+func BuildComponentTemplateFromResource(template *v1alpha1.ComponentTemplate) *ComponentTemplate {
+	spec := template.Spec
+	spec.Name = template.Name
+
+	return &ComponentTemplate{
+		ComponentTemplateSpec: &spec,
+		Generation:            template.Generation,
+	}
+}
+
+func (resourceManager *ResourceManager) ListComponentTemplates() ([]*ComponentTemplate, error) {
+	var templateList v1alpha1.ComponentTemplateList
+
+	if err := resourceManager.List(&templateList); err != nil {
+		return nil, err
+	}
+
+	res := make([]*ComponentTemplate, 0, len(templateList.Items))
+
+	for i := range templateList.Items {
+		res = append(res, BuildComponentTemplateFromResource(&templateList.Items[i]))
+	}
+
+	return res, nil
+}
+
+func (resourceManager *ResourceManager) GetComponentTemplate(name string) (*ComponentTemplate, error) {
+	var template v1alpha1.ComponentTemplate
+
+	if err := resourceManager.Get("", name, &template); err != nil {
+		return nil, err
+	}
+
+	return BuildComponentTemplateFromResource(&template), nil
+}
+
+func (resourceManager *ResourceManager) CreateComponentTemplate(spec *CreateOrUpdateComponentTemplateRequest) (*ComponentTemplate, error) {
+	template := &v1alpha1.ComponentTemplate{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: spec.Name,
+		},
+		Spec: *spec,
+	}
+
+	if err := resourceManager.Create(template); err != nil {
+		return nil, err
+	}
+
+	return BuildComponentTemplateFromResource(template), nil
+}
+
+func (resourceManager *ResourceManager) UpdateComponentTemplate(spec *CreateOrUpdateComponentTemplateRequest) (*ComponentTemplate, error) {
+	var template v1alpha1.ComponentTemplate
+
+	if err := resourceManager.Get("", spec.Name, &template); err != nil {
+		return nil, err
+	}
+
+	template.Spec = *spec
+
+	if err := resourceManager.Update(&template); err != nil {
+		return nil, err
+	}
+
+	return BuildComponentTemplateFromResource(&template), nil
+}
+
+func (resourceManager *ResourceManager) DeleteComponentTemplate(name string) error {
+	return resourceManager.Delete(&v1alpha1.ComponentTemplate{ObjectMeta: metaV1.ObjectMeta{Name: name}})
+}
+
+// BuildComponentSpecFromTemplate converts a template to a component spec.
+// Volume mounts of the template become temporary disks, templates can't claim persistent volumes.
+func BuildComponentSpecFromTemplate(spec *v1alpha1.ComponentTemplateSpec) v1alpha1.ComponentSpec {
+	componentSpec := v1alpha1.ComponentSpec{
+		Image:         spec.Image,
+		Command:       buildComponentCommand(append(append([]string{}, spec.Command...), spec.Args...)),
+		Env:           append([]v1alpha1.EnvVar{}, spec.Env...),
+		Ports:         append([]v1alpha1.Port{}, spec.Ports...),
+		WorkloadType:  spec.WorkLoadType,
+		Schedule:      spec.Schedule,
+		BeforeStart:   append([]string{}, spec.BeforeStart...),
+		AfterStart:    append([]string{}, spec.AfterStart...),
+		BeforeDestroy: append([]string{}, spec.BeforeDestroy...),
+	}
+
+	if !spec.CPU.IsZero() || !spec.Memory.IsZero() {
+		componentSpec.ResourceRequirements = &coreV1.ResourceRequirements{
+			Requests: coreV1.ResourceList{},
+		}
+
+		if !spec.CPU.IsZero() {
+			componentSpec.ResourceRequirements.Requests[coreV1.ResourceCPU] = spec.CPU
+		}
+
+		if !spec.Memory.IsZero() {
+			componentSpec.ResourceRequirements.Requests[coreV1.ResourceMemory] = spec.Memory
+		}
+	}
+
+	for _, mount := range spec.VolumeMounts {
+		componentSpec.Volumes = append(componentSpec.Volumes, v1alpha1.Volume{
+			Path: mount.MountPath,
+			Type: v1alpha1.VolumeTypeTemporaryDisk,
+		})
+	}
+
+	return componentSpec
+}
+
+// The command of a component is a single string, it's run by sh -c when it contains spaces.
+// Each word is quoted, so argument boundaries are kept.
+func buildComponentCommand(words []string) string {
+	if len(words) == 1 && !strings.Contains(words[0], " ") {
+		return words[0]
+	}
+
+	quoted := make([]string, 0, len(words))
+
+	for _, word := range words {
+		quoted = append(quoted, shellQuote(word))
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+var shellSafeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+func shellQuote(word string) string {
+	if shellSafeWord.MatchString(word) {
+		return word
+	}
+
+	return "'" + strings.ReplaceAll(word, "'", `'"'"'`) + "'"
+}
+
+// Overrides are a partial component spec in json. Fields in overrides replace the fields from the template.
+func applyComponentTemplateOverrides(spec *v1alpha1.ComponentSpec, overrides json.RawMessage) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(overrides, spec); err != nil {
+		return fmt.Errorf("invalid template overrides: %s", err.Error())
+	}
+
+	return nil
+}
+
+type CreateComponentFromTemplateRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Template string `json:"template" validate:"required"`
+
+	// Track the template, so template updates can be rolled out to the component.
+	Track bool `json:"track"`
+
+	Overrides json.RawMessage `json:"overrides,omitempty"`
+
+	// Same as the fields of creating a component, they are not part of the template.
+	Plugins                         []runtime.RawExtension `json:"plugins,omitempty"`
+	*v1alpha1.ProtectedEndpointSpec `json:"protectedEndpoint,omitempty"`
+}
+
+func (resourceManager *ResourceManager) BuildComponentFromTemplate(namespace string, req *CreateComponentFromTemplateRequest) (*v1alpha1.Component, error) {
+	var template v1alpha1.ComponentTemplate
+
+	if err := resourceManager.Get("", req.Template, &template); err != nil {
+		return nil, err
+	}
+
+	spec := BuildComponentSpecFromTemplate(&template.Spec)
+
+	if err := applyComponentTemplateOverrides(&spec, req.Overrides); err != nil {
+		return nil, err
+	}
+
+	component := &v1alpha1.Component{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      req.Name,
+			Namespace: namespace,
+		},
+		Spec: spec,
+	}
+
+	if req.Track {
+		component.Labels = map[string]string{
+			v1alpha1.ComponentTemplateLabelName: template.Name,
+		}
+
+		component.Annotations = map[string]string{
+			v1alpha1.ComponentTemplateGenerationAnnotation: strconv.FormatInt(template.Generation, 10),
+			v1alpha1.ComponentTemplateImageAnnotation:      spec.Image,
+		}
+
+		if len(req.Overrides) > 0 {
+			component.Annotations[v1alpha1.ComponentTemplateOverridesAnnotation] = string(req.Overrides)
+		}
+	}
+
+	return component, nil
+}
+
+type ComponentTemplateRolloutItem struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+
+	// The template generation the component was last rolled out from
+	Generation int64 `json:"generation"`
+
+	// Whether the spec will be changed by the rollout
+	Changed bool `json:"changed"`
+
+	Current *v1alpha1.ComponentSpec `json:"current"`
+	Desired *v1alpha1.ComponentSpec `json:"desired,omitempty"`
+
+	// Why the desired spec can't be built, e.g. invalid overrides
+	Error string `json:"error,omitempty"`
+
+	// The image of the template with overrides
+	templateImage string
+}
+
+type ComponentTemplateRollout struct {
+	Template   string                          `json:"template"`
+	Generation int64                           `json:"generation"`
+	Components []*ComponentTemplateRolloutItem `json:"components"`
+}
+
+// PreviewComponentTemplateRollout returns the current and desired specs of all components tracking the template.
+func (resourceManager *ResourceManager) PreviewComponentTemplateRollout(name string) (*ComponentTemplateRollout, []v1alpha1.Component, error) {
+	var template v1alpha1.ComponentTemplate
+
+	if err := resourceManager.Get("", name, &template); err != nil {
+		return nil, nil, err
+	}
+
+	var componentList v1alpha1.ComponentList
+
+	if err := resourceManager.List(&componentList, client.MatchingLabels{v1alpha1.ComponentTemplateLabelName: name}); err != nil {
+		return nil, nil, err
+	}
+
+	rollout := &ComponentTemplateRollout{
+		Template:   template.Name,
+		Generation: template.Generation,
+		Components: make([]*ComponentTemplateRolloutItem, 0, len(componentList.Items)),
+	}
+
+	for i := range componentList.Items {
+		component := &componentList.Items[i]
+		generation, _ := strconv.ParseInt(component.Annotations[v1alpha1.ComponentTemplateGenerationAnnotation], 10, 64)
+
+		item := &ComponentTemplateRolloutItem{
+			Namespace:  component.Namespace,
+			Name:       component.Name,
+			Generation: generation,
+			Current:    &component.Spec,
+		}
+
+		desired, templateImage, err := buildDesiredComponentSpec(&template.Spec, component)
+
+		if err != nil {
+			item.Error = err.Error()
+		} else {
+			item.templateImage = templateImage
+
+			// apply the same defaults as the webhook, otherwise defaulted fields always look changed
+			defaulted := v1alpha1.Component{ObjectMeta: component.ObjectMeta, Spec: *desired}
+			defaulted.Default()
+
+			item.Desired = &defaulted.Spec
+			item.Changed = !reflect.DeepEqual(normalizeComponentSpec(component.Spec), normalizeComponentSpec(defaulted.Spec))
+		}
+
+		rollout.Components = append(rollout.Components, item)
+	}
+
+	return rollout, componentList.Items, nil
+}
+
+// buildDesiredComponentSpec merges the fields owned by the template and overrides into the spec of the component.
+// The image of the template with overrides is returned as well.
+func buildDesiredComponentSpec(template *v1alpha1.ComponentTemplateSpec, component *v1alpha1.Component) (*v1alpha1.ComponentSpec, string, error) {
+	overrides := json.RawMessage(component.Annotations[v1alpha1.ComponentTemplateOverridesAnnotation])
+	built := BuildComponentSpecFromTemplate(template)
+	desired := built
+
+	if err := applyComponentTemplateOverrides(&desired, overrides); err != nil {
+		return nil, "", err
+	}
+
+	preserveVolumeClaims(&desired, &component.Spec)
+
+	merged, err := mergeComponentTemplateFields(component.Spec, desired, componentTemplateOwnedFields(built, overrides))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	// the image of the template is unchanged, keep the image deployed by others, e.g. the deploy webhook
+	if component.Annotations[v1alpha1.ComponentTemplateImageAnnotation] == desired.Image {
+		merged.Image = component.Spec.Image
+	}
+
+	return &merged, desired.Image, nil
+}
+
+// Volume claims without names get random names by default, keep the existing claims of the same path.
+func preserveVolumeClaims(desired, current *v1alpha1.ComponentSpec) {
+	for i := range desired.Volumes {
+		vol := &desired.Volumes[i]
+
+		if vol.PVC != "" || (vol.Type != v1alpha1.VolumeTypePersistentVolumeClaim && vol.Type != v1alpha1.VolumeTypePersistentVolumeClaimTemplate) {
+			continue
+		}
+
+		for _, currentVol := range current.Volumes {
+			if currentVol.Path == vol.Path && currentVol.Type == vol.Type {
+				vol.PVC = currentVol.PVC
+				break
+			}
+		}
+	}
+}
+
+// componentTemplateOwnedFields returns the json fields of the component spec set by the template or the overrides.
+// Other fields are owned by the component, rollouts don't change them.
+func componentTemplateOwnedFields(built v1alpha1.ComponentSpec, overrides json.RawMessage) map[string]bool {
+	owned := make(map[string]bool)
+
+	// fields without omitempty, e.g. enableHeadlessService, are always present, the zero values are not set by the template
+	for key, value := range normalizeComponentSpec(built) {
+		if value != nil && !reflect.ValueOf(value).IsZero() {
+			owned[key] = true
+		}
+	}
+
+	var overrideFields map[string]json.RawMessage
+
+	if len(overrides) > 0 && json.Unmarshal(overrides, &overrideFields) == nil {
+		for key := range overrideFields {
+			owned[key] = true
+		}
+	}
+
+	return owned
+}
+
+// mergeComponentTemplateFields replaces the owned fields of the current spec with the desired ones.
+func mergeComponentTemplateFields(current, desired v1alpha1.ComponentSpec, owned map[string]bool) (v1alpha1.ComponentSpec, error) {
+	merged := normalizeComponentSpec(current)
+	desiredFields := normalizeComponentSpec(desired)
+
+	if merged == nil {
+		merged = make(map[string]interface{})
+	}
+
+	for key := range owned {
+		if value, exist := desiredFields[key]; exist {
+			merged[key] = value
+		} else {
+			delete(merged, key)
+		}
+	}
+
+	var spec v1alpha1.ComponentSpec
+	bts, err := json.Marshal(merged)
+
+	if err != nil {
+		return spec, err
+	}
+
+	err = json.Unmarshal(bts, &spec)
+
+	return spec, err
+}
+
+// Compare specs by their json form, so nil and empty fields are treated the same.
+func normalizeComponentSpec(spec v1alpha1.ComponentSpec) map[string]interface{} {
+	var res map[string]interface{}
+	bts, _ := json.Marshal(spec)
+	_ = json.Unmarshal(bts, &res)
+	return res
+}
+
+// RolloutComponentTemplate updates all components tracking the template to the desired specs.
+// Components with invalid overrides are skipped and reported in the result.
+func (resourceManager *ResourceManager) RolloutComponentTemplate(name string) (*ComponentTemplateRollout, error) {
+	rollout, components, err := resourceManager.PreviewComponentTemplateRollout(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range rollout.Components {
+		if item.Desired == nil {
+			continue
+		}
+
+		component := components[i]
+
+		if !item.Changed && item.Generation == rollout.Generation {
+			continue
+		}
+
+		component.Spec = *item.Desired
+
+		if component.Annotations == nil {
+			component.Annotations = make(map[string]string)
+		}
+
+		component.Annotations[v1alpha1.ComponentTemplateGenerationAnnotation] = strconv.FormatInt(rollout.Generation, 10)
+		component.Annotations[v1alpha1.ComponentTemplateImageAnnotation] = item.templateImage
+
+		if err := resourceManager.Update(&component); err != nil {
+			item.Error = err.Error()
+			continue
+		}
+
+		item.Generation = rollout.Generation
+		item.Current = &component.Spec
+		item.Changed = false
+	}
+
+	return rollout, nil
+}
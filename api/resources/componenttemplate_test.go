@@ -0,0 +1,176 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testComponentTemplateSpec() *v1alpha1.ComponentTemplateSpec {
+	return &v1alpha1.ComponentTemplateSpec{
+		Name:    "web",
+		Image:   "nginx:1.19",
+		Command: []string{"nginx"},
+		Args:    []string{"-g", "daemon off;"},
+		Env: []v1alpha1.EnvVar{
+			{Name: "MODE", Value: "production", Type: v1alpha1.EnvVarTypeStatic},
+		},
+		Ports: []v1alpha1.Port{
+			{ContainerPort: 80, Protocol: v1alpha1.PortProtocolHTTP},
+		},
+		BeforeStart:  []string{"./migrate"},
+		CPU:          resource.MustParse("100m"),
+		VolumeMounts: []coreV1.VolumeMount{{Name: "cache", MountPath: "/cache"}},
+	}
+}
+
+func TestBuildComponentSpecFromTemplate(t *testing.T) {
+	spec := BuildComponentSpecFromTemplate(testComponentTemplateSpec())
+
+	assert.Equal(t, "nginx:1.19", spec.Image)
+	assert.Equal(t, "nginx -g 'daemon off;'", spec.Command)
+	assert.Len(t, spec.Env, 1)
+	assert.Equal(t, []string{"./migrate"}, spec.BeforeStart)
+	assert.Equal(t, "100m", spec.ResourceRequirements.Requests.Cpu().String())
+	assert.NotContains(t, spec.ResourceRequirements.Requests, coreV1.ResourceMemory)
+	assert.Equal(t, []v1alpha1.Volume{{Path: "/cache", Type: v1alpha1.VolumeTypeTemporaryDisk}}, spec.Volumes)
+}
+
+func TestApplyComponentTemplateOverrides(t *testing.T) {
+	spec := BuildComponentSpecFromTemplate(testComponentTemplateSpec())
+
+	err := applyComponentTemplateOverrides(&spec, json.RawMessage(`{"image":"nginx:1.20","replicas":3}`))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "nginx:1.20", spec.Image)
+	assert.Equal(t, int32(3), *spec.Replicas)
+	assert.Equal(t, "nginx -g 'daemon off;'", spec.Command)
+
+	assert.NotNil(t, applyComponentTemplateOverrides(&spec, json.RawMessage(`{"image":1}`)))
+}
+
+func TestPreserveVolumeClaims(t *testing.T) {
+	current := &v1alpha1.ComponentSpec{
+		Volumes: []v1alpha1.Volume{
+			{Path: "/data", Type: v1alpha1.VolumeTypePersistentVolumeClaim, PVC: "pvc-data"},
+		},
+	}
+
+	desired := &v1alpha1.ComponentSpec{
+		Volumes: []v1alpha1.Volume{
+			{Path: "/data", Type: v1alpha1.VolumeTypePersistentVolumeClaim},
+			{Path: "/logs", Type: v1alpha1.VolumeTypePersistentVolumeClaim},
+		},
+	}
+
+	preserveVolumeClaims(desired, current)
+
+	assert.Equal(t, "pvc-data", desired.Volumes[0].PVC)
+	assert.Equal(t, "", desired.Volumes[1].PVC)
+}
+
+func TestBuildComponentCommand(t *testing.T) {
+	assert.Equal(t, "nginx", buildComponentCommand([]string{"nginx"}))
+	assert.Equal(t, "", buildComponentCommand(nil))
+	assert.Equal(t, "'/bin/my app'", buildComponentCommand([]string{"/bin/my app"}))
+	assert.Equal(t, `sh -c 'echo "$HOME" '"'"'quoted'"'"''`, buildComponentCommand([]string{"sh", "-c", `echo "$HOME" 'quoted'`}))
+	assert.Equal(t, "echo ''", buildComponentCommand([]string{"echo", ""}))
+}
+
+func TestMergeComponentTemplateFields(t *testing.T) {
+	built := BuildComponentSpecFromTemplate(testComponentTemplateSpec())
+	overrides := json.RawMessage(`{"replicas":3}`)
+	desired := built
+	assert.Nil(t, applyComponentTemplateOverrides(&desired, overrides))
+
+	replicas := int32(1)
+	current := v1alpha1.ComponentSpec{
+		Image:                 "nginx:deployed",
+		Command:               "old",
+		Replicas:              &replicas,
+		EnableHeadlessService: true,
+		NodeSelectorLabels:    map[string]string{"disk": "ssd"},
+	}
+
+	owned := componentTemplateOwnedFields(built, overrides)
+	assert.False(t, owned["enableHeadlessService"])
+	assert.True(t, owned["replicas"])
+
+	merged, err := mergeComponentTemplateFields(current, desired, owned)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "nginx:1.19", merged.Image)
+	assert.Equal(t, desired.Command, merged.Command)
+	assert.Equal(t, int32(3), *merged.Replicas)
+	assert.Equal(t, desired.BeforeStart, merged.BeforeStart)
+
+	// fields not set by the template are kept
+	assert.True(t, merged.EnableHeadlessService)
+	assert.Equal(t, map[string]string{"disk": "ssd"}, merged.NodeSelectorLabels)
+}
+
+func TestRolloutComponentTemplateKeepsDeployedImage(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.Nil(t, clientgoscheme.AddToScheme(scheme))
+	assert.Nil(t, v1alpha1.AddToScheme(scheme))
+
+	template := &v1alpha1.ComponentTemplate{
+		ObjectMeta: metaV1.ObjectMeta{Name: "web", Generation: 2},
+		Spec:       *testComponentTemplateSpec(),
+	}
+
+	component := &v1alpha1.Component{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "app",
+			Name:      "web",
+			Labels:    map[string]string{v1alpha1.ComponentTemplateLabelName: "web"},
+			Annotations: map[string]string{
+				v1alpha1.ComponentTemplateGenerationAnnotation: "1",
+				v1alpha1.ComponentTemplateImageAnnotation:      "nginx:1.19",
+			},
+		},
+		Spec: v1alpha1.ComponentSpec{
+			// updated by the deploy webhook
+			Image:   "nginx:deployed",
+			Command: "nginx",
+		},
+	}
+
+	resourceManager := &ResourceManager{
+		ctx:    context.Background(),
+		Client: fake.NewFakeClientWithScheme(scheme, template, component),
+	}
+
+	rollout, err := resourceManager.RolloutComponentTemplate("web")
+	assert.Nil(t, err)
+	assert.Equal(t, "", rollout.Components[0].Error)
+
+	var fetched v1alpha1.Component
+	assert.Nil(t, resourceManager.Get("app", "web", &fetched))
+	assert.Equal(t, "nginx:deployed", fetched.Spec.Image)
+	assert.Equal(t, "nginx -g 'daemon off;'", fetched.Spec.Command)
+	assert.Equal(t, "2", fetched.Annotations[v1alpha1.ComponentTemplateGenerationAnnotation])
+
+	// a new image of the template is rolled out
+	var fetchedTemplate v1alpha1.ComponentTemplate
+	assert.Nil(t, resourceManager.Get("", "web", &fetchedTemplate))
+	fetchedTemplate.Spec.Image = "nginx:1.20"
+	fetchedTemplate.Generation = 3
+	assert.Nil(t, resourceManager.Update(&fetchedTemplate))
+
+	_, err = resourceManager.RolloutComponentTemplate("web")
+	assert.Nil(t, err)
+
+	assert.Nil(t, resourceManager.Get("app", "web", &fetched))
+	assert.Equal(t, "nginx:1.20", fetched.Spec.Image)
+	assert.Equal(t, "nginx:1.20", fetched.Annotations[v1alpha1.ComponentTemplateImageAnnotation])
+}
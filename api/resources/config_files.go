@@ -0,0 +1,255 @@
+package resources
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/kalmhq/kalm/controller/lib/files"
+	"github.com/pmezard/go-difflib/difflib"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Files of direct configs are generated from component specs by the controller
+const ConfigFilesReservedDir = "/kalm-direct-configs"
+
+type ConfigFileMove struct {
+	OldPath string `json:"oldPath"`
+	NewPath string `json:"newPath"`
+}
+
+// ValidateConfigFilePath checks the path can be edited through the api
+func ValidateConfigFilePath(path string) error {
+	if err := files.ValidateFilePath(path); err != nil {
+		return err
+	}
+
+	if path == "/" {
+		return fmt.Errorf("can't edit the root dir")
+	}
+
+	if path == ConfigFilesReservedDir || strings.HasPrefix(path, ConfigFilesReservedDir+"/") {
+		return fmt.Errorf("files under %s are managed by components", ConfigFilesReservedDir)
+	}
+
+	return nil
+}
+
+// GetConfigFileTree returns the file or dir at path, with all its descendants.
+// Like secret envs, contents of files stored in secrets are never returned.
+func (resourceManager *ResourceManager) GetConfigFileTree(namespace, path string) (*files.FileItem, error) {
+	store, err := files.LoadStore(resourceManager.ctx, resourceManager.Client, namespace)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return getConfigFileTree(store, path)
+}
+
+func (resourceManager *ResourceManager) CreateConfigFile(namespace, author string, file *files.File) (*files.FileItem, error) {
+	store, err := resourceManager.updateConfigFiles(namespace, author, func(store *files.Store) error {
+		return store.AddFile(file)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return getConfigFileTree(store, file.Path)
+}
+
+// UpdateConfigFile replaces the content of an existing file
+func (resourceManager *ResourceManager) UpdateConfigFile(namespace, author string, file *files.File) (*files.FileItem, error) {
+	store, err := resourceManager.updateConfigFiles(namespace, author, func(store *files.Store) error {
+		return store.UpdateFile(file)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return getConfigFileTree(store, file.Path)
+}
+
+// MoveConfigFile moves a file or a dir with all its descendants to a new path
+func (resourceManager *ResourceManager) MoveConfigFile(namespace, author string, move *ConfigFileMove) (*files.FileItem, error) {
+	store, err := resourceManager.updateConfigFiles(namespace, author, func(store *files.Store) error {
+		return store.MoveFile(move.OldPath, move.NewPath)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return getConfigFileTree(store, move.NewPath)
+}
+
+func (resourceManager *ResourceManager) DeleteConfigFile(namespace, author, path string) error {
+	_, err := resourceManager.updateConfigFiles(namespace, author, func(store *files.Store) error {
+		return store.DeleteFile(path)
+	})
+
+	return err
+}
+
+// updateConfigFiles applies the change on the latest files, the kalm-files config map and its shards.
+// Components and users edit the files at the same time, so the change is retried on conflicts.
+func (resourceManager *ResourceManager) updateConfigFiles(namespace, author string, change func(store *files.Store) error) (*files.Store, error) {
+	var store *files.Store
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		if store, err = files.LoadStore(resourceManager.ctx, resourceManager.Client, namespace); err != nil {
+			return err
+		}
+
+		store.Author = author
+
+		if err := change(store); err != nil {
+			return err
+		}
+
+		return store.Save(resourceManager.ctx, resourceManager.Client)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+type ConfigFileRevision struct {
+	Revision   int         `json:"revision"`
+	Author     string      `json:"author,omitempty"`
+	Timestamp  metaV1.Time `json:"timestamp,omitempty"`
+	RollbackOf int         `json:"rollbackOf,omitempty"`
+	Secret     bool        `json:"secret,omitempty"`
+	Base64     bool        `json:"base64,omitempty"`
+
+	// unified diff against the previous revision, empty if either of them is stored in secrets
+	Diff string `json:"diff,omitempty"`
+}
+
+// ConfigFileRollback restores a file to a revision, or all files in a dir to their contents at the time
+type ConfigFileRollback struct {
+	Path     string       `json:"path"`
+	Revision int          `json:"revision,omitempty"`
+	Time     *metaV1.Time `json:"time,omitempty"`
+}
+
+// ListConfigFileRevisions returns revisions of a file, the latest first
+func (resourceManager *ResourceManager) ListConfigFileRevisions(namespace, path string) ([]*ConfigFileRevision, error) {
+	store, err := files.LoadStore(resourceManager.ctx, resourceManager.Client, namespace)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := store.Content(path); err != nil {
+		return nil, err
+	}
+
+	revisions, err := store.Revisions(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*ConfigFileRevision, 0, len(revisions))
+	var previous []byte
+	var previousSecret bool
+
+	for _, revision := range revisions {
+		content, ref, err := store.RevisionContent(path, revision)
+
+		if err != nil {
+			return nil, err
+		}
+
+		item := &ConfigFileRevision{
+			Revision:   revision.Revision,
+			Author:     revision.Author,
+			Timestamp:  revision.Timestamp,
+			RollbackOf: revision.RollbackOf,
+			Secret:     ref != nil && ref.Kind == files.ShardKindSecret,
+			Base64:     ref != nil && ref.Binary,
+		}
+
+		// a diff with a secret revision on either side would show the secret content
+		if !item.Secret && !previousSecret {
+			item.Diff = diffConfigFile(path, previous, content, item.Base64)
+		}
+
+		previous = content
+		previousSecret = item.Secret
+
+		if item.Secret {
+			previous = nil
+		}
+		res = append([]*ConfigFileRevision{item}, res...)
+	}
+
+	return res, nil
+}
+
+func diffConfigFile(path string, previous, content []byte, binary bool) string {
+	if binary {
+		if bytes.Equal(previous, content) {
+			return ""
+		}
+
+		return fmt.Sprintf("Binary files differ: %s\n", path)
+	}
+
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(previous)),
+		B:        difflib.SplitLines(string(content)),
+		FromFile: path,
+		ToFile:   path,
+		Context:  3,
+	})
+
+	return diff
+}
+
+// RollbackConfigFile restores files, pods mounting them are restarted as their contents change.
+func (resourceManager *ResourceManager) RollbackConfigFile(namespace, author string, rollback *ConfigFileRollback) (*files.FileItem, error) {
+	store, err := resourceManager.updateConfigFiles(namespace, author, func(store *files.Store) error {
+		if rollback.Time != nil {
+			_, err := store.RollbackDir(rollback.Path, rollback.Time.Time)
+			return err
+		}
+
+		return store.RollbackFile(rollback.Path, rollback.Revision)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return getConfigFileTree(store, rollback.Path)
+}
+
+func getConfigFileTree(store *files.Store, path string) (*files.FileItem, error) {
+	root, err := store.GetFileItemTree(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hideSecretContents(root)
+
+	return root, nil
+}
+
+func hideSecretContents(item *files.FileItem) {
+	if item.Secret {
+		item.Content = ""
+	}
+
+	for _, child := range item.Children {
+		hideSecretContents(child)
+	}
+}
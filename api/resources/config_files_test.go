@@ -0,0 +1,35 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kalmhq/kalm/controller/lib/files"
+	"gotest.tools/assert"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestConfigFileRevisionsDontDiffSecrets(t *testing.T) {
+	resourceManager := &ResourceManager{
+		ctx:    context.Background(),
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme),
+	}
+
+	_, err := resourceManager.CreateConfigFile("default", "foo@bar", &files.File{Path: "/app.conf", Content: "public"})
+	assert.NilError(t, err)
+	_, err = resourceManager.UpdateConfigFile("default", "foo@bar", &files.File{Path: "/app.conf", Content: "private", Secret: true})
+	assert.NilError(t, err)
+	_, err = resourceManager.UpdateConfigFile("default", "foo@bar", &files.File{Path: "/app.conf", Content: "public again"})
+	assert.NilError(t, err)
+
+	revisions, err := resourceManager.ListConfigFileRevisions("default", "/app.conf")
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(revisions))
+
+	// the latest first
+	assert.Equal(t, "", revisions[0].Diff)
+	assert.Equal(t, true, revisions[1].Secret)
+	assert.Equal(t, "", revisions[1].Diff)
+	assert.Assert(t, revisions[2].Diff != "")
+}
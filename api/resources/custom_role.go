@@ -0,0 +1,69 @@
+package resources
+
+import (
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type CustomRole struct {
+	*v1alpha1.CustomRoleSpec `json:",inline"`
+	Name                     string `json:"name" validate:"required"`
+}
+
+func BuildCustomRoleFromResource(customRole *v1alpha1.CustomRole) *CustomRole {
+	return &CustomRole{
+		CustomRoleSpec: &customRole.Spec,
+		Name:           customRole.Name,
+	}
+}
+
+func (resourceManager *ResourceManager) ListCustomRoles() ([]*CustomRole, error) {
+	var customRoleList v1alpha1.CustomRoleList
+
+	if err := resourceManager.List(&customRoleList); err != nil {
+		return nil, err
+	}
+
+	res := make([]*CustomRole, 0, len(customRoleList.Items))
+
+	for i := range customRoleList.Items {
+		res = append(res, BuildCustomRoleFromResource(&customRoleList.Items[i]))
+	}
+
+	return res, nil
+}
+
+func (resourceManager *ResourceManager) CreateCustomRole(customRole *CustomRole) (*CustomRole, error) {
+	role := &v1alpha1.CustomRole{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: customRole.Name,
+		},
+		Spec: *customRole.CustomRoleSpec,
+	}
+
+	if err := resourceManager.Create(role); err != nil {
+		return nil, err
+	}
+
+	return BuildCustomRoleFromResource(role), nil
+}
+
+func (resourceManager *ResourceManager) UpdateCustomRole(customRole *CustomRole) (*CustomRole, error) {
+	var role v1alpha1.CustomRole
+
+	if err := resourceManager.Get("", customRole.Name, &role); err != nil {
+		return nil, err
+	}
+
+	role.Spec = *customRole.CustomRoleSpec
+
+	if err := resourceManager.Update(&role); err != nil {
+		return nil, err
+	}
+
+	return BuildCustomRoleFromResource(&role), nil
+}
+
+func (resourceManager *ResourceManager) DeleteCustomRole(name string) error {
+	return resourceManager.Delete(&v1alpha1.CustomRole{ObjectMeta: metaV1.ObjectMeta{Name: name}})
+}
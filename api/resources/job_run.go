@@ -0,0 +1,350 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/kalmhq/kalm/controller/controllers"
+	batchV1 "k8s.io/api/batch/v1"
+	batchV1Beta1 "k8s.io/api/batch/v1beta1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type JobRunStatus string
+
+const (
+	JobRunStatusPending   JobRunStatus = "Pending"
+	JobRunStatusRunning   JobRunStatus = "Running"
+	JobRunStatusSucceeded JobRunStatus = "Succeeded"
+	JobRunStatusFailed    JobRunStatus = "Failed"
+)
+
+type JobRun struct {
+	Name                string       `json:"name"`
+	Run                 int          `json:"run"`
+	Status              JobRunStatus `json:"status"`
+	Message             string       `json:"message,omitempty"`
+	Active              int32        `json:"active"`
+	Succeeded           int32        `json:"succeeded"`
+	Failed              int32        `json:"failed"`
+	CreationTimestamp   int64        `json:"createTimestamp"`
+	StartTimestamp      int64        `json:"startTimestamp"`
+	CompletionTimestamp int64        `json:"completionTimestamp"`
+	Pods                []string     `json:"pods"`
+
+	// Exit code of the main container in the latest pod of the run
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// Path of the api to get logs of the run
+	LogsPath string `json:"logsPath"`
+}
+
+type JobRunPodLog struct {
+	PodName string `json:"podName"`
+	Log     string `json:"log"`
+
+	// Logs may be unavailable, e.g. the pod is not started yet
+	Error string `json:"error,omitempty"`
+}
+
+func toTimestamp(t *metaV1.Time) int64 {
+	if t == nil {
+		return 0
+	}
+
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func buildJobRun(job *batchV1.Job, pods []coreV1.Pod) *JobRun {
+	run, _ := strconv.Atoi(job.Labels[controllers.KalmLabelJobRunKey])
+
+	jobRun := &JobRun{
+		Name:                job.Name,
+		Run:                 run,
+		Status:              JobRunStatusPending,
+		Active:              job.Status.Active,
+		Succeeded:           job.Status.Succeeded,
+		Failed:              job.Status.Failed,
+		CreationTimestamp:   toTimestamp(&job.CreationTimestamp),
+		StartTimestamp:      toTimestamp(job.Status.StartTime),
+		CompletionTimestamp: toTimestamp(job.Status.CompletionTime),
+		Pods:                []string{},
+	}
+
+	if job.Status.StartTime != nil {
+		jobRun.Status = JobRunStatusRunning
+	}
+
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != coreV1.ConditionTrue {
+			continue
+		}
+
+		switch condition.Type {
+		case batchV1.JobComplete:
+			jobRun.Status = JobRunStatusSucceeded
+		case batchV1.JobFailed:
+			jobRun.Status = JobRunStatusFailed
+			jobRun.Message = condition.Message
+		}
+	}
+
+	var latestPod *coreV1.Pod
+
+	for i := range pods {
+		pod := &pods[i]
+
+		if pod.Labels["job-name"] != job.Name {
+			continue
+		}
+
+		jobRun.Pods = append(jobRun.Pods, pod.Name)
+
+		if latestPod == nil || latestPod.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			latestPod = pod
+		}
+	}
+
+	if latestPod != nil && len(latestPod.Spec.Containers) > 0 {
+		for _, status := range latestPod.Status.ContainerStatuses {
+			if status.Name == latestPod.Spec.Containers[0].Name && status.State.Terminated != nil {
+				exitCode := status.State.Terminated.ExitCode
+				jobRun.ExitCode = &exitCode
+			}
+		}
+	}
+
+	return jobRun
+}
+
+// Jobs of job components and jobs of cronjob components are labeled with the component.
+// Names of jobs are truncated for long component names, so they are not compared.
+func isJobOfComponent(job *batchV1.Job, componentName string) bool {
+	return job.Labels[controllers.KalmLabelComponentKey] == componentName
+}
+
+// ListJobRuns returns runs of a job or cronjob component, the latest run comes first.
+// Runs deleted by ttlSecondsAfterFinished or history limits are not included.
+func (resourceManager *ResourceManager) ListJobRuns(namespace, componentName string) ([]*JobRun, error) {
+	var jobList batchV1.JobList
+
+	if err := resourceManager.List(&jobList, client.InNamespace(namespace), client.MatchingLabels{controllers.KalmLabelComponentKey: componentName}); err != nil {
+		return nil, err
+	}
+
+	var podList coreV1.PodList
+
+	if err := resourceManager.List(&podList, client.InNamespace(namespace), client.MatchingLabels{controllers.KalmLabelComponentKey: componentName}); err != nil {
+		return nil, err
+	}
+
+	res := make([]*JobRun, 0, len(jobList.Items))
+
+	for i := range jobList.Items {
+		res = append(res, buildJobRun(&jobList.Items[i], podList.Items))
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CreationTimestamp != res[j].CreationTimestamp {
+			return res[i].CreationTimestamp > res[j].CreationTimestamp
+		}
+
+		return res[i].Name > res[j].Name
+	})
+
+	return res, nil
+}
+
+// RerunJobComponent starts a new run of a job component. The controller creates the job of the new run.
+func (resourceManager *ResourceManager) RerunJobComponent(component *v1alpha1.Component) (*JobRun, error) {
+	if component.Spec.WorkloadType != v1alpha1.WorkloadTypeJob {
+		return nil, fmt.Errorf("component %s is not a job", component.Name)
+	}
+
+	var run int
+
+	// concurrent reruns must not pick the same run, the patch fails if the component is changed since it's read
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		run = controllers.GetJobRun(component)
+
+		// the annotation may be lost when the component is applied again, runs are never reused
+		if component.Status.LastJobRun > run {
+			run = component.Status.LastJobRun
+		}
+
+		run++
+
+		copied := component.DeepCopy()
+
+		if copied.Annotations == nil {
+			copied.Annotations = make(map[string]string)
+		}
+
+		copied.Annotations[v1alpha1.ComponentJobRunAnnotation] = strconv.Itoa(run)
+
+		err := resourceManager.Patch(copied, client.MergeFromWithOptions(component, client.MergeFromWithOptimisticLock{}))
+
+		if errors.IsConflict(err) {
+			component = &v1alpha1.Component{}
+
+			if err := resourceManager.Get(copied.Namespace, copied.Name, component); err != nil {
+				return err
+			}
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &JobRun{
+		Name:   controllers.GetJobNameOfRun(component.Name, run),
+		Run:    run,
+		Status: JobRunStatusPending,
+		Pods:   []string{},
+	}, nil
+}
+
+// TriggerCronJobComponent runs a cronjob component immediately, like `kubectl create job --from=cronjob/<name>`.
+// The job is owned by the cronjob, so it's counted by the history limits.
+func (resourceManager *ResourceManager) TriggerCronJobComponent(component *v1alpha1.Component) (*JobRun, error) {
+	if component.Spec.WorkloadType != v1alpha1.WorkloadTypeCronjob {
+		return nil, fmt.Errorf("component %s is not a cronjob", component.Name)
+	}
+
+	var cronJob batchV1Beta1.CronJob
+
+	if err := resourceManager.Get(component.Namespace, component.Name, &cronJob); err != nil {
+		return nil, err
+	}
+
+	annotations := map[string]string{"cronjob.kubernetes.io/instantiate": "manual"}
+
+	for k, v := range cronJob.Spec.JobTemplate.Annotations {
+		annotations[k] = v
+	}
+
+	labels := map[string]string{controllers.KalmLabelComponentKey: component.Name}
+
+	for k, v := range cronJob.Spec.JobTemplate.Labels {
+		labels[k] = v
+	}
+
+	job := &batchV1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			GenerateName: getManualJobGenerateName(cronJob.Name),
+			Namespace:    cronJob.Namespace,
+			Labels:       labels,
+			Annotations:  annotations,
+			OwnerReferences: []metaV1.OwnerReference{
+				*metaV1.NewControllerRef(&cronJob, batchV1Beta1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+
+	if err := resourceManager.Create(job); err != nil {
+		return nil, err
+	}
+
+	return buildJobRun(job, nil), nil
+}
+
+// The job controller puts the job name in a label of pods, which is limited to 63 characters.
+// The api server appends 5 random characters to the generate name.
+const manualJobNamePrefixMaxLength = validation.DNS1123LabelMaxLength - len("-manual-") - 5
+
+func getManualJobGenerateName(cronJobName string) string {
+	prefix := cronJobName
+
+	if len(prefix) > manualJobNamePrefixMaxLength {
+		prefix = prefix[:manualJobNamePrefixMaxLength]
+	}
+
+	return prefix + "-manual-"
+}
+
+// SuspendCronJobComponent suspends or resumes the schedule of a cronjob component.
+func (resourceManager *ResourceManager) SuspendCronJobComponent(component *v1alpha1.Component, suspend bool) (*v1alpha1.Component, error) {
+	if component.Spec.WorkloadType != v1alpha1.WorkloadTypeCronjob {
+		return nil, fmt.Errorf("component %s is not a cronjob", component.Name)
+	}
+
+	copied := component.DeepCopy()
+
+	if copied.Spec.CronJob == nil {
+		copied.Spec.CronJob = &v1alpha1.CronJobConfig{}
+	}
+
+	copied.Spec.CronJob.Suspend = suspend
+
+	if err := resourceManager.Patch(copied, client.MergeFrom(component)); err != nil {
+		return nil, err
+	}
+
+	return copied, nil
+}
+
+// GetJobRunLogs returns the last lines of logs of each pod in the run. Pods are filtered by canView.
+func (resourceManager *ResourceManager) GetJobRunLogs(namespace, componentName, jobName string, tailLines int64, canView func(podName string) bool) ([]JobRunPodLog, error) {
+	var job batchV1.Job
+
+	if err := resourceManager.Get(namespace, jobName, &job); err != nil {
+		return nil, err
+	}
+
+	if !isJobOfComponent(&job, componentName) {
+		return nil, fmt.Errorf("job %s is not a run of component %s", jobName, componentName)
+	}
+
+	var podList coreV1.PodList
+
+	if err := resourceManager.List(&podList, client.InNamespace(namespace), client.MatchingLabels{"job-name": jobName}); err != nil {
+		return nil, err
+	}
+
+	k8sClient, err := kubernetes.NewForConfig(resourceManager.Cfg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]JobRunPodLog, 0, len(podList.Items))
+
+	sort.Slice(podList.Items, func(i, j int) bool {
+		return podList.Items[i].Name < podList.Items[j].Name
+	})
+
+	for _, pod := range podList.Items {
+		if !canView(pod.Name) {
+			continue
+		}
+
+		logs, err := k8sClient.CoreV1().Pods(namespace).GetLogs(pod.Name, &coreV1.PodLogOptions{
+			Container: pod.Spec.Containers[0].Name,
+			TailLines: &tailLines,
+		}).DoRaw(context.Background())
+
+		podLog := JobRunPodLog{PodName: pod.Name, Log: string(logs)}
+
+		if err != nil {
+			podLog.Error = err.Error()
+		}
+
+		res = append(res, podLog)
+	}
+
+	return res, nil
+}
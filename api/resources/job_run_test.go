@@ -0,0 +1,120 @@
+package resources
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/kalmhq/kalm/controller/controllers"
+	"github.com/stretchr/testify/assert"
+	batchV1 "k8s.io/api/batch/v1"
+	batchV1Beta1 "k8s.io/api/batch/v1beta1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestBuildJobRun(t *testing.T) {
+	job := &batchV1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:   "migration-3",
+			Labels: map[string]string{controllers.KalmLabelJobRunKey: "3"},
+		},
+	}
+
+	pods := []coreV1.Pod{
+		{ObjectMeta: metaV1.ObjectMeta{Name: "migration-3-abcde", Labels: map[string]string{"job-name": "migration-3"}}},
+		{ObjectMeta: metaV1.ObjectMeta{Name: "migration-2-fghij", Labels: map[string]string{"job-name": "migration-2"}}},
+	}
+
+	run := buildJobRun(job, pods)
+	assert.Equal(t, 3, run.Run)
+	assert.Equal(t, JobRunStatusPending, run.Status)
+	assert.Equal(t, []string{"migration-3-abcde"}, run.Pods)
+
+	startTime := metaV1.Now()
+	job.Status.StartTime = &startTime
+	job.Status.Active = 1
+	assert.Equal(t, JobRunStatusRunning, buildJobRun(job, pods).Status)
+
+	job.Status.Conditions = []batchV1.JobCondition{
+		{Type: batchV1.JobFailed, Status: coreV1.ConditionTrue, Message: "Job has reached the specified backoff limit"},
+	}
+	run = buildJobRun(job, pods)
+	assert.Equal(t, JobRunStatusFailed, run.Status)
+	assert.Equal(t, "Job has reached the specified backoff limit", run.Message)
+}
+
+func TestBuildJobRunExitCodeAndOwner(t *testing.T) {
+	isController := true
+
+	// jobs of cronjobs have labels of the job template
+	job := &batchV1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:   "report-1600000000",
+			Labels: map[string]string{controllers.KalmLabelComponentKey: "report"},
+			OwnerReferences: []metaV1.OwnerReference{
+				{Kind: "CronJob", Name: "report", Controller: &isController},
+			},
+		},
+	}
+
+	assert.True(t, isJobOfComponent(job, "report"))
+	assert.False(t, isJobOfComponent(job, "other"))
+
+	older := coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:              "report-1600000000-aaaaa",
+			Labels:            map[string]string{"job-name": job.Name},
+			CreationTimestamp: metaV1.Unix(1600000000, 0),
+		},
+		Spec: coreV1.PodSpec{Containers: []coreV1.Container{{Name: "report"}}},
+		Status: coreV1.PodStatus{ContainerStatuses: []coreV1.ContainerStatus{
+			{Name: "report", State: coreV1.ContainerState{Terminated: &coreV1.ContainerStateTerminated{ExitCode: 1}}},
+		}},
+	}
+
+	newer := *older.DeepCopy()
+	newer.Name = "report-1600000000-bbbbb"
+	newer.CreationTimestamp = metaV1.Unix(1600000060, 0)
+	newer.Status.ContainerStatuses[0].State.Terminated.ExitCode = 0
+
+	run := buildJobRun(job, []coreV1.Pod{newer, older})
+	assert.Equal(t, 2, len(run.Pods))
+	assert.NotNil(t, run.ExitCode)
+	assert.Equal(t, int32(0), *run.ExitCode)
+}
+
+func TestTriggerCronJobComponent(t *testing.T) {
+	cronJob := &batchV1Beta1.CronJob{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "backup"},
+	}
+
+	resourceManager := &ResourceManager{
+		ctx:    context.Background(),
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, cronJob),
+	}
+
+	component := &v1alpha1.Component{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "backup"},
+		Spec:       v1alpha1.ComponentSpec{WorkloadType: v1alpha1.WorkloadTypeCronjob},
+	}
+
+	// triggered twice in the same second
+	first, err := resourceManager.TriggerCronJobComponent(component)
+	assert.Nil(t, err)
+	second, err := resourceManager.TriggerCronJobComponent(component)
+	assert.Nil(t, err)
+
+	assert.True(t, strings.HasPrefix(first.Name, "backup-manual-"))
+	assert.NotEqual(t, first.Name, second.Name)
+
+	// job names are used as pod labels
+	name := getManualJobGenerateName(strings.Repeat("a", 63)) + "xxxxx"
+	assert.Len(t, name, validation.DNS1123LabelMaxLength)
+	assert.Empty(t, validation.IsValidLabelValue(name))
+	assert.Equal(t, "backup-manual-", getManualJobGenerateName("backup"))
+}
@@ -2,16 +2,13 @@ package resources
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
-	"strconv"
 	"time"
 
 	"go.uber.org/zap"
 	"k8s.io/client-go/rest"
 
 	"github.com/kalmhq/kalm/api/log"
-	_ "github.com/mattn/go-sqlite3"
 	v12 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -19,11 +16,25 @@ import (
 	mclientv1beta1 "k8s.io/metrics/pkg/client/clientset/versioned/typed/metrics/v1beta1"
 )
 
-var metricDb *sql.DB
-var metricResolution = 5 * time.Second
-var metricDuration = 15 * time.Minute
+var metricStorage MetricStorage
+
+type MetricScraperOptions struct {
+	// name of a registered storage
+	Storage string
+	DSN     string
+	Tiers   string
+
+	// percent, see DefaultVolumeUsageWarningThreshold
+	VolumeUsageWarningThreshold int
+}
+
+func StartMetricScraper(ctx context.Context, cfg *rest.Config, options *MetricScraperOptions) error {
+	tiers, err := ParseMetricTiers(options.Tiers)
+	if err != nil {
+		log.Error("Invalid metric tiers", zap.Error(err))
+		return err
+	}
 
-func StartMetricScraper(ctx context.Context, cfg *rest.Config) error {
 	metricClient, err := mclientv1beta1.NewForConfig(cfg)
 	if err != nil {
 		log.Error("Init metric client error", zap.Error(err))
@@ -35,41 +46,51 @@ func StartMetricScraper(ctx context.Context, cfg *rest.Config) error {
 		return err
 	}
 
-	metricDb, err = sql.Open("sqlite3", "/tmp/metric_scraper.db")
+	storage, err := OpenMetricStorage(options.Storage, options.DSN, tiers)
 	if err != nil {
-		log.Error("Unable to open Sqlite database", zap.Error(err))
+		log.Error("Unable to open metric storage", zap.Error(err))
 		return err
 	}
-	defer metricDb.Close()
+	defer storage.Close()
 
-	// Populate tables
-	err = CreateDatabase(metricDb)
-	if err != nil {
-		log.Error("Unable to initialize database tables", zap.Error(err))
-		return err
-	}
+	metricStorage = storage
+
+	log.Info("Metric scraper started", zap.String("storage", options.Storage), zap.String("tiers", options.Tiers))
+
+	volumeUsageWatcher := newVolumeUsageWatcher(restClient, options.VolumeUsageWarningThreshold)
 
-	log.Info("Metric scraper started")
+	// Start the machine. Scrape at the resolution of the first tier
+	ticker := time.NewTicker(tiers[0].Resolution)
 
-	// Start the machine. Scrape every metricResolution
-	ticker := time.NewTicker(metricResolution)
+	volumeInterval := VolumeStatsScrapeInterval
+	if tiers[0].Resolution > volumeInterval {
+		volumeInterval = tiers[0].Resolution
+	}
+	volumeTicker := time.NewTicker(volumeInterval)
 
 	for {
 		select {
 		case <-ctx.Done():
 			ticker.Stop()
+			volumeTicker.Stop()
 			return nil
 
 		case <-ticker.C:
-			err = update(metricClient, restClient, metricDb, &metricDuration)
+			err = update(metricClient, restClient, storage)
 			if err != nil {
 				log.Error("Error updating metrics", zap.Error(err))
 			}
+
+		case <-volumeTicker.C:
+			err = updateVolumes(restClient, storage, volumeUsageWatcher)
+			if err != nil {
+				log.Error("Error updating volume metrics", zap.Error(err))
+			}
 		}
 	}
 }
 
-func update(client *mclientv1beta1.MetricsV1beta1Client, restClient *kubernetes.Clientset, db *sql.DB, metricDuration *time.Duration) error {
+func update(client *mclientv1beta1.MetricsV1beta1Client, restClient *kubernetes.Clientset, storage MetricStorage) error {
 	podMetrics, err := client.PodMetricses("").List(context.Background(), v1.ListOptions{})
 	if err != nil {
 		log.Error("Error scraping pod metrics", zap.Error(err))
@@ -89,24 +110,61 @@ func update(client *mclientv1beta1.MetricsV1beta1Client, restClient *kubernetes.
 		return err
 	}
 
-	// Insert scrapes into DB
-	err = UpdateDatabase(db, nodeMetrics, podMetrics)
+	now := time.Now()
+
+	// Insert scrapes into storage
+	err = storage.Insert(now, nodeMetricSamples(nodeMetrics), podMetricSamples(podMetrics))
 	if err != nil {
-		log.Error("Error updating database", zap.Error(err))
+		log.Error("Error updating metric storage", zap.Error(err))
 		return err
 	}
 
-	// Delete rows outside of the metricDuration time
-	err = CullDatabase(db, metricDuration)
+	// Downsample into coarser tiers and delete samples out of retentions
+	err = storage.Compact(now)
 	if err != nil {
-		log.Error("Error culling database", zap.Error(err))
+		log.Error("Error compacting metric storage", zap.Error(err))
 		return err
 	}
 
-	log.Debug(fmt.Sprintf("Database updated: %d nodes, %d pods", len(nodeMetrics.Items), len(podMetrics.Items)))
+	log.Debug(fmt.Sprintf("Metric storage updated: %d nodes, %d pods", len(nodeMetrics.Items), len(podMetrics.Items)))
 	return nil
 }
 
+func nodeMetricSamples(nodeMetrics *v1beta1.NodeMetricsList) []*MetricSample {
+	samples := make([]*MetricSample, 0, len(nodeMetrics.Items))
+
+	for _, v := range nodeMetrics.Items {
+		samples = append(samples, &MetricSample{
+			UID:    string(v.UID),
+			Name:   v.Name,
+			CPU:    v.Usage.Cpu().MilliValue(),
+			Memory: v.Usage.Memory().MilliValue() / 1000,
+		})
+	}
+
+	return samples
+}
+
+func podMetricSamples(podMetrics *v1beta1.PodMetricsList) []*MetricSample {
+	var samples []*MetricSample
+
+	for _, v := range podMetrics.Items {
+		for _, u := range v.Containers {
+			samples = append(samples, &MetricSample{
+				UID:       string(v.UID),
+				Name:      v.Name,
+				Namespace: v.Namespace,
+				Container: u.Name,
+				Component: v.Labels["kalm-component"],
+				CPU:       u.Usage.Cpu().MilliValue(),
+				Memory:    u.Usage.Memory().MilliValue() / 1000,
+			})
+		}
+	}
+
+	return samples
+}
+
 func completePodMetrics(podMetrics *v1beta1.PodMetricsList, podDetails *v12.PodList) *v1beta1.PodMetricsList {
 	podDetailsMap := make(map[string]*v12.Pod)
 	for i := range podDetails.Items {
@@ -124,34 +182,40 @@ func completePodMetrics(podMetrics *v1beta1.PodMetricsList, podDetails *v12.PodL
 	return podMetrics
 }
 
-const PodMetricSql = "select time, sum(cpu) as cpu, sum(memory) as memory from pods where name = ? and namespace = ? group by time order by time asc;"
-const ComponentMetricSql = "select time, sum(cpu) as cpu, sum(memory) as memory from pods where component = ? and namespace = ? group by time order by time asc;"
-const ApplicationMetricSql = "select time, sum(cpu) as cpu, sum(memory) as memory from pods where namespace = ? group by time order by time asc;"
-const NodeMetricSql = "select time, sum(cpu) as cpu, sum(memory) as memory from nodes where name = ? group by time order by time asc;"
-const NodesMetricSql = "select time, sum(cpu) as cpu, sum(memory) as memory from nodes group by time order by time asc;"
-
 func GetApplicationMetric(namespace string) MetricHistories {
-	return getMetricHistories(ApplicationMetricSql, namespace)
+	return GetApplicationMetricInRange(namespace, DefaultMetricRange)
+}
+
+func GetApplicationMetricInRange(namespace string, r time.Duration) MetricHistories {
+	return getMetricHistories(&MetricQuery{Kind: MetricKindPod, Namespace: namespace, Range: r})
 }
 
 func GetPodMetric(podName, namespace string) PodMetrics {
 	podMetrics := PodMetrics{
 		Name:            podName,
-		MetricHistories: getMetricHistories(PodMetricSql, podName, namespace),
+		MetricHistories: getMetricHistories(&MetricQuery{Kind: MetricKindPod, Name: podName, Namespace: namespace, Range: DefaultMetricRange}),
 	}
 
 	return podMetrics
 }
 
 func GetComponentMetric(componentName, namespace string) MetricHistories {
-	return getMetricHistories(ComponentMetricSql, componentName, namespace)
+	return GetComponentMetricInRange(componentName, namespace, DefaultMetricRange)
+}
+
+func GetComponentMetricInRange(componentName, namespace string, r time.Duration) MetricHistories {
+	return getMetricHistories(&MetricQuery{Kind: MetricKindPod, Component: componentName, Namespace: namespace, Range: r})
 }
 
 func GetFilteredNodeMetrics(nodes []string) NodesMetricHistories {
+	return GetFilteredNodeMetricsInRange(nodes, DefaultMetricRange)
+}
+
+func GetFilteredNodeMetricsInRange(nodes []string, r time.Duration) NodesMetricHistories {
 	nodeMetricHistories := make(map[string]MetricHistories)
-	nodesMetric := getMetricHistories(NodesMetricSql)
+	nodesMetric := getMetricHistories(&MetricQuery{Kind: MetricKindNode, Range: r})
 	for _, node := range nodes {
-		nodeMetric := getMetricHistories(NodeMetricSql, node)
+		nodeMetric := getMetricHistories(&MetricQuery{Kind: MetricKindNode, Name: node, Range: r})
 		nodeMetricHistories[node] = nodeMetric
 	}
 	return NodesMetricHistories{
@@ -161,183 +225,21 @@ func GetFilteredNodeMetrics(nodes []string) NodesMetricHistories {
 	}
 }
 
-func getMetricHistories(sql string, args ...interface{}) MetricHistories {
-	metricHistories := MetricHistories{}
-
-	if metricDb == nil {
+func getMetricHistories(query *MetricQuery) MetricHistories {
+	if metricStorage == nil {
 		log.Info("Metric is not available.")
-		return metricHistories
+		return MetricHistories{}
 	}
 
-	rows, err := metricDb.Query(sql, args...)
+	metricHistories, err := metricStorage.Query(query, time.Now())
 	if err != nil {
 		log.Error("Error getting metrics", zap.Error(err))
-		return metricHistories
-	}
-
-	defer rows.Close()
-
-	for rows.Next() {
-		var cpuValue string
-		var memoryValue string
-		var metricTime string
-		err = rows.Scan(&metricTime, &cpuValue, &memoryValue)
-		if err != nil {
-			return metricHistories
-		}
-
-		layout := "2006-01-02T15:04:05Z"
-		t, err := time.Parse(layout, metricTime)
-		if err != nil {
-			return metricHistories
-		}
-
-		cpuUint, _ := strconv.ParseFloat(cpuValue, 64)
-		memoryUnit, _ := strconv.ParseFloat(memoryValue, 64)
-
-		metricHistories.CPU = append(metricHistories.CPU, MetricPoint{
-			Timestamp: t,
-			Value:     cpuUint,
-		})
-		metricHistories.Memory = append(metricHistories.Memory, MetricPoint{
-			Timestamp: t,
-			Value:     memoryUnit,
-		})
-	}
-
-	err = rows.Err()
-	if err != nil {
-		return metricHistories
+		return MetricHistories{}
 	}
 
 	return metricHistories
 }
 
-/*
-	CreateDatabase creates tables for node and pod metrics
-*/
-func CreateDatabase(db *sql.DB) error {
-	sqlStmt := `
-	create table if not exists nodes (uid text, name text, cpu text, memory text, storage text, time datetime);
-	create table if not exists pods (uid text, name text, namespace text, container text, component text, cpu text, memory text, storage text, time datetime);
-	`
-	_, err := db.Exec(sqlStmt)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-/*
-	UpdateDatabase updates nodeMetrics and podMetrics with scraped data
-*/
-func UpdateDatabase(db *sql.DB, nodeMetrics *v1beta1.NodeMetricsList, podMetrics *v1beta1.PodMetricsList) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-	stmt, err := tx.Prepare("insert into nodes(uid, name, cpu, memory, storage, time) values(?, ?, ?, ?, ?, datetime('now'))")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	for _, v := range nodeMetrics.Items {
-		_, err = stmt.Exec(v.UID, v.Name, v.Usage.Cpu().MilliValue(), v.Usage.Memory().MilliValue()/1000, v.Usage.StorageEphemeral().MilliValue()/1000)
-		if err != nil {
-			return err
-		}
-	}
-
-	stmt, err = tx.Prepare("insert into pods(uid, name, namespace, container, component, cpu, memory, storage, time) values(?, ?, ?, ?, ?, ?, ?, ?, datetime('now'))")
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	for _, v := range podMetrics.Items {
-		component := ""
-		_, ok := v.ObjectMeta.Labels["kalm-component"]
-		if ok {
-			component = v.ObjectMeta.Labels["kalm-component"]
-		}
-		for _, u := range v.Containers {
-			_, err = stmt.Exec(v.UID, v.Name, v.Namespace, u.Name, component, u.Usage.Cpu().MilliValue(), u.Usage.Memory().MilliValue()/1000, u.Usage.StorageEphemeral().MilliValue()/1000)
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	err = tx.Commit()
-
-	if err != nil {
-		rberr := tx.Rollback()
-		if rberr != nil {
-			return rberr
-		}
-		return err
-	}
-
-	return nil
-}
-
-/*
-	CullDatabase deletes rows from nodes and pods based on a time window.
-*/
-func CullDatabase(db *sql.DB, window *time.Duration) error {
-	tx, err := db.Begin()
-
-	if err != nil {
-		return err
-	}
-
-	windowStr := fmt.Sprintf("-%.0f seconds", window.Seconds())
-
-	nodestmt, err := tx.Prepare("delete from nodes where time <= datetime('now', ?);")
-	if err != nil {
-		return err
-	}
-
-	defer nodestmt.Close()
-	res, err := nodestmt.Exec(windowStr)
-	if err != nil {
-		return err
-	}
-
-	affected, _ := res.RowsAffected()
-	log.Debug("Cleaning up nodes", zap.Int64("rows", affected))
-
-	podstmt, err := tx.Prepare("delete from pods where time <= datetime('now', ?);")
-
-	if err != nil {
-		return err
-	}
-
-	defer podstmt.Close()
-
-	res, err = podstmt.Exec(windowStr)
-
-	if err != nil {
-		return err
-	}
-
-	affected, _ = res.RowsAffected()
-	log.Debug(fmt.Sprintf("Cleaning up pods: %d rows removed", affected))
-	err = tx.Commit()
-
-	if err != nil {
-		rberr := tx.Rollback()
-		if rberr != nil {
-			return rberr
-		}
-		return err
-	}
-
-	return nil
-}
-
 type NodesMetricHistories struct {
 	CPU    MetricHistory              `json:"cpu"`
 	Memory MetricHistory              `json:"memory"`
@@ -0,0 +1,191 @@
+package resources
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricTier keeps samples at the resolution for the retention.
+// The first tier is scraped, the others are downsampled from the previous tier.
+type MetricTier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+const DefaultMetricTiers = "5s:1h,1m:1d,10m:30d"
+
+// Range of metrics embedded in application, component and node responses
+const DefaultMetricRange = 15 * time.Minute
+
+// ParseMetricTiers parses tiers like "5s:1h,1m:1d,10m:30d", durations also accept a "d" unit for days.
+func ParseMetricTiers(s string) ([]MetricTier, error) {
+	var tiers []MetricTier
+
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ":")
+
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid metric tier %s, expect <resolution>:<retention>", part)
+		}
+
+		resolution, err := ParseMetricDuration(fields[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		retention, err := ParseMetricDuration(fields[1])
+
+		if err != nil {
+			return nil, err
+		}
+
+		tier := MetricTier{Resolution: resolution, Retention: retention}
+
+		if resolution < time.Second || resolution%time.Second != 0 {
+			return nil, fmt.Errorf("resolution of metric tier %s must be whole seconds", part)
+		}
+
+		if retention < resolution {
+			return nil, fmt.Errorf("retention of metric tier %s is shorter than its resolution", part)
+		}
+
+		if len(tiers) > 0 {
+			previous := tiers[len(tiers)-1]
+
+			if resolution <= previous.Resolution || resolution%previous.Resolution != 0 {
+				return nil, fmt.Errorf("resolution of metric tier %s must be a multiple of the previous one", part)
+			}
+
+			if retention <= previous.Retention {
+				return nil, fmt.Errorf("retention of metric tier %s must be longer than the previous one", part)
+			}
+		}
+
+		tiers = append(tiers, tier)
+	}
+
+	return tiers, nil
+}
+
+func ParseMetricDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %s", s)
+		}
+
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(s)
+}
+
+// pickMetricTier returns the finest tier keeping samples of the range
+func pickMetricTier(tiers []MetricTier, r time.Duration) MetricTier {
+	for _, tier := range tiers {
+		if tier.Retention >= r {
+			return tier
+		}
+	}
+
+	return tiers[len(tiers)-1]
+}
+
+type MetricKind string
+
+const (
+	MetricKindNode MetricKind = "node"
+	MetricKindPod  MetricKind = "pod"
+)
+
+// MetricSample is the usage of a node, or a container of a pod
+type MetricSample struct {
+	UID       string
+	Name      string
+	Namespace string
+	Container string
+	Component string
+
+	// in millicores
+	CPU int64
+	// in bytes
+	Memory int64
+}
+
+// VolumeSample is the usage of a pvc reported by kubelet volume stats
+type VolumeSample struct {
+	Namespace string
+	PVC       string
+
+	UsedBytes      int64
+	AvailableBytes int64
+	CapacityBytes  int64
+	InodesUsed     int64
+	Inodes         int64
+}
+
+type VolumeMetricHistories struct {
+	Used       MetricHistory `json:"used"`
+	Available  MetricHistory `json:"available"`
+	InodesUsed MetricHistory `json:"inodesUsed"`
+	Inodes     MetricHistory `json:"inodes"`
+}
+
+// MetricQuery sums usages of matched nodes or pods, empty filters match all.
+type MetricQuery struct {
+	Kind      MetricKind
+	Name      string
+	Namespace string
+	Component string
+	Range     time.Duration
+}
+
+// MetricStorage keeps samples in tiers. Writes of the same object at the same time are idempotent,
+// so replicas of api server can scrape into a shared storage.
+type MetricStorage interface {
+	// Insert writes samples scraped at the time into the first tier
+	Insert(t time.Time, nodes, pods []*MetricSample) error
+
+	// Compact downsamples finished periods into coarser tiers, and removes samples out of retentions
+	Compact(now time.Time) error
+
+	Query(query *MetricQuery, now time.Time) (MetricHistories, error)
+
+	// InsertVolumes writes volume usages scraped at the time into the first tier
+	InsertVolumes(t time.Time, volumes []*VolumeSample) error
+
+	QueryVolume(namespace, pvc string, r time.Duration, now time.Time) (VolumeMetricHistories, error)
+
+	Close() error
+}
+
+// MetricStorageFactory opens a storage with the data source name, e.g. a file path or a database url
+type MetricStorageFactory func(dsn string, tiers []MetricTier) (MetricStorage, error)
+
+var metricStorageFactories = map[string]MetricStorageFactory{}
+var metricStorageFactoriesLock sync.Mutex
+
+// RegisterMetricStorage makes a storage available by the name, like drivers of database/sql
+func RegisterMetricStorage(name string, factory MetricStorageFactory) {
+	metricStorageFactoriesLock.Lock()
+	defer metricStorageFactoriesLock.Unlock()
+
+	metricStorageFactories[name] = factory
+}
+
+func OpenMetricStorage(name, dsn string, tiers []MetricTier) (MetricStorage, error) {
+	metricStorageFactoriesLock.Lock()
+	factory, exist := metricStorageFactories[name]
+	metricStorageFactoriesLock.Unlock()
+
+	if !exist {
+		return nil, fmt.Errorf("unknown metric storage %s", name)
+	}
+
+	return factory(dsn, tiers)
+}
@@ -0,0 +1,291 @@
+package resources
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	RegisterMetricStorage("sqlite3", NewSqliteMetricStorage)
+}
+
+// SqliteMetricStorage keeps samples in a sqlite file, put the file on a PVC to keep samples across restarts.
+// Times are unix seconds, truncated to the resolution of each tier.
+type SqliteMetricStorage struct {
+	db    *sql.DB
+	tiers []MetricTier
+}
+
+// a sample is identified by its keys and time, values are averaged in downsampling
+var sqliteMetricTables = []struct {
+	name    string
+	columns string
+	keys    string
+	values  []string
+}{
+	{name: "node_metrics", columns: "uid, name", keys: "uid", values: []string{"cpu", "memory"}},
+	{name: "pod_metrics", columns: "uid, name, namespace, container, component", keys: "uid, container", values: []string{"cpu", "memory"}},
+	{name: "volume_metrics", columns: "namespace, pvc", keys: "namespace, pvc", values: []string{"used", "available", "capacity", "inodes_used", "inodes"}},
+}
+
+func NewSqliteMetricStorage(dsn string, tiers []MetricTier) (MetricStorage, error) {
+	db, err := sql.Open("sqlite3", dsn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// sqlite doesn't support concurrent writes
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+	create table if not exists node_metrics (tier integer, uid text, name text, cpu real, memory real, time integer, primary key (tier, uid, time));
+	create table if not exists pod_metrics (tier integer, uid text, name text, namespace text, container text, component text, cpu real, memory real, time integer, primary key (tier, uid, container, time));
+	create index if not exists pod_metrics_namespace on pod_metrics (tier, namespace, time);
+	create table if not exists volume_metrics (tier integer, namespace text, pvc text, used real, available real, capacity real, inodes_used real, inodes real, time integer, primary key (tier, namespace, pvc, time));
+	`)
+
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &SqliteMetricStorage{db: db, tiers: tiers}, nil
+}
+
+func (s *SqliteMetricStorage) Insert(t time.Time, nodes, pods []*MetricSample) error {
+	tier := s.tiers[0]
+	bucket := t.Truncate(tier.Resolution).Unix()
+
+	return s.withTx(func(tx *sql.Tx) error {
+		nodeStmt, err := tx.Prepare("insert or replace into node_metrics (tier, uid, name, cpu, memory, time) values (0, ?, ?, ?, ?, ?)")
+
+		if err != nil {
+			return err
+		}
+
+		defer nodeStmt.Close()
+
+		for _, v := range nodes {
+			if _, err := nodeStmt.Exec(v.UID, v.Name, v.CPU, v.Memory, bucket); err != nil {
+				return err
+			}
+		}
+
+		podStmt, err := tx.Prepare("insert or replace into pod_metrics (tier, uid, name, namespace, container, component, cpu, memory, time) values (0, ?, ?, ?, ?, ?, ?, ?, ?)")
+
+		if err != nil {
+			return err
+		}
+
+		defer podStmt.Close()
+
+		for _, v := range pods {
+			if _, err := podStmt.Exec(v.UID, v.Name, v.Namespace, v.Container, v.Component, v.CPU, v.Memory, bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *SqliteMetricStorage) Compact(now time.Time) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		for i := 1; i < len(s.tiers); i++ {
+			resolution := int64(s.tiers[i].Resolution.Seconds())
+
+			// only finished periods are downsampled, unfinished ones are done in later compactions
+			end := now.Truncate(s.tiers[i].Resolution).Unix()
+
+			for _, table := range sqliteMetricTables {
+				var last sql.NullInt64
+
+				if err := tx.QueryRow("select max(time) from "+table.name+" where tier = ?", i).Scan(&last); err != nil {
+					return err
+				}
+
+				start := int64(0)
+
+				if last.Valid {
+					start = last.Int64 + resolution
+				}
+
+				if start >= end {
+					continue
+				}
+
+				averages := make([]string, len(table.values))
+
+				for j, value := range table.values {
+					averages[j] = "avg(" + value + ")"
+				}
+
+				_, err := tx.Exec(
+					"insert or replace into "+table.name+" (tier, "+table.columns+", "+strings.Join(table.values, ", ")+", time) "+
+						"select ?, "+table.columns+", "+strings.Join(averages, ", ")+", (time / ?) * ? from "+table.name+
+						" where tier = ? and time >= ? and time < ? group by "+table.keys+", time / ?",
+					i, resolution, resolution, i-1, start, end, resolution,
+				)
+
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for i, tier := range s.tiers {
+			before := now.Add(-tier.Retention).Unix()
+
+			for _, table := range sqliteMetricTables {
+				if _, err := tx.Exec("delete from "+table.name+" where tier = ? and time < ?", i, before); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *SqliteMetricStorage) Query(query *MetricQuery, now time.Time) (MetricHistories, error) {
+	metricHistories := MetricHistories{}
+
+	tier := pickMetricTier(s.tiers, query.Range)
+	tierIndex := 0
+
+	for i := range s.tiers {
+		if s.tiers[i] == tier {
+			tierIndex = i
+		}
+	}
+
+	table := "pod_metrics"
+
+	if query.Kind == MetricKindNode {
+		table = "node_metrics"
+	}
+
+	where := []string{"tier = ?", "time >= ?"}
+	args := []interface{}{tierIndex, now.Add(-query.Range).Unix()}
+
+	for column, value := range map[string]string{"name": query.Name, "namespace": query.Namespace, "component": query.Component} {
+		if value != "" {
+			where = append(where, column+" = ?")
+			args = append(args, value)
+		}
+	}
+
+	rows, err := s.db.Query("select time, sum(cpu), sum(memory) from "+table+" where "+strings.Join(where, " and ")+" group by time order by time asc", args...)
+
+	if err != nil {
+		return metricHistories, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var t int64
+		var cpu, memory float64
+
+		if err := rows.Scan(&t, &cpu, &memory); err != nil {
+			return metricHistories, err
+		}
+
+		metricHistories.CPU = append(metricHistories.CPU, MetricPoint{
+			Timestamp: time.Unix(t, 0).UTC(),
+			Value:     cpu,
+		})
+		metricHistories.Memory = append(metricHistories.Memory, MetricPoint{
+			Timestamp: time.Unix(t, 0).UTC(),
+			Value:     memory,
+		})
+	}
+
+	return metricHistories, rows.Err()
+}
+
+func (s *SqliteMetricStorage) InsertVolumes(t time.Time, volumes []*VolumeSample) error {
+	bucket := t.Truncate(s.tiers[0].Resolution).Unix()
+
+	return s.withTx(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare("insert or replace into volume_metrics (tier, namespace, pvc, used, available, capacity, inodes_used, inodes, time) values (0, ?, ?, ?, ?, ?, ?, ?, ?)")
+
+		if err != nil {
+			return err
+		}
+
+		defer stmt.Close()
+
+		for _, v := range volumes {
+			if _, err := stmt.Exec(v.Namespace, v.PVC, v.UsedBytes, v.AvailableBytes, v.CapacityBytes, v.InodesUsed, v.Inodes, bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *SqliteMetricStorage) QueryVolume(namespace, pvc string, r time.Duration, now time.Time) (VolumeMetricHistories, error) {
+	histories := VolumeMetricHistories{}
+
+	tier := pickMetricTier(s.tiers, r)
+	tierIndex := 0
+
+	for i := range s.tiers {
+		if s.tiers[i] == tier {
+			tierIndex = i
+		}
+	}
+
+	rows, err := s.db.Query(
+		"select time, used, available, inodes_used, inodes from volume_metrics where tier = ? and time >= ? and namespace = ? and pvc = ? order by time asc",
+		tierIndex, now.Add(-r).Unix(), namespace, pvc,
+	)
+
+	if err != nil {
+		return histories, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var t int64
+		var used, available, inodesUsed, inodes float64
+
+		if err := rows.Scan(&t, &used, &available, &inodesUsed, &inodes); err != nil {
+			return histories, err
+		}
+
+		timestamp := time.Unix(t, 0).UTC()
+		histories.Used = append(histories.Used, MetricPoint{Timestamp: timestamp, Value: used})
+		histories.Available = append(histories.Available, MetricPoint{Timestamp: timestamp, Value: available})
+		histories.InodesUsed = append(histories.InodesUsed, MetricPoint{Timestamp: timestamp, Value: inodesUsed})
+		histories.Inodes = append(histories.Inodes, MetricPoint{Timestamp: timestamp, Value: inodes})
+	}
+
+	return histories, rows.Err()
+}
+
+func (s *SqliteMetricStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *SqliteMetricStorage) withTx(f func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
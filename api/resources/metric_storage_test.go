@@ -0,0 +1,126 @@
+package resources
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMetricTiers(t *testing.T) {
+	tiers, err := ParseMetricTiers(DefaultMetricTiers)
+	assert.Nil(t, err)
+	assert.Equal(t, []MetricTier{
+		{Resolution: 5 * time.Second, Retention: time.Hour},
+		{Resolution: time.Minute, Retention: 24 * time.Hour},
+		{Resolution: 10 * time.Minute, Retention: 30 * 24 * time.Hour},
+	}, tiers)
+
+	for _, s := range []string{"", "5s", "500ms:1h", "5s:1h,7s:1d", "5s:1h,1m:30m", "1m:1h,5s:1d", "5s:1x"} {
+		_, err := ParseMetricTiers(s)
+		assert.NotNil(t, err, s)
+	}
+
+	assert.Equal(t, 5*time.Second, pickMetricTier(tiers, 15*time.Minute).Resolution)
+	assert.Equal(t, time.Minute, pickMetricTier(tiers, 6*time.Hour).Resolution)
+	assert.Equal(t, 10*time.Minute, pickMetricTier(tiers, 365*24*time.Hour).Resolution)
+}
+
+func TestSqliteMetricStorage(t *testing.T) {
+	tiers, err := ParseMetricTiers("5s:1m,20s:2m")
+	assert.Nil(t, err)
+
+	storage, err := OpenMetricStorage("sqlite3", filepath.Join(t.TempDir(), "metrics.db"), tiers)
+	assert.Nil(t, err)
+	defer storage.Close()
+
+	start := time.Unix(1000000000, 0)
+	nodes := []*MetricSample{{UID: "n1", Name: "node-1", CPU: 100, Memory: 1000}}
+	pods := []*MetricSample{
+		{UID: "p1", Name: "pod-1", Namespace: "ns", Container: "a", Component: "web", CPU: 10, Memory: 100},
+		{UID: "p1", Name: "pod-1", Namespace: "ns", Container: "b", Component: "web", CPU: 20, Memory: 200},
+		{UID: "p2", Name: "pod-2", Namespace: "other", Container: "a", Component: "web", CPU: 40, Memory: 400},
+	}
+
+	for i := 0; i < 8; i++ {
+		now := start.Add(time.Duration(i) * 5 * time.Second)
+		nodes[0].CPU = int64(100 + i*10)
+
+		assert.Nil(t, storage.Insert(now, nodes, pods))
+		// writes are idempotent
+		assert.Nil(t, storage.Insert(now, nodes, pods))
+		assert.Nil(t, storage.Compact(now))
+	}
+
+	now := start.Add(35 * time.Second)
+
+	// pods of a namespace are summed
+	histories, err := storage.Query(&MetricQuery{Kind: MetricKindPod, Namespace: "ns", Range: time.Minute}, now)
+	assert.Nil(t, err)
+	assert.Len(t, histories.CPU, 8)
+	assert.Equal(t, float64(30), histories.CPU[0].Value)
+	assert.Equal(t, float64(300), histories.Memory[0].Value)
+
+	histories, err = storage.Query(&MetricQuery{Kind: MetricKindPod, Component: "web", Range: time.Minute}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(70), histories.CPU[0].Value)
+
+	// finished periods are downsampled into the coarser tier
+	histories, err = storage.Query(&MetricQuery{Kind: MetricKindNode, Name: "node-1", Range: 2 * time.Minute}, now)
+	assert.Nil(t, err)
+	assert.Len(t, histories.CPU, 1)
+	assert.Equal(t, start.Unix(), histories.CPU[0].Timestamp.Unix())
+	assert.Equal(t, float64(115), histories.CPU[0].Value)
+
+	histories, err = storage.Query(&MetricQuery{Kind: MetricKindPod, Name: "pod-1", Namespace: "ns", Range: 2 * time.Minute}, now)
+	assert.Nil(t, err)
+	assert.Len(t, histories.CPU, 1)
+	assert.Equal(t, float64(30), histories.CPU[0].Value)
+
+	// samples out of retentions are removed
+	assert.Nil(t, storage.Compact(start.Add(5*time.Minute)))
+
+	histories, err = storage.Query(&MetricQuery{Kind: MetricKindNode, Range: 10 * time.Minute}, start.Add(5*time.Minute))
+	assert.Nil(t, err)
+	assert.Len(t, histories.CPU, 0)
+}
+
+func TestSqliteVolumeMetricStorage(t *testing.T) {
+	tiers, err := ParseMetricTiers("1m:10m,5m:1h")
+	assert.Nil(t, err)
+
+	storage, err := OpenMetricStorage("sqlite3", filepath.Join(t.TempDir(), "metrics.db"), tiers)
+	assert.Nil(t, err)
+	defer storage.Close()
+
+	start := time.Unix(1000000200, 0)
+	volumes := []*VolumeSample{
+		{Namespace: "ns", PVC: "data", CapacityBytes: 1000, Inodes: 100},
+		{Namespace: "ns", PVC: "logs", CapacityBytes: 1000, UsedBytes: 900},
+	}
+
+	for i := 0; i < 6; i++ {
+		now := start.Add(time.Duration(i) * time.Minute)
+		volumes[0].UsedBytes = int64(100 * (i + 1))
+		volumes[0].AvailableBytes = volumes[0].CapacityBytes - volumes[0].UsedBytes
+
+		assert.Nil(t, storage.InsertVolumes(now, volumes))
+		assert.Nil(t, storage.Compact(now))
+	}
+
+	now := start.Add(6 * time.Minute)
+
+	histories, err := storage.QueryVolume("ns", "data", 10*time.Minute, now)
+	assert.Nil(t, err)
+	assert.Len(t, histories.Used, 6)
+	assert.Equal(t, float64(100), histories.Used[0].Value)
+	assert.Equal(t, float64(400), histories.Available[5].Value)
+	assert.Equal(t, float64(100), histories.Inodes[0].Value)
+
+	// finished periods are downsampled into the coarser tier
+	histories, err = storage.QueryVolume("ns", "data", time.Hour, now)
+	assert.Nil(t, err)
+	assert.Len(t, histories.Used, 1)
+	assert.Equal(t, float64(300), histories.Used[0].Value)
+}
@@ -34,6 +34,7 @@ type Node struct {
 	Roles              []string           `json:"roles"`
 	InternalIP         string             `json:"internalIP"`
 	ExternalIP         string             `json:"externalIP"`
+	Taints             []coreV1.Taint     `json:"taints"`
 	AllocatedResources AllocatedResources `json:"allocatedResources"`
 }
 
@@ -141,6 +142,46 @@ func (resourceManager *ResourceManager) UncordonNode(node *coreV1.Node) error {
 	return nil
 }
 
+// Add a taint to the node. An existing taint with the same key and effect is replaced.
+func (resourceManager *ResourceManager) AddNodeTaint(node *coreV1.Node, taint coreV1.Taint) (*coreV1.Node, error) {
+	nodeCopy := node.DeepCopy()
+	nodeCopy.Spec.Taints = []coreV1.Taint{taint}
+
+	for _, t := range node.Spec.Taints {
+		if t.Key == taint.Key && t.Effect == taint.Effect {
+			continue
+		}
+
+		nodeCopy.Spec.Taints = append(nodeCopy.Spec.Taints, t)
+	}
+
+	if err := resourceManager.Patch(nodeCopy, client.MergeFrom(node)); err != nil {
+		return nil, err
+	}
+
+	return nodeCopy, nil
+}
+
+// Remove taints with the key from the node. If effect is blank, taints of all effects are removed.
+func (resourceManager *ResourceManager) RemoveNodeTaint(node *coreV1.Node, key string, effect coreV1.TaintEffect) (*coreV1.Node, error) {
+	nodeCopy := node.DeepCopy()
+	nodeCopy.Spec.Taints = nil
+
+	for _, t := range node.Spec.Taints {
+		if t.Key == key && (effect == "" || t.Effect == effect) {
+			continue
+		}
+
+		nodeCopy.Spec.Taints = append(nodeCopy.Spec.Taints, t)
+	}
+
+	if err := resourceManager.Patch(nodeCopy, client.MergeFrom(node)); err != nil {
+		return nil, err
+	}
+
+	return nodeCopy, nil
+}
+
 func (resourceManager *ResourceManager) BuildNodeResponse(node *coreV1.Node) *Node {
 	histories := GetFilteredNodeMetrics([]string{node.Name})
 
@@ -155,6 +196,7 @@ func (resourceManager *ResourceManager) BuildNodeResponse(node *coreV1.Node) *No
 		StatusTexts:        getNodeRunningStatus(node),
 		InternalIP:         getNodeInternalIP(node),
 		ExternalIP:         getNodeExternalIP(node),
+		Taints:             node.Spec.Taints,
 		AllocatedResources: *resourceManager.getAllocatedResources(node),
 	}
 }
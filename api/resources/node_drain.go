@@ -0,0 +1,308 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	policyV1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultNodeDrainTimeoutSeconds = 300
+
+	// Evictions refused by disruption budgets are retried with this interval
+	nodeDrainRetryInterval = 5 * time.Second
+)
+
+type DrainNodeOptions struct {
+	// Overrides the termination grace period of pods. Use the pod's own grace period if not set.
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
+
+	// How long to wait for all pods to be evicted. Defaults to DefaultNodeDrainTimeoutSeconds.
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
+
+	// Delete pods not managed by a controller. They are gone for good, nothing recreates them.
+	Force bool `json:"force,omitempty"`
+}
+
+type NodeDrainPhase string
+
+const (
+	NodeDrainPhaseCordoned  NodeDrainPhase = "Cordoned"
+	NodeDrainPhaseSkipped   NodeDrainPhase = "Skipped"
+	NodeDrainPhaseEvicting  NodeDrainPhase = "Evicting"
+	NodeDrainPhaseBlocked   NodeDrainPhase = "Blocked"
+	NodeDrainPhaseEvicted   NodeDrainPhase = "Evicted"
+	NodeDrainPhaseCompleted NodeDrainPhase = "Completed"
+	NodeDrainPhaseFailed    NodeDrainPhase = "Failed"
+)
+
+type NodeDrainProgress struct {
+	Node      string         `json:"node"`
+	Phase     NodeDrainPhase `json:"phase"`
+	Namespace string         `json:"namespace,omitempty"`
+	Pod       string         `json:"pod,omitempty"`
+	Message   string         `json:"message,omitempty"`
+}
+
+// Pods of daemonsets are recreated on the node right away, and mirror pods can't be deleted through the api server.
+func shouldSkipPodInDrain(pod *coreV1.Pod) (bool, string) {
+	if _, isMirror := pod.Annotations[coreV1.MirrorPodAnnotationKey]; isMirror {
+		return true, "mirror pod"
+	}
+
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller != nil && *owner.Controller && owner.Kind == "DaemonSet" {
+			return true, "managed by daemonset " + owner.Name
+		}
+	}
+
+	return false, ""
+}
+
+func isPodManagedByController(pod *coreV1.Pod) bool {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller != nil && *owner.Controller {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Finished pods are not protected by disruption budgets, they are deleted without eviction.
+func isPodFinished(pod *coreV1.Pod) bool {
+	return pod.Status.Phase == coreV1.PodSucceeded || pod.Status.Phase == coreV1.PodFailed
+}
+
+// Like kubectl drain, nothing is evicted if there are running pods not managed by a controller, unless forced.
+// Each refused pod is reported.
+func filterPodsToDrain(nodeName string, pods []coreV1.Pod, force bool, progress func(*NodeDrainProgress)) ([]coreV1.Pod, error) {
+	var res []coreV1.Pod
+	var unmanaged []string
+
+	for i := range pods {
+		pod := pods[i]
+
+		if skip, reason := shouldSkipPodInDrain(&pod); skip {
+			progress(&NodeDrainProgress{Node: nodeName, Phase: NodeDrainPhaseSkipped, Namespace: pod.Namespace, Pod: pod.Name, Message: reason})
+			continue
+		}
+
+		if !force && !isPodFinished(&pod) && !isPodManagedByController(&pod) {
+			unmanaged = append(unmanaged, pod.Namespace+"/"+pod.Name)
+			progress(&NodeDrainProgress{
+				Node:      nodeName,
+				Phase:     NodeDrainPhaseFailed,
+				Namespace: pod.Namespace,
+				Pod:       pod.Name,
+				Message:   "pod is not managed by a controller and won't be recreated, use force to delete it",
+			})
+			continue
+		}
+
+		res = append(res, pod)
+	}
+
+	if len(unmanaged) > 0 {
+		return nil, fmt.Errorf("cannot delete pods not managed by a controller (use force to override): %s", strings.Join(unmanaged, ", "))
+	}
+
+	return res, nil
+}
+
+// DrainNode cordons the node and evicts its pods through the eviction api, so PodDisruptionBudgets are respected.
+// Pods are evicted concurrently, evictions refused by budgets are retried per pod until the timeout.
+// Progress is reported for each pod.
+func (resourceManager *ResourceManager) DrainNode(ctx context.Context, node *coreV1.Node, options *DrainNodeOptions, progress func(*NodeDrainProgress)) error {
+	timeoutSeconds := options.TimeoutSeconds
+
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = DefaultNodeDrainTimeoutSeconds
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	err := resourceManager.drainNode(ctx, node, options, progress)
+
+	if err != nil {
+		progress(&NodeDrainProgress{Node: node.Name, Phase: NodeDrainPhaseFailed, Message: err.Error()})
+		return err
+	}
+
+	progress(&NodeDrainProgress{Node: node.Name, Phase: NodeDrainPhaseCompleted})
+	return nil
+}
+
+func (resourceManager *ResourceManager) drainNode(ctx context.Context, node *coreV1.Node, options *DrainNodeOptions, progress func(*NodeDrainProgress)) error {
+	if !node.Spec.Unschedulable {
+		if err := resourceManager.CordonNode(node); err != nil {
+			return err
+		}
+	}
+
+	progress(&NodeDrainProgress{Node: node.Name, Phase: NodeDrainPhaseCordoned})
+
+	var podList coreV1.PodList
+
+	if err := resourceManager.List(&podList, client.MatchingFields{"spec.nodeName": node.Name}); err != nil {
+		return err
+	}
+
+	pods, err := filterPodsToDrain(node.Name, podList.Items, options.Force, progress)
+
+	if err != nil {
+		return err
+	}
+
+	k8sClient, err := kubernetes.NewForConfig(resourceManager.Cfg)
+
+	if err != nil {
+		return err
+	}
+
+	return resourceManager.evictPods(ctx, k8sClient, node.Name, pods, options.GracePeriodSeconds, progress)
+}
+
+// evictPods evicts pods concurrently, so a pod blocked by its disruption budget doesn't hold up others.
+// Finished pods are deleted directly. The first failure cancels evictions of other pods.
+// Progress is reported one at a time.
+func (resourceManager *ResourceManager) evictPods(ctx context.Context, k8sClient kubernetes.Interface, nodeName string, pods []coreV1.Pod, gracePeriodSeconds *int64, progress func(*NodeDrainProgress)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mutex sync.Mutex
+	var firstErr error
+
+	report := func(phase NodeDrainPhase, pod *coreV1.Pod, msg string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		progress(&NodeDrainProgress{Node: nodeName, Phase: phase, Namespace: pod.Namespace, Pod: pod.Name, Message: msg})
+	}
+
+	fail := func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	wg := sync.WaitGroup{}
+
+	for i := range pods {
+		pod := &pods[i]
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if isPodFinished(pod) {
+				if err := deleteFinishedPod(ctx, k8sClient, pod, gracePeriodSeconds); err != nil {
+					fail(fmt.Errorf("delete pod %s/%s failed: %s", pod.Namespace, pod.Name, err.Error()))
+					return
+				}
+			} else if err := evictPod(ctx, k8sClient, pod, gracePeriodSeconds, func(msg string) {
+				report(NodeDrainPhaseBlocked, pod, msg)
+			}); err != nil {
+				fail(fmt.Errorf("evict pod %s/%s failed: %s", pod.Namespace, pod.Name, err.Error()))
+				return
+			}
+
+			report(NodeDrainPhaseEvicting, pod, "")
+
+			if err := resourceManager.waitForPodDeleted(ctx, pod); err != nil {
+				fail(fmt.Errorf("wait for pod %s/%s to be deleted failed: %s", pod.Namespace, pod.Name, err.Error()))
+				return
+			}
+
+			report(NodeDrainPhaseEvicted, pod, "")
+		}()
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
+func deleteFinishedPod(ctx context.Context, k8sClient kubernetes.Interface, pod *coreV1.Pod, gracePeriodSeconds *int64) error {
+	err := k8sClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metaV1.DeleteOptions{
+		GracePeriodSeconds: gracePeriodSeconds,
+		Preconditions:      &metaV1.Preconditions{UID: &pod.UID},
+	})
+
+	// the pod is gone, or replaced by a new one with the same name
+	if errors.IsNotFound(err) || errors.IsConflict(err) {
+		return nil
+	}
+
+	return err
+}
+
+func evictPod(ctx context.Context, k8sClient kubernetes.Interface, pod *coreV1.Pod, gracePeriodSeconds *int64, blocked func(msg string)) error {
+	eviction := &policyV1beta1.Eviction{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+		DeleteOptions: &metaV1.DeleteOptions{
+			GracePeriodSeconds: gracePeriodSeconds,
+		},
+	}
+
+	for {
+		err := k8sClient.PolicyV1beta1().Evictions(pod.Namespace).Evict(ctx, eviction)
+
+		if err == nil || errors.IsNotFound(err) {
+			return nil
+		}
+
+		// the eviction would break a disruption budget
+		if !errors.IsTooManyRequests(err) {
+			return err
+		}
+
+		blocked(err.Error())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(nodeDrainRetryInterval):
+		}
+	}
+}
+
+// A pod is deleted when it's not found, or a new pod with the same name is created, e.g. by a statefulset.
+func (resourceManager *ResourceManager) waitForPodDeleted(ctx context.Context, pod *coreV1.Pod) error {
+	for {
+		var current coreV1.Pod
+
+		err := resourceManager.Client.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}, &current)
+
+		if errors.IsNotFound(err) || (err == nil && current.UID != pod.UID) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package resources
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	policyV1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestShouldSkipPodInDrain(t *testing.T) {
+	isController := true
+
+	daemonSetPod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			OwnerReferences: []metaV1.OwnerReference{{Kind: "DaemonSet", Name: "fluentd", Controller: &isController}},
+		},
+	}
+
+	mirrorPod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Annotations: map[string]string{coreV1.MirrorPodAnnotationKey: "hash"},
+		},
+	}
+
+	replicaSetPod := &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			OwnerReferences: []metaV1.OwnerReference{{Kind: "ReplicaSet", Name: "web-7d9f", Controller: &isController}},
+		},
+	}
+
+	skip, reason := shouldSkipPodInDrain(daemonSetPod)
+	assert.True(t, skip)
+	assert.Equal(t, "managed by daemonset fluentd", reason)
+
+	skip, _ = shouldSkipPodInDrain(mirrorPod)
+	assert.True(t, skip)
+
+	skip, _ = shouldSkipPodInDrain(replicaSetPod)
+	assert.False(t, skip)
+
+	skip, _ = shouldSkipPodInDrain(&coreV1.Pod{})
+	assert.False(t, skip)
+}
+
+func TestFilterPodsToDrain(t *testing.T) {
+	isController := true
+
+	pods := []coreV1.Pod{
+		{ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "web", OwnerReferences: []metaV1.OwnerReference{{Kind: "ReplicaSet", Name: "web-7d9f", Controller: &isController}}}},
+		{ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "bare"}},
+		{ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "finished"}, Status: coreV1.PodStatus{Phase: coreV1.PodSucceeded}},
+	}
+
+	var refused []string
+
+	res, err := filterPodsToDrain("node-1", pods, false, func(progress *NodeDrainProgress) {
+		if progress.Phase == NodeDrainPhaseFailed {
+			refused = append(refused, progress.Pod)
+		}
+	})
+
+	// bare pods are not recreated, nothing is evicted without force
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "app/bare")
+	assert.Nil(t, res)
+	assert.Equal(t, []string{"bare"}, refused)
+
+	res, err = filterPodsToDrain("node-1", pods, true, func(*NodeDrainProgress) {})
+	assert.Nil(t, err)
+	assert.Len(t, res, 3)
+}
+
+func TestEvictPodsConcurrently(t *testing.T) {
+	k8sClient := k8sfake.NewSimpleClientset()
+
+	k8sClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyV1beta1.Eviction)
+
+		if eviction.Name == "blocked" {
+			return true, nil, errors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 1)
+		}
+
+		return true, nil, nil
+	})
+
+	// evicted pods are not found anymore
+	resourceManager := &ResourceManager{
+		ctx:    context.Background(),
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme),
+	}
+
+	pods := []coreV1.Pod{
+		{ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "blocked"}},
+		{ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "web"}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	phases := make(map[string][]NodeDrainPhase)
+
+	err := resourceManager.evictPods(ctx, k8sClient, "node-1", pods, nil, func(progress *NodeDrainProgress) {
+		phases[progress.Pod] = append(phases[progress.Pod], progress.Phase)
+	})
+
+	// the blocked pod doesn't hold up the other one
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "evict pod app/blocked failed")
+	assert.Equal(t, []NodeDrainPhase{NodeDrainPhaseEvicting, NodeDrainPhaseEvicted}, phases["web"])
+	assert.Equal(t, []NodeDrainPhase{NodeDrainPhaseBlocked}, phases["blocked"])
+}
+
+func TestEvictPodsDeletesFinishedPods(t *testing.T) {
+	finished := coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "job-x7k2p"},
+		Status:     coreV1.PodStatus{Phase: coreV1.PodFailed},
+	}
+
+	k8sClient := k8sfake.NewSimpleClientset(finished.DeepCopy())
+
+	// every eviction would break a budget
+	k8sClient.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 1)
+	})
+
+	resourceManager := &ResourceManager{
+		ctx:    context.Background(),
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	var phases []NodeDrainPhase
+
+	err := resourceManager.evictPods(ctx, k8sClient, "node-1", []coreV1.Pod{finished}, nil, func(progress *NodeDrainProgress) {
+		phases = append(phases, progress.Phase)
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []NodeDrainPhase{NodeDrainPhaseEvicting, NodeDrainPhaseEvicted}, phases)
+
+	_, err = k8sClient.CoreV1().Pods("app").Get(context.Background(), finished.Name, metaV1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err))
+}
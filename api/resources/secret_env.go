@@ -0,0 +1,167 @@
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// A key of the application secret env. Values are write only, they are never returned by the api.
+type SecretEnvKey struct {
+	Name      string    `json:"name" validate:"required"`
+	Value     string    `json:"value,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+}
+
+func getSecretEnvUpdatedAt(secret *coreV1.Secret) map[string]time.Time {
+	res := make(map[string]time.Time)
+
+	if v, ok := secret.Annotations[v1alpha1.SecretEnvUpdatedAtAnnotation]; ok {
+		_ = json.Unmarshal([]byte(v), &res)
+	}
+
+	return res
+}
+
+func setSecretEnvUpdatedAt(secret *coreV1.Secret, updatedAt map[string]time.Time) {
+	bts, _ := json.Marshal(updatedAt)
+
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+
+	secret.Annotations[v1alpha1.SecretEnvUpdatedAtAnnotation] = string(bts)
+}
+
+func (resourceManager *ResourceManager) getSecretEnvSecret(namespace string) (*coreV1.Secret, error) {
+	var secret coreV1.Secret
+
+	if err := resourceManager.Get(namespace, v1alpha1.SecretEnvSecretName, &secret); err != nil {
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+func (resourceManager *ResourceManager) ListSecretEnvKeys(namespace string) ([]*SecretEnvKey, error) {
+	secret, err := resourceManager.getSecretEnvSecret(namespace)
+
+	if errors.IsNotFound(err) {
+		return []*SecretEnvKey{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	updatedAt := getSecretEnvUpdatedAt(secret)
+	res := make([]*SecretEnvKey, 0, len(secret.Data))
+
+	for key := range secret.Data {
+		res = append(res, &SecretEnvKey{
+			Name:      key,
+			UpdatedAt: updatedAt[key],
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
+
+func (resourceManager *ResourceManager) CreateSecretEnvKey(namespace string, key *SecretEnvKey) (*SecretEnvKey, error) {
+	secret, err := resourceManager.getSecretEnvSecret(namespace)
+
+	if errors.IsNotFound(err) {
+		secret = &coreV1.Secret{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: namespace,
+				Name:      v1alpha1.SecretEnvSecretName,
+			},
+			Type: coreV1.SecretTypeOpaque,
+		}
+
+		return resourceManager.setSecretEnvKey(secret, key, true)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, exist := secret.Data[key.Name]; exist {
+		return nil, fmt.Errorf("secret env key %s already exists", key.Name)
+	}
+
+	return resourceManager.setSecretEnvKey(secret, key, false)
+}
+
+// RotateSecretEnvKey replaces the value of an existing key. Components using the key are restarted by the controller.
+func (resourceManager *ResourceManager) RotateSecretEnvKey(namespace string, key *SecretEnvKey) (*SecretEnvKey, error) {
+	secret, err := resourceManager.getSecretEnvSecret(namespace)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, exist := secret.Data[key.Name]; !exist {
+		return nil, fmt.Errorf("secret env key %s not found", key.Name)
+	}
+
+	return resourceManager.setSecretEnvKey(secret, key, false)
+}
+
+func (resourceManager *ResourceManager) setSecretEnvKey(secret *coreV1.Secret, key *SecretEnvKey, isNew bool) (*SecretEnvKey, error) {
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+
+	secret.Data[key.Name] = []byte(key.Value)
+
+	// the controller restarts components using the key once its updated time changes, so it's not truncated
+	now := time.Now().UTC()
+	updatedAt := getSecretEnvUpdatedAt(secret)
+	updatedAt[key.Name] = now
+	setSecretEnvUpdatedAt(secret, updatedAt)
+
+	var err error
+
+	if isNew {
+		err = resourceManager.Create(secret)
+	} else {
+		err = resourceManager.Update(secret)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &SecretEnvKey{Name: key.Name, UpdatedAt: now}, nil
+}
+
+func (resourceManager *ResourceManager) DeleteSecretEnvKey(namespace, name string) error {
+	secret, err := resourceManager.getSecretEnvSecret(namespace)
+
+	if err != nil {
+		return err
+	}
+
+	if _, exist := secret.Data[name]; !exist {
+		return nil
+	}
+
+	delete(secret.Data, name)
+
+	updatedAt := getSecretEnvUpdatedAt(secret)
+	delete(updatedAt, name)
+	setSecretEnvUpdatedAt(secret, updatedAt)
+
+	return resourceManager.Update(secret)
+}
@@ -0,0 +1,64 @@
+package resources
+
+import (
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Shared envs of an application. Components refer to them with envs of the external type.
+type SharedEnv map[string]string
+
+func (resourceManager *ResourceManager) GetSharedEnv(namespace string) (SharedEnv, error) {
+	var configMap coreV1.ConfigMap
+
+	if err := resourceManager.Get(namespace, v1alpha1.SharedEnvConfigMapName, &configMap); err != nil {
+		if errors.IsNotFound(err) {
+			return SharedEnv{}, nil
+		}
+
+		return nil, err
+	}
+
+	if configMap.Data == nil {
+		return SharedEnv{}, nil
+	}
+
+	return configMap.Data, nil
+}
+
+// UpdateSharedEnv replaces all shared envs of an application.
+func (resourceManager *ResourceManager) UpdateSharedEnv(namespace string, sharedEnv SharedEnv) (SharedEnv, error) {
+	var configMap coreV1.ConfigMap
+
+	err := resourceManager.Get(namespace, v1alpha1.SharedEnvConfigMapName, &configMap)
+
+	if errors.IsNotFound(err) {
+		configMap = coreV1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: namespace,
+				Name:      v1alpha1.SharedEnvConfigMapName,
+			},
+			Data: sharedEnv,
+		}
+
+		if err := resourceManager.Create(&configMap); err != nil {
+			return nil, err
+		}
+
+		return sharedEnv, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	configMap.Data = sharedEnv
+
+	if err := resourceManager.Update(&configMap); err != nil {
+		return nil, err
+	}
+
+	return sharedEnv, nil
+}
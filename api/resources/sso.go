@@ -85,6 +85,9 @@ type ProtectedEndpoint struct {
 	Ports                       []uint32 `json:"ports"`
 	Groups                      []string `json:"groups"`
 	AllowToPassIfHasBearerToken bool     `json:"allowToPassIfHasBearerToken,omitempty"`
+
+	Rules           []v1alpha1.ProtectedEndpointRule           `json:"rules,omitempty"`
+	ForwardedClaims []v1alpha1.ProtectedEndpointForwardedClaim `json:"forwardedClaims,omitempty"`
 }
 
 type SSOConfig struct {
@@ -167,6 +170,8 @@ func ProtectedEndpointCRDToProtectedEndpoint(endpoint *v1alpha1.ProtectedEndpoin
 		Ports:                       endpoint.Spec.Ports,
 		Groups:                      endpoint.Spec.Groups,
 		AllowToPassIfHasBearerToken: endpoint.Spec.AllowToPassIfHasBearerToken,
+		Rules:                       endpoint.Spec.Rules,
+		ForwardedClaims:             endpoint.Spec.ForwardedClaims,
 	}
 
 	// import for frontend
@@ -216,6 +221,8 @@ func (resourceManager *ResourceManager) CreateProtectedEndpoint(ep *ProtectedEnd
 			Ports:                       ep.Ports,
 			Groups:                      ep.Groups,
 			AllowToPassIfHasBearerToken: ep.AllowToPassIfHasBearerToken,
+			Rules:                       ep.Rules,
+			ForwardedClaims:             ep.ForwardedClaims,
 		},
 	}
 
@@ -243,6 +250,8 @@ func (resourceManager *ResourceManager) UpdateProtectedEndpoint(ep *ProtectedEnd
 			Ports:                       ep.Ports,
 			Groups:                      ep.Groups,
 			AllowToPassIfHasBearerToken: ep.AllowToPassIfHasBearerToken,
+			Rules:                       ep.Rules,
+			ForwardedClaims:             ep.ForwardedClaims,
 		},
 	}
 
@@ -0,0 +1,477 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kalmhq/kalm/api/log"
+	"go.uber.org/zap"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The volume browser mounts a pvc read-only into a short-lived helper pod,
+// files are listed and read by exec'ing into the pod, so the app pod can be crash-looping.
+const (
+	VolumeBrowserImage       = "busybox:1.32"
+	VolumeBrowserMountPath   = "/volume"
+	VolumeBrowserIdleTimeout = 10 * time.Minute
+
+	// helper pods are deleted by the cleaner after being idle for the timeout,
+	// the deadline only stops pods left behind if the cleaner is not running
+	volumeBrowserActiveDeadline = 6 * time.Hour
+
+	LabelVolumeBrowser          = "kalm-volume-browser"
+	AnnotationVolumeBrowserPVC  = "kalm-volume-browser-pvc"
+	AnnotationVolumeBrowserUsed = "kalm-volume-browser-last-used"
+)
+
+type VolumeFileType string
+
+const (
+	VolumeFileTypeFile    VolumeFileType = "file"
+	VolumeFileTypeDir     VolumeFileType = "dir"
+	VolumeFileTypeSymlink VolumeFileType = "symlink"
+	VolumeFileTypeOther   VolumeFileType = "other"
+)
+
+type VolumeFile struct {
+	Name       string         `json:"name"`
+	Path       string         `json:"path"`
+	Type       VolumeFileType `json:"type"`
+	Size       int64          `json:"size"`
+	Mode       string         `json:"mode"`
+	ModifiedAt time.Time      `json:"modifiedAt"`
+}
+
+// type, size, modification time, permission and name, the name comes last as it may contain the separator
+const volumeFileStatFormat = "%F|%s|%Y|%a|%n"
+
+func getVolumeBrowserPodName(pvcName string) string {
+	return "kalm-volume-browser-" + pvcName
+}
+
+// CleanVolumeFilePath returns the absolute path in the volume, paths can't go up out of the volume
+func CleanVolumeFilePath(p string) string {
+	return path.Clean("/" + p)
+}
+
+func parseVolumeFileStat(line string) (*VolumeFile, error) {
+	fields := strings.SplitN(line, "|", 5)
+
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("unexpected stat output: %s", line)
+	}
+
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("unexpected stat output: %s", line)
+	}
+
+	modifiedAt, err := strconv.ParseInt(fields[2], 10, 64)
+
+	if err != nil {
+		return nil, fmt.Errorf("unexpected stat output: %s", line)
+	}
+
+	fileType := VolumeFileTypeOther
+
+	switch fields[0] {
+	case "regular file", "regular empty file":
+		fileType = VolumeFileTypeFile
+	case "directory":
+		fileType = VolumeFileTypeDir
+	case "symbolic link":
+		fileType = VolumeFileTypeSymlink
+	}
+
+	filePath := CleanVolumeFilePath(strings.TrimPrefix(fields[4], VolumeBrowserMountPath))
+
+	return &VolumeFile{
+		Name:       path.Base(filePath),
+		Path:       filePath,
+		Type:       fileType,
+		Size:       size,
+		Mode:       fields[3],
+		ModifiedAt: time.Unix(modifiedAt, 0).UTC(),
+	}, nil
+}
+
+func buildVolumeBrowserPod(pvc *coreV1.PersistentVolumeClaim, nodeName string) *coreV1.Pod {
+	activeDeadlineSeconds := int64(volumeBrowserActiveDeadline.Seconds())
+	terminationGracePeriodSeconds := int64(0)
+	automountServiceAccountToken := false
+	readOnlyRootFilesystem := true
+	allowPrivilegeEscalation := false
+
+	return &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: pvc.Namespace,
+			Name:      getVolumeBrowserPodName(pvc.Name),
+			Labels: map[string]string{
+				LabelVolumeBrowser: "true",
+			},
+			Annotations: map[string]string{
+				AnnotationVolumeBrowserPVC:  pvc.Name,
+				AnnotationVolumeBrowserUsed: time.Now().UTC().Format(time.RFC3339),
+
+				// kalm application namespaces have istio injection enabled, the helper doesn't need a sidecar
+				"sidecar.istio.io/inject": "false",
+			},
+		},
+		Spec: coreV1.PodSpec{
+			// a ReadWriteOnce pvc in use can only be mounted on the same node
+			NodeName:                      nodeName,
+			RestartPolicy:                 coreV1.RestartPolicyNever,
+			ActiveDeadlineSeconds:         &activeDeadlineSeconds,
+			TerminationGracePeriodSeconds: &terminationGracePeriodSeconds,
+
+			// symlinks in the volume can point to any file of the helper pod
+			AutomountServiceAccountToken: &automountServiceAccountToken,
+
+			Containers: []coreV1.Container{
+				{
+					Name:    "browser",
+					Image:   VolumeBrowserImage,
+					Command: []string{"sleep", strconv.Itoa(int(volumeBrowserActiveDeadline.Seconds()))},
+					VolumeMounts: []coreV1.VolumeMount{
+						{Name: "volume", MountPath: VolumeBrowserMountPath, ReadOnly: true},
+					},
+					SecurityContext: &coreV1.SecurityContext{
+						ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+						AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+					},
+					// namespaces with a resource quota only accept pods with resources
+					Resources: coreV1.ResourceRequirements{
+						Requests: coreV1.ResourceList{
+							coreV1.ResourceCPU:    resource.MustParse("10m"),
+							coreV1.ResourceMemory: resource.MustParse("16Mi"),
+						},
+						Limits: coreV1.ResourceList{
+							coreV1.ResourceCPU:    resource.MustParse("100m"),
+							coreV1.ResourceMemory: resource.MustParse("64Mi"),
+						},
+					},
+				},
+			},
+			Volumes: []coreV1.Volume{
+				{
+					Name: "volume",
+					VolumeSource: coreV1.VolumeSource{
+						PersistentVolumeClaim: &coreV1.PersistentVolumeClaimVolumeSource{
+							ClaimName: pvc.Name,
+							ReadOnly:  true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ensureVolumeBrowserPod creates the helper pod of the pvc if it doesn't exist, and waits until it's running
+func (resourceManager *ResourceManager) ensureVolumeBrowserPod(namespace, pvcName string) (*coreV1.Pod, error) {
+	var pvc coreV1.PersistentVolumeClaim
+
+	if err := resourceManager.Get(namespace, pvcName, &pvc); err != nil {
+		return nil, err
+	}
+
+	var pod coreV1.Pod
+	err := resourceManager.Get(namespace, getVolumeBrowserPodName(pvcName), &pod)
+
+	if err == nil && (pod.Status.Phase == coreV1.PodSucceeded || pod.Status.Phase == coreV1.PodFailed) {
+		if err := resourceManager.deleteVolumeBrowserPod(&pod); err != nil {
+			return nil, err
+		}
+
+		err = resourceManager.waitForVolumeBrowserPodDeleted(namespace, pod.Name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = errors.NewNotFound(coreV1.Resource("pods"), pod.Name)
+	}
+
+	if errors.IsNotFound(err) {
+		nodeName, err := resourceManager.getNodeOfPVCInUse(&pvc)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := resourceManager.Create(buildVolumeBrowserPod(&pvc, nodeName)); err != nil && !errors.IsAlreadyExists(err) {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if err := resourceManager.touchVolumeBrowserPod(&pod); err != nil {
+		return nil, err
+	}
+
+	if err := wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
+		if err := resourceManager.Get(namespace, getVolumeBrowserPodName(pvcName), &pod); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+
+		switch pod.Status.Phase {
+		case coreV1.PodRunning:
+			return true, nil
+		case coreV1.PodSucceeded, coreV1.PodFailed:
+			return false, fmt.Errorf("volume browser of %s stopped unexpectedly", pvcName)
+		}
+
+		return false, nil
+	}); err != nil {
+		if err == wait.ErrWaitTimeout {
+			return nil, fmt.Errorf("volume browser of %s is not running in time, check events of pod %s", pvcName, getVolumeBrowserPodName(pvcName))
+		}
+
+		return nil, err
+	}
+
+	return &pod, nil
+}
+
+func (resourceManager *ResourceManager) getNodeOfPVCInUse(pvc *coreV1.PersistentVolumeClaim) (string, error) {
+	var podList coreV1.PodList
+
+	if err := resourceManager.List(&podList, client.InNamespace(pvc.Namespace)); err != nil {
+		return "", err
+	}
+
+	for _, pod := range podList.Items {
+		if pod.Spec.NodeName == "" || pod.Labels[LabelVolumeBrowser] == "true" || !isPVCInUse(*pvc, []coreV1.Pod{pod}) {
+			continue
+		}
+
+		if pod.Status.Phase == coreV1.PodPending || pod.Status.Phase == coreV1.PodRunning {
+			return pod.Spec.NodeName, nil
+		}
+	}
+
+	return "", nil
+}
+
+// the last used time is updated at most once a minute
+func (resourceManager *ResourceManager) touchVolumeBrowserPod(pod *coreV1.Pod) error {
+	if lastUsed, err := time.Parse(time.RFC3339, pod.Annotations[AnnotationVolumeBrowserUsed]); err == nil && time.Since(lastUsed) < time.Minute {
+		return nil
+	}
+
+	copied := pod.DeepCopy()
+
+	if copied.Annotations == nil {
+		copied.Annotations = make(map[string]string)
+	}
+
+	copied.Annotations[AnnotationVolumeBrowserUsed] = time.Now().UTC().Format(time.RFC3339)
+
+	return resourceManager.Patch(copied, client.MergeFrom(pod))
+}
+
+func (resourceManager *ResourceManager) deleteVolumeBrowserPod(pod *coreV1.Pod) error {
+	return client.IgnoreNotFound(resourceManager.Delete(pod, client.GracePeriodSeconds(0)))
+}
+
+func (resourceManager *ResourceManager) waitForVolumeBrowserPodDeleted(namespace, name string) error {
+	return wait.PollImmediate(time.Second, time.Minute, func() (bool, error) {
+		var pod coreV1.Pod
+
+		if err := resourceManager.Get(namespace, name, &pod); err != nil {
+			if errors.IsNotFound(err) {
+				return true, nil
+			}
+
+			return false, err
+		}
+
+		return false, nil
+	})
+}
+
+func (resourceManager *ResourceManager) execInVolumeBrowser(pod *coreV1.Pod, command []string, stdout io.Writer) error {
+	k8sClient, err := kubernetes.NewForConfig(resourceManager.Cfg)
+
+	if err != nil {
+		return err
+	}
+
+	req := k8sClient.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod.Name).
+		Namespace(pod.Namespace).
+		SubResource("exec")
+
+	req = req.VersionedParams(&coreV1.PodExecOptions{
+		Command:   command,
+		Stdout:    true,
+		Stderr:    true,
+		Container: pod.Spec.Containers[0].Name,
+	}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(resourceManager.Cfg, "POST", req.URL())
+
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+
+	if err := exec.Stream(remotecommand.StreamOptions{Stdout: stdout, Stderr: &stderr}); err != nil {
+		if stderr.Len() > 0 {
+			return fmt.Errorf("%s", strings.TrimSpace(stderr.String()))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+func (resourceManager *ResourceManager) statVolumeFiles(pod *coreV1.Pod, command []string) ([]*VolumeFile, error) {
+	var stdout bytes.Buffer
+
+	if err := resourceManager.execInVolumeBrowser(pod, command, &stdout); err != nil {
+		return nil, err
+	}
+
+	files := []*VolumeFile{}
+
+	for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+		if line == "" {
+			continue
+		}
+
+		file, err := parseVolumeFileStat(line)
+
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// ListVolumeFiles lists the directory of the path in the pvc
+func (resourceManager *ResourceManager) ListVolumeFiles(namespace, pvcName, dir string) ([]*VolumeFile, error) {
+	pod, err := resourceManager.ensureVolumeBrowserPod(namespace, pvcName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resourceManager.statVolumeFiles(pod, []string{
+		"find", VolumeBrowserMountPath + CleanVolumeFilePath(dir), "-mindepth", "1", "-maxdepth", "1",
+		"-exec", "stat", "-c", volumeFileStatFormat, "{}", "+",
+	})
+}
+
+func (resourceManager *ResourceManager) GetVolumeFile(namespace, pvcName, filePath string) (*VolumeFile, error) {
+	pod, err := resourceManager.ensureVolumeBrowserPod(namespace, pvcName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := resourceManager.statVolumeFiles(pod, []string{
+		"stat", "-c", volumeFileStatFormat, "--", VolumeBrowserMountPath + CleanVolumeFilePath(filePath),
+	})
+
+	if err != nil {
+		if strings.Contains(err.Error(), "No such file or directory") {
+			return nil, errors.NewNotFound(schema.GroupResource{Resource: "files"}, CleanVolumeFilePath(filePath))
+		}
+
+		return nil, err
+	}
+
+	if len(files) != 1 {
+		return nil, fmt.Errorf("file %s is not found", filePath)
+	}
+
+	return files[0], nil
+}
+
+// DownloadVolumeFile writes the content of a regular file to the writer
+func (resourceManager *ResourceManager) DownloadVolumeFile(namespace, pvcName, filePath string, w io.Writer) error {
+	pod, err := resourceManager.ensureVolumeBrowserPod(namespace, pvcName)
+
+	if err != nil {
+		return err
+	}
+
+	return resourceManager.execInVolumeBrowser(pod, []string{"cat", "--", VolumeBrowserMountPath + CleanVolumeFilePath(filePath)}, w)
+}
+
+// StartVolumeBrowserCleaner deletes helper pods idle for VolumeBrowserIdleTimeout, or stopped.
+// Every api server replica runs a cleaner, deleting the same pod twice is harmless.
+func (resourceManager *ResourceManager) StartVolumeBrowserCleaner(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := resourceManager.cleanVolumeBrowserPods(time.Now()); err != nil {
+				log.Error("Error cleaning volume browser pods", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (resourceManager *ResourceManager) cleanVolumeBrowserPods(now time.Time) error {
+	var podList coreV1.PodList
+
+	if err := resourceManager.List(&podList, client.MatchingLabels{LabelVolumeBrowser: "true"}); err != nil {
+		return err
+	}
+
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+
+		// the label can be set on any pod, only delete pods created by the volume browser
+		pvcName := pod.Annotations[AnnotationVolumeBrowserPVC]
+
+		if pvcName == "" || pod.Name != getVolumeBrowserPodName(pvcName) {
+			continue
+		}
+
+		lastUsed, err := time.Parse(time.RFC3339, pod.Annotations[AnnotationVolumeBrowserUsed])
+
+		idle := err != nil || now.Sub(lastUsed) > VolumeBrowserIdleTimeout
+		stopped := pod.Status.Phase == coreV1.PodSucceeded || pod.Status.Phase == coreV1.PodFailed
+
+		if !idle && !stopped {
+			continue
+		}
+
+		if err := resourceManager.deleteVolumeBrowserPod(pod); err != nil {
+			return err
+		}
+
+		log.Debug("Volume browser pod deleted", zap.String("namespace", pod.Namespace), zap.String("name", pod.Name))
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package resources
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCleanVolumeFilePath(t *testing.T) {
+	tests := []struct {
+		Path  string
+		Clean string
+	}{
+		{"", "/"},
+		{"data", "/data"},
+		{"/data/", "/data"},
+		{"../../etc/passwd", "/etc/passwd"},
+		{"/data/../../..", "/"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.Clean, CleanVolumeFilePath(test.Path))
+	}
+}
+
+func TestParseVolumeFileStat(t *testing.T) {
+	file, err := parseVolumeFileStat("regular file|1024|1600000000|644|/volume/data/a|b.log")
+	assert.NilError(t, err)
+	assert.Equal(t, "a|b.log", file.Name)
+	assert.Equal(t, "/data/a|b.log", file.Path)
+	assert.Equal(t, VolumeFileTypeFile, file.Type)
+	assert.Equal(t, int64(1024), file.Size)
+	assert.Equal(t, "644", file.Mode)
+	assert.Equal(t, time.Unix(1600000000, 0).UTC(), file.ModifiedAt)
+
+	file, err = parseVolumeFileStat("directory|4096|1600000000|755|/volume")
+	assert.NilError(t, err)
+	assert.Equal(t, "/", file.Path)
+	assert.Equal(t, VolumeFileTypeDir, file.Type)
+
+	_, err = parseVolumeFileStat("stat: can't stat '/volume/x'")
+	assert.ErrorContains(t, err, "unexpected stat output")
+}
+
+func TestBuildVolumeBrowserPod(t *testing.T) {
+	pvc := &coreV1.PersistentVolumeClaim{ObjectMeta: metaV1.ObjectMeta{Namespace: "ns", Name: "data"}}
+	pod := buildVolumeBrowserPod(pvc, "node-1")
+
+	assert.Equal(t, "kalm-volume-browser-data", pod.Name)
+	assert.Equal(t, "node-1", pod.Spec.NodeName)
+	assert.Equal(t, false, *pod.Spec.AutomountServiceAccountToken)
+	assert.Equal(t, true, pod.Spec.Volumes[0].PersistentVolumeClaim.ReadOnly)
+	assert.Equal(t, true, pod.Spec.Containers[0].VolumeMounts[0].ReadOnly)
+	assert.Equal(t, "false", pod.Annotations["sidecar.istio.io/inject"])
+	assert.Equal(t, "64Mi", pod.Spec.Containers[0].Resources.Limits.Memory().String())
+}
+
+func TestCleanVolumeBrowserPods(t *testing.T) {
+	now := time.Now()
+
+	newPod := func(name string, lastUsed time.Time, phase coreV1.PodPhase) *coreV1.Pod {
+		return &coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: "ns",
+				Name:      name,
+				Labels:    map[string]string{LabelVolumeBrowser: "true"},
+				Annotations: map[string]string{
+					AnnotationVolumeBrowserPVC:  strings.TrimPrefix(name, "kalm-volume-browser-"),
+					AnnotationVolumeBrowserUsed: lastUsed.UTC().Format(time.RFC3339),
+				},
+			},
+			Status: coreV1.PodStatus{Phase: phase},
+		}
+	}
+
+	resourceManager := &ResourceManager{
+		ctx: context.Background(),
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+			newPod("kalm-volume-browser-active", now.Add(-time.Minute), coreV1.PodRunning),
+			newPod("kalm-volume-browser-idle", now.Add(-VolumeBrowserIdleTimeout-time.Minute), coreV1.PodRunning),
+			newPod("kalm-volume-browser-stopped", now, coreV1.PodFailed),
+			// labeled by others
+			newPod("app", now, coreV1.PodFailed),
+		),
+	}
+
+	assert.NilError(t, resourceManager.cleanVolumeBrowserPods(now))
+
+	var podList coreV1.PodList
+	assert.NilError(t, resourceManager.List(&podList))
+	assert.Equal(t, 2, len(podList.Items))
+	assert.Equal(t, "kalm-volume-browser-active", podList.Items[0].Name)
+	assert.Equal(t, "app", podList.Items[1].Name)
+}
+
+func TestGetNodeOfPVCInUse(t *testing.T) {
+	pvc := &coreV1.PersistentVolumeClaim{ObjectMeta: metaV1.ObjectMeta{Namespace: "ns", Name: "data"}}
+
+	newPod := func(name, nodeName string) *coreV1.Pod {
+		return &coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Namespace: "ns", Name: name},
+			Spec: coreV1.PodSpec{
+				NodeName: nodeName,
+				Volumes: []coreV1.Volume{{
+					Name: "data",
+					VolumeSource: coreV1.VolumeSource{
+						PersistentVolumeClaim: &coreV1.PersistentVolumeClaimVolumeSource{ClaimName: "data"},
+					},
+				}},
+			},
+			Status: coreV1.PodStatus{Phase: coreV1.PodRunning},
+		}
+	}
+
+	resourceManager := &ResourceManager{
+		ctx:    context.Background(),
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme, newPod("app", "node-1")),
+	}
+
+	nodeName, err := resourceManager.getNodeOfPVCInUse(pvc)
+	assert.NilError(t, err)
+	assert.Equal(t, "node-1", nodeName)
+
+	pvc.Name = "unused"
+	nodeName, err = resourceManager.getNodeOfPVCInUse(pvc)
+	assert.NilError(t, err)
+	assert.Equal(t, "", nodeName)
+}
@@ -0,0 +1,175 @@
+package resources
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kalmhq/kalm/controller/controllers"
+	"github.com/kalmhq/kalm/controller/lib/snapshot"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type VolumeSnapshot struct {
+	*snapshot.Info `json:",inline"`
+	ComponentName  string `json:"componentName,omitempty"`
+}
+
+type VolumeSnapshotRequest struct {
+	// Blank means <pvc>-<time>
+	Name string `json:"name"`
+
+	// Blank means the default VolumeSnapshotClass
+	VolumeSnapshotClassName *string `json:"volumeSnapshotClassName,omitempty"`
+}
+
+// VolumeRestoreRequest creates a new pvc from a snapshot in the same namespace,
+// a component can claim it by setting the pvc of a volume to the name.
+type VolumeRestoreRequest struct {
+	PVC string `json:"pvc" validate:"required"`
+
+	// Blank means the restore size of the snapshot
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// Blank means the storage class of the pvc the snapshot is taken from
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
+func wrapSnapshotError(err error) error {
+	if snapshot.IsNotSupported(err) {
+		return snapshot.ErrNotSupported
+	}
+
+	return err
+}
+
+func (resourceManager *ResourceManager) ListVolumeSnapshots(opts ...client.ListOption) ([]*VolumeSnapshot, error) {
+	snapshotList := snapshot.NewList()
+
+	if err := resourceManager.List(snapshotList, opts...); err != nil {
+		return nil, wrapSnapshotError(err)
+	}
+
+	res := make([]*VolumeSnapshot, 0, len(snapshotList.Items))
+
+	for i := range snapshotList.Items {
+		res = append(res, &VolumeSnapshot{
+			Info:          snapshot.GetInfo(&snapshotList.Items[i]),
+			ComponentName: snapshotList.Items[i].GetLabels()[controllers.KalmLabelComponentKey],
+		})
+	}
+
+	return res, nil
+}
+
+func (resourceManager *ResourceManager) CreateVolumeSnapshot(namespace, pvcName string, req *VolumeSnapshotRequest) (*VolumeSnapshot, error) {
+	var pvc coreV1.PersistentVolumeClaim
+
+	if err := resourceManager.Get(namespace, pvcName, &pvc); err != nil {
+		return nil, err
+	}
+
+	name := req.Name
+
+	if name == "" {
+		name = fmt.Sprintf("%s-%s", pvcName, time.Now().UTC().Format("20060102-150405"))
+	}
+
+	labels := map[string]string{
+		controllers.KalmLabelManaged: "true",
+		snapshot.LabelPVC:            pvcName,
+	}
+
+	if componentName := pvc.Labels[controllers.KalmLabelComponentKey]; componentName != "" {
+		labels[controllers.KalmLabelComponentKey] = componentName
+	}
+
+	volumeSnapshot := snapshot.New(namespace, name, pvcName, req.VolumeSnapshotClassName, labels)
+
+	if err := resourceManager.Create(volumeSnapshot); err != nil {
+		return nil, wrapSnapshotError(err)
+	}
+
+	return &VolumeSnapshot{
+		Info:          snapshot.GetInfo(volumeSnapshot),
+		ComponentName: labels[controllers.KalmLabelComponentKey],
+	}, nil
+}
+
+func (resourceManager *ResourceManager) DeleteVolumeSnapshot(namespace, name string) error {
+	volumeSnapshot := snapshot.NewEmpty()
+	volumeSnapshot.SetNamespace(namespace)
+	volumeSnapshot.SetName(name)
+
+	return wrapSnapshotError(resourceManager.Delete(volumeSnapshot))
+}
+
+func (resourceManager *ResourceManager) RestoreVolumeSnapshot(namespace, name string, req *VolumeRestoreRequest) (*Volume, error) {
+	volumeSnapshot := snapshot.NewEmpty()
+
+	if err := resourceManager.Get(namespace, name, volumeSnapshot); err != nil {
+		return nil, wrapSnapshotError(err)
+	}
+
+	info := snapshot.GetInfo(volumeSnapshot)
+
+	if !info.ReadyToUse {
+		return nil, fmt.Errorf("volume snapshot %s is not ready to use", name)
+	}
+
+	storageClassName := req.StorageClassName
+	accessModes := []coreV1.PersistentVolumeAccessMode{coreV1.ReadWriteOnce}
+
+	var sourcePVC coreV1.PersistentVolumeClaim
+
+	if err := resourceManager.Get(namespace, info.PVC, &sourcePVC); err == nil {
+		if storageClassName == nil {
+			storageClassName = sourcePVC.Spec.StorageClassName
+		}
+
+		if len(sourcePVC.Spec.AccessModes) > 0 {
+			accessModes = sourcePVC.Spec.AccessModes
+		}
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var size resource.Quantity
+
+	if req.Size != nil {
+		size = *req.Size
+	} else if info.RestoreSize != nil {
+		size = *info.RestoreSize
+	} else {
+		return nil, fmt.Errorf("size is required, restore size of volume snapshot %s is unknown", name)
+	}
+
+	pvc := coreV1.PersistentVolumeClaim{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: namespace,
+			Name:      req.PVC,
+			Labels: map[string]string{
+				controllers.KalmLabelManaged: "true",
+			},
+		},
+		Spec: coreV1.PersistentVolumeClaimSpec{
+			AccessModes: accessModes,
+			Resources: coreV1.ResourceRequirements{
+				Requests: coreV1.ResourceList{
+					coreV1.ResourceStorage: size,
+				},
+			},
+			StorageClassName: storageClassName,
+			DataSource:       snapshot.DataSource(name),
+		},
+	}
+
+	if err := resourceManager.Create(&pvc); err != nil {
+		return nil, err
+	}
+
+	return resourceManager.BuildVolumeResponse(pvc)
+}
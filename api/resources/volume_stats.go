@@ -0,0 +1,257 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kalmhq/kalm/api/log"
+	"go.uber.org/zap"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedCoreV1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Kubelet refreshes volume stats every minute by default, scraping faster gets the same values
+const VolumeStatsScrapeInterval = time.Minute
+
+// Percent of bytes or inodes used, above which a warning event is emitted on the pvc
+const DefaultVolumeUsageWarningThreshold = 85
+
+// VolumeUsage is the latest scraped usage of a pvc
+type VolumeUsage struct {
+	UsedBytes      int64     `json:"usedBytes"`
+	AvailableBytes int64     `json:"availableBytes"`
+	CapacityBytes  int64     `json:"capacityBytes"`
+	InodesUsed     int64     `json:"inodesUsed"`
+	Inodes         int64     `json:"inodes"`
+	Time           time.Time `json:"time"`
+}
+
+// the part of kubelet /stats/summary about pvc volumes
+type kubeletStatsSummary struct {
+	Pods []struct {
+		VolumeStats []struct {
+			PVCRef *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+			UsedBytes      *int64 `json:"usedBytes"`
+			AvailableBytes *int64 `json:"availableBytes"`
+			CapacityBytes  *int64 `json:"capacityBytes"`
+			InodesUsed     *int64 `json:"inodesUsed"`
+			Inodes         *int64 `json:"inodes"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+var latestVolumeUsages = map[string]*VolumeUsage{}
+var latestVolumeUsagesLock sync.RWMutex
+
+// GetVolumeUsage returns nil if the pvc is not mounted or not scraped yet
+func GetVolumeUsage(namespace, pvc string) *VolumeUsage {
+	latestVolumeUsagesLock.RLock()
+	defer latestVolumeUsagesLock.RUnlock()
+
+	return latestVolumeUsages[namespace+"/"+pvc]
+}
+
+func GetVolumeMetricInRange(namespace, pvc string, r time.Duration) VolumeMetricHistories {
+	if metricStorage == nil {
+		log.Info("Metric is not available.")
+		return VolumeMetricHistories{}
+	}
+
+	histories, err := metricStorage.QueryVolume(namespace, pvc, r, time.Now())
+
+	if err != nil {
+		log.Error("Error getting volume metrics", zap.Error(err))
+		return VolumeMetricHistories{}
+	}
+
+	return histories
+}
+
+func volumeSamplesFromSummary(summary *kubeletStatsSummary) []*VolumeSample {
+	var samples []*VolumeSample
+
+	// a pvc mounted by several pods on the node is reported by each of them
+	seen := make(map[string]bool)
+
+	for _, pod := range summary.Pods {
+		for _, v := range pod.VolumeStats {
+			if v.PVCRef == nil || seen[v.PVCRef.Namespace+"/"+v.PVCRef.Name] {
+				continue
+			}
+
+			seen[v.PVCRef.Namespace+"/"+v.PVCRef.Name] = true
+
+			samples = append(samples, &VolumeSample{
+				Namespace:      v.PVCRef.Namespace,
+				PVC:            v.PVCRef.Name,
+				UsedBytes:      valueOfInt64(v.UsedBytes),
+				AvailableBytes: valueOfInt64(v.AvailableBytes),
+				CapacityBytes:  valueOfInt64(v.CapacityBytes),
+				InodesUsed:     valueOfInt64(v.InodesUsed),
+				Inodes:         valueOfInt64(v.Inodes),
+			})
+		}
+	}
+
+	return samples
+}
+
+func valueOfInt64(v *int64) int64 {
+	if v == nil {
+		return 0
+	}
+
+	return *v
+}
+
+// percent of bytes or inodes used, whichever is higher
+func volumeUsagePercent(v *VolumeSample) float64 {
+	var percent float64
+
+	if v.CapacityBytes > 0 {
+		percent = float64(v.UsedBytes) * 100 / float64(v.CapacityBytes)
+	}
+
+	if v.Inodes > 0 {
+		if inodesPercent := float64(v.InodesUsed) * 100 / float64(v.Inodes); inodesPercent > percent {
+			percent = inodesPercent
+		}
+	}
+
+	return percent
+}
+
+// volumeUsageWatcher emits a warning event when the usage of a pvc crosses the threshold,
+// it is emitted again only after the usage drops below the threshold.
+type volumeUsageWatcher struct {
+	threshold float64
+	above     map[string]bool
+	recorder  record.EventRecorder
+	client    kubernetes.Interface
+}
+
+func newVolumeUsageWatcher(client kubernetes.Interface, threshold int) *volumeUsageWatcher {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedCoreV1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+
+	return &volumeUsageWatcher{
+		threshold: float64(threshold),
+		above:     make(map[string]bool),
+		recorder:  broadcaster.NewRecorder(scheme.Scheme, coreV1.EventSource{Component: "kalm-api"}),
+		client:    client,
+	}
+}
+
+// crossed returns samples going above the threshold since the last check
+func (w *volumeUsageWatcher) crossed(samples []*VolumeSample) []*VolumeSample {
+	var res []*VolumeSample
+
+	for _, v := range samples {
+		key := v.Namespace + "/" + v.PVC
+		above := volumeUsagePercent(v) >= w.threshold
+
+		if above && !w.above[key] {
+			res = append(res, v)
+		}
+
+		w.above[key] = above
+	}
+
+	return res
+}
+
+func (w *volumeUsageWatcher) check(samples []*VolumeSample) {
+	for _, v := range w.crossed(samples) {
+		pvc, err := w.client.CoreV1().PersistentVolumeClaims(v.Namespace).Get(context.Background(), v.PVC, metaV1.GetOptions{})
+
+		if err != nil {
+			log.Error("Error getting pvc of volume usage warning", zap.Error(err))
+			continue
+		}
+
+		w.recorder.Eventf(pvc, coreV1.EventTypeWarning, "VolumeUsageHigh",
+			"Volume is %.0f%% full, %d of %d bytes and %d of %d inodes are used",
+			volumeUsagePercent(v), v.UsedBytes, v.CapacityBytes, v.InodesUsed, v.Inodes,
+		)
+	}
+}
+
+func scrapeVolumeStats(restClient *kubernetes.Clientset) ([]*VolumeSample, error) {
+	nodes, err := restClient.CoreV1().Nodes().List(context.Background(), metaV1.ListOptions{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []*VolumeSample
+
+	for _, node := range nodes.Items {
+		data, err := restClient.CoreV1().RESTClient().Get().
+			Resource("nodes").Name(node.Name).SubResource("proxy").Suffix("stats/summary").
+			DoRaw(context.Background())
+
+		// the other nodes are still scraped
+		if err != nil {
+			log.Error("Error scraping volume stats", zap.String("node", node.Name), zap.Error(err))
+			continue
+		}
+
+		var summary kubeletStatsSummary
+
+		if err := json.Unmarshal(data, &summary); err != nil {
+			log.Error("Error parsing volume stats", zap.String("node", node.Name), zap.Error(err))
+			continue
+		}
+
+		samples = append(samples, volumeSamplesFromSummary(&summary)...)
+	}
+
+	return samples, nil
+}
+
+func updateVolumes(restClient *kubernetes.Clientset, storage MetricStorage, watcher *volumeUsageWatcher) error {
+	samples, err := scrapeVolumeStats(restClient)
+
+	if err != nil {
+		log.Error("Error scraping volume stats", zap.Error(err))
+		return err
+	}
+
+	now := time.Now()
+	usages := make(map[string]*VolumeUsage, len(samples))
+
+	for _, v := range samples {
+		usages[v.Namespace+"/"+v.PVC] = &VolumeUsage{
+			UsedBytes:      v.UsedBytes,
+			AvailableBytes: v.AvailableBytes,
+			CapacityBytes:  v.CapacityBytes,
+			InodesUsed:     v.InodesUsed,
+			Inodes:         v.Inodes,
+			Time:           now,
+		}
+	}
+
+	latestVolumeUsagesLock.Lock()
+	latestVolumeUsages = usages
+	latestVolumeUsagesLock.Unlock()
+
+	watcher.check(samples)
+
+	if err := storage.InsertVolumes(now, samples); err != nil {
+		log.Error("Error updating volume metric storage", zap.Error(err))
+		return err
+	}
+
+	log.Debug(fmt.Sprintf("Volume metric storage updated: %d volumes", len(samples)))
+	return nil
+}
@@ -0,0 +1,64 @@
+package resources
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeSamplesFromSummary(t *testing.T) {
+	data := `{
+		"node": {"nodeName": "node-1"},
+		"pods": [
+			{
+				"podRef": {"name": "db-0", "namespace": "app"},
+				"volume": [
+					{"name": "default-token", "usedBytes": 12288},
+					{"name": "data", "usedBytes": 900, "availableBytes": 100, "capacityBytes": 1000, "inodesUsed": 10, "inodes": 100, "pvcRef": {"name": "data-db-0", "namespace": "app"}}
+				]
+			},
+			{
+				"podRef": {"name": "backup", "namespace": "app"},
+				"volume": [
+					{"name": "data", "usedBytes": 900, "availableBytes": 100, "capacityBytes": 1000, "inodesUsed": 10, "inodes": 100, "pvcRef": {"name": "data-db-0", "namespace": "app"}}
+				]
+			}
+		]
+	}`
+
+	var summary kubeletStatsSummary
+	assert.Nil(t, json.Unmarshal([]byte(data), &summary))
+
+	samples := volumeSamplesFromSummary(&summary)
+	assert.Len(t, samples, 1)
+	assert.Equal(t, &VolumeSample{
+		Namespace:      "app",
+		PVC:            "data-db-0",
+		UsedBytes:      900,
+		AvailableBytes: 100,
+		CapacityBytes:  1000,
+		InodesUsed:     10,
+		Inodes:         100,
+	}, samples[0])
+}
+
+func TestVolumeUsageWatcher(t *testing.T) {
+	w := &volumeUsageWatcher{threshold: 85, above: make(map[string]bool)}
+
+	data := &VolumeSample{Namespace: "app", PVC: "data", UsedBytes: 500, CapacityBytes: 1000, InodesUsed: 90, Inodes: 100}
+	logs := &VolumeSample{Namespace: "app", PVC: "logs", UsedBytes: 500, CapacityBytes: 1000}
+
+	// inodes of data are 90% used
+	assert.Equal(t, float64(90), volumeUsagePercent(data))
+	assert.Equal(t, []*VolumeSample{data}, w.crossed([]*VolumeSample{data, logs}))
+
+	// warned only once while staying above the threshold
+	assert.Len(t, w.crossed([]*VolumeSample{data, logs}), 0)
+
+	data.InodesUsed = 10
+	assert.Len(t, w.crossed([]*VolumeSample{data, logs}), 0)
+
+	data.UsedBytes = 950
+	assert.Equal(t, []*VolumeSample{data}, w.crossed([]*VolumeSample{data, logs}))
+}
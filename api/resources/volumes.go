@@ -4,6 +4,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	"strconv"
 
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
 	"github.com/kalmhq/kalm/controller/controllers"
 	coreV1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -24,6 +25,13 @@ type Volume struct {
 	PVC                 string            `json:"pvc"`
 	PV                  string            `json:"pvToMatch"`
 	StsVolClaimTemplate string            `json:"stsVolClaimTemplate,omitempty"`
+
+	// Resizing, FileSystemResizePending or Pending when the requested capacity is not allocated yet
+	ResizeStatus  string `json:"resizeStatus,omitempty"`
+	ResizeMessage string `json:"resizeMessage,omitempty"`
+
+	// Scraped from kubelet volume stats, nil if the volume is not mounted
+	Usage *VolumeUsage `json:"usage,omitempty"`
 }
 
 func (resourceManager *ResourceManager) BuildVolumeResponse(
@@ -49,6 +57,8 @@ func (resourceManager *ResourceManager) BuildVolumeResponse(
 
 	stsVolClaimTemplate := pvc.Labels[controllers.KalmLabelVolClaimTemplateName]
 
+	resizeStatus, resizeMessage := getResizeStatusOfPVC(pvc, capInQuantity, allocatedQuantity)
+
 	return &Volume{
 		Name:                pvc.Name,
 		ComponentName:       compName,
@@ -58,9 +68,50 @@ func (resourceManager *ResourceManager) BuildVolumeResponse(
 		RequestedCapacity:   capInQuantity,
 		AllocatedCapacity:   allocatedQuantity,
 		StsVolClaimTemplate: stsVolClaimTemplate,
+		ResizeStatus:        resizeStatus,
+		ResizeMessage:       resizeMessage,
+		Usage:               GetVolumeUsage(pvc.Namespace, pvc.Name),
 	}, nil
 }
 
+func getResizeStatusOfPVC(pvc coreV1.PersistentVolumeClaim, requested, allocated resource.Quantity) (string, string) {
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Status != coreV1.ConditionTrue {
+			continue
+		}
+
+		if condition.Type == coreV1.PersistentVolumeClaimResizing || condition.Type == coreV1.PersistentVolumeClaimFileSystemResizePending {
+			return string(condition.Type), condition.Message
+		}
+	}
+
+	// unbound claims are not allocated yet
+	if pvc.Status.Phase == coreV1.ClaimBound && requested.Cmp(allocated) > 0 {
+		return "Pending", ""
+	}
+
+	return "", ""
+}
+
+// CheckComponentVolumeExpansion returns an error if a size increase of the component volumes can't be applied to its claims
+func (resourceManager *ResourceManager) CheckComponentVolumeExpansion(component *v1alpha1.Component) error {
+	for _, vol := range component.Spec.Volumes {
+		pvcs, err := controllers.GetPVCsOfVolume(resourceManager.ctx, resourceManager.Client, component, vol)
+
+		if err != nil {
+			return err
+		}
+
+		for i := range pvcs {
+			if err := controllers.CheckVolumeExpansion(resourceManager.ctx, resourceManager.Client, &pvcs[i], vol.Size); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func formatQuantity(quantity resource.Quantity) string {
 	capInStr := strconv.FormatInt(quantity.Value(), 10)
 	return capInStr
@@ -2,6 +2,7 @@ package resources
 
 import (
 	"gotest.tools/assert"
+	coreV1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"testing"
 )
@@ -27,3 +28,30 @@ func TestFormatQuantity(t *testing.T) {
 		assert.Equal(t, test.FormatRst, str)
 	}
 }
+
+func TestGetResizeStatusOfPVC(t *testing.T) {
+	requested := resource.MustParse("2Gi")
+	allocated := resource.MustParse("1Gi")
+
+	pvc := coreV1.PersistentVolumeClaim{
+		Status: coreV1.PersistentVolumeClaimStatus{Phase: coreV1.ClaimBound},
+	}
+
+	status, _ := getResizeStatusOfPVC(pvc, requested, allocated)
+	assert.Equal(t, "Pending", status)
+
+	status, _ = getResizeStatusOfPVC(pvc, requested, requested)
+	assert.Equal(t, "", status)
+
+	pvc.Status.Conditions = []coreV1.PersistentVolumeClaimCondition{
+		{
+			Type:    coreV1.PersistentVolumeClaimFileSystemResizePending,
+			Status:  coreV1.ConditionTrue,
+			Message: "Waiting for user to (re-)start a pod to finish file system resize of volume on node.",
+		},
+	}
+
+	status, message := getResizeStatusOfPVC(pvc, requested, allocated)
+	assert.Equal(t, "FileSystemResizePending", status)
+	assert.Equal(t, pvc.Status.Conditions[0].Message, message)
+}
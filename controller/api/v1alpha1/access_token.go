@@ -48,9 +48,15 @@ type AccessTokenRule struct {
 type AccessTokenSpec struct {
 	Memo string `json:"memo,omitempty"`
 
-	// The token, the access token name should be sha256 of this token.
-	// +kubebuilder:validation:MinLength=64
-	Token string `json:"token"`
+	// Deprecated: plaintext token of legacy records, the access token name should be sha256 of this token.
+	// New tokens are never stored in plaintext. Legacy tokens are hashed into TokenHash on startup.
+	Token string `json:"token,omitempty"`
+
+	// Salted hash of the token, in format of "<salt>:<sha256 of salt and token>".
+	TokenHash string `json:"tokenHash,omitempty"`
+
+	// First few characters of the token. It's used to identify a token without revealing it.
+	TokenPrefix string `json:"tokenPrefix,omitempty"`
 
 	// Rules of this key
 	// +kubebuilder:validation:MinItems=1
@@ -74,6 +80,7 @@ type AccessTokenStatus struct {
 // +kubebuilder:resource:scope=Cluster
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Type",type="string",JSONPath=".metadata.labels.tokenType"
+// +kubebuilder:printcolumn:name="Prefix",type="string",JSONPath=".spec.tokenPrefix"
 // +kubebuilder:printcolumn:name="Creator",type="string",JSONPath=".spec.creator"
 // +kubebuilder:printcolumn:name="ExpiredAt",type="string",JSONPath=".spec.expiredAt"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
@@ -16,9 +16,13 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"strings"
+
 	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -42,6 +46,10 @@ var _ webhook.Defaulter = &AccessToken{}
 // Default implements webhook.Defaulter so a webhook will be registered for the type
 func (r *AccessToken) Default() {
 	accesstokenlog.Info("default", "name", r.Name)
+
+	if err := r.SealToken(); err != nil {
+		accesstokenlog.Error(err, "seal access token failed", "name", r.Name)
+	}
 }
 
 // +kubebuilder:webhook:verbs=create;update,path=/validate-core-kalm-dev-v1alpha1-accesstoken,mutating=false,failurePolicy=fail,groups=core.kalm.dev,resources=accesstokens,versions=v1alpha1,name=vaccesstoken.kb.io
@@ -70,7 +78,12 @@ func (r *AccessToken) ValidateUpdate(old runtime.Object) error {
 	if oldAccessToken, ok := old.(*AccessToken); !ok {
 		return fmt.Errorf("old object is not an access token")
 	} else {
-		if r.Spec.Token != oldAccessToken.Spec.Token {
+		// A legacy plaintext token is allowed to be replaced by its own hash.
+		isMigrating := oldAccessToken.Spec.Token != "" && r.Spec.Token == "" && r.MatchToken(oldAccessToken.Spec.Token)
+
+		if !isMigrating && (r.Spec.Token != oldAccessToken.Spec.Token ||
+			r.Spec.TokenHash != oldAccessToken.Spec.TokenHash ||
+			r.Spec.TokenPrefix != oldAccessToken.Spec.TokenPrefix) {
 			return fmt.Errorf("Can't modify token")
 		}
 	}
@@ -78,11 +91,85 @@ func (r *AccessToken) ValidateUpdate(old runtime.Object) error {
 	return r.validate()
 }
 
+// Length of the visible token prefix. It's long enough to tell tokens apart, but reveals nothing useful to an attacker.
+const AccessTokenPrefixLength = 8
+
+const accessTokenSaltLength = 16
+
 func GetAccessTokenNameFromToken(token string) string {
 	tokenHash := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(tokenHash[:])
 }
 
+func GetAccessTokenPrefix(token string) string {
+	if len(token) <= AccessTokenPrefixLength {
+		return token
+	}
+
+	return token[:AccessTokenPrefixLength]
+}
+
+// HashAccessToken returns a salted hash of the token, in format of "<salt>:<hash>"
+func HashAccessToken(token string) (string, error) {
+	salt := make([]byte, accessTokenSaltLength)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	return hashAccessTokenWithSalt(token, hex.EncodeToString(salt)), nil
+}
+
+func hashAccessTokenWithSalt(token, salt string) string {
+	hash := sha256.Sum256([]byte(salt + token))
+	return salt + ":" + hex.EncodeToString(hash[:])
+}
+
+// VerifyAccessTokenHash checks whether the token matches a hash generated by HashAccessToken
+func VerifyAccessTokenHash(token, tokenHash string) bool {
+	parts := strings.SplitN(tokenHash, ":", 2)
+
+	if len(parts) != 2 {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(hashAccessTokenWithSalt(token, parts[0])), []byte(tokenHash)) == 1
+}
+
+// SealToken replaces the plaintext token with a salted hash and a visible prefix.
+// It does nothing if the token is already sealed.
+func (r *AccessToken) SealToken() error {
+	if r.Spec.Token == "" {
+		return nil
+	}
+
+	tokenHash, err := HashAccessToken(r.Spec.Token)
+
+	if err != nil {
+		return err
+	}
+
+	r.Spec.TokenHash = tokenHash
+	r.Spec.TokenPrefix = GetAccessTokenPrefix(r.Spec.Token)
+	r.Spec.Token = ""
+
+	return nil
+}
+
+// MatchToken checks whether the given plaintext token belongs to this access token.
+// Legacy records which are not sealed yet are compared with the plaintext token directly.
+func (r *AccessToken) MatchToken(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	if r.Spec.TokenHash != "" {
+		return r.Spec.TokenPrefix == GetAccessTokenPrefix(token) && VerifyAccessTokenHash(token, r.Spec.TokenHash)
+	}
+
+	return r.Spec.Token != "" && subtle.ConstantTimeCompare([]byte(r.Spec.Token), []byte(token)) == 1
+}
+
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
 func (r *AccessToken) ValidateDelete() error {
 	accesstokenlog.Info("validate delete", "name", r.Name)
@@ -92,13 +179,29 @@ func (r *AccessToken) ValidateDelete() error {
 func (r *AccessToken) validate() error {
 	var rst KalmValidateErrorList
 
-	expectedName := GetAccessTokenNameFromToken(r.Spec.Token)
+	if r.Spec.Token != "" {
+		expectedName := GetAccessTokenNameFromToken(r.Spec.Token)
 
-	if expectedName != r.Name {
-		rst = append(rst, KalmValidateError{
-			Err:  fmt.Sprintf("name and token hash are not matched. Expect name: %s, but got %s", expectedName, r.Name),
-			Path: "spec.token",
-		})
+		if expectedName != r.Name {
+			rst = append(rst, KalmValidateError{
+				Err:  fmt.Sprintf("name and token hash are not matched. Expect name: %s, but got %s", expectedName, r.Name),
+				Path: "spec.token",
+			})
+		}
+	} else {
+		if len(strings.SplitN(r.Spec.TokenHash, ":", 2)) != 2 {
+			rst = append(rst, KalmValidateError{
+				Err:  "token hash is required and should be in format of <salt>:<hash>",
+				Path: "spec.tokenHash",
+			})
+		}
+
+		if len(r.Spec.TokenPrefix) != AccessTokenPrefixLength {
+			rst = append(rst, KalmValidateError{
+				Err:  fmt.Sprintf("token prefix should be %d characters", AccessTokenPrefixLength),
+				Path: "spec.tokenPrefix",
+			})
+		}
 	}
 
 	for i, rule := range r.Spec.Rules {
@@ -63,3 +63,72 @@ func TestAccessTokenValidateRules(t *testing.T) {
 
 	assert.Nil(t, key.validate())
 }
+
+func TestAccessTokenSealToken(t *testing.T) {
+	token := "abcdefghabcdefghabcdefghabcdefghabcdefghabcdefghabcdefghabcdefgh"
+
+	key := AccessToken{
+		ObjectMeta: ctrl.ObjectMeta{
+			Name: "abcdefgh-token",
+		},
+		Spec: AccessTokenSpec{
+			Token: token,
+		},
+	}
+
+	assert.Nil(t, key.SealToken())
+	assert.Empty(t, key.Spec.Token)
+	assert.Equal(t, "abcdefgh", key.Spec.TokenPrefix)
+	assert.NotContains(t, key.Spec.TokenHash, token)
+	assert.Nil(t, key.validate())
+
+	assert.True(t, key.MatchToken(token))
+	assert.False(t, key.MatchToken(token+"a"))
+	assert.False(t, key.MatchToken("abcdefgh"))
+	assert.False(t, key.MatchToken(""))
+
+	// the same token should be hashed with different salts
+	another := AccessToken{Spec: AccessTokenSpec{Token: token}}
+	assert.Nil(t, another.SealToken())
+	assert.NotEqual(t, key.Spec.TokenHash, another.Spec.TokenHash)
+}
+
+func TestAccessTokenMatchLegacyToken(t *testing.T) {
+	token := "abcdefghabcdefghabcdefghabcdefghabcdefghabcdefghabcdefghabcdefgh"
+
+	key := AccessToken{
+		Spec: AccessTokenSpec{
+			Token: token,
+		},
+	}
+
+	assert.True(t, key.MatchToken(token))
+	assert.False(t, key.MatchToken("abcdefgh"))
+}
+
+func TestAccessTokenValidateUpdateMigration(t *testing.T) {
+	token := "abcdefghabcdefghabcdefghabcdefghabcdefghabcdefghabcdefghabcdefgh"
+	tokenHash := sha256.Sum256([]byte(token))
+	name := hex.EncodeToString(tokenHash[:])
+
+	old := AccessToken{
+		ObjectMeta: ctrl.ObjectMeta{
+			Name: name,
+		},
+		Spec: AccessTokenSpec{
+			Token: token,
+		},
+	}
+
+	sealed := old.DeepCopy()
+	assert.Nil(t, sealed.SealToken())
+	assert.Nil(t, sealed.ValidateUpdate(&old))
+
+	another := AccessToken{Spec: AccessTokenSpec{Token: "another-token"}}
+	assert.Nil(t, another.SealToken())
+
+	modified := sealed.DeepCopy()
+	modified.Spec.TokenHash = another.Spec.TokenHash
+	assert.Contains(t, modified.ValidateUpdate(&old).Error(), "Can't modify token")
+	assert.Contains(t, modified.ValidateUpdate(sealed).Error(), "Can't modify token")
+}
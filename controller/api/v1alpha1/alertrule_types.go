@@ -0,0 +1,187 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:validation:Enum=cpu;memory;restartCount;podNotReadyDuration;http5xxRate;httpP95Latency
+type AlertRuleMetric string
+
+const (
+	// Cores used by pods
+	AlertRuleMetricCPU AlertRuleMetric = "cpu"
+
+	// Bytes used by pods
+	AlertRuleMetricMemory AlertRuleMetric = "memory"
+
+	// Restarts of containers in pods within AlertRuleRestartCountWindow
+	AlertRuleMetricRestartCount AlertRuleMetric = "restartCount"
+
+	// Seconds since the longest not ready pod became not ready
+	AlertRuleMetricPodNotReadyDuration AlertRuleMetric = "podNotReadyDuration"
+
+	// 5xx responses per second over 5 minutes, collected by istio
+	AlertRuleMetricHTTP5xxRate AlertRuleMetric = "http5xxRate"
+
+	// Seconds of the 95th percentile of request durations over 5 minutes, collected by istio
+	AlertRuleMetricHTTPP95Latency AlertRuleMetric = "httpP95Latency"
+)
+
+const (
+	// Restarts are counted in the window, so the rule is resolved once containers stop restarting
+	AlertRuleRestartCountWindow = 10 * time.Minute
+
+	// Restart counts are sampled with the interval, restarts are counted from the latest sample before the window.
+	// They may be counted in up to AlertRuleRestartCountWindow + AlertRuleRestartCountSampleInterval.
+	AlertRuleRestartCountSampleInterval = 2 * time.Minute
+)
+
+// +kubebuilder:validation:Enum=webhook;slack;email
+type AlertReceiverType string
+
+const (
+	// Notifications are posted as json to the url
+	AlertReceiverTypeWebhook AlertReceiverType = "webhook"
+
+	// Notifications are posted as slack compatible messages to the incoming webhook url
+	AlertReceiverTypeSlack AlertReceiverType = "slack"
+
+	// Notifications are sent through the smtp server configured in the kalm-alert-smtp secret of kalm-system
+	AlertReceiverTypeEmail AlertReceiverType = "email"
+)
+
+type AlertReceiver struct {
+	Type AlertReceiverType `json:"type"`
+
+	// Url of webhook and slack receivers
+	URL string `json:"url,omitempty"`
+
+	// Addresses of email receivers
+	To []string `json:"to,omitempty"`
+}
+
+// AlertRuleSpec defines the desired state of AlertRule
+type AlertRuleSpec struct {
+	// Blank means all components of the application
+	Component string `json:"component,omitempty"`
+
+	Metric AlertRuleMetric `json:"metric"`
+
+	// The rule fires when the value is above the threshold.
+	// Values are in cores, bytes, counts, seconds or requests per second, e.g. 500m, 1Gi, 3, 300, 0.5
+	// restartCount is the number of restarts in the last 10 minutes, see AlertRuleRestartCountWindow.
+	Threshold resource.Quantity `json:"threshold"`
+
+	// How long the value stays above the threshold before the rule fires
+	// +optional
+	For *metav1.Duration `json:"for,omitempty"`
+
+	// +kubebuilder:validation:MinItems=1
+	Receivers []AlertReceiver `json:"receivers"`
+
+	// No notifications are sent before the time. The rule is still evaluated and its history is still recorded.
+	// +optional
+	SilencedUntil *metav1.Time `json:"silencedUntil,omitempty"`
+}
+
+type AlertState string
+
+const (
+	AlertStateOK      AlertState = "OK"
+	AlertStatePending AlertState = "Pending"
+	AlertStateFiring  AlertState = "Firing"
+
+	// The value can't be evaluated, e.g. metrics are not available
+	AlertStateUnknown AlertState = "Unknown"
+)
+
+// How many state changes are kept in the status of a rule
+const AlertRuleHistoryLimit = 20
+
+// AlertRecord is a state change of a rule
+type AlertRecord struct {
+	State AlertState  `json:"state"`
+	Value string      `json:"value,omitempty"`
+	Time  metav1.Time `json:"time"`
+
+	// Receivers are notified when the rule fires or is resolved
+	Notified bool `json:"notified,omitempty"`
+	Silenced bool `json:"silenced,omitempty"`
+
+	// Failed evaluation or notifications
+	Error string `json:"error,omitempty"`
+}
+
+// AlertRestartCountSample is the restart counts of containers at the time, keyed by pod uid and container name
+type AlertRestartCountSample struct {
+	Time   metav1.Time      `json:"time"`
+	Counts map[string]int32 `json:"counts,omitempty"`
+}
+
+// AlertRuleStatus defines the observed state of AlertRule
+type AlertRuleStatus struct {
+	State AlertState `json:"state,omitempty"`
+	Value string     `json:"value,omitempty"`
+
+	// When the value went above the threshold
+	PendingSince    *metav1.Time `json:"pendingSince,omitempty"`
+	LastEvaluatedAt *metav1.Time `json:"lastEvaluatedAt,omitempty"`
+
+	// The latest comes last
+	History []AlertRecord `json:"history,omitempty"`
+
+	// firing or resolved, the notification which failed to be delivered, it's retried in later evaluations
+	UndeliveredNotification string `json:"undeliveredNotification,omitempty"`
+
+	// Samples of the restartCount metric in the window, the oldest comes first
+	RestartCountSamples []AlertRestartCountSample `json:"restartCountSamples,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Component",type="string",JSONPath=".spec.component"
+// +kubebuilder:printcolumn:name="Metric",type="string",JSONPath=".spec.metric"
+// +kubebuilder:printcolumn:name="Threshold",type="string",JSONPath=".spec.threshold"
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AlertRule is the Schema for the alertrules API
+// Rules are evaluated by the controller periodically, receivers are notified when a rule fires or is resolved.
+type AlertRule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AlertRuleSpec   `json:"spec,omitempty"`
+	Status AlertRuleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AlertRuleList contains a list of AlertRule
+type AlertRuleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AlertRule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AlertRule{}, &AlertRuleList{})
+}
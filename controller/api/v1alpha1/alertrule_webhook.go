@@ -0,0 +1,149 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var alertrulelog = logf.Log.WithName("alertrule-resource")
+
+func (r *AlertRule) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-core-kalm-dev-v1alpha1-alertrule,mutating=false,failurePolicy=fail,groups=core.kalm.dev,resources=alertrules,versions=v1alpha1,name=valertrule.kb.io
+
+var _ webhook.Validator = &AlertRule{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *AlertRule) ValidateCreate() error {
+	alertrulelog.Info("validate create", "name", r.Name)
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *AlertRule) ValidateUpdate(old runtime.Object) error {
+	alertrulelog.Info("validate update", "name", r.Name)
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *AlertRule) ValidateDelete() error {
+	alertrulelog.Info("validate delete", "name", r.Name)
+	return nil
+}
+
+func isValidAlertRuleMetric(metric AlertRuleMetric) bool {
+	switch metric {
+	case AlertRuleMetricCPU, AlertRuleMetricMemory, AlertRuleMetricRestartCount, AlertRuleMetricPodNotReadyDuration,
+		AlertRuleMetricHTTP5xxRate, AlertRuleMetricHTTPP95Latency:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *AlertRule) validate() error {
+	var rst KalmValidateErrorList
+
+	if r.Spec.Component != "" {
+		for _, msg := range validation.IsDNS1123Label(r.Spec.Component) {
+			rst = append(rst, KalmValidateError{
+				Err:  msg,
+				Path: "spec.component",
+			})
+		}
+	}
+
+	if !isValidAlertRuleMetric(r.Spec.Metric) {
+		rst = append(rst, KalmValidateError{
+			Err:  fmt.Sprintf("invalid metric %s", r.Spec.Metric),
+			Path: "spec.metric",
+		})
+	}
+
+	if r.Spec.Threshold.Sign() < 0 {
+		rst = append(rst, KalmValidateError{
+			Err:  "threshold should not be negative",
+			Path: "spec.threshold",
+		})
+	}
+
+	if r.Spec.For != nil && r.Spec.For.Duration < 0 {
+		rst = append(rst, KalmValidateError{
+			Err:  "for should not be negative",
+			Path: "spec.for",
+		})
+	}
+
+	if len(r.Spec.Receivers) == 0 {
+		rst = append(rst, KalmValidateError{
+			Err:  "receivers should not be empty",
+			Path: "spec.receivers",
+		})
+	}
+
+	for i, receiver := range r.Spec.Receivers {
+		switch receiver.Type {
+		case AlertReceiverTypeWebhook, AlertReceiverTypeSlack:
+			if u, err := url.Parse(receiver.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				rst = append(rst, KalmValidateError{
+					Err:  "url should be a http or https url",
+					Path: fmt.Sprintf("spec.receivers[%d].url", i),
+				})
+			}
+		case AlertReceiverTypeEmail:
+			if len(receiver.To) == 0 {
+				rst = append(rst, KalmValidateError{
+					Err:  "to should not be empty",
+					Path: fmt.Sprintf("spec.receivers[%d].to", i),
+				})
+			}
+
+			for j, to := range receiver.To {
+				if _, err := mail.ParseAddress(to); err != nil {
+					rst = append(rst, KalmValidateError{
+						Err:  fmt.Sprintf("invalid email address %s", to),
+						Path: fmt.Sprintf("spec.receivers[%d].to[%d]", i, j),
+					})
+				}
+			}
+		default:
+			rst = append(rst, KalmValidateError{
+				Err:  fmt.Sprintf("invalid receiver type %s", receiver.Type),
+				Path: fmt.Sprintf("spec.receivers[%d].type", i),
+			})
+		}
+	}
+
+	if len(rst) == 0 {
+		return nil
+	}
+
+	return rst
+}
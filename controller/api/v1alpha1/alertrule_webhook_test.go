@@ -0,0 +1,58 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestAlertRuleValidate(t *testing.T) {
+	rule := AlertRule{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: "app",
+			Name:      "high-cpu",
+		},
+		Spec: AlertRuleSpec{
+			Component: "web",
+			Metric:    AlertRuleMetricCPU,
+			Threshold: resource.MustParse("500m"),
+			For:       &metav1.Duration{Duration: 5 * time.Minute},
+			Receivers: []AlertReceiver{
+				{Type: AlertReceiverTypeWebhook, URL: "https://example.com/hook"},
+				{Type: AlertReceiverTypeSlack, URL: "https://hooks.slack.com/services/xxx"},
+				{Type: AlertReceiverTypeEmail, To: []string{"ops@example.com"}},
+			},
+		},
+	}
+
+	assert.Nil(t, rule.validate())
+
+	rule.Spec.Metric = "disk"
+	assert.NotNil(t, rule.validate())
+
+	rule.Spec.Metric = AlertRuleMetricHTTPP95Latency
+	rule.Spec.Threshold = resource.MustParse("-1")
+	assert.NotNil(t, rule.validate())
+
+	rule.Spec.Threshold = resource.MustParse("0.3")
+	rule.Spec.Component = "Web_1"
+	assert.NotNil(t, rule.validate())
+
+	rule.Spec.Component = ""
+	rule.Spec.Receivers[0].URL = "ftp://example.com"
+	assert.NotNil(t, rule.validate())
+
+	rule.Spec.Receivers[0].URL = "http://example.com"
+	rule.Spec.Receivers[2].To = []string{"not an address"}
+	assert.NotNil(t, rule.validate())
+
+	rule.Spec.Receivers[2].To = nil
+	assert.NotNil(t, rule.validate())
+
+	rule.Spec.Receivers = nil
+	assert.NotNil(t, rule.validate())
+}
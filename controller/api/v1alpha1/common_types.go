@@ -7,6 +7,18 @@ import (
 
 const KalmSystemNamespace = "kalm-system"
 
+// Envs with type external read values from this configmap in the application namespace.
+// The env value is the key in the configmap.
+const SharedEnvConfigMapName = "kalm-shared-env"
+
+// Envs with type secret refer to keys of this secret in the application namespace.
+// Values are never copied into components or workloads.
+const SecretEnvSecretName = "kalm-secret-env"
+
+// Annotation of the secret env secret, a json object of the last updated time of each key.
+// Keys of a secret have no metadata of their own.
+const SecretEnvUpdatedAtAnnotation = "kalm.dev/secret-env-updated-at"
+
 type EnvVarType string
 
 const (
@@ -15,6 +27,7 @@ const (
 	EnvVarTypeLinked   EnvVarType = "linked"
 	EnvVarTypeFieldRef EnvVarType = "fieldref"
 	EnvVarTypeBuiltin  EnvVarType = "builtin"
+	EnvVarTypeSecret   EnvVarType = "secret"
 
 	EnvVarBuiltinHost      string = "host"
 	EnvVarBuiltinPodName   string = "podName"
@@ -43,7 +56,7 @@ type EnvVar struct {
 
 	Value string `json:"value,omitempty"`
 
-	// +kubebuilder:validation:Enum=static;external;linked;fieldref;builtin
+	// +kubebuilder:validation:Enum=static;external;linked;fieldref;builtin;secret
 	Type EnvVarType `json:"type,omitempty"`
 
 	Prefix string `json:"prefix,omitempty"`
@@ -103,6 +116,24 @@ type Volume struct {
 	//
 	// for Type: pvc, required, todo validate this in webhook?
 	PVC string `json:"pvc,omitempty"`
+
+	// Take volume snapshots of the pvc periodically, only for Type: pvc and pvcTemplate
+	// +optional
+	SnapshotSchedule *VolumeSnapshotSchedule `json:"snapshotSchedule,omitempty"`
+}
+
+// VolumeSnapshotSchedule requires the CSI VolumeSnapshot api in the cluster
+type VolumeSnapshotSchedule struct {
+	// Standard cron format, e.g. "0 3 * * *"
+	Schedule string `json:"schedule"`
+
+	// How many scheduled snapshots are kept for each pvc, the oldest ones are deleted first
+	// +kubebuilder:validation:Minimum=1
+	Retention int `json:"retention"`
+
+	// Blank means the default VolumeSnapshotClass
+	// +optional
+	VolumeSnapshotClassName *string `json:"volumeSnapshotClassName,omitempty"`
 }
 
 type Config struct {
@@ -17,8 +17,10 @@ package v1alpha1
 
 import (
 	apps1 "k8s.io/api/apps/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type PreInjectFile struct {
@@ -57,8 +59,25 @@ type ComponentSpec struct {
 	NodeSelectorLabels map[string]string `json:"nodeSelectorLabels,omitempty"`
 	PreferNotCoLocated bool              `json:"preferNotCoLocated,omitempty"`
 
+	// Allow pods to be scheduled on nodes with matching taints
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+
+	// Node affinity expressions. They are combined with NodeSelectorLabels.
+	NodeAffinity *NodeAffinity `json:"nodeAffinity,omitempty"`
+
+	// Spread pods evenly across zones or nodes
+	TopologySpread []TopologySpread `json:"topologySpread,omitempty"`
+
+	// Keep enough pods available during voluntary disruptions, e.g. node drains
+	DisruptionBudget *DisruptionBudget `json:"disruptionBudget,omitempty"`
+
 	StartAfterComponents []string `json:"startAfterComponents,omitempty"`
 
+	// How long to wait for components in StartAfterComponents to be ready.
+	// The workload is started with a warning once the timeout is reached. Wait forever if not set.
+	// +kubebuilder:validation:Minimum=1
+	StartAfterComponentsTimeoutSeconds *int32 `json:"startAfterComponentsTimeoutSeconds,omitempty"`
+
 	Command string `json:"command,omitempty"`
 
 	// +optional
@@ -66,11 +85,17 @@ type ComponentSpec struct {
 
 	Ports []Port `json:"ports,omitempty"`
 
-	// +kubebuilder:validation:Enum=server;cronjob;statefulset;daemonset
+	// +kubebuilder:validation:Enum=server;cronjob;statefulset;daemonset;job
 	WorkloadType WorkloadType `json:"workloadType,omitempty"`
 
 	Schedule string `json:"schedule,omitempty"`
 
+	// Options of cronjob workloads
+	CronJob *CronJobConfig `json:"cronJob,omitempty"`
+
+	// Options of job workloads
+	Job *JobConfig `json:"job,omitempty"`
+
 	// +k8s:openapi-gen=true
 	// +optional
 	LivenessProbe *v1.Probe `json:"livenessProbe,omitempty"`
@@ -78,11 +103,12 @@ type ComponentSpec struct {
 	// +optional
 	ReadinessProbe *v1.Probe `json:"readinessProbe,omitempty"`
 
-	// Deprecated
+	// Commands run in order by an init container with the image, envs and volumes of the component.
+	// The main container is not started until all of them succeed.
 	BeforeStart []string `json:"beforeStart,omitempty"`
-	// Deprecated
+	// Commands run in order by the postStart hook of the main container.
 	AfterStart []string `json:"afterStart,omitempty"`
-	// Deprecated
+	// Commands run in order by the preStop hook of the main container.
 	BeforeDestroy []string `json:"beforeDestroy,omitempty"`
 
 	// +optional
@@ -117,13 +143,183 @@ type ComponentSpec struct {
 	DirectConfigs []DirectConfig `json:"directConfigs,omitempty"`
 }
 
+// +kubebuilder:validation:Enum=zone;node
+type TopologySpreadTopology string
+
+const (
+	TopologySpreadTopologyZone TopologySpreadTopology = "zone"
+	TopologySpreadTopologyNode TopologySpreadTopology = "node"
+)
+
+type TopologySpread struct {
+	Topology TopologySpreadTopology `json:"topology"`
+
+	// The max difference of pod numbers between two zones or nodes, defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxSkew int32 `json:"maxSkew,omitempty"`
+
+	// Pods breaking the constraint are not scheduled. By default they are scheduled anyway.
+	// +optional
+	Required bool `json:"required,omitempty"`
+}
+
+// Only one of MinAvailable and MaxUnavailable can be set. Both accept numbers or percentages.
+type DisruptionBudget struct {
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+type NodeAffinity struct {
+	// Pods are only scheduled on nodes matching all of the expressions
+	// +optional
+	Required []v1.NodeSelectorRequirement `json:"required,omitempty"`
+
+	// Nodes matching more preferred terms are favored by the scheduler
+	// +optional
+	Preferred []PreferredNodeAffinityTerm `json:"preferred,omitempty"`
+}
+
+type PreferredNodeAffinityTerm struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+
+	// +kubebuilder:validation:MinItems=1
+	MatchExpressions []v1.NodeSelectorRequirement `json:"matchExpressions"`
+}
+
+type CronJobConfig struct {
+	// Suspend subsequent runs, runs already started are not affected
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// How to treat concurrent runs, defaults to Allow
+	// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+	// +optional
+	ConcurrencyPolicy batchv1beta1.ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// How many successful runs to keep, defaults to 3
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
+
+	// How many failed runs to keep, defaults to 5
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+}
+
+// Jobs are immutable once started. Changes take effect on the next run.
+type JobConfig struct {
+	// How many pods must succeed, defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Completions *int32 `json:"completions,omitempty"`
+
+	// How many pods can run at the same time, defaults to 1
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Parallelism *int32 `json:"parallelism,omitempty"`
+
+	// How many retries before the run is marked as failed, defaults to 6
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// Finished runs are deleted after this many seconds. Keep them forever if not set.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+type ComponentConditionType string
+
+const (
+	// All envs with type external are found in the shared env of the application
+	ComponentConditionExternalEnvResolved ComponentConditionType = "ExternalEnvResolved"
+
+	// All envs with type secret are found in the secret env of the application
+	ComponentConditionSecretEnvResolved ComponentConditionType = "SecretEnvResolved"
+
+	// All components in StartAfterComponents are ready
+	ComponentConditionDependenciesReady ComponentConditionType = "DependenciesReady"
+
+	// BeforeStart, AfterStart and BeforeDestroy commands of current pods didn't fail
+	ComponentConditionLifecycleCommandsSucceeded ComponentConditionType = "LifecycleCommandsSucceeded"
+)
+
+type ComponentCondition struct {
+	Type   ComponentConditionType `json:"type"`
+	Status v1.ConditionStatus     `json:"status"`
+
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
 // ComponentStatus defines the observed state of Component
 type ComponentStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// +optional
+	Conditions []ComponentCondition `json:"conditions,omitempty"`
+
+	// The last run a Job is created for. A run is never created twice,
+	// so runs deleted after TTLSecondsAfterFinished are not started again.
+	// +optional
+	LastJobRun int `json:"lastJobRun,omitempty"`
+}
+
+func (s *ComponentStatus) GetCondition(conditionType ComponentConditionType) *ComponentCondition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// SetCondition adds or updates a condition. LastTransitionTime is only changed when the status changes.
+func (s *ComponentStatus) SetCondition(condition ComponentCondition) {
+	existing := s.GetCondition(condition.Type)
+
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.Now()
+		}
+
+		s.Conditions = append(s.Conditions, condition)
+		return
+	}
+
+	if existing.Status != condition.Status {
+		existing.Status = condition.Status
+		existing.LastTransitionTime = metav1.Now()
+	}
+
+	existing.Reason = condition.Reason
+	existing.Message = condition.Message
+}
+
+func (s *ComponentStatus) RemoveCondition(conditionType ComponentConditionType) {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			s.Conditions = append(s.Conditions[:i], s.Conditions[i+1:]...)
+			return
+		}
+	}
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Workload",type="string",JSONPath=".spec.workloadType"
 // +kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.image"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
@@ -20,13 +20,16 @@ import (
 	"fmt"
 	"github.com/robfig/cron"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	apimachineryval "k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"math/rand"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -134,6 +137,10 @@ func (r *Component) ValidateUpdate(old runtime.Object) error {
 		}
 	}
 
+	if oldComponent, ok := old.(*Component); ok {
+		volErrList = append(volErrList, r.validateVolumeSizeUpdate(oldComponent)...)
+	}
+
 	commonValidateErr := r.validate()
 
 	volErrList = append(volErrList, commonValidateErr...)
@@ -157,16 +164,7 @@ func isIdenticalVolMap(mapNew map[string]Volume, mapOld map[string]Volume) (bool
 			return false, fmt.Errorf("volume not exist in old resource: %s", volName)
 		}
 
-		// storage request
-		storageRequestNew := volNew.Size
-		storageRequestOld := volOld.Size
-		if !storageRequestNew.Equal(storageRequestOld) {
-			return false, fmt.Errorf("volume size changed, %s -> %s",
-				storageRequestOld.String(),
-				storageRequestNew.String(),
-			)
-		}
-
+		// size can be increased, claims are expanded by the controller, shrinking is checked in validateVolumeSizeUpdate
 		// storageClass
 		scNew := volNew.StorageClassName
 		scOld := volOld.StorageClassName
@@ -181,6 +179,32 @@ func isIdenticalVolMap(mapNew map[string]Volume, mapOld map[string]Volume) (bool
 	return true, nil
 }
 
+// persistent volumes can be expanded but not shrunk
+func (r *Component) validateVolumeSizeUpdate(old *Component) (rst KalmValidateErrorList) {
+	oldSizes := make(map[string]resource.Quantity)
+
+	for _, vol := range old.Spec.Volumes {
+		if vol.Type == VolumeTypePersistentVolumeClaim || vol.Type == VolumeTypePersistentVolumeClaimTemplate {
+			oldSizes[string(vol.Type)+"/"+vol.PVC] = vol.Size
+		}
+	}
+
+	for i, vol := range r.Spec.Volumes {
+		oldSize, exist := oldSizes[string(vol.Type)+"/"+vol.PVC]
+
+		if !exist || vol.Size.Cmp(oldSize) >= 0 {
+			continue
+		}
+
+		rst = append(rst, KalmValidateError{
+			Err:  fmt.Sprintf("volume size can't be decreased, %s -> %s", oldSize.String(), vol.Size.String()),
+			Path: fmt.Sprintf(".spec.volumes[%d].size", i),
+		})
+	}
+
+	return
+}
+
 func getStsTemplateVolMap(component *Component) map[string]Volume {
 	rst := make(map[string]Volume)
 
@@ -205,11 +229,17 @@ func (r *Component) validate() KalmValidateErrorList {
 	rst = append(rst, r.validateEnvVarList()...)
 	rst = append(rst, validateLabels(r.Spec.NodeSelectorLabels, ".spec.nodeSelectorLabels")...)
 	rst = append(rst, r.validateScheduleOfComponentIfIsCronJob()...)
+	rst = append(rst, r.validateJobConfig()...)
+	rst = append(rst, r.validateCronJobConfig()...)
 	rst = append(rst, r.validateProbes()...)
 	rst = append(rst, r.validateResRequirement()...)
 	rst = append(rst, r.validateVolumesOfComponent()...)
 	rst = append(rst, r.validateRunnerPermission()...)
 	rst = append(rst, r.validatePreInjectedFiles()...)
+	rst = append(rst, r.validateDisruptionBudget()...)
+	rst = append(rst, r.validateTopologySpread()...)
+	rst = append(rst, r.validateTolerations()...)
+	rst = append(rst, r.validateNodeAffinity()...)
 
 	if len(rst) == 0 {
 		return nil
@@ -253,6 +283,10 @@ func (r *Component) validateVolumesOfComponent() (rst KalmValidateErrorList) {
 			}
 		}
 
+		if vol.SnapshotSchedule != nil {
+			rst = append(rst, validateVolumeSnapshotSchedule(vol, fmt.Sprintf(".spec.volumes[%d].snapshotSchedule", i))...)
+		}
+
 		if vol.Type == VolumeTypeHostPath {
 			if vol.HostPath == "" {
 				rst = append(rst, KalmValidateError{
@@ -323,13 +357,35 @@ func (r *Component) validateVolumesOfComponent() (rst KalmValidateErrorList) {
 
 func (r *Component) isStatelessWorkload() bool {
 	switch r.Spec.WorkloadType {
-	case WorkloadTypeServer, WorkloadTypeDaemonSet, WorkloadTypeCronjob:
+	case WorkloadTypeServer, WorkloadTypeDaemonSet, WorkloadTypeCronjob, WorkloadTypeJob:
 		return true
 	default:
 		return false
 	}
 }
 
+func (r *Component) validateJobConfig() (rst KalmValidateErrorList) {
+	if r.Spec.Job != nil && r.Spec.WorkloadType != WorkloadTypeJob {
+		rst = append(rst, KalmValidateError{
+			Err:  "job options are only supported by job workloads",
+			Path: ".spec.job",
+		})
+	}
+
+	return rst
+}
+
+func (r *Component) validateCronJobConfig() (rst KalmValidateErrorList) {
+	if r.Spec.CronJob != nil && r.Spec.WorkloadType != WorkloadTypeCronjob {
+		rst = append(rst, KalmValidateError{
+			Err:  "cronJob options are only supported by cronjob workloads",
+			Path: ".spec.cronJob",
+		})
+	}
+
+	return rst
+}
+
 func (r *Component) validateScheduleOfComponentIfIsCronJob() (rst KalmValidateErrorList) {
 	if r.Spec.WorkloadType != WorkloadTypeCronjob {
 		return nil
@@ -346,6 +402,31 @@ func (r *Component) validateScheduleOfComponentIfIsCronJob() (rst KalmValidateEr
 	return
 }
 
+func validateVolumeSnapshotSchedule(vol Volume, fieldPath string) (rst KalmValidateErrorList) {
+	if vol.Type != VolumeTypePersistentVolumeClaim && vol.Type != VolumeTypePersistentVolumeClaimTemplate {
+		rst = append(rst, KalmValidateError{
+			Err:  fmt.Sprintf("snapshots are only supported by %s and %s volumes", VolumeTypePersistentVolumeClaim, VolumeTypePersistentVolumeClaimTemplate),
+			Path: fieldPath,
+		})
+	}
+
+	if _, err := cron.ParseStandard(vol.SnapshotSchedule.Schedule); err != nil {
+		rst = append(rst, KalmValidateError{
+			Err:  err.Error(),
+			Path: fieldPath + ".schedule",
+		})
+	}
+
+	if vol.SnapshotSchedule.Retention < 1 {
+		rst = append(rst, KalmValidateError{
+			Err:  "retention should be at least 1",
+			Path: fieldPath + ".retention",
+		})
+	}
+
+	return
+}
+
 func validateLabels(labels map[string]string, fieldPath string) (rst KalmValidateErrorList) {
 	if valid, errList := isValidLabels(labels, field.NewPath(fieldPath)); !valid {
 		return toKalmValidateErrors(errList)
@@ -385,6 +466,184 @@ func (r *Component) validateEnvVarList() (rst KalmValidateErrorList) {
 				Path: fmt.Sprintf(".spec.env[%d]", i),
 			})
 		}
+
+		if env.Type == EnvVarTypeSecret {
+			for _, err := range apimachineryval.IsConfigMapKey(env.Value) {
+				rst = append(rst, KalmValidateError{
+					Err:  "invalid secret key: " + err,
+					Path: fmt.Sprintf(".spec.env[%d].value", i),
+				})
+			}
+		}
+	}
+
+	return rst
+}
+
+func (r *Component) validateDisruptionBudget() (rst KalmValidateErrorList) {
+	budget := r.Spec.DisruptionBudget
+
+	if budget == nil {
+		return nil
+	}
+
+	if r.Spec.WorkloadType != WorkloadTypeServer && r.Spec.WorkloadType != WorkloadTypeStatefulSet {
+		rst = append(rst, KalmValidateError{
+			Err:  "disruption budget is only supported by server and statefulset workloads",
+			Path: ".spec.disruptionBudget",
+		})
+	}
+
+	if (budget.MinAvailable == nil) == (budget.MaxUnavailable == nil) {
+		rst = append(rst, KalmValidateError{
+			Err:  "exactly one of minAvailable and maxUnavailable must be set",
+			Path: ".spec.disruptionBudget",
+		})
+
+		return rst
+	}
+
+	for path, value := range map[string]*intstr.IntOrString{
+		".spec.disruptionBudget.minAvailable":   budget.MinAvailable,
+		".spec.disruptionBudget.maxUnavailable": budget.MaxUnavailable,
+	} {
+		if value == nil {
+			continue
+		}
+
+		if value.Type == intstr.Int && value.IntVal < 0 {
+			rst = append(rst, KalmValidateError{Err: "must not be negative", Path: path})
+		}
+
+		if value.Type == intstr.String {
+			percent, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%"))
+
+			if !strings.HasSuffix(value.StrVal, "%") || err != nil || percent < 0 || percent > 100 {
+				rst = append(rst, KalmValidateError{Err: "must be a number or a percentage between 0% and 100%", Path: path})
+			}
+		}
+	}
+
+	return rst
+}
+
+func (r *Component) validateTopologySpread() (rst KalmValidateErrorList) {
+	seen := make(map[TopologySpreadTopology]bool)
+
+	for i, spread := range r.Spec.TopologySpread {
+		if spread.Topology != TopologySpreadTopologyZone && spread.Topology != TopologySpreadTopologyNode {
+			rst = append(rst, KalmValidateError{
+				Err:  "topology must be zone or node",
+				Path: fmt.Sprintf(".spec.topologySpread[%d].topology", i),
+			})
+		}
+
+		if seen[spread.Topology] {
+			rst = append(rst, KalmValidateError{
+				Err:  "duplicate topology",
+				Path: fmt.Sprintf(".spec.topologySpread[%d].topology", i),
+			})
+		}
+
+		seen[spread.Topology] = true
+	}
+
+	return rst
+}
+
+func (r *Component) validateTolerations() (rst KalmValidateErrorList) {
+	for i, toleration := range r.Spec.Tolerations {
+		path := fmt.Sprintf(".spec.tolerations[%d]", i)
+
+		if toleration.Key != "" {
+			for _, msg := range apimachineryval.IsQualifiedName(toleration.Key) {
+				rst = append(rst, KalmValidateError{Err: msg, Path: path + ".key"})
+			}
+		}
+
+		switch toleration.Operator {
+		case v1.TolerationOpEqual, "":
+			if toleration.Key == "" {
+				rst = append(rst, KalmValidateError{Err: "key is required when operator is Equal", Path: path + ".key"})
+			}
+
+			for _, msg := range apimachineryval.IsValidLabelValue(toleration.Value) {
+				rst = append(rst, KalmValidateError{Err: msg, Path: path + ".value"})
+			}
+		case v1.TolerationOpExists:
+			if toleration.Value != "" {
+				rst = append(rst, KalmValidateError{Err: "value must be empty when operator is Exists", Path: path + ".value"})
+			}
+		default:
+			rst = append(rst, KalmValidateError{Err: "operator must be Equal or Exists", Path: path + ".operator"})
+		}
+
+		switch toleration.Effect {
+		case "", v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+		default:
+			rst = append(rst, KalmValidateError{Err: "effect must be NoSchedule, PreferNoSchedule or NoExecute", Path: path + ".effect"})
+		}
+
+		if toleration.TolerationSeconds != nil && toleration.Effect != v1.TaintEffectNoExecute {
+			rst = append(rst, KalmValidateError{Err: "tolerationSeconds is only allowed with the NoExecute effect", Path: path + ".tolerationSeconds"})
+		}
+	}
+
+	return rst
+}
+
+func (r *Component) validateNodeAffinity() (rst KalmValidateErrorList) {
+	affinity := r.Spec.NodeAffinity
+
+	if affinity == nil {
+		return nil
+	}
+
+	rst = append(rst, validateNodeSelectorRequirements(affinity.Required, ".spec.nodeAffinity.required")...)
+
+	for i, term := range affinity.Preferred {
+		path := fmt.Sprintf(".spec.nodeAffinity.preferred[%d]", i)
+
+		if term.Weight < 1 || term.Weight > 100 {
+			rst = append(rst, KalmValidateError{Err: "weight must be between 1 and 100", Path: path + ".weight"})
+		}
+
+		if len(term.MatchExpressions) == 0 {
+			rst = append(rst, KalmValidateError{Err: "at least one match expression is required", Path: path + ".matchExpressions"})
+		}
+
+		rst = append(rst, validateNodeSelectorRequirements(term.MatchExpressions, path+".matchExpressions")...)
+	}
+
+	return rst
+}
+
+func validateNodeSelectorRequirements(requirements []v1.NodeSelectorRequirement, fieldPath string) (rst KalmValidateErrorList) {
+	for i, requirement := range requirements {
+		path := fmt.Sprintf("%s[%d]", fieldPath, i)
+
+		for _, msg := range apimachineryval.IsQualifiedName(requirement.Key) {
+			rst = append(rst, KalmValidateError{Err: msg, Path: path + ".key"})
+		}
+
+		switch requirement.Operator {
+		case v1.NodeSelectorOpIn, v1.NodeSelectorOpNotIn:
+			if len(requirement.Values) == 0 {
+				rst = append(rst, KalmValidateError{Err: "values must be set when operator is In or NotIn", Path: path + ".values"})
+			}
+		case v1.NodeSelectorOpExists, v1.NodeSelectorOpDoesNotExist:
+			if len(requirement.Values) > 0 {
+				rst = append(rst, KalmValidateError{Err: "values must be empty when operator is Exists or DoesNotExist", Path: path + ".values"})
+			}
+		case v1.NodeSelectorOpGt, v1.NodeSelectorOpLt:
+			if len(requirement.Values) != 1 {
+				rst = append(rst, KalmValidateError{Err: "exactly one value must be set when operator is Gt or Lt", Path: path + ".values"})
+			} else if _, err := strconv.ParseInt(requirement.Values[0], 10, 64); err != nil {
+				rst = append(rst, KalmValidateError{Err: "value must be an integer when operator is Gt or Lt", Path: path + ".values"})
+			}
+		default:
+			rst = append(rst, KalmValidateError{Err: "operator must be one of In, NotIn, Exists, DoesNotExist, Gt and Lt", Path: path + ".operator"})
+		}
 	}
 
 	return rst
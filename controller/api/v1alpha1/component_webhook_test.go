@@ -3,7 +3,9 @@ package v1alpha1
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"testing"
 )
@@ -216,3 +218,258 @@ func TestSTSForbiddenUpdatePersistVol(t *testing.T) {
 	assert.NotNil(t, err)
 	assert.Contains(t, err.Error(), "should not update volume of type: pvcTemplate")
 }
+
+func TestComponentSecretEnvMustBeValidKey(t *testing.T) {
+	component := Component{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: "kalm-system",
+			Name:      "kalm-comp-secret-env",
+		},
+		Spec: ComponentSpec{
+			Image: fmt.Sprintf("%s:%s", "foo", "bar"),
+			Env: []EnvVar{
+				{Name: "DB_PASSWORD", Type: EnvVarTypeSecret, Value: "db-password"},
+			},
+		},
+	}
+
+	component.Default()
+	assert.Nil(t, component.validate())
+
+	component.Spec.Env[0].Value = "db password"
+	err := component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "invalid secret key")
+}
+
+func TestComponentDisruptionBudgetAndTopologySpread(t *testing.T) {
+	minAvailable := intstr.FromString("50%")
+	maxUnavailable := intstr.FromInt(1)
+
+	component := Component{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: "kalm-system",
+			Name:      "kalm-comp-pdb",
+		},
+		Spec: ComponentSpec{
+			Image: fmt.Sprintf("%s:%s", "foo", "bar"),
+			DisruptionBudget: &DisruptionBudget{
+				MinAvailable: &minAvailable,
+			},
+			TopologySpread: []TopologySpread{
+				{Topology: TopologySpreadTopologyZone},
+				{Topology: TopologySpreadTopologyNode, MaxSkew: 2, Required: true},
+			},
+		},
+	}
+
+	component.Default()
+	assert.Nil(t, component.validate())
+
+	component.Spec.DisruptionBudget.MaxUnavailable = &maxUnavailable
+	err := component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "exactly one of minAvailable and maxUnavailable")
+
+	invalidPercent := intstr.FromString("150%")
+	component.Spec.DisruptionBudget = &DisruptionBudget{MinAvailable: &invalidPercent}
+	assert.NotNil(t, component.validate())
+
+	component.Spec.DisruptionBudget = &DisruptionBudget{MaxUnavailable: &maxUnavailable}
+	component.Spec.WorkloadType = WorkloadTypeDaemonSet
+	assert.NotNil(t, component.validate())
+
+	component.Spec.DisruptionBudget = nil
+	component.Spec.TopologySpread = append(component.Spec.TopologySpread, TopologySpread{Topology: TopologySpreadTopologyZone})
+	err = component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "duplicate topology")
+}
+
+func TestComponentTolerationsAndNodeAffinity(t *testing.T) {
+	tolerationSeconds := int64(60)
+
+	component := Component{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: "kalm-system",
+			Name:      "kalm-comp-affinity",
+		},
+		Spec: ComponentSpec{
+			Image: fmt.Sprintf("%s:%s", "foo", "bar"),
+			Tolerations: []v1.Toleration{
+				{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "batch", Effect: v1.TaintEffectNoSchedule},
+				{Key: "spot", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoExecute, TolerationSeconds: &tolerationSeconds},
+			},
+			NodeAffinity: &NodeAffinity{
+				Required: []v1.NodeSelectorRequirement{
+					{Key: "kubernetes.io/arch", Operator: v1.NodeSelectorOpIn, Values: []string{"amd64"}},
+				},
+				Preferred: []PreferredNodeAffinityTerm{
+					{
+						Weight: 50,
+						MatchExpressions: []v1.NodeSelectorRequirement{
+							{Key: "node.kubernetes.io/lifecycle", Operator: v1.NodeSelectorOpNotIn, Values: []string{"spot"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	component.Default()
+	assert.Nil(t, component.validate())
+
+	component.Spec.Tolerations[1].Value = "true"
+	err := component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "value must be empty when operator is Exists")
+
+	component.Spec.Tolerations[1].Value = ""
+	component.Spec.Tolerations[0].TolerationSeconds = &tolerationSeconds
+	err = component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "tolerationSeconds is only allowed with the NoExecute effect")
+
+	component.Spec.Tolerations[0].TolerationSeconds = nil
+	component.Spec.NodeAffinity.Required[0].Operator = v1.NodeSelectorOpGt
+	err = component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "value must be an integer")
+
+	component.Spec.NodeAffinity.Required[0].Operator = v1.NodeSelectorOpIn
+	component.Spec.NodeAffinity.Preferred[0].Weight = 0
+	err = component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "weight must be between 1 and 100")
+}
+
+func TestComponentJobConfig(t *testing.T) {
+	completions := int32(3)
+
+	component := Component{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: "kalm-system",
+			Name:      "kalm-comp-job",
+		},
+		Spec: ComponentSpec{
+			Image:        fmt.Sprintf("%s:%s", "foo", "bar"),
+			WorkloadType: WorkloadTypeJob,
+			Job: &JobConfig{
+				Completions: &completions,
+			},
+		},
+	}
+
+	component.Default()
+	assert.Nil(t, component.validate())
+
+	component.Spec.WorkloadType = WorkloadTypeServer
+	err := component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "job options are only supported by job workloads")
+}
+
+func TestComponentCronJobConfig(t *testing.T) {
+	historyLimit := int32(1)
+
+	component := Component{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: "kalm-system",
+			Name:      "kalm-comp-cronjob",
+		},
+		Spec: ComponentSpec{
+			Image:        fmt.Sprintf("%s:%s", "foo", "bar"),
+			WorkloadType: WorkloadTypeCronjob,
+			Schedule:     "*/5 * * * *",
+			CronJob: &CronJobConfig{
+				Suspend:                    true,
+				ConcurrencyPolicy:          "Forbid",
+				SuccessfulJobsHistoryLimit: &historyLimit,
+			},
+		},
+	}
+
+	component.Default()
+	assert.Nil(t, component.validate())
+
+	component.Spec.WorkloadType = WorkloadTypeJob
+	err := component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "cronJob options are only supported by cronjob workloads")
+}
+
+func TestComponentVolumeSnapshotSchedule(t *testing.T) {
+	component := Component{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: "kalm-system",
+			Name:      "kalm-comp-snapshot",
+		},
+		Spec: ComponentSpec{
+			Image:        fmt.Sprintf("%s:%s", "foo", "bar"),
+			WorkloadType: WorkloadTypeServer,
+			Volumes: []Volume{
+				{
+					Path: "/data",
+					Size: resource.MustParse("1Gi"),
+					Type: VolumeTypePersistentVolumeClaim,
+					PVC:  "data",
+					SnapshotSchedule: &VolumeSnapshotSchedule{
+						Schedule:  "0 3 * * *",
+						Retention: 7,
+					},
+				},
+			},
+		},
+	}
+
+	component.Default()
+	assert.Nil(t, component.validate())
+
+	component.Spec.Volumes[0].SnapshotSchedule.Schedule = "every day"
+	component.Spec.Volumes[0].SnapshotSchedule.Retention = 0
+	err := component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ".spec.volumes[0].snapshotSchedule.schedule")
+	assert.Contains(t, err.Error(), "retention should be at least 1")
+
+	component.Spec.Volumes[0].SnapshotSchedule.Schedule = "0 3 * * *"
+	component.Spec.Volumes[0].SnapshotSchedule.Retention = 7
+	component.Spec.Volumes[0].Type = VolumeTypeTemporaryDisk
+	err = component.validate()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "snapshots are only supported by pvc and pvcTemplate volumes")
+}
+
+func TestComponentVolumeExpansion(t *testing.T) {
+	sc := "standard"
+
+	componentOld := Component{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: "kalm-system",
+			Name:      "kalm-comp-sts",
+		},
+		Spec: ComponentSpec{
+			Image:        fmt.Sprintf("%s:%s", "foo", "bar"),
+			WorkloadType: WorkloadTypeStatefulSet,
+			Volumes: []Volume{
+				{
+					Path:             "/data",
+					Size:             resource.MustParse("1Gi"),
+					Type:             VolumeTypePersistentVolumeClaimTemplate,
+					StorageClassName: &sc,
+					PVC:              "data",
+				},
+			},
+		},
+	}
+	componentOld.Default()
+
+	componentNew := componentOld.DeepCopy()
+	componentNew.Spec.Volumes[0].Size = resource.MustParse("2Gi")
+	assert.Nil(t, componentNew.ValidateUpdate(&componentOld))
+
+	componentNew.Spec.Volumes[0].Size = resource.MustParse("512Mi")
+	err := componentNew.ValidateUpdate(&componentOld)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "volume size can't be decreased, 1Gi -> 512Mi")
+}
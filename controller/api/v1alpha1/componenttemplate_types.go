@@ -21,7 +21,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// +kubebuilder:validation:Enum=server;cronjob;daemonset;statefulset
+// +kubebuilder:validation:Enum=server;cronjob;daemonset;statefulset;job
 type WorkloadType string
 
 const (
@@ -29,6 +29,25 @@ const (
 	WorkloadTypeCronjob     WorkloadType = "cronjob"
 	WorkloadTypeDaemonSet   WorkloadType = "daemonset"
 	WorkloadTypeStatefulSet WorkloadType = "statefulset"
+	WorkloadTypeJob         WorkloadType = "job"
+)
+
+const (
+	// Components tracking a template have this label, the value is the template name.
+	ComponentTemplateLabelName = "kalm-component-template"
+
+	// The generation of the template a tracking component was last rolled out from.
+	ComponentTemplateGenerationAnnotation = "kalm.dev/component-template-generation"
+
+	// Overrides applied on top of the template, they are applied again on each rollout.
+	ComponentTemplateOverridesAnnotation = "kalm.dev/component-template-overrides"
+
+	// The image the template and overrides last rolled out. A different component image is set by others, e.g. the deploy webhook,
+	// it's kept until the image of the template changes.
+	ComponentTemplateImageAnnotation = "kalm.dev/component-template-image"
+
+	// The current run of a job component. Each run is a new job, increase it to run the job again.
+	ComponentJobRunAnnotation = "kalm.dev/job-run"
 )
 
 // ComponentTemplateSpec defines the desired state of ComponentTemplate
@@ -51,7 +70,7 @@ type ComponentTemplateSpec struct {
 	// +optional
 	// ReadinessProbe *v1.Probe `json:"readinessProbe,omitempty"`
 
-	// +kubebuilder:validation:Enum=server;cronjob;daemonset;statefulset
+	// +kubebuilder:validation:Enum=server;cronjob;daemonset;statefulset;job
 	WorkLoadType WorkloadType `json:"workloadType,omitempty"`
 
 	Schedule string `json:"schedule,omitempty"`
@@ -0,0 +1,89 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Besides view, edit and manage, custom roles can grant narrower verbs.
+// Built-in roles imply them: viewers can read logs, editors can exec into pods and deploy components.
+// +kubebuilder:validation:Enum=view;edit;manage;logs;exec;deploy
+type CustomRoleVerb string
+
+const (
+	CustomRoleVerbView   CustomRoleVerb = "view"
+	CustomRoleVerbEdit   CustomRoleVerb = "edit"
+	CustomRoleVerbManage CustomRoleVerb = "manage"
+
+	// Read logs of pods
+	CustomRoleVerbLogs CustomRoleVerb = "logs"
+
+	// Exec into containers of pods
+	CustomRoleVerbExec CustomRoleVerb = "exec"
+
+	// Update component images through the deploy webhook
+	CustomRoleVerbDeploy CustomRoleVerb = "deploy"
+)
+
+type CustomRoleRule struct {
+	// +kubebuilder:validation:MinItems=1
+	Verbs []CustomRoleVerb `json:"verbs"`
+
+	// Kind of resources, e.g. components, pods. "*" means all kinds.
+	// +kubebuilder:validation:MinLength=1
+	Kind string `json:"kind"`
+
+	// Name of the resource. Blank or "*" means all resources of the kind.
+	Name string `json:"name,omitempty"`
+}
+
+// CustomRoleSpec defines the desired state of CustomRole
+// A custom role is bound to users or groups through a RoleBinding with role "custom".
+// The rules are granted in the namespace of the binding, or in all applications if the binding is in kalm-system.
+type CustomRoleSpec struct {
+	Description string `json:"description,omitempty"`
+
+	// +kubebuilder:validation:MinItems=1
+	Rules []CustomRoleRule `json:"rules"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Description",type="string",JSONPath=".spec.description"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// CustomRole is the Schema for the customroles API
+type CustomRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec CustomRoleSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// CustomRoleList contains a list of CustomRole
+type CustomRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CustomRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CustomRole{}, &CustomRoleList{})
+}
@@ -0,0 +1,129 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var customrolelog = logf.Log.WithName("customrole-resource")
+
+func (r *CustomRole) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-core-kalm-dev-v1alpha1-customrole,mutating=false,failurePolicy=fail,groups=core.kalm.dev,resources=customroles,versions=v1alpha1,name=vcustomrole.kb.io
+
+var _ webhook.Validator = &CustomRole{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *CustomRole) ValidateCreate() error {
+	customrolelog.Info("validate create", "name", r.Name)
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *CustomRole) ValidateUpdate(old runtime.Object) error {
+	customrolelog.Info("validate update", "name", r.Name)
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *CustomRole) ValidateDelete() error {
+	customrolelog.Info("validate delete", "name", r.Name)
+	return nil
+}
+
+func isValidCustomRoleVerb(verb CustomRoleVerb) bool {
+	switch verb {
+	case CustomRoleVerbView, CustomRoleVerbEdit, CustomRoleVerbManage, CustomRoleVerbLogs, CustomRoleVerbExec, CustomRoleVerbDeploy:
+		return true
+	default:
+		return false
+	}
+}
+
+// Kinds are plural resource names in kalm, e.g. components, httpRoutes
+var customRoleKindRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9]*$`)
+
+func (r *CustomRole) validate() error {
+	var rst KalmValidateErrorList
+
+	if len(r.Spec.Rules) == 0 {
+		rst = append(rst, KalmValidateError{
+			Err:  "rules should not be empty",
+			Path: "spec.rules",
+		})
+	}
+
+	for i, rule := range r.Spec.Rules {
+		if len(rule.Verbs) == 0 {
+			rst = append(rst, KalmValidateError{
+				Err:  "verbs should not be empty",
+				Path: fmt.Sprintf("spec.rules[%d].verbs", i),
+			})
+		}
+
+		for j, verb := range rule.Verbs {
+			if !isValidCustomRoleVerb(verb) {
+				rst = append(rst, KalmValidateError{
+					Err:  fmt.Sprintf("invalid verb %s", verb),
+					Path: fmt.Sprintf("spec.rules[%d].verbs[%d]", i, j),
+				})
+			}
+		}
+
+		// kind and name are written into casbin policy lines, only identifiers and resource names are allowed.
+		if rule.Kind != "*" && !customRoleKindRegexp.MatchString(rule.Kind) {
+			rst = append(rst, KalmValidateError{
+				Err:  "invalid kind",
+				Path: fmt.Sprintf("spec.rules[%d].kind", i),
+			})
+		}
+
+		if rule.Kind == "*" && rule.Name != "" && rule.Name != "*" {
+			rst = append(rst, KalmValidateError{
+				Err:  "name must be blank when kind is *",
+				Path: fmt.Sprintf("spec.rules[%d].name", i),
+			})
+		}
+
+		if rule.Name != "" && rule.Name != "*" {
+			for _, msg := range validation.IsDNS1123Subdomain(rule.Name) {
+				rst = append(rst, KalmValidateError{
+					Err:  "invalid name: " + msg,
+					Path: fmt.Sprintf("spec.rules[%d].name", i),
+				})
+			}
+		}
+	}
+
+	if len(rst) == 0 {
+		return nil
+	}
+
+	return rst
+}
@@ -0,0 +1,58 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestCustomRoleValidate(t *testing.T) {
+	role := CustomRole{
+		ObjectMeta: ctrl.ObjectMeta{
+			Name: "deployer",
+		},
+		Spec: CustomRoleSpec{
+			Rules: []CustomRoleRule{
+				{Verbs: []CustomRoleVerb{CustomRoleVerbView}, Kind: "*"},
+				{Verbs: []CustomRoleVerb{CustomRoleVerbDeploy}, Kind: "components"},
+				{Verbs: []CustomRoleVerb{CustomRoleVerbLogs}, Kind: "pods", Name: "*"},
+			},
+		},
+	}
+
+	assert.Nil(t, role.validate())
+
+	role.Spec.Rules[1].Verbs = []CustomRoleVerb{"delete"}
+	assert.NotNil(t, role.validate())
+
+	role.Spec.Rules[1].Verbs = nil
+	assert.NotNil(t, role.validate())
+
+	role.Spec.Rules[1].Verbs = []CustomRoleVerb{CustomRoleVerbDeploy}
+	role.Spec.Rules[1].Kind = "components/*"
+	assert.NotNil(t, role.validate())
+
+	role.Spec.Rules[1].Kind = "components"
+	role.Spec.Rules[1].Name = "api,*"
+	assert.NotNil(t, role.validate())
+
+	role.Spec.Rules[1].Name = "api\np, role, *, *"
+	assert.NotNil(t, role.validate())
+
+	role.Spec.Rules[1].Name = "api-v2.web"
+	assert.Nil(t, role.validate())
+
+	role.Spec.Rules[1].Kind = "components\r"
+	assert.NotNil(t, role.validate())
+
+	role.Spec.Rules[1].Kind = "httpRoutes"
+	assert.Nil(t, role.validate())
+
+	role.Spec.Rules[1].Name = ""
+	role.Spec.Rules[0].Name = "api"
+	assert.NotNil(t, role.validate())
+
+	role.Spec.Rules = nil
+	assert.NotNil(t, role.validate())
+}
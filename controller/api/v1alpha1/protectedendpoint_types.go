@@ -33,6 +33,62 @@ const (
 	TypeHttpRoute ProtectedEndpointType = "HttpRoute"
 )
 
+// +kubebuilder:validation:Enum=Allow;Deny
+type ProtectedEndpointRuleEffect string
+
+const (
+	ProtectedEndpointRuleEffectAllow ProtectedEndpointRuleEffect = "Allow"
+	ProtectedEndpointRuleEffectDeny  ProtectedEndpointRuleEffect = "Deny"
+)
+
+// Match a claim of the id token against a list of values.
+// If the claim is an array, any element matching any value is a match.
+type ProtectedEndpointClaimMatcher struct {
+	// Claim name. Nested claims can be referenced with dots, e.g. "org.team"
+	// +kubebuilder:validation:MinLength=1
+	Claim string `json:"claim"`
+
+	// +kubebuilder:validation:MinItems=1
+	Values []string `json:"values"`
+}
+
+// ProtectedEndpointRule grants or denies requests to some paths and methods of the endpoint.
+// Different kinds of conditions (emailDomains, users, groups and claims) are ANDed,
+// values in one kind of condition are ORed. A rule without any condition matches all authenticated users.
+type ProtectedEndpointRule struct {
+	// Default to Allow
+	Effect ProtectedEndpointRuleEffect `json:"effect,omitempty"`
+
+	// Paths this rule applies to. A path ending with "*" is a prefix match, otherwise it's an exact match.
+	// Empty means all paths.
+	Paths []string `json:"paths,omitempty"`
+
+	// HTTP methods this rule applies to. Empty means all methods.
+	Methods []string `json:"methods,omitempty"`
+
+	// Match the domain of the verified email claim, e.g. "example.com"
+	EmailDomains []string `json:"emailDomains,omitempty"`
+
+	// Match the email or the subject claim
+	Users []string `json:"users,omitempty"`
+
+	// Match the groups claim
+	Groups []string `json:"groups,omitempty"`
+
+	// Match custom claims. All matchers must match.
+	Claims []ProtectedEndpointClaimMatcher `json:"claims,omitempty"`
+}
+
+// Forward a claim of the id token to the upstream as a request header.
+// Array claims are joined with ",", object claims are json encoded.
+type ProtectedEndpointForwardedClaim struct {
+	// +kubebuilder:validation:MinLength=1
+	Claim string `json:"claim"`
+
+	// +kubebuilder:validation:MinLength=1
+	Header string `json:"header"`
+}
+
 // ProtectedEndpointSpec defines the desired state of ProtectedEndpoint
 type ProtectedEndpointSpec struct {
 	// +kubebuilder:validation:MinLength=1
@@ -46,6 +102,15 @@ type ProtectedEndpointSpec struct {
 	// This flag should be set carefully. Please make sure that the upstream can handle the token correctly.
 	// Otherwise, client can bypass kalm sso by sending a not empty bearer token.
 	AllowToPassIfHasBearerToken bool `json:"allowToPassIfHasBearerToken,omitempty"`
+
+	// Claim based authorization rules, evaluated after the groups check.
+	// Among the rules applying to a request, a matched Deny rule always rejects the request.
+	// Otherwise, if there are Allow rules applying to the request, at least one of them must match.
+	// Requests no rule applies to are allowed.
+	Rules []ProtectedEndpointRule `json:"rules,omitempty"`
+
+	// Claims forwarded to the upstream as headers.
+	ForwardedClaims []ProtectedEndpointForwardedClaim `json:"forwardedClaims,omitempty"`
 }
 
 // ProtectedEndpointStatus defines the observed state of ProtectedEndpoint
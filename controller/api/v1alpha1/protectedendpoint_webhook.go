@@ -17,7 +17,11 @@ package v1alpha1
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
+
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -73,9 +77,139 @@ func (r *ProtectedEndpoint) validate() error {
 		}
 	}
 
+	for i, rule := range r.Spec.Rules {
+		rst = append(rst, validateProtectedEndpointRule(rule, fmt.Sprintf("spec.rules[%d]", i))...)
+	}
+
+	headers := make(map[string]struct{}, len(r.Spec.ForwardedClaims))
+
+	for i, forwardedClaim := range r.Spec.ForwardedClaims {
+		path := fmt.Sprintf("spec.forwardedClaims[%d]", i)
+
+		if forwardedClaim.Claim == "" {
+			rst = append(rst, KalmValidateError{
+				Err:  "claim should not be blank",
+				Path: path + ".claim",
+			})
+		}
+
+		header := strings.ToLower(forwardedClaim.Header)
+
+		if errs := validation.IsHTTPHeaderName(forwardedClaim.Header); len(errs) > 0 {
+			rst = append(rst, KalmValidateError{
+				Err:  "invalid header name: " + strings.Join(errs, "; "),
+				Path: path + ".header",
+			})
+		} else if isReservedForwardedClaimHeader(header) {
+			rst = append(rst, KalmValidateError{
+				Err:  fmt.Sprintf("header %s is reserved", forwardedClaim.Header),
+				Path: path + ".header",
+			})
+		}
+
+		if _, exist := headers[header]; exist {
+			rst = append(rst, KalmValidateError{
+				Err:  fmt.Sprintf("header %s is duplicated", forwardedClaim.Header),
+				Path: path + ".header",
+			})
+		}
+
+		headers[header] = struct{}{}
+	}
+
 	if len(rst) == 0 {
 		return nil
 	}
 
 	return rst
 }
+
+var validProtectedEndpointRuleMethods = map[string]struct{}{
+	http.MethodGet:     {},
+	http.MethodHead:    {},
+	http.MethodPost:    {},
+	http.MethodPut:     {},
+	http.MethodPatch:   {},
+	http.MethodDelete:  {},
+	http.MethodConnect: {},
+	http.MethodOptions: {},
+	http.MethodTrace:   {},
+}
+
+func validateProtectedEndpointRule(rule ProtectedEndpointRule, path string) (rst KalmValidateErrorList) {
+	if rule.Effect != "" && rule.Effect != ProtectedEndpointRuleEffectAllow && rule.Effect != ProtectedEndpointRuleEffectDeny {
+		rst = append(rst, KalmValidateError{
+			Err:  "effect should be Allow or Deny",
+			Path: path + ".effect",
+		})
+	}
+
+	for i, p := range rule.Paths {
+		if !strings.HasPrefix(p, "/") {
+			rst = append(rst, KalmValidateError{
+				Err:  "path should start with /",
+				Path: fmt.Sprintf("%s.paths[%d]", path, i),
+			})
+		} else if strings.Contains(strings.TrimSuffix(p, "*"), "*") {
+			rst = append(rst, KalmValidateError{
+				Err:  "* is only allowed at the end of a path",
+				Path: fmt.Sprintf("%s.paths[%d]", path, i),
+			})
+		}
+	}
+
+	for i, method := range rule.Methods {
+		if _, ok := validProtectedEndpointRuleMethods[strings.ToUpper(method)]; !ok {
+			rst = append(rst, KalmValidateError{
+				Err:  fmt.Sprintf("invalid method %s", method),
+				Path: fmt.Sprintf("%s.methods[%d]", path, i),
+			})
+		}
+	}
+
+	for i, domain := range rule.EmailDomains {
+		if errs := validation.IsDNS1123Subdomain(strings.ToLower(domain)); len(errs) > 0 {
+			rst = append(rst, KalmValidateError{
+				Err:  "invalid email domain: " + strings.Join(errs, "; "),
+				Path: fmt.Sprintf("%s.emailDomains[%d]", path, i),
+			})
+		}
+	}
+
+	for i, matcher := range rule.Claims {
+		if matcher.Claim == "" {
+			rst = append(rst, KalmValidateError{
+				Err:  "claim should not be blank",
+				Path: fmt.Sprintf("%s.claims[%d].claim", path, i),
+			})
+		}
+
+		if len(matcher.Values) == 0 {
+			rst = append(rst, KalmValidateError{
+				Err:  "values should not be empty",
+				Path: fmt.Sprintf("%s.claims[%d].values", path, i),
+			})
+		}
+	}
+
+	return rst
+}
+
+// These headers are either used by kalm sso or have special meanings for envoy and upstreams.
+var reservedForwardedClaimHeaders = map[string]struct{}{
+	"authorization":     {},
+	"content-length":    {},
+	"cookie":            {},
+	"host":              {},
+	"kalm-route":        {},
+	"kalm-set-cookie":   {},
+	"kalm-sso-userinfo": {},
+}
+
+func isReservedForwardedClaimHeader(header string) bool {
+	if _, ok := reservedForwardedClaimHeaders[header]; ok {
+		return true
+	}
+
+	return strings.HasPrefix(header, "x-envoy-") || strings.HasPrefix(header, "kalm-sso-")
+}
@@ -31,3 +31,72 @@ func TestProtectedEndpoint_Validate(t *testing.T) {
 	protectedEndpoint.Spec.Ports = []uint32{0}
 	assert.NotNil(t, protectedEndpoint.validate())
 }
+
+func TestProtectedEndpoint_ValidateRules(t *testing.T) {
+	protectedEndpoint := ProtectedEndpoint{
+		ObjectMeta: ctrl.ObjectMeta{
+			Namespace: "test-ns",
+			Name:      "test-name",
+		},
+		Spec: ProtectedEndpointSpec{
+			EndpointName: "test-ep",
+			Rules: []ProtectedEndpointRule{
+				{
+					Effect:       ProtectedEndpointRuleEffectDeny,
+					Paths:        []string{"/admin/*"},
+					Methods:      []string{"DELETE", "post"},
+					EmailDomains: []string{"example.com"},
+					Claims: []ProtectedEndpointClaimMatcher{
+						{Claim: "org.team", Values: []string{"payments"}},
+					},
+				},
+			},
+			ForwardedClaims: []ProtectedEndpointForwardedClaim{
+				{Claim: "email", Header: "X-User-Email"},
+			},
+		},
+	}
+
+	assert.Nil(t, protectedEndpoint.validate())
+
+	rule := &protectedEndpoint.Spec.Rules[0]
+
+	rule.Paths = []string{"admin"}
+	assert.NotNil(t, protectedEndpoint.validate())
+
+	rule.Paths = []string{"/admin/*/users"}
+	assert.NotNil(t, protectedEndpoint.validate())
+
+	rule.Paths = nil
+	rule.Methods = []string{"FETCH"}
+	assert.NotNil(t, protectedEndpoint.validate())
+
+	rule.Methods = nil
+	rule.Effect = "Maybe"
+	assert.NotNil(t, protectedEndpoint.validate())
+
+	rule.Effect = ""
+	rule.Claims[0].Values = nil
+	assert.NotNil(t, protectedEndpoint.validate())
+
+	protectedEndpoint.Spec.Rules = nil
+	assert.Nil(t, protectedEndpoint.validate())
+
+	// reserved header
+	protectedEndpoint.Spec.ForwardedClaims[0].Header = "Cookie"
+	assert.NotNil(t, protectedEndpoint.validate())
+
+	protectedEndpoint.Spec.ForwardedClaims[0].Header = "kalm-sso-userinfo"
+	assert.NotNil(t, protectedEndpoint.validate())
+
+	// invalid header
+	protectedEndpoint.Spec.ForwardedClaims[0].Header = "X User"
+	assert.NotNil(t, protectedEndpoint.validate())
+
+	// duplicated header
+	protectedEndpoint.Spec.ForwardedClaims = []ProtectedEndpointForwardedClaim{
+		{Claim: "email", Header: "X-User"},
+		{Claim: "sub", Header: "x-user"},
+	}
+	assert.NotNil(t, protectedEndpoint.validate())
+}
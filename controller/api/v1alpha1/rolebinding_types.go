@@ -27,6 +27,9 @@ const (
 	ClusterRoleEditor = "clusterEditor"
 	ClusterRoleOwner  = "clusterOwner"
 
+	// Grant the rules of a CustomRole
+	RoleCustom = "custom"
+
 	SubjectTypeUser  = "user"
 	SubjectTypeGroup = "group"
 )
@@ -38,9 +41,12 @@ type RoleBindingSpec struct {
 	// +kubebuilder:validation:Enum=user;group
 	SubjectType string `json:"subjectType"`
 
-	// +kubebuilder:validation:Enum=viewer;editor;owner;clusterViewer;clusterEditor;clusterOwner
+	// +kubebuilder:validation:Enum=viewer;editor;owner;clusterViewer;clusterEditor;clusterOwner;custom
 	Role string `json:"role"`
 
+	// Name of the CustomRole, required if role is custom.
+	CustomRole string `json:"customRole,omitempty"`
+
 	// Creator of this binding
 	// +kubebuilder:validation:MinLength=1
 	Creator string `json:"creator"`
@@ -18,7 +18,10 @@ package v1alpha1
 import (
 	"crypto/md5"
 	"fmt"
+	"strings"
+
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -113,6 +116,20 @@ func (r *RoleBinding) validate() error {
 		}
 	}
 
+	if r.Spec.Role == RoleCustom {
+		if errs := validation.IsDNS1123Subdomain(r.Spec.CustomRole); len(errs) > 0 {
+			rst = append(rst, KalmValidateError{
+				Err:  "invalid custom role name: " + strings.Join(errs, "; "),
+				Path: ".spec.customRole",
+			})
+		}
+	} else if r.Spec.CustomRole != "" {
+		rst = append(rst, KalmValidateError{
+			Err:  "customRole is only allowed when role is custom",
+			Path: ".spec.customRole",
+		})
+	}
+
 	if len(rst) == 0 {
 		return nil
 	}
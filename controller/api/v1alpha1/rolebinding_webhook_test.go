@@ -20,3 +20,30 @@ func TestRoleBindingValidate(t *testing.T) {
 
 	assert.Nil(t, key.validate())
 }
+
+func TestRoleBindingValidateCustomRole(t *testing.T) {
+	key := RoleBinding{
+		ObjectMeta: ctrl.ObjectMeta{
+			Name:      "test",
+			Namespace: "test-ns",
+		},
+		Spec: RoleBindingSpec{
+			Subject:    "abc",
+			Role:       RoleCustom,
+			CustomRole: "deployer",
+			Creator:    "test",
+		},
+	}
+
+	assert.Nil(t, key.validate())
+
+	key.Spec.CustomRole = ""
+	assert.NotNil(t, key.validate())
+
+	key.Spec.CustomRole = "Invalid_Name"
+	assert.NotNil(t, key.validate())
+
+	key.Spec.Role = RoleEditor
+	key.Spec.CustomRole = "deployer"
+	assert.NotNil(t, key.validate())
+}
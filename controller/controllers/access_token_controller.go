@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// AccessTokenReconciler removes access tokens once they are expired.
+// The api server already refuses expired tokens, this controller makes sure they don't stay around forever.
+type AccessTokenReconciler struct {
+	*BaseReconciler
+	ctx context.Context
+}
+
+func NewAccessTokenReconciler(mgr ctrl.Manager) *AccessTokenReconciler {
+	return &AccessTokenReconciler{
+		BaseReconciler: NewBaseReconciler(mgr, "AccessToken"),
+		ctx:            context.Background(),
+	}
+}
+
+func (r *AccessTokenReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.AccessToken{}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=core.kalm.dev,resources=accesstokens,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core.kalm.dev,resources=accesstokens/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *AccessTokenReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	var accessToken v1alpha1.AccessToken
+
+	if err := r.Get(r.ctx, req.NamespacedName, &accessToken); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	return r.deleteIfExpired(&accessToken, accessToken.Spec.ExpiredAt, "access token")
+}
+
+// deleteIfExpired deletes the object if the expire time is reached.
+// Otherwise, a reconcile is scheduled at the expire time.
+func (r *BaseReconciler) deleteIfExpired(obj runtime.Object, expiredAt *metaV1.Time, kind string) (ctrl.Result, error) {
+	if expiredAt == nil {
+		return ctrl.Result{}, nil
+	}
+
+	if remaining := time.Until(expiredAt.Time); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	r.EmitNormalEvent(obj, "Expired", "%s is expired at %s, deleting", kind, expiredAt.Format(time.RFC3339))
+
+	if err := r.Delete(context.Background(), obj); err != nil && !errors.IsNotFound(err) {
+		r.EmitWarningEvent(obj, err, "failed to delete expired %s", kind)
+		return ctrl.Result{}, err
+	}
+
+	r.Log.Info("expired object deleted", "kind", kind, "expiredAt", expiredAt.Format(time.RFC3339))
+
+	return ctrl.Result{}, nil
+}
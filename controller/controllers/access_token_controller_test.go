@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/suite"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type AccessTokenControllerSuite struct {
+	BasicSuite
+}
+
+func TestAccessTokenControllerSuite(t *testing.T) {
+	suite.Run(t, new(AccessTokenControllerSuite))
+}
+
+func (suite *AccessTokenControllerSuite) SetupSuite() {
+	suite.BasicSuite.SetupSuite()
+}
+
+func (suite *AccessTokenControllerSuite) TearDownSuite() {
+	suite.BasicSuite.TearDownSuite()
+}
+
+func genAccessToken(expiredAt *metaV1.Time) *v1alpha1.AccessToken {
+	accessToken := &v1alpha1.AccessToken{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name: randomName(),
+		},
+		Spec: v1alpha1.AccessTokenSpec{
+			Token: randomName(),
+			Rules: []v1alpha1.AccessTokenRule{
+				{
+					Verb:      v1alpha1.AccessTokenVerbView,
+					Namespace: "*",
+					Kind:      "*",
+					Name:      "*",
+				},
+			},
+			Creator:   "test",
+			ExpiredAt: expiredAt,
+		},
+	}
+
+	_ = accessToken.SealToken()
+
+	return accessToken
+}
+
+func (suite *AccessTokenControllerSuite) TestExpiredAccessTokenIsDeleted() {
+	expiredAt := metaV1.NewTime(time.Now().Add(-time.Minute))
+	accessToken := genAccessToken(&expiredAt)
+	suite.createObject(accessToken)
+
+	suite.Eventually(func() bool {
+		err := suite.K8sClient.Get(context.Background(), client.ObjectKey{Name: accessToken.Name}, accessToken)
+		return errors.IsNotFound(err)
+	})
+}
+
+func (suite *AccessTokenControllerSuite) TestAccessTokenIsDeletedAfterExpiration() {
+	expiredAt := metaV1.NewTime(time.Now().Add(3 * time.Second))
+	accessToken := genAccessToken(&expiredAt)
+	suite.createObject(accessToken)
+
+	suite.Nil(suite.K8sClient.Get(context.Background(), client.ObjectKey{Name: accessToken.Name}, accessToken))
+
+	suite.Eventually(func() bool {
+		err := suite.K8sClient.Get(context.Background(), client.ObjectKey{Name: accessToken.Name}, accessToken)
+		return errors.IsNotFound(err)
+	})
+}
+
+func (suite *AccessTokenControllerSuite) TestExpiredRoleBindingIsDeleted() {
+	ns := suite.SetupKalmEnabledNs("")
+	expiredAt := metaV1.NewTime(time.Now().Add(-time.Minute))
+
+	roleBinding := &v1alpha1.RoleBinding{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: ns.Name,
+			Name:      randomName(),
+		},
+		Spec: v1alpha1.RoleBindingSpec{
+			Subject:     "test@example.com",
+			SubjectType: v1alpha1.SubjectTypeUser,
+			Role:        v1alpha1.RoleViewer,
+			Creator:     "test",
+			ExpiredAt:   &expiredAt,
+		},
+	}
+	suite.createObject(roleBinding)
+
+	suite.Eventually(func() bool {
+		err := suite.K8sClient.Get(context.Background(), client.ObjectKey{Namespace: ns.Name, Name: roleBinding.Name}, roleBinding)
+		return errors.IsNotFound(err)
+	})
+}
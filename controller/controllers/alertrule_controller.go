@@ -0,0 +1,537 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/kalmhq/kalm/controller/lib/alert"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	AlertRuleEvaluationInterval = 30 * time.Second
+
+	// Secret in kalm-system with host, port, username, password and from of the smtp server for email receivers
+	AlertSMTPSecretName = "kalm-alert-smtp"
+)
+
+// AlertMetricSource returns the current value of the metric of a rule.
+// Sources of windowed metrics keep their samples in the status of the rule, e.g. restartCount.
+type AlertMetricSource interface {
+	Value(ctx context.Context, rule *corev1alpha1.AlertRule) (float64, error)
+}
+
+// AlertRuleReconciler evaluates alert rules periodically, and notifies receivers when rules fire or are resolved.
+type AlertRuleReconciler struct {
+	*BaseReconciler
+	ctx    context.Context
+	source AlertMetricSource
+}
+
+func NewAlertRuleReconciler(mgr ctrl.Manager) *AlertRuleReconciler {
+	return &AlertRuleReconciler{
+		BaseReconciler: NewBaseReconciler(mgr, "AlertRule"),
+		ctx:            context.Background(),
+		source:         NewKalmAlertMetricSource(mgr.GetAPIReader()),
+	}
+}
+
+func (r *AlertRuleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// status updates of evaluations don't trigger reconciles, rules are evaluated periodically
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1alpha1.AlertRule{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=core.kalm.dev,resources=alertrules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core.kalm.dev,resources=alertrules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metrics.k8s.io,resources=*,verbs=get;list;watch
+
+func (r *AlertRuleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	var rule corev1alpha1.AlertRule
+
+	if err := r.Get(r.ctx, req.NamespacedName, &rule); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	if rule.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	now := time.Now()
+	copied := rule.DeepCopy()
+	value, err := r.source.Value(r.ctx, copied)
+
+	if err != nil {
+		r.Log.Error(err, "evaluate alert rule failed", "rule", req.NamespacedName.String())
+	}
+
+	record, notificationState := evaluateAlertRule(copied, value, err, now)
+
+	silenced := copied.Spec.SilencedUntil != nil && now.Before(copied.Spec.SilencedUntil.Time)
+
+	if notificationState != "" {
+		// a new notification supersedes the undelivered one
+		copied.Status.UndeliveredNotification = ""
+
+		if silenced {
+			record.Silenced = true
+		} else if err := r.notifyReceivers(copied, notificationState, now); err != nil {
+			r.EmitWarningEvent(copied, err, "failed to notify receivers of alert rule")
+			record.Error = err.Error()
+			copied.Status.UndeliveredNotification = string(notificationState)
+		} else {
+			record.Notified = true
+		}
+	} else if undelivered := alert.NotificationState(copied.Status.UndeliveredNotification); undelivered != "" && !silenced {
+		r.retryUndeliveredNotification(copied, undelivered, now)
+	}
+
+	if record != nil {
+		copied.Status.History = append(copied.Status.History, *record)
+
+		if len(copied.Status.History) > corev1alpha1.AlertRuleHistoryLimit {
+			copied.Status.History = copied.Status.History[len(copied.Status.History)-corev1alpha1.AlertRuleHistoryLimit:]
+		}
+	}
+
+	if err := r.Status().Patch(r.ctx, copied, client.MergeFrom(&rule)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: AlertRuleEvaluationInterval}, nil
+}
+
+// retryUndeliveredNotification delivers the notification again if it still matches the state of the rule.
+// All receivers are notified again, a duplicated notification is better than a missing one.
+func (r *AlertRuleReconciler) retryUndeliveredNotification(rule *corev1alpha1.AlertRule, state alert.NotificationState, now time.Time) {
+	firing := rule.Status.State == corev1alpha1.AlertStateFiring
+
+	if (state == alert.NotificationStateFiring) != firing {
+		rule.Status.UndeliveredNotification = ""
+		return
+	}
+
+	if err := r.notifyReceivers(rule, state, now); err != nil {
+		return
+	}
+
+	rule.Status.UndeliveredNotification = ""
+
+	recordState := corev1alpha1.AlertStateOK
+
+	if firing {
+		recordState = corev1alpha1.AlertStateFiring
+	}
+
+	for i := len(rule.Status.History) - 1; i >= 0; i-- {
+		if record := &rule.Status.History[i]; record.State == recordState && record.Error != "" {
+			record.Notified = true
+			record.Error = ""
+			break
+		}
+	}
+}
+
+// evaluateAlertRule updates the status of the rule with the value.
+// A record is returned if the state changes, along with the notification to send if receivers should be notified.
+func evaluateAlertRule(rule *corev1alpha1.AlertRule, value float64, evalErr error, now time.Time) (*corev1alpha1.AlertRecord, alert.NotificationState) {
+	status := &rule.Status
+	previous := status.State
+	evaluatedAt := metaV1.NewTime(now)
+	status.LastEvaluatedAt = &evaluatedAt
+
+	if evalErr != nil {
+		status.State = corev1alpha1.AlertStateUnknown
+		status.Value = ""
+		status.PendingSince = nil
+
+		if previous == corev1alpha1.AlertStateUnknown {
+			return nil, ""
+		}
+
+		return &corev1alpha1.AlertRecord{State: status.State, Time: evaluatedAt, Error: evalErr.Error()}, ""
+	}
+
+	status.Value = formatAlertValue(value, rule.Spec.Threshold.Format)
+	threshold := float64(rule.Spec.Threshold.MilliValue()) / 1000
+
+	if value > threshold {
+		if status.PendingSince == nil {
+			status.PendingSince = &evaluatedAt
+		}
+
+		status.State = corev1alpha1.AlertStatePending
+
+		if rule.Spec.For == nil || now.Sub(status.PendingSince.Time) >= rule.Spec.For.Duration {
+			status.State = corev1alpha1.AlertStateFiring
+		}
+	} else {
+		status.PendingSince = nil
+		status.State = corev1alpha1.AlertStateOK
+	}
+
+	// the first evaluation of a healthy rule is not a change worth recording
+	if status.State == previous || (previous == "" && status.State == corev1alpha1.AlertStateOK) {
+		return nil, ""
+	}
+
+	record := &corev1alpha1.AlertRecord{State: status.State, Value: status.Value, Time: evaluatedAt}
+
+	switch {
+	case status.State == corev1alpha1.AlertStateFiring:
+		return record, alert.NotificationStateFiring
+	case previous == corev1alpha1.AlertStateFiring:
+		// resolved
+		return record, alert.NotificationStateResolved
+	default:
+		return record, ""
+	}
+}
+
+// formatAlertValue formats the value in the format of the threshold, e.g. 800m, 1Gi
+func formatAlertValue(value float64, format resource.Format) string {
+	return resource.NewMilliQuantity(int64(math.Round(value*1000)), format).String()
+}
+
+func (r *AlertRuleReconciler) notifyReceivers(rule *corev1alpha1.AlertRule, state alert.NotificationState, now time.Time) error {
+	n := &alert.Notification{
+		State:       state,
+		Application: rule.Namespace,
+		Component:   rule.Spec.Component,
+		Rule:        rule.Name,
+		Metric:      string(rule.Spec.Metric),
+		Value:       rule.Status.Value,
+		Threshold:   rule.Spec.Threshold.String(),
+		Time:        now,
+	}
+
+	var errs []string
+
+	for _, receiver := range rule.Spec.Receivers {
+		var err error
+
+		switch receiver.Type {
+		case corev1alpha1.AlertReceiverTypeWebhook:
+			err = alert.SendWebhook(r.ctx, receiver.URL, n)
+		case corev1alpha1.AlertReceiverTypeSlack:
+			err = alert.SendSlack(r.ctx, receiver.URL, n)
+		case corev1alpha1.AlertReceiverTypeEmail:
+			var config *alert.SMTPConfig
+
+			if config, err = r.getSMTPConfig(); err == nil {
+				err = alert.SendEmail(config, receiver.To, n)
+			}
+		default:
+			err = fmt.Errorf("unknown receiver type")
+		}
+
+		if err != nil {
+			// errors may contain responses of internal addresses, they are only logged.
+			// Records and events are readable by viewers of the application.
+			r.Log.Error(err, "notify alert receiver failed", "rule", rule.Namespace+"/"+rule.Name, "type", receiver.Type)
+			errs = append(errs, fmt.Sprintf("%s receiver: delivery failed", receiver.Type))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func (r *AlertRuleReconciler) getSMTPConfig() (*alert.SMTPConfig, error) {
+	var secret coreV1.Secret
+
+	if err := r.Reader.Get(r.ctx, types.NamespacedName{Namespace: KalmSystemNamespace, Name: AlertSMTPSecretName}, &secret); err != nil {
+		return nil, err
+	}
+
+	return alert.ParseSMTPConfig(secret.Data)
+}
+
+// KalmAlertMetricSource reads resource usages from metrics-server, pod states from the api server,
+// and http metrics from the prometheus of istio.
+type KalmAlertMetricSource struct {
+	reader            client.Reader
+	prometheusAddress string
+	httpClient        *http.Client
+}
+
+func NewKalmAlertMetricSource(reader client.Reader) *KalmAlertMetricSource {
+	address := os.Getenv("KALM_ISTIO_PROMETHEUS_API_ADDRESS")
+
+	if address == "" {
+		address = "http://prometheus.istio-system:9090"
+	}
+
+	return &KalmAlertMetricSource{
+		reader:            reader,
+		prometheusAddress: address,
+		httpClient:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *KalmAlertMetricSource) Value(ctx context.Context, rule *corev1alpha1.AlertRule) (float64, error) {
+	switch rule.Spec.Metric {
+	case corev1alpha1.AlertRuleMetricCPU, corev1alpha1.AlertRuleMetricMemory:
+		return s.resourceUsage(ctx, rule)
+	case corev1alpha1.AlertRuleMetricRestartCount:
+		pods, err := s.listPods(ctx, rule)
+
+		if err != nil {
+			return 0, err
+		}
+
+		return restartsInWindow(&rule.Status, containerRestartCounts(pods), time.Now()), nil
+	case corev1alpha1.AlertRuleMetricPodNotReadyDuration:
+		pods, err := s.listPods(ctx, rule)
+
+		if err != nil {
+			return 0, err
+		}
+
+		return podNotReadyDuration(pods, time.Now()), nil
+	case corev1alpha1.AlertRuleMetricHTTP5xxRate, corev1alpha1.AlertRuleMetricHTTPP95Latency:
+		return s.queryPrometheus(ctx, httpAlertQuery(rule))
+	default:
+		return 0, fmt.Errorf("unknown metric %s", rule.Spec.Metric)
+	}
+}
+
+func (s *KalmAlertMetricSource) listPods(ctx context.Context, rule *corev1alpha1.AlertRule) ([]coreV1.Pod, error) {
+	var podList coreV1.PodList
+
+	opts := []client.ListOption{client.InNamespace(rule.Namespace), client.HasLabels{KalmLabelComponentKey}}
+
+	if rule.Spec.Component != "" {
+		opts = append(opts, client.MatchingLabels{KalmLabelComponentKey: rule.Spec.Component})
+	}
+
+	if err := s.reader.List(ctx, &podList, opts...); err != nil {
+		return nil, err
+	}
+
+	return podList.Items, nil
+}
+
+func (s *KalmAlertMetricSource) resourceUsage(ctx context.Context, rule *corev1alpha1.AlertRule) (float64, error) {
+	pods, err := s.listPods(ctx, rule)
+
+	if err != nil {
+		return 0, err
+	}
+
+	var metricsList metricsv1beta1.PodMetricsList
+
+	if err := s.reader.List(ctx, &metricsList, client.InNamespace(rule.Namespace)); err != nil {
+		return 0, err
+	}
+
+	return resourceUsageValue(rule.Spec.Metric, pods, metricsList.Items), nil
+}
+
+// resourceUsageValue sums usages of containers in the pods, in cores or bytes
+func resourceUsageValue(metric corev1alpha1.AlertRuleMetric, pods []coreV1.Pod, podMetrics []metricsv1beta1.PodMetrics) float64 {
+	names := make(map[string]bool, len(pods))
+
+	for _, pod := range pods {
+		names[pod.Name] = true
+	}
+
+	var milli int64
+
+	for _, m := range podMetrics {
+		if !names[m.Name] {
+			continue
+		}
+
+		for _, container := range m.Containers {
+			if metric == corev1alpha1.AlertRuleMetricCPU {
+				milli += container.Usage.Cpu().MilliValue()
+			} else {
+				milli += container.Usage.Memory().MilliValue()
+			}
+		}
+	}
+
+	return float64(milli) / 1000
+}
+
+// containerRestartCounts returns restart counts of containers, keyed by pod uid and container name
+func containerRestartCounts(pods []coreV1.Pod) map[string]int32 {
+	counts := make(map[string]int32)
+
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			counts[string(pod.UID)+"/"+status.Name] = status.RestartCount
+		}
+	}
+
+	return counts
+}
+
+// restartsInWindow records the counts as a sample in the status, and returns the restarts since the latest sample
+// taken before the window. Containers not in that sample are new, all of their restarts are in the window.
+// RestartCount is the total of the container's lifetime, so it can't be alerted on directly, the rule would never resolve.
+func restartsInWindow(status *corev1alpha1.AlertRuleStatus, counts map[string]int32, now time.Time) float64 {
+	samples := status.RestartCountSamples
+
+	if len(samples) == 0 || now.Sub(samples[len(samples)-1].Time.Time) >= corev1alpha1.AlertRuleRestartCountSampleInterval {
+		samples = append(samples, corev1alpha1.AlertRestartCountSample{Time: metaV1.NewTime(now), Counts: counts})
+	}
+
+	// samples before the base are not needed anymore
+	base := 0
+
+	for i := range samples {
+		if now.Sub(samples[i].Time.Time) >= corev1alpha1.AlertRuleRestartCountWindow {
+			base = i
+		}
+	}
+
+	samples = samples[base:]
+	status.RestartCountSamples = samples
+
+	var value float64
+
+	for key, count := range counts {
+		if restarts := count - samples[0].Counts[key]; restarts > 0 {
+			value += float64(restarts)
+		}
+	}
+
+	return value
+}
+
+// podNotReadyDuration returns seconds since the longest not ready pod became not ready
+func podNotReadyDuration(pods []coreV1.Pod, now time.Time) float64 {
+	var value float64
+
+	for _, pod := range pods {
+		// finished pods of jobs are not ready, but they are not a problem
+		if pod.Status.Phase == coreV1.PodSucceeded || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == coreV1.PodReady && condition.Status != coreV1.ConditionTrue {
+				value = math.Max(value, now.Sub(condition.LastTransitionTime.Time).Seconds())
+			}
+		}
+	}
+
+	return value
+}
+
+func httpAlertQuery(rule *corev1alpha1.AlertRule) string {
+	service := fmt.Sprintf(`.*\\.%s\\.svc\\.cluster\\.local`, rule.Namespace)
+
+	if rule.Spec.Component != "" {
+		service = fmt.Sprintf(`%s\\.%s\\.svc\\.cluster\\.local`, rule.Spec.Component, rule.Namespace)
+	}
+
+	if rule.Spec.Metric == corev1alpha1.AlertRuleMetricHTTP5xxRate {
+		return fmt.Sprintf(`sum(istio:istio_requests_total:by_destination_service:resp5xx_rate5m{destination_service=~"%s"})`, service)
+	}
+
+	return fmt.Sprintf(
+		`histogram_quantile(0.95, sum(rate(istio_request_duration_milliseconds_bucket{reporter="destination",destination_service=~"%s"}[5m])) by (le)) / 1000`,
+		service,
+	)
+}
+
+type promInstantResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Result []struct {
+			Value []interface{} `json:"value"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// queryPrometheus returns the value of an instant query, no series or NaN means no traffic and is treated as 0
+func (s *KalmAlertMetricSource) queryPrometheus(ctx context.Context, query string) (float64, error) {
+	api := fmt.Sprintf("%s/api/v1/query?query=%s", s.prometheusAddress, url.QueryEscape(query))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, api, nil)
+
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return parsePromInstantValue(body)
+}
+
+func parsePromInstantValue(body []byte) (float64, error) {
+	var promResp promInstantResponse
+
+	if err := json.Unmarshal(body, &promResp); err != nil {
+		return 0, err
+	}
+
+	if promResp.Status != "success" {
+		return 0, fmt.Errorf("prometheus query failed: %s", promResp.Error)
+	}
+
+	if len(promResp.Data.Result) == 0 || len(promResp.Data.Result[0].Value) != 2 {
+		return 0, nil
+	}
+
+	valueInStr, ok := promResp.Data.Result[0].Value[1].(string)
+
+	if !ok {
+		return 0, fmt.Errorf("invalid prometheus value %v", promResp.Data.Result[0].Value[1])
+	}
+
+	value, err := strconv.ParseFloat(valueInStr, 64)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, nil
+	}
+
+	return value, nil
+}
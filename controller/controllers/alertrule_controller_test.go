@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/kalmhq/kalm/controller/lib/alert"
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func genAlertRule() *corev1alpha1.AlertRule {
+	return &corev1alpha1.AlertRule{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "high-cpu"},
+		Spec: corev1alpha1.AlertRuleSpec{
+			Component: "web",
+			Metric:    corev1alpha1.AlertRuleMetricCPU,
+			Threshold: resource.MustParse("500m"),
+			For:       &metaV1.Duration{Duration: time.Minute},
+		},
+	}
+}
+
+func TestEvaluateAlertRule(t *testing.T) {
+	rule := genAlertRule()
+	now := time.Now()
+
+	rec, state := evaluateAlertRule(rule, 0.2, nil, now)
+	assert.Nil(t, rec)
+	assert.Equal(t, corev1alpha1.AlertStateOK, rule.Status.State)
+	assert.Equal(t, "200m", rule.Status.Value)
+
+	rec, state = evaluateAlertRule(rule, 0.8, nil, now.Add(30*time.Second))
+	assert.Equal(t, corev1alpha1.AlertStatePending, rec.State)
+	assert.Equal(t, alert.NotificationState(""), state)
+
+	rec, _ = evaluateAlertRule(rule, 0.8, nil, now.Add(60*time.Second))
+	assert.Nil(t, rec)
+	assert.Equal(t, corev1alpha1.AlertStatePending, rule.Status.State)
+
+	rec, state = evaluateAlertRule(rule, 0.9, nil, now.Add(90*time.Second))
+	assert.Equal(t, corev1alpha1.AlertStateFiring, rec.State)
+	assert.Equal(t, "900m", rec.Value)
+	assert.Equal(t, alert.NotificationStateFiring, state)
+
+	rec, _ = evaluateAlertRule(rule, 0.9, nil, now.Add(120*time.Second))
+	assert.Nil(t, rec)
+
+	rec, state = evaluateAlertRule(rule, 0.5, nil, now.Add(150*time.Second))
+	assert.Equal(t, corev1alpha1.AlertStateOK, rec.State)
+	assert.Equal(t, alert.NotificationStateResolved, state)
+	assert.Nil(t, rule.Status.PendingSince)
+
+	rec, state = evaluateAlertRule(rule, 0, fmt.Errorf("metrics not available"), now.Add(180*time.Second))
+	assert.Equal(t, corev1alpha1.AlertStateUnknown, rec.State)
+	assert.Equal(t, "metrics not available", rec.Error)
+	assert.Equal(t, alert.NotificationState(""), state)
+
+	// rules without for fire immediately
+	rule.Spec.For = nil
+	rec, state = evaluateAlertRule(rule, 0.6, nil, now.Add(210*time.Second))
+	assert.Equal(t, corev1alpha1.AlertStateFiring, rec.State)
+	assert.Equal(t, alert.NotificationStateFiring, state)
+
+	rule.Spec.Threshold = resource.MustParse("1Gi")
+	evaluateAlertRule(rule, 2*1024*1024*1024, nil, now.Add(240*time.Second))
+	assert.Equal(t, "2Gi", rule.Status.Value)
+}
+
+func TestAlertRuleValues(t *testing.T) {
+	now := time.Now()
+	pods := []coreV1.Pod{
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web-1", UID: "uid-1"},
+			Status: coreV1.PodStatus{
+				Phase:             coreV1.PodRunning,
+				ContainerStatuses: []coreV1.ContainerStatus{{Name: "web", RestartCount: 2}, {Name: "istio-proxy", RestartCount: 1}},
+				Conditions: []coreV1.PodCondition{
+					{Type: coreV1.PodReady, Status: coreV1.ConditionFalse, LastTransitionTime: metaV1.NewTime(now.Add(-2 * time.Minute))},
+				},
+			},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web-2", UID: "uid-2"},
+			Status: coreV1.PodStatus{
+				Phase:             coreV1.PodRunning,
+				ContainerStatuses: []coreV1.ContainerStatus{{Name: "web", RestartCount: 4}},
+				Conditions: []coreV1.PodCondition{
+					{Type: coreV1.PodReady, Status: coreV1.ConditionTrue, LastTransitionTime: metaV1.NewTime(now.Add(-time.Hour))},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, map[string]int32{"uid-1/web": 2, "uid-1/istio-proxy": 1, "uid-2/web": 4}, containerRestartCounts(pods))
+	assert.Equal(t, float64(120), podNotReadyDuration(pods, now))
+
+	podMetrics := []metricsv1beta1.PodMetrics{
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web-1"},
+			Containers: []metricsv1beta1.ContainerMetrics{
+				{Usage: coreV1.ResourceList{coreV1.ResourceCPU: resource.MustParse("200m"), coreV1.ResourceMemory: resource.MustParse("64Mi")}},
+			},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "web-2"},
+			Containers: []metricsv1beta1.ContainerMetrics{
+				{Usage: coreV1.ResourceList{coreV1.ResourceCPU: resource.MustParse("300m"), coreV1.ResourceMemory: resource.MustParse("64Mi")}},
+			},
+		},
+		{
+			ObjectMeta: metaV1.ObjectMeta{Name: "other"},
+			Containers: []metricsv1beta1.ContainerMetrics{
+				{Usage: coreV1.ResourceList{coreV1.ResourceCPU: resource.MustParse("1")}},
+			},
+		},
+	}
+
+	assert.Equal(t, 0.5, resourceUsageValue(corev1alpha1.AlertRuleMetricCPU, pods, podMetrics))
+	assert.Equal(t, float64(128*1024*1024), resourceUsageValue(corev1alpha1.AlertRuleMetricMemory, pods, podMetrics))
+
+	value, err := parsePromInstantValue([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1596240000,"0.25"]}]}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 0.25, value)
+
+	value, err = parsePromInstantValue([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1596240000,"NaN"]}]}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, float64(0), value)
+
+	_, err = parsePromInstantValue([]byte(`{"status":"error","error":"bad query"}`))
+	assert.NotNil(t, err)
+
+	rule := genAlertRule()
+	rule.Spec.Metric = corev1alpha1.AlertRuleMetricHTTP5xxRate
+	assert.Equal(t, `sum(istio:istio_requests_total:by_destination_service:resp5xx_rate5m{destination_service=~"web\\.app\\.svc\\.cluster\\.local"})`, httpAlertQuery(rule))
+}
+
+func TestRestartsInWindow(t *testing.T) {
+	now := time.Now()
+	status := &corev1alpha1.AlertRuleStatus{}
+
+	// restarts before the first evaluation are not counted
+	assert.Equal(t, float64(0), restartsInWindow(status, map[string]int32{"uid-1/web": 5}, now))
+	assert.Len(t, status.RestartCountSamples, 1)
+
+	// samples are taken with the interval, restarts are counted from the first one
+	assert.Equal(t, float64(2), restartsInWindow(status, map[string]int32{"uid-1/web": 7}, now.Add(time.Minute)))
+	assert.Len(t, status.RestartCountSamples, 1)
+
+	// containers of new pods count all of their restarts
+	assert.Equal(t, float64(5), restartsInWindow(status, map[string]int32{"uid-1/web": 7, "uid-2/web": 3}, now.Add(3*time.Minute)))
+	assert.Len(t, status.RestartCountSamples, 2)
+
+	// no restarts in the window, the rule resolves
+	for i := 5; i <= 15; i += 2 {
+		restartsInWindow(status, map[string]int32{"uid-1/web": 7, "uid-2/web": 3}, now.Add(time.Duration(i)*time.Minute))
+	}
+
+	assert.Equal(t, float64(0), restartsInWindow(status, map[string]int32{"uid-1/web": 7, "uid-2/web": 3}, now.Add(16*time.Minute)))
+	assert.True(t, len(status.RestartCountSamples) <= 7)
+	assert.True(t, now.Add(16*time.Minute).Sub(status.RestartCountSamples[0].Time.Time) >= corev1alpha1.AlertRuleRestartCountWindow)
+}
+
+type fakeAlertMetricSource struct {
+	value float64
+}
+
+func (s *fakeAlertMetricSource) Value(ctx context.Context, rule *corev1alpha1.AlertRule) (float64, error) {
+	return s.value, nil
+}
+
+func TestAlertRuleReconcile(t *testing.T) {
+	var notifications []alert.Notification
+	unavailable := true
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unavailable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var n alert.Notification
+		_ = json.NewDecoder(r.Body).Decode(&n)
+		notifications = append(notifications, n)
+	}))
+	defer server.Close()
+
+	defer func(check func(string) error) { alert.CheckDialAddress = check }(alert.CheckDialAddress)
+	alert.CheckDialAddress = func(string) error { return nil }
+
+	rule := genAlertRule()
+	rule.Spec.For = nil
+	rule.Spec.Receivers = []corev1alpha1.AlertReceiver{{Type: corev1alpha1.AlertReceiverTypeWebhook, URL: server.URL}}
+
+	base := newFakeBaseReconciler(rule)
+	c := base.Client
+	source := &fakeAlertMetricSource{value: 0.8}
+
+	r := &AlertRuleReconciler{
+		BaseReconciler: base,
+		ctx:            context.Background(),
+		source:         source,
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "app", Name: "high-cpu"}}
+
+	result, err := r.Reconcile(req)
+	assert.Nil(t, err)
+	assert.Equal(t, AlertRuleEvaluationInterval, result.RequeueAfter)
+	assert.Len(t, notifications, 0)
+
+	var fetched corev1alpha1.AlertRule
+	assert.Nil(t, c.Get(context.Background(), req.NamespacedName, &fetched))
+	assert.Equal(t, corev1alpha1.AlertStateFiring, fetched.Status.State)
+	assert.Equal(t, "firing", fetched.Status.UndeliveredNotification)
+	assert.Len(t, fetched.Status.History, 1)
+	assert.False(t, fetched.Status.History[0].Notified)
+	assert.Equal(t, "webhook receiver: delivery failed", fetched.Status.History[0].Error)
+
+	// the failed notification is retried in the next evaluation
+	unavailable = false
+	_, err = r.Reconcile(req)
+	assert.Nil(t, err)
+
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, alert.NotificationStateFiring, notifications[0].State)
+	assert.Equal(t, "app", notifications[0].Application)
+	assert.Equal(t, "800m", notifications[0].Value)
+
+	// the fake client can't remove fields with merge patches, cleared fields are covered in TestRetryUndeliveredNotification
+	assert.Nil(t, c.Get(context.Background(), req.NamespacedName, &fetched))
+	assert.Equal(t, corev1alpha1.AlertStateFiring, fetched.Status.State)
+	assert.Len(t, fetched.Status.History, 1)
+	assert.True(t, fetched.Status.History[0].Notified)
+	fetched.Status.UndeliveredNotification = ""
+	assert.Nil(t, c.Status().Update(context.Background(), &fetched))
+
+	// silenced rules record resolutions without notifications
+	silencedUntil := metaV1.NewTime(time.Now().Add(time.Hour))
+	fetched.Spec.SilencedUntil = &silencedUntil
+	assert.Nil(t, c.Update(context.Background(), &fetched))
+
+	source.value = 0.1
+	_, err = r.Reconcile(req)
+	assert.Nil(t, err)
+	assert.Len(t, notifications, 1)
+
+	assert.Nil(t, c.Get(context.Background(), req.NamespacedName, &fetched))
+	assert.Equal(t, corev1alpha1.AlertStateOK, fetched.Status.State)
+	assert.Len(t, fetched.Status.History, 2)
+	assert.True(t, fetched.Status.History[1].Silenced)
+	assert.False(t, fetched.Status.History[1].Notified)
+}
+
+func TestRetryUndeliveredNotification(t *testing.T) {
+	var notifications int
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notifications++
+	}))
+	defer server.Close()
+
+	defer func(check func(string) error) { alert.CheckDialAddress = check }(alert.CheckDialAddress)
+	alert.CheckDialAddress = func(string) error { return nil }
+
+	r := &AlertRuleReconciler{
+		BaseReconciler: &BaseReconciler{Log: ctrl.Log.WithName("test")},
+		ctx:            context.Background(),
+	}
+
+	rule := genAlertRule()
+	rule.Spec.Receivers = []corev1alpha1.AlertReceiver{{Type: corev1alpha1.AlertReceiverTypeWebhook, URL: server.URL}}
+	rule.Status.State = corev1alpha1.AlertStateOK
+	rule.Status.UndeliveredNotification = string(alert.NotificationStateFiring)
+	rule.Status.History = []corev1alpha1.AlertRecord{
+		{State: corev1alpha1.AlertStateFiring, Error: "webhook receiver: delivery failed"},
+		{State: corev1alpha1.AlertStateOK, Notified: true},
+	}
+
+	// the rule is not firing anymore, the firing notification is dropped
+	r.retryUndeliveredNotification(rule, alert.NotificationStateFiring, time.Now())
+	assert.Equal(t, 0, notifications)
+	assert.Equal(t, "", rule.Status.UndeliveredNotification)
+
+	rule.Status.History = append(rule.Status.History,
+		corev1alpha1.AlertRecord{State: corev1alpha1.AlertStateFiring},
+		corev1alpha1.AlertRecord{State: corev1alpha1.AlertStateOK, Error: "webhook receiver: delivery failed"},
+		corev1alpha1.AlertRecord{State: corev1alpha1.AlertStatePending},
+	)
+	rule.Status.State = corev1alpha1.AlertStatePending
+	rule.Status.UndeliveredNotification = string(alert.NotificationStateResolved)
+
+	r.retryUndeliveredNotification(rule, alert.NotificationStateResolved, time.Now())
+	assert.Equal(t, 1, notifications)
+	assert.Equal(t, "", rule.Status.UndeliveredNotification)
+	assert.True(t, rule.Status.History[3].Notified)
+	assert.Equal(t, "", rule.Status.History[3].Error)
+	assert.Equal(t, "webhook receiver: delivery failed", rule.Status.History[0].Error)
+}
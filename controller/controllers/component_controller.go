@@ -18,13 +18,17 @@ package controllers
 import (
 	"context"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"path"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	js "github.com/dop251/goja"
 	"github.com/kalmhq/kalm/controller/lib/files"
@@ -36,15 +40,21 @@ import (
 	batchV1 "k8s.io/api/batch/v1"
 	batchV1Beta1 "k8s.io/api/batch/v1beta1"
 	coreV1 "k8s.io/api/core/v1"
+	policyV1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
@@ -53,6 +63,8 @@ import (
 
 const (
 	AnnoLastUpdatedByWebhook = "last-updated-by-webhook"
+	AnnoSecretEnvChecksum    = "kalm.dev/secret-env-checksum"
+	AnnoConfigFilesChecksum  = "kalm.dev/config-files-checksum"
 	ControllerComponent      = "controller-component"
 )
 
@@ -74,10 +86,21 @@ type ComponentReconcilerTask struct {
 	destinationRule *v1alpha3.DestinationRule
 	headlessService *coreV1.Service
 	cronJob         *batchV1Beta1.CronJob
+	job             *batchV1.Job
 	deployment      *appsV1.Deployment
 	daemonSet       *appsV1.DaemonSet
 	statefulSet     *appsV1.StatefulSet
 	pluginBindings  *corev1alpha1.ComponentPluginBindingList
+	pdb             *policyV1beta1.PodDisruptionBudget
+
+	// values of external envs, filled by resolveExternalEnvs()
+	sharedEnv map[string]string
+
+	// checksum of the version of the secret referenced by secret envs, filled by resolveSecretEnvs()
+	secretEnvChecksum string
+
+	// set when the component needs to be reconciled again later, e.g. waiting for dependencies with a timeout
+	requeueAfter time.Duration
 }
 
 // +kubebuilder:rbac:groups=core.kalm.dev,resources=components,verbs=get;list;watch;create;update;patch;delete
@@ -92,8 +115,13 @@ type ComponentReconcilerTask struct {
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=persistentvolume,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=networking.istio.io,resources=destinationrules,verbs=*
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
 
 func (r *ComponentReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	r.Log.Info("reconciling component", "req", req)
@@ -103,7 +131,9 @@ func (r *ComponentReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		ctx:                 context.Background(),
 	}
 
-	return ctrl.Result{}, task.Run(req)
+	err := task.Run(req)
+
+	return ctrl.Result{RequeueAfter: task.requeueAfter}, err
 }
 
 func (r *ComponentReconcilerTask) WarningEvent(err error, msg string, args ...interface{}) {
@@ -156,7 +186,201 @@ func (r *ComponentPluginBindingsMapper) Map(object handler.MapObject) []reconcil
 	}
 }
 
+// Re-reconcile components using envs of the given type when their source in the application changes.
+// The source is the shared env configmap for external envs, and the secret env secret for secret envs.
+type EnvSourceMapper struct {
+	*BaseReconciler
+	envType corev1alpha1.EnvVarType
+}
+
+func (r *EnvSourceMapper) Map(object handler.MapObject) []reconcile.Request {
+	var componentList corev1alpha1.ComponentList
+
+	if err := r.List(context.Background(), &componentList, client.InNamespace(object.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "Can't list components in env source mapper.")
+		return nil
+	}
+
+	var res []reconcile.Request
+
+	for i := range componentList.Items {
+		if !hasEnvOfType(&componentList.Items[i], r.envType) {
+			continue
+		}
+
+		res = append(res, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      componentList.Items[i].Name,
+				Namespace: componentList.Items[i].Namespace,
+			},
+		})
+	}
+
+	return res
+}
+
+// Re-reconcile components mounting config files when the files they mount are changed in the kalm-files config map.
+// Contents of files may be sharded into other config maps and secrets, but an edit always stores the content
+// under a new ref in the index, as the ref of the previous revision is kept in the history. So shards are not watched.
+type ConfigFilesHandler struct {
+	*BaseReconciler
+}
+
+var _ handler.EventHandler = &ConfigFilesHandler{}
+
+func (r *ConfigFilesHandler) Create(e event.CreateEvent, queue workqueue.RateLimitingInterface) {
+	r.enqueue(e.Meta, nil, e.Object, queue)
+}
+
+func (r *ConfigFilesHandler) Update(e event.UpdateEvent, queue workqueue.RateLimitingInterface) {
+	r.enqueue(e.MetaNew, e.ObjectOld, e.ObjectNew, queue)
+}
+
+func (r *ConfigFilesHandler) Delete(e event.DeleteEvent, queue workqueue.RateLimitingInterface) {
+	r.enqueue(e.Meta, e.Object, nil, queue)
+}
+
+func (r *ConfigFilesHandler) Generic(event.GenericEvent, workqueue.RateLimitingInterface) {}
+
+func (r *ConfigFilesHandler) enqueue(meta metaV1.Object, oldObj, newObj runtime.Object, queue workqueue.RateLimitingInterface) {
+	oldConfigMap, _ := oldObj.(*coreV1.ConfigMap)
+	newConfigMap, _ := newObj.(*coreV1.ConfigMap)
+	paths := files.ChangedFilePaths(oldConfigMap, newConfigMap)
+
+	if len(paths) == 0 {
+		return
+	}
+
+	var componentList corev1alpha1.ComponentList
+
+	if err := r.List(context.Background(), &componentList, client.InNamespace(meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "Can't list components in config files handler.")
+		return
+	}
+
+	for i := range componentList.Items {
+		if !componentMountsAnyOf(&componentList.Items[i], paths) {
+			continue
+		}
+
+		queue.Add(reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      componentList.Items[i].Name,
+				Namespace: componentList.Items[i].Namespace,
+			},
+		})
+	}
+}
+
+// Index of components by the components they start after
+const startAfterComponentsKey = ".spec.startAfterComponents"
+
+// Re-reconcile components waiting for a component when its workload changes.
+type DependentComponentsMapper struct {
+	*BaseReconciler
+}
+
+func (r *DependentComponentsMapper) Map(object handler.MapObject) []reconcile.Request {
+	componentName := object.Meta.GetLabels()[KalmLabelComponentKey]
+
+	if componentName == "" {
+		return nil
+	}
+
+	var componentList corev1alpha1.ComponentList
+
+	if err := r.List(
+		context.Background(),
+		&componentList,
+		client.InNamespace(object.Meta.GetNamespace()),
+		client.MatchingFields{startAfterComponentsKey: componentName},
+	); err != nil {
+		r.Log.Error(err, "Can't list components in dependent components mapper.")
+		return nil
+	}
+
+	res := make([]reconcile.Request, 0, len(componentList.Items))
+
+	for i := range componentList.Items {
+		res = append(res, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      componentList.Items[i].Name,
+				Namespace: componentList.Items[i].Namespace,
+			},
+		})
+	}
+
+	return res
+}
+
+// Re-reconcile components with lifecycle commands when their pods change, to report command failures.
+type LifecycleCommandsPodMapper struct {
+	*BaseReconciler
+}
+
+func (r *LifecycleCommandsPodMapper) Map(object handler.MapObject) []reconcile.Request {
+	componentName := object.Meta.GetLabels()[KalmLabelComponentKey]
+
+	if componentName == "" {
+		return nil
+	}
+
+	key := types.NamespacedName{Namespace: object.Meta.GetNamespace(), Name: componentName}
+
+	var component corev1alpha1.Component
+
+	if err := r.Get(context.Background(), key, &component); err != nil {
+		return nil
+	}
+
+	if !hasLifecycleCommands(&component) {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: key}}
+}
+
+// metaPredicate filters events of objects by their metadata
+func metaPredicate(f func(meta metaV1.Object) bool) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return f(e.Meta) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return f(e.MetaNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return f(e.Meta) },
+		GenericFunc: func(e event.GenericEvent) bool { return f(e.Meta) },
+	}
+}
+
+func hasName(name string) func(meta metaV1.Object) bool {
+	return func(meta metaV1.Object) bool {
+		return meta.GetName() == name
+	}
+}
+
+func hasComponentLabel(meta metaV1.Object) bool {
+	return meta.GetLabels()[KalmLabelComponentKey] != ""
+}
+
+func hasLifecycleCommands(component *corev1alpha1.Component) bool {
+	return len(component.Spec.BeforeStart) > 0 || len(component.Spec.AfterStart) > 0 || len(component.Spec.BeforeDestroy) > 0
+}
+
+func hasEnvOfType(component *corev1alpha1.Component, envType corev1alpha1.EnvVarType) bool {
+	for _, env := range component.Spec.Env {
+		if env.Type == envType {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (r *ComponentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1alpha1.Component{}, startAfterComponentsKey, func(rawObj runtime.Object) []string {
+		return rawObj.(*corev1alpha1.Component).Spec.StartAfterComponents
+	}); err != nil {
+		return err
+	}
+
 	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &appsV1.Deployment{}, ownerKey, func(rawObj runtime.Object) []string {
 		deployment := rawObj.(*appsV1.Deployment)
 		owner := metaV1.GetControllerOf(deployment)
@@ -209,16 +433,45 @@ func (r *ComponentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return err
 	}
 
+	// Watches of the same kind share one informer. Only events of the few objects components use are handled.
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1alpha1.Component{}).
 		Watches(&source.Kind{Type: &corev1alpha1.ComponentPluginBinding{}}, &handler.EnqueueRequestsFromMapFunc{
 			ToRequests: &ComponentPluginBindingsMapper{r.BaseReconciler},
 		}).
+		Watches(&source.Kind{Type: &coreV1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: &EnvSourceMapper{r.BaseReconciler, corev1alpha1.EnvVarTypeExternal},
+		}, builder.WithPredicates(metaPredicate(hasName(corev1alpha1.SharedEnvConfigMapName)))).
+		Watches(
+			&source.Kind{Type: &coreV1.ConfigMap{}},
+			&ConfigFilesHandler{r.BaseReconciler},
+			builder.WithPredicates(metaPredicate(hasName(files.KALM_CONFIG_MAP_NAME))),
+		).
+		Watches(&source.Kind{Type: &coreV1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: &EnvSourceMapper{r.BaseReconciler, corev1alpha1.EnvVarTypeSecret},
+		}, builder.WithPredicates(metaPredicate(hasName(corev1alpha1.SecretEnvSecretName)))).
+		Watches(&source.Kind{Type: &appsV1.Deployment{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: &DependentComponentsMapper{r.BaseReconciler},
+		}).
+		Watches(&source.Kind{Type: &appsV1.StatefulSet{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: &DependentComponentsMapper{r.BaseReconciler},
+		}).
+		Watches(&source.Kind{Type: &appsV1.DaemonSet{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: &DependentComponentsMapper{r.BaseReconciler},
+		}).
+		Watches(&source.Kind{Type: &batchV1.Job{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: &DependentComponentsMapper{r.BaseReconciler},
+		}).
+		Watches(&source.Kind{Type: &coreV1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: &LifecycleCommandsPodMapper{r.BaseReconciler},
+		}, builder.WithPredicates(metaPredicate(hasComponentLabel))).
 		Owns(&appsV1.Deployment{}).
 		Owns(&batchV1Beta1.CronJob{}).
+		Owns(&batchV1.Job{}).
 		Owns(&appsV1.DaemonSet{}).
 		Owns(&appsV1.StatefulSet{}).
 		Owns(&coreV1.Service{}).
+		Owns(&policyV1beta1.PodDisruptionBudget{}).
 		Complete(r)
 }
 func (r *ComponentReconcilerTask) Run(req ctrl.Request) error {
@@ -246,14 +499,56 @@ func (r *ComponentReconcilerTask) Run(req ctrl.Request) error {
 		return err
 	}
 
+	if err := r.ReconcileVolumeExpansion(); err != nil {
+		return err
+	}
+
+	if err := r.ReconcilePodDisruptionBudget(); err != nil {
+		return err
+	}
+
+	if err := r.reconcileLifecycleCommandsStatus(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 const (
 	KalmLabelComponentKey = "kalm-component"
 	KalmLabelNamespaceKey = "kalm-namespace"
+
+	// The run number of jobs created for job components
+	KalmLabelJobRunKey = "kalm-job-run"
 )
 
+// GetJobRun returns the current run of a job component. Runs start from 1.
+func GetJobRun(component *corev1alpha1.Component) int {
+	run, err := strconv.Atoi(component.Annotations[corev1alpha1.ComponentJobRunAnnotation])
+
+	if err != nil || run < 1 {
+		return 1
+	}
+
+	return run
+}
+
+// GetJobNameOfRun returns the name of the job of a run. The job controller puts the name in a label of pods,
+// which is limited to 63 characters. Long component names are truncated, and a short hash of the name is appended,
+// so components sharing the prefix don't share jobs. Runs of a component are found by labels, not by names.
+func GetJobNameOfRun(componentName string, run int) string {
+	suffix := fmt.Sprintf("-%d", run)
+
+	if len(componentName)+len(suffix) <= validation.DNS1123LabelMaxLength {
+		return componentName + suffix
+	}
+
+	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(componentName)))[:8]
+	suffix = "-" + hash + suffix
+
+	return componentName[:validation.DNS1123LabelMaxLength-len(suffix)] + suffix
+}
+
 func (r *ComponentReconcilerTask) GetLabels() map[string]string {
 	res := map[string]string{
 		KalmLabelNamespaceKey: r.component.Namespace,
@@ -627,6 +922,11 @@ func (r *ComponentReconcilerTask) ReconcileWorkload() (err error) {
 				return err
 			}
 		}
+		if r.job != nil {
+			if err := r.Delete(r.ctx, r.job, client.PropagationPolicy(metaV1.DeletePropagationBackground)); err != nil {
+				return err
+			}
+		}
 		if r.daemonSet != nil {
 			if err := r.Delete(r.ctx, r.daemonSet); err != nil {
 				return err
@@ -641,6 +941,26 @@ func (r *ComponentReconcilerTask) ReconcileWorkload() (err error) {
 		return
 	}
 
+	if resolved, err := r.resolveExternalEnvs(); err != nil {
+		return err
+	} else if !resolved {
+		// Keep the current workload running until the missing envs are added.
+		// The shared env watcher will trigger a new reconciliation.
+		return nil
+	}
+
+	if resolved, err := r.resolveSecretEnvs(); err != nil {
+		return err
+	} else if !resolved {
+		return nil
+	}
+
+	if ready, err := r.waitForDependencies(); err != nil {
+		return err
+	} else if !ready {
+		return nil
+	}
+
 	if err := r.reconcileDirectConfigs(); err != nil {
 		return err
 	}
@@ -660,18 +980,32 @@ func (r *ComponentReconcilerTask) ReconcileWorkload() (err error) {
 			return err
 		}
 
+		r.addBeforeStartContainer(template)
+
 		return r.ReconcileDeployment(template)
 	case corev1alpha1.WorkloadTypeCronjob:
 		if err := r.prepareVolsForSimpleWorkload(template); err != nil {
 			return err
 		}
 
+		r.addBeforeStartContainer(template)
+
 		return r.ReconcileCronJob(template)
+	case corev1alpha1.WorkloadTypeJob:
+		if err := r.prepareVolsForSimpleWorkload(template); err != nil {
+			return err
+		}
+
+		r.addBeforeStartContainer(template)
+
+		return r.ReconcileJob(template)
 	case corev1alpha1.WorkloadTypeDaemonSet:
 		if err := r.prepareVolsForSimpleWorkload(template); err != nil {
 			return err
 		}
 
+		r.addBeforeStartContainer(template)
+
 		return r.ReconcileDaemonSet(template)
 	case corev1alpha1.WorkloadTypeStatefulSet:
 		volClaimTemplates, err := r.prepareVolsForSTS(template)
@@ -679,6 +1013,8 @@ func (r *ComponentReconcilerTask) ReconcileWorkload() (err error) {
 			return err
 		}
 
+		r.addBeforeStartContainer(template)
+
 		return r.ReconcileStatefulSet(template, volClaimTemplates)
 	default:
 		return fmt.Errorf("unknown workload type: %s", string(r.component.Spec.WorkloadType))
@@ -835,10 +1171,35 @@ func (r *ComponentReconcilerTask) ReconcileCronJob(podTemplateSpec *coreV1.PodTe
 
 	successJobHistoryLimit := int32(3)
 	failJobHistoryLimit := int32(5)
+	suspend := false
+	var concurrencyPolicy batchV1Beta1.ConcurrencyPolicy
+
+	if cronJobConfig := component.Spec.CronJob; cronJobConfig != nil {
+		suspend = cronJobConfig.Suspend
+		concurrencyPolicy = cronJobConfig.ConcurrencyPolicy
+
+		if cronJobConfig.SuccessfulJobsHistoryLimit != nil {
+			successJobHistoryLimit = *cronJobConfig.SuccessfulJobsHistoryLimit
+		}
+
+		if cronJobConfig.FailedJobsHistoryLimit != nil {
+			failJobHistoryLimit = *cronJobConfig.FailedJobsHistoryLimit
+		}
+	}
+
+	if concurrencyPolicy == "" {
+		concurrencyPolicy = batchV1Beta1.AllowConcurrent
+	}
 
 	desiredCJSpec := batchV1Beta1.CronJobSpec{
-		Schedule: component.Spec.Schedule,
+		Schedule:          component.Spec.Schedule,
+		Suspend:           &suspend,
+		ConcurrencyPolicy: concurrencyPolicy,
 		JobTemplate: batchV1Beta1.JobTemplateSpec{
+			// jobs are labeled, so runs of the component can be listed
+			ObjectMeta: metaV1.ObjectMeta{
+				Labels: labelMap,
+			},
 			Spec: batchV1.JobSpec{
 				Template: *podTemplateSpec,
 			},
@@ -888,6 +1249,93 @@ func (r *ComponentReconcilerTask) ReconcileCronJob(podTemplateSpec *coreV1.PodTe
 	return nil
 }
 
+// ReconcileJob creates the job of the current run. The pod template of a started job can't be changed,
+// so spec changes take effect on the next run, except parallelism.
+func (r *ComponentReconcilerTask) ReconcileJob(podTemplateSpec *coreV1.PodTemplateSpec) error {
+	component := r.component
+	jobConfig := component.Spec.Job
+	run := GetJobRun(component)
+
+	if jobConfig == nil {
+		jobConfig = &corev1alpha1.JobConfig{}
+	}
+
+	if r.job != nil {
+		if err := r.setLastJobRun(run); err != nil {
+			return err
+		}
+
+		if jobConfig.Parallelism == nil || (r.job.Spec.Parallelism != nil && *r.job.Spec.Parallelism == *jobConfig.Parallelism) {
+			return nil
+		}
+
+		r.job.Spec.Parallelism = jobConfig.Parallelism
+
+		if err := r.Update(r.ctx, r.job); err != nil {
+			r.WarningEvent(err, "unable to update Job for Component")
+			return err
+		}
+
+		r.NormalEvent("JobUpdated", r.job.Name+" is updated.")
+		return nil
+	}
+
+	if podTemplateSpec.Spec.RestartPolicy == coreV1.RestartPolicyAlways ||
+		podTemplateSpec.Spec.RestartPolicy == "" {
+
+		podTemplateSpec.Spec.RestartPolicy = coreV1.RestartPolicyOnFailure
+	}
+
+	// the job of the run is finished and deleted, or deleted by users, a new run is started by a rerun
+	if run <= component.Status.LastJobRun {
+		return nil
+	}
+
+	labelMap := r.GetLabels()
+	labelMap[KalmLabelJobRunKey] = strconv.Itoa(run)
+
+	job := &batchV1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        GetJobNameOfRun(component.Name, run),
+			Namespace:   r.namespace.Name,
+			Labels:      labelMap,
+			Annotations: r.GetAnnotations(),
+		},
+		Spec: batchV1.JobSpec{
+			Template:                *podTemplateSpec,
+			Completions:             jobConfig.Completions,
+			Parallelism:             jobConfig.Parallelism,
+			BackoffLimit:            jobConfig.BackoffLimit,
+			TTLSecondsAfterFinished: jobConfig.TTLSecondsAfterFinished,
+		},
+	}
+
+	if err := ctrl.SetControllerReference(component, job, r.Scheme); err != nil {
+		r.WarningEvent(err, "unable to set owner for job")
+		return err
+	}
+
+	if err := r.Create(r.ctx, job); err != nil {
+		r.WarningEvent(err, "unable to create Job for Component")
+		return err
+	}
+
+	r.NormalEvent("JobCreated", job.Name+" is created.")
+
+	return r.setLastJobRun(run)
+}
+
+func (r *ComponentReconcilerTask) setLastJobRun(run int) error {
+	if r.component.Status.LastJobRun >= run {
+		return nil
+	}
+
+	copied := r.component.DeepCopy()
+	copied.Status.LastJobRun = run
+
+	return r.patchComponentStatus(copied)
+}
+
 func (r *ComponentReconcilerTask) ReconcileStatefulSet(
 	spec *coreV1.PodTemplateSpec,
 	volClaimTemplates []coreV1.PersistentVolumeClaim,
@@ -1006,6 +1454,11 @@ func (r *ComponentReconcilerTask) GetPodTemplateWithoutVols() (template *coreV1.
 		template.ObjectMeta.Annotations[AnnoLastUpdatedByWebhook] = v
 	}
 
+	// pods are re-rolled once the secret of secret envs is changed
+	if r.secretEnvChecksum != "" {
+		template.ObjectMeta.Annotations[AnnoSecretEnvChecksum] = r.secretEnvChecksum
+	}
+
 	mainContainer := &template.Spec.Containers[0]
 
 	if component.Spec.TerminationGracePeriodSeconds != nil {
@@ -1021,6 +1474,22 @@ func (r *ComponentReconcilerTask) GetPodTemplateWithoutVols() (template *coreV1.
 		}
 	}
 
+	if len(component.Spec.AfterStart) > 0 || len(component.Spec.BeforeDestroy) > 0 {
+		mainContainer.Lifecycle = &coreV1.Lifecycle{}
+
+		if len(component.Spec.AfterStart) > 0 {
+			mainContainer.Lifecycle.PostStart = &coreV1.Handler{
+				Exec: &coreV1.ExecAction{Command: getLifecycleCommand(component.Spec.AfterStart)},
+			}
+		}
+
+		if len(component.Spec.BeforeDestroy) > 0 {
+			mainContainer.Lifecycle.PreStop = &coreV1.Handler{
+				Exec: &coreV1.ExecAction{Command: getLifecycleCommand(component.Spec.BeforeDestroy)},
+			}
+		}
+	}
+
 	var pullImageSecrets coreV1.SecretList
 	if err := r.Client.List(
 		r.ctx,
@@ -1054,6 +1523,9 @@ func (r *ComponentReconcilerTask) GetPodTemplateWithoutVols() (template *coreV1.
 		template.Spec.Affinity = affinity
 	}
 
+	template.Spec.TopologySpreadConstraints = r.getTopologySpreadConstraints()
+	template.Spec.Tolerations = component.Spec.Tolerations
+
 	if component.Spec.RunnerPermission != nil {
 		template.Spec.ServiceAccountName = r.getNameForPermission()
 	}
@@ -1074,12 +1546,17 @@ func (r *ComponentReconcilerTask) GetPodTemplateWithoutVols() (template *coreV1.
 		case "", corev1alpha1.EnvVarTypeStatic:
 			value = env.Value
 		case corev1alpha1.EnvVarTypeExternal:
-			//value, err = r.FindShareEnvValue(env.Value)
-			//
-			////  if the env can't be found in sharedEnv, ignore it
-			//if err != nil {
-			//	continue
-			//}
+			// values are inlined, so pods are re-rolled once a shared value changes
+			value = r.sharedEnv[env.Value]
+		case corev1alpha1.EnvVarTypeSecret:
+			valueFrom = &coreV1.EnvVarSource{
+				SecretKeyRef: &coreV1.SecretKeySelector{
+					LocalObjectReference: coreV1.LocalObjectReference{
+						Name: corev1alpha1.SecretEnvSecretName,
+					},
+					Key: env.Value,
+				},
+			}
 		case corev1alpha1.EnvVarTypeLinked:
 			value, err = r.getValueOfLinkedEnv(env)
 			if err != nil {
@@ -1134,28 +1611,481 @@ func (r *ComponentReconcilerTask) GetPodTemplateWithoutVols() (template *coreV1.
 	return template, nil
 }
 
-func getVolName(componentName, diskPath string) string {
-	return fmt.Sprintf("%s-%x", componentName, md5.Sum([]byte(diskPath)))
+// Lifecycle commands are joined, so they run in order and stop at the first failure.
+func getLifecycleCommand(commands []string) []string {
+	return []string{"sh", "-c", strings.Join(commands, " && ")}
 }
 
-func (r *ComponentReconcilerTask) getValueOfLinkedEnv(env corev1alpha1.EnvVar) (string, error) {
-	if env.Value == "" {
-		return env.Value, nil
+const BeforeStartContainerName = "before-start"
+
+// addBeforeStartContainer runs BeforeStart commands in the last init container,
+// so pre injected files and volumes are ready when they run.
+func (r *ComponentReconcilerTask) addBeforeStartContainer(template *coreV1.PodTemplateSpec) {
+	if len(r.component.Spec.BeforeStart) == 0 {
+		return
 	}
 
-	parts := strings.Split(env.Value, "/")
-	if len(parts) != 2 {
-		return "", fmt.Errorf("wrong componentPort config %s, format error", env.Value)
+	mainContainer := &template.Spec.Containers[0]
+
+	template.Spec.InitContainers = append(template.Spec.InitContainers, coreV1.Container{
+		Name:            BeforeStartContainerName,
+		Image:           mainContainer.Image,
+		ImagePullPolicy: mainContainer.ImagePullPolicy,
+		Command:         getLifecycleCommand(r.component.Spec.BeforeStart),
+		Env:             mainContainer.Env,
+		EnvFrom:         mainContainer.EnvFrom,
+		VolumeMounts:    mainContainer.VolumeMounts,
+		Resources:       mainContainer.Resources,
+	})
+}
+
+// reconcileLifecycleCommandsStatus reports failed lifecycle commands of current pods in the component status.
+// BeforeStart failures are read from the init container status,
+// AfterStart and BeforeDestroy failures are only recorded in events of pods by kubelet.
+// Pods are read from the cache, events are not cached, they are listed once for all pods.
+func (r *ComponentReconcilerTask) reconcileLifecycleCommandsStatus() error {
+	if !hasLifecycleCommands(r.component) {
+		return r.removeComponentCondition(corev1alpha1.ComponentConditionLifecycleCommandsSucceeded)
 	}
 
-	var service coreV1.Service
-	err := r.Reader.Get(r.ctx, types.NamespacedName{
-		Namespace: r.component.Namespace,
-		Name:      parts[0],
-	}, &service)
+	var podList coreV1.PodList
 
-	if err != nil {
-		return "", fmt.Errorf("wrong componentPort config %s, service not exist", env.Value)
+	if err := r.List(r.ctx, &podList, client.InNamespace(r.component.Namespace), client.MatchingLabels{KalmLabelComponentKey: r.component.Name}); err != nil {
+		return err
+	}
+
+	var reason string
+	var failures []string
+
+	addFailure := func(failureReason, msg string, args ...interface{}) {
+		if reason == "" {
+			reason = failureReason
+		}
+
+		failures = append(failures, fmt.Sprintf(msg, args...))
+	}
+
+	for _, pod := range podList.Items {
+		for _, status := range pod.Status.InitContainerStatuses {
+			if status.Name != BeforeStartContainerName {
+				continue
+			}
+
+			if exitCode := getFailedExitCode(status); exitCode != 0 {
+				addFailure("BeforeStartFailed", "before start commands failed in pod %s with exit code %d", pod.Name, exitCode)
+			}
+		}
+	}
+
+	if len(podList.Items) > 0 && (len(r.component.Spec.AfterStart) > 0 || len(r.component.Spec.BeforeDestroy) > 0) {
+		var eventList coreV1.EventList
+
+		// only warnings of pods, a namespace may have lots of events
+		if err := r.Reader.List(r.ctx, &eventList, client.InNamespace(r.component.Namespace), client.MatchingFields{
+			"involvedObject.kind": "Pod",
+			"type":                coreV1.EventTypeWarning,
+		}); err != nil {
+			return err
+		}
+
+		for _, failure := range getLifecycleHookFailures(podList.Items, eventList.Items) {
+			addFailure(failure.reason, "%s", failure.message)
+		}
+	}
+
+	copied := r.component.DeepCopy()
+
+	if len(failures) == 0 {
+		copied.Status.SetCondition(corev1alpha1.ComponentCondition{
+			Type:   corev1alpha1.ComponentConditionLifecycleCommandsSucceeded,
+			Status: coreV1.ConditionTrue,
+		})
+
+		return r.patchComponentStatus(copied)
+	}
+
+	condition := corev1alpha1.ComponentCondition{
+		Type:    corev1alpha1.ComponentConditionLifecycleCommandsSucceeded,
+		Status:  coreV1.ConditionFalse,
+		Reason:  reason,
+		Message: strings.Join(failures, "; "),
+	}
+
+	// only emit the event when the failure changes, pods keep updating while they are crashing
+	if current := r.component.Status.GetCondition(condition.Type); current == nil || current.Message != condition.Message {
+		r.WarningEvent(fmt.Errorf("%s", condition.Message), "lifecycle commands failed")
+	}
+
+	copied.Status.SetCondition(condition)
+
+	return r.patchComponentStatus(copied)
+}
+
+type lifecycleHookFailure struct {
+	reason  string
+	message string
+}
+
+// getLifecycleHookFailures returns failed hooks of the pods recorded in the events, in the order of the pods
+func getLifecycleHookFailures(pods []coreV1.Pod, events []coreV1.Event) []lifecycleHookFailure {
+	var res []lifecycleHookFailure
+
+	for _, pod := range pods {
+		for _, event := range events {
+			if event.InvolvedObject.UID != pod.UID {
+				continue
+			}
+
+			switch event.Reason {
+			case "FailedPostStartHook":
+				res = append(res, lifecycleHookFailure{"AfterStartFailed", fmt.Sprintf("after start commands failed in pod %s: %s", pod.Name, event.Message)})
+			case "FailedPreStopHook":
+				res = append(res, lifecycleHookFailure{"BeforeDestroyFailed", fmt.Sprintf("before destroy commands failed in pod %s: %s", pod.Name, event.Message)})
+			}
+		}
+	}
+
+	return res
+}
+
+// getFailedExitCode returns the exit code of the last failure of the container, or 0 if it's not failing.
+// A failure of the last termination is cleared once the container is ready again.
+func getFailedExitCode(status coreV1.ContainerStatus) int32 {
+	if status.Ready {
+		return 0
+	}
+
+	terminated := status.State.Terminated
+
+	if terminated == nil {
+		terminated = status.LastTerminationState.Terminated
+	}
+
+	if terminated == nil {
+		return 0
+	}
+
+	return terminated.ExitCode
+}
+
+func getVolName(componentName, diskPath string) string {
+	return fmt.Sprintf("%s-%x", componentName, md5.Sum([]byte(diskPath)))
+}
+
+// resolveExternalEnvs loads the shared env of the application.
+// It returns false if any external env can't be found, and the reason is recorded in the component status.
+func (r *ComponentReconcilerTask) resolveExternalEnvs() (bool, error) {
+	if !hasEnvOfType(r.component, corev1alpha1.EnvVarTypeExternal) {
+		return true, r.removeComponentCondition(corev1alpha1.ComponentConditionExternalEnvResolved)
+	}
+
+	var configMap coreV1.ConfigMap
+
+	if err := r.Get(r.ctx, types.NamespacedName{
+		Name:      corev1alpha1.SharedEnvConfigMapName,
+		Namespace: r.component.Namespace,
+	}, &configMap); client.IgnoreNotFound(err) != nil {
+		r.WarningEvent(err, "get shared env failed")
+		return false, err
+	}
+
+	r.sharedEnv = configMap.Data
+
+	var missingKeys []string
+
+	for _, env := range r.component.Spec.Env {
+		if env.Type != corev1alpha1.EnvVarTypeExternal {
+			continue
+		}
+
+		if _, exist := r.sharedEnv[env.Value]; !exist {
+			missingKeys = append(missingKeys, env.Value)
+		}
+	}
+
+	return r.setEnvResolvedCondition(corev1alpha1.ComponentConditionExternalEnvResolved, "shared env "+corev1alpha1.SharedEnvConfigMapName, missingKeys)
+}
+
+// resolveSecretEnvs checks keys referenced by secret envs exist, and computes the checksum of their values.
+// Values never leave the secret, workloads read them with secretKeyRef.
+func (r *ComponentReconcilerTask) resolveSecretEnvs() (bool, error) {
+	if !hasEnvOfType(r.component, corev1alpha1.EnvVarTypeSecret) {
+		return true, r.removeComponentCondition(corev1alpha1.ComponentConditionSecretEnvResolved)
+	}
+
+	var secret coreV1.Secret
+
+	if err := r.Get(r.ctx, types.NamespacedName{
+		Name:      corev1alpha1.SecretEnvSecretName,
+		Namespace: r.component.Namespace,
+	}, &secret); client.IgnoreNotFound(err) != nil {
+		r.WarningEvent(err, "get secret env failed")
+		return false, err
+	}
+
+	var missingKeys []string
+
+	// The checksum is readable by anyone who can read the workload, so it's computed from the updated time of
+	// the used keys instead of the values. Changes of other keys don't re-roll the pods.
+	// Keys without an updated time are not written by kalm, the version of the secret is used for them.
+	updatedAt := make(map[string]string)
+	_ = json.Unmarshal([]byte(secret.Annotations[corev1alpha1.SecretEnvUpdatedAtAnnotation]), &updatedAt)
+
+	hash := sha256.New()
+
+	for _, env := range r.component.Spec.Env {
+		if env.Type != corev1alpha1.EnvVarTypeSecret {
+			continue
+		}
+
+		if _, exist := secret.Data[env.Value]; !exist {
+			missingKeys = append(missingKeys, env.Value)
+			continue
+		}
+
+		version, exist := updatedAt[env.Value]
+
+		if !exist {
+			version = string(secret.UID) + "/" + secret.ResourceVersion
+		}
+
+		hash.Write([]byte(env.Value))
+		hash.Write([]byte{0})
+		hash.Write([]byte(version))
+		hash.Write([]byte{0})
+	}
+
+	r.secretEnvChecksum = hex.EncodeToString(hash.Sum(nil))
+
+	return r.setEnvResolvedCondition(corev1alpha1.ComponentConditionSecretEnvResolved, "secret env "+corev1alpha1.SecretEnvSecretName, missingKeys)
+}
+
+func (r *ComponentReconcilerTask) setEnvResolvedCondition(conditionType corev1alpha1.ComponentConditionType, sourceName string, missingKeys []string) (bool, error) {
+	copied := r.component.DeepCopy()
+
+	if len(missingKeys) > 0 {
+		err := fmt.Errorf("keys %s are not found in %s", strings.Join(missingKeys, ", "), sourceName)
+		r.WarningEvent(err, "resolve envs failed")
+
+		copied.Status.SetCondition(corev1alpha1.ComponentCondition{
+			Type:    conditionType,
+			Status:  coreV1.ConditionFalse,
+			Reason:  "MissingKeys",
+			Message: err.Error(),
+		})
+
+		return false, r.patchComponentStatus(copied)
+	}
+
+	copied.Status.SetCondition(corev1alpha1.ComponentCondition{
+		Type:   conditionType,
+		Status: coreV1.ConditionTrue,
+	})
+
+	return true, r.patchComponentStatus(copied)
+}
+
+func (r *ComponentReconcilerTask) removeComponentCondition(conditionType corev1alpha1.ComponentConditionType) error {
+	if r.component.Status.GetCondition(conditionType) == nil {
+		return nil
+	}
+
+	copied := r.component.DeepCopy()
+	copied.Status.RemoveCondition(conditionType)
+	return r.patchComponentStatus(copied)
+}
+
+func (r *ComponentReconcilerTask) hasWorkload() bool {
+	return r.deployment != nil || r.statefulSet != nil || r.daemonSet != nil || r.cronJob != nil || r.job != nil
+}
+
+// waitForDependencies holds the first start of the workload until all components in StartAfterComponents are ready.
+// Running workloads are never stopped because a dependency becomes unready.
+// It returns false if the workload should not be created yet.
+func (r *ComponentReconcilerTask) waitForDependencies() (bool, error) {
+	if len(r.component.Spec.StartAfterComponents) == 0 {
+		return true, r.removeComponentCondition(corev1alpha1.ComponentConditionDependenciesReady)
+	}
+
+	waitingOn, err := r.findUnreadyDependency()
+
+	if err != nil {
+		return false, err
+	}
+
+	copied := r.component.DeepCopy()
+
+	if waitingOn == "" {
+		copied.Status.SetCondition(corev1alpha1.ComponentCondition{
+			Type:   corev1alpha1.ComponentConditionDependenciesReady,
+			Status: coreV1.ConditionTrue,
+		})
+
+		return true, r.patchComponentStatus(copied)
+	}
+
+	// Already started, the condition is kept as it is, e.g. the timeout reason stays visible.
+	if r.hasWorkload() {
+		return true, nil
+	}
+
+	condition := corev1alpha1.ComponentCondition{
+		Type:    corev1alpha1.ComponentConditionDependenciesReady,
+		Status:  coreV1.ConditionFalse,
+		Reason:  "WaitingForDependency",
+		Message: fmt.Sprintf("waiting for component %s to be ready", waitingOn),
+	}
+
+	copied.Status.SetCondition(condition)
+
+	if timeout := r.component.Spec.StartAfterComponentsTimeoutSeconds; timeout != nil {
+		waitingSince := copied.Status.GetCondition(corev1alpha1.ComponentConditionDependenciesReady).LastTransitionTime
+		remaining := time.Until(waitingSince.Add(time.Duration(*timeout) * time.Second))
+
+		if remaining <= 0 {
+			err := fmt.Errorf("component %s is not ready after %d seconds", waitingOn, *timeout)
+			r.WarningEvent(err, "wait for dependencies timeout, starting anyway")
+
+			condition.Reason = "WaitTimeout"
+			condition.Message = err.Error()
+			copied.Status.SetCondition(condition)
+
+			return true, r.patchComponentStatus(copied)
+		}
+
+		r.requeueAfter = remaining
+	}
+
+	return false, r.patchComponentStatus(copied)
+}
+
+// findUnreadyDependency returns the name of the first component in StartAfterComponents which is not ready.
+func (r *ComponentReconcilerTask) findUnreadyDependency() (string, error) {
+	for _, name := range r.component.Spec.StartAfterComponents {
+		var dep corev1alpha1.Component
+
+		if err := r.Reader.Get(r.ctx, types.NamespacedName{Namespace: r.component.Namespace, Name: name}, &dep); err != nil {
+			if errors.IsNotFound(err) {
+				return name, nil
+			}
+
+			return "", err
+		}
+
+		ready, err := r.isComponentReady(&dep)
+
+		if err != nil {
+			return "", err
+		}
+
+		if !ready {
+			return name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// A component is ready once all replicas of its current workload revision are ready.
+// Cronjobs have no long running pods, they are always ready. Jobs are ready once the current run completes.
+func (r *ComponentReconcilerTask) isComponentReady(component *corev1alpha1.Component) (bool, error) {
+	key := types.NamespacedName{Namespace: component.Namespace, Name: component.Name}
+
+	switch component.Spec.WorkloadType {
+	case corev1alpha1.WorkloadTypeCronjob:
+		return true, nil
+	case corev1alpha1.WorkloadTypeJob:
+		var job batchV1.Job
+
+		key.Name = GetJobNameOfRun(component.Name, GetJobRun(component))
+
+		if err := r.Reader.Get(r.ctx, key, &job); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+
+		for _, condition := range job.Status.Conditions {
+			if condition.Type == batchV1.JobComplete && condition.Status == coreV1.ConditionTrue {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	case corev1alpha1.WorkloadTypeDaemonSet:
+		var daemonSet appsV1.DaemonSet
+
+		if err := r.Reader.Get(r.ctx, key, &daemonSet); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+
+		return daemonSet.Status.ObservedGeneration >= daemonSet.Generation &&
+			daemonSet.Status.UpdatedNumberScheduled == daemonSet.Status.DesiredNumberScheduled &&
+			daemonSet.Status.NumberReady == daemonSet.Status.DesiredNumberScheduled, nil
+	case corev1alpha1.WorkloadTypeStatefulSet:
+		var statefulSet appsV1.StatefulSet
+
+		if err := r.Reader.Get(r.ctx, key, &statefulSet); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+
+		return statefulSet.Status.ObservedGeneration >= statefulSet.Generation &&
+			statefulSet.Status.ReadyReplicas >= desiredReplicas(statefulSet.Spec.Replicas), nil
+	default:
+		var deployment appsV1.Deployment
+
+		if err := r.Reader.Get(r.ctx, key, &deployment); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+
+		desired := desiredReplicas(deployment.Spec.Replicas)
+
+		return deployment.Status.ObservedGeneration >= deployment.Generation &&
+			deployment.Status.UpdatedReplicas >= desired &&
+			deployment.Status.ReadyReplicas >= desired, nil
+	}
+}
+
+func desiredReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+
+	return *replicas
+}
+
+// patchComponentStatus is the only writer of component status. Status is a subresource,
+// updates and patches of components by the api and other controllers don't change it.
+func (r *ComponentReconcilerTask) patchComponentStatus(copied *corev1alpha1.Component) error {
+	if reflect.DeepEqual(copied.Status, r.component.Status) {
+		return nil
+	}
+
+	if err := r.Status().Patch(r.ctx, copied, client.MergeFrom(r.component)); err != nil {
+		r.WarningEvent(err, "patch component status failed")
+		return err
+	}
+
+	r.component = copied
+	return nil
+}
+
+func (r *ComponentReconcilerTask) getValueOfLinkedEnv(env corev1alpha1.EnvVar) (string, error) {
+	if env.Value == "" {
+		return env.Value, nil
+	}
+
+	parts := strings.Split(env.Value, "/")
+	if len(parts) != 2 {
+		return "", fmt.Errorf("wrong componentPort config %s, format error", env.Value)
+	}
+
+	var service coreV1.Service
+	err := r.Reader.Get(r.ctx, types.NamespacedName{
+		Namespace: r.component.Namespace,
+		Name:      parts[0],
+	}, &service)
+
+	if err != nil {
+		return "", fmt.Errorf("wrong componentPort config %s, service not exist", env.Value)
 	}
 
 	var port int32
@@ -1308,17 +2238,21 @@ func findPluginAndValidateConfigNew(pluginBinding *corev1alpha1.ComponentPluginB
 	return pluginProgram, nil, nil
 }
 
-func (r *ComponentReconcilerTask) parseComponentConfigs(component *corev1alpha1.Component, volumes *[]coreV1.Volume, volumeMounts *[]coreV1.VolumeMount) {
-	var configMap coreV1.ConfigMap
+// parseComponentConfigs mounts files of the kalm-files config map, and records the checksum of their contents
+// in the pod template, so pods are re-rolled once a mounted file is edited.
+// Contents may be sharded into other config maps and secrets, such files are mounted with projected volumes.
+func (r *ComponentReconcilerTask) parseComponentConfigs(template *coreV1.PodTemplateSpec, volumes *[]coreV1.Volume, volumeMounts *[]coreV1.VolumeMount) error {
+	component := r.component
 
-	err := r.Client.Get(r.ctx, types.NamespacedName{
-		Name:      files.KALM_CONFIG_MAP_NAME,
-		Namespace: r.component.Namespace,
-	}, &configMap)
+	if len(component.Spec.Configs) == 0 && len(component.Spec.DirectConfigs) == 0 {
+		return nil
+	}
+
+	store, err := files.LoadStore(r.ctx, r.Reader, component.Namespace)
 
 	if err != nil {
-		r.WarningEvent(err, "can't get files config-map. Skip configs.")
-		return
+		r.WarningEvent(err, "can't load files. Skip configs.")
+		return err
 	}
 
 	// key is mount dir, values is the files
@@ -1328,7 +2262,7 @@ func (r *ComponentReconcilerTask) parseComponentConfigs(component *corev1alpha1.
 		mountPath := config.MountPath
 
 		for _, path := range config.Paths {
-			root, err := files.GetFileItemTree(&configMap, path)
+			root, err := files.GetFileItemTree(store.Index, path)
 
 			if err != nil {
 				r.WarningEvent(err, fmt.Sprintf("can't find file item at %s", path))
@@ -1339,28 +2273,20 @@ func (r *ComponentReconcilerTask) parseComponentConfigs(component *corev1alpha1.
 		}
 	}
 
+	var mountedFiles []string
+
 	for mountPath, rawFileNamesMap := range mountPaths {
 		name := fmt.Sprintf("configs-%x", md5.Sum([]byte(mountPath)))
-		items := make([]coreV1.KeyToPath, 0, len(rawFileNamesMap))
+		fileNames := make(map[string]string, len(rawFileNamesMap))
 
 		for itemRawFileName := range rawFileNamesMap {
-
-			items = append(items, coreV1.KeyToPath{
-				Path: files.GetFileNameFromRawPath(itemRawFileName),
-				Key:  files.EncodeFilePath(itemRawFileName),
-			})
+			mountedFiles = append(mountedFiles, itemRawFileName)
+			fileNames[itemRawFileName] = files.GetFileNameFromRawPath(itemRawFileName)
 		}
 
 		volume := coreV1.Volume{
-			Name: name,
-			VolumeSource: coreV1.VolumeSource{
-				ConfigMap: &coreV1.ConfigMapVolumeSource{
-					LocalObjectReference: coreV1.LocalObjectReference{
-						Name: files.KALM_CONFIG_MAP_NAME,
-					},
-					Items: items,
-				},
-			},
+			Name:         name,
+			VolumeSource: configFilesVolumeSource(store, fileNames),
 		}
 
 		volumeMount := coreV1.VolumeMount{
@@ -1379,20 +2305,8 @@ func (r *ComponentReconcilerTask) parseComponentConfigs(component *corev1alpha1.
 		name := fmt.Sprintf("direct-config-%s-%d", component.Name, i)
 
 		vol := coreV1.Volume{
-			Name: name,
-			VolumeSource: coreV1.VolumeSource{
-				ConfigMap: &coreV1.ConfigMapVolumeSource{
-					LocalObjectReference: coreV1.LocalObjectReference{
-						Name: files.KALM_CONFIG_MAP_NAME,
-					},
-					Items: []coreV1.KeyToPath{
-						{
-							Key:  files.EncodeFilePath(path),
-							Path: "adhoc-name",
-						},
-					},
-				},
-			},
+			Name:         name,
+			VolumeSource: configFilesVolumeSource(store, map[string]string{path: "adhoc-name"}),
 		}
 
 		volMount := coreV1.VolumeMount{
@@ -1403,9 +2317,89 @@ func (r *ComponentReconcilerTask) parseComponentConfigs(component *corev1alpha1.
 
 		*volumes = append(*volumes, vol)
 		*volumeMounts = append(*volumeMounts, volMount)
+		mountedFiles = append(mountedFiles, path)
+	}
+
+	template.ObjectMeta.Annotations[AnnoConfigFilesChecksum] = store.Checksum(mountedFiles)
+
+	return nil
+}
+
+// configFilesVolumeSource projects files, keys are paths in the file tree, values are file names in the volume.
+// Files with inline contents in the kalm-files config map are mounted the same way as before sharding.
+func configFilesVolumeSource(store *files.Store, fileNames map[string]string) coreV1.VolumeSource {
+	configMapItems := make(map[string][]coreV1.KeyToPath)
+	secretItems := make(map[string][]coreV1.KeyToPath)
+
+	for path, fileName := range fileNames {
+		kind, name, key := store.MountSource(path)
+		item := coreV1.KeyToPath{Key: key, Path: fileName}
+
+		if kind == files.ShardKindSecret {
+			secretItems[name] = append(secretItems[name], item)
+		} else {
+			configMapItems[name] = append(configMapItems[name], item)
+		}
+	}
+
+	for _, items := range configMapItems {
+		sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+	}
+
+	for _, items := range secretItems {
+		sort.Slice(items, func(i, j int) bool { return items[i].Path < items[j].Path })
+	}
+
+	if len(secretItems) == 0 && len(configMapItems) == 1 && configMapItems[files.KALM_CONFIG_MAP_NAME] != nil {
+		return coreV1.VolumeSource{
+			ConfigMap: &coreV1.ConfigMapVolumeSource{
+				LocalObjectReference: coreV1.LocalObjectReference{
+					Name: files.KALM_CONFIG_MAP_NAME,
+				},
+				Items: configMapItems[files.KALM_CONFIG_MAP_NAME],
+			},
+		}
+	}
+
+	var sources []coreV1.VolumeProjection
+
+	for _, name := range sortedKeys(configMapItems) {
+		sources = append(sources, coreV1.VolumeProjection{
+			ConfigMap: &coreV1.ConfigMapProjection{
+				LocalObjectReference: coreV1.LocalObjectReference{Name: name},
+				Items:                configMapItems[name],
+			},
+		})
+	}
+
+	for _, name := range sortedKeys(secretItems) {
+		sources = append(sources, coreV1.VolumeProjection{
+			Secret: &coreV1.SecretProjection{
+				LocalObjectReference: coreV1.LocalObjectReference{Name: name},
+				Items:                secretItems[name],
+			},
+		})
+	}
+
+	return coreV1.VolumeSource{
+		Projected: &coreV1.ProjectedVolumeSource{
+			Sources: sources,
+		},
 	}
 }
 
+func sortedKeys(m map[string][]coreV1.KeyToPath) []string {
+	keys := make([]string, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
 func (r *ComponentReconcilerTask) getPVC(pvcName string) (*coreV1.PersistentVolumeClaim, error) {
 	pvcList := coreV1.PersistentVolumeClaimList{}
 
@@ -1429,6 +2423,102 @@ func (r *ComponentReconcilerTask) getPVC(pvcName string) (*coreV1.PersistentVolu
 	return nil, nil
 }
 
+var topologySpreadKeys = map[corev1alpha1.TopologySpreadTopology]string{
+	corev1alpha1.TopologySpreadTopologyZone: "topology.kubernetes.io/zone",
+	corev1alpha1.TopologySpreadTopologyNode: "kubernetes.io/hostname",
+}
+
+func (r *ComponentReconcilerTask) getTopologySpreadConstraints() []coreV1.TopologySpreadConstraint {
+	var res []coreV1.TopologySpreadConstraint
+
+	for _, spread := range r.component.Spec.TopologySpread {
+		maxSkew := spread.MaxSkew
+
+		if maxSkew == 0 {
+			maxSkew = 1
+		}
+
+		whenUnsatisfiable := coreV1.ScheduleAnyway
+
+		if spread.Required {
+			whenUnsatisfiable = coreV1.DoNotSchedule
+		}
+
+		res = append(res, coreV1.TopologySpreadConstraint{
+			MaxSkew:           maxSkew,
+			TopologyKey:       topologySpreadKeys[spread.Topology],
+			WhenUnsatisfiable: whenUnsatisfiable,
+			LabelSelector: &metaV1.LabelSelector{
+				MatchLabels: r.getPodSelectorLabels(),
+			},
+		})
+	}
+
+	return res
+}
+
+// Labels every pod of the component has, no matter what user labels are set.
+func (r *ComponentReconcilerTask) getPodSelectorLabels() map[string]string {
+	return map[string]string{
+		KalmLabelNamespaceKey: r.component.Namespace,
+		KalmLabelComponentKey: r.component.Name,
+	}
+}
+
+// ReconcilePodDisruptionBudget keeps the pdb of the component in sync with spec.disruptionBudget.
+// Evictions, e.g. by node drains, are refused by the api server if they break the budget.
+func (r *ComponentReconcilerTask) ReconcilePodDisruptionBudget() error {
+	budget := r.component.Spec.DisruptionBudget
+
+	if budget == nil || !IsNamespaceKalmEnabled(r.namespace) {
+		if r.pdb != nil {
+			if err := r.Delete(r.ctx, r.pdb); client.IgnoreNotFound(err) != nil {
+				r.WarningEvent(err, "unable to delete PodDisruptionBudget for Component")
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	isNew := r.pdb == nil
+
+	if isNew {
+		r.pdb = &policyV1beta1.PodDisruptionBudget{
+			ObjectMeta: metaV1.ObjectMeta{
+				Name:      r.component.Name,
+				Namespace: r.component.Namespace,
+				Labels:    r.GetLabels(),
+			},
+		}
+	}
+
+	r.pdb.Spec.Selector = &metaV1.LabelSelector{MatchLabels: r.getPodSelectorLabels()}
+	r.pdb.Spec.MinAvailable = budget.MinAvailable
+	r.pdb.Spec.MaxUnavailable = budget.MaxUnavailable
+
+	if isNew {
+		if err := ctrl.SetControllerReference(r.component, r.pdb, r.Scheme); err != nil {
+			r.WarningEvent(err, "unable to set owner for PodDisruptionBudget")
+			return err
+		}
+
+		if err := r.Create(r.ctx, r.pdb); err != nil {
+			r.WarningEvent(err, "unable to create PodDisruptionBudget for Component")
+			return err
+		}
+
+		return nil
+	}
+
+	if err := r.Update(r.ctx, r.pdb); err != nil {
+		r.WarningEvent(err, "unable to update PodDisruptionBudget for Component")
+		return err
+	}
+
+	return nil
+}
+
 func (r *ComponentReconcilerTask) decideAffinity() (*coreV1.Affinity, bool) {
 	component := &r.component.Spec
 
@@ -1445,12 +2535,40 @@ func (r *ComponentReconcilerTask) decideAffinity() (*coreV1.Affinity, bool) {
 		})
 	}
 
+	var preferredTerms []coreV1.PreferredSchedulingTerm
+
+	if component.NodeAffinity != nil {
+		// terms are ORed, so the required expressions are added to each of them
+		if required := component.NodeAffinity.Required; len(required) > 0 {
+			if len(nodeSelectorTerms) == 0 {
+				nodeSelectorTerms = []coreV1.NodeSelectorTerm{{}}
+			}
+
+			for i := range nodeSelectorTerms {
+				nodeSelectorTerms[i].MatchExpressions = append(nodeSelectorTerms[i].MatchExpressions, required...)
+			}
+		}
+
+		for _, term := range component.NodeAffinity.Preferred {
+			preferredTerms = append(preferredTerms, coreV1.PreferredSchedulingTerm{
+				Weight: term.Weight,
+				Preference: coreV1.NodeSelectorTerm{
+					MatchExpressions: term.MatchExpressions,
+				},
+			})
+		}
+	}
+
 	var nodeAffinity *coreV1.NodeAffinity
-	if len(nodeSelectorTerms) > 0 {
+	if len(nodeSelectorTerms) > 0 || len(preferredTerms) > 0 {
 		nodeAffinity = &coreV1.NodeAffinity{
-			RequiredDuringSchedulingIgnoredDuringExecution: &coreV1.NodeSelector{
+			PreferredDuringSchedulingIgnoredDuringExecution: preferredTerms,
+		}
+
+		if len(nodeSelectorTerms) > 0 {
+			nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &coreV1.NodeSelector{
 				NodeSelectorTerms: nodeSelectorTerms,
-			},
+			}
 		}
 	}
 
@@ -1534,6 +2652,14 @@ func (r *ComponentReconcilerTask) DeleteResources() (err error) {
 		}
 	}
 
+	if r.job != nil {
+		// pods of jobs are orphaned by default
+		if err := r.Client.Delete(r.ctx, r.job, client.PropagationPolicy(metaV1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			r.WarningEvent(err, "Delete job error")
+			return err
+		}
+	}
+
 	if r.statefulSet != nil {
 		if err := r.DeleteItem(r.statefulSet); client.IgnoreNotFound(err) != nil {
 			return err
@@ -1583,11 +2709,17 @@ func (r *ComponentReconcilerTask) LoadResources() (err error) {
 		return err
 	}
 
+	if err := r.LoadPodDisruptionBudget(); err != nil {
+		return err
+	}
+
 	switch r.component.Spec.WorkloadType {
 	case corev1alpha1.WorkloadTypeServer, "":
 		return r.LoadDeployment()
 	case corev1alpha1.WorkloadTypeCronjob:
 		return r.LoadCronJob()
+	case corev1alpha1.WorkloadTypeJob:
+		return r.LoadJob()
 	case corev1alpha1.WorkloadTypeDaemonSet:
 		return r.LoadDaemonSet()
 	case corev1alpha1.WorkloadTypeStatefulSet:
@@ -1676,6 +2808,16 @@ func (r *ComponentReconcilerTask) LoadDeployment() error {
 	return nil
 }
 
+func (r *ComponentReconcilerTask) LoadPodDisruptionBudget() error {
+	var pdb policyV1beta1.PodDisruptionBudget
+	err := r.LoadItem(&pdb)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	r.pdb = &pdb
+	return nil
+}
+
 func (r *ComponentReconcilerTask) LoadCronJob() error {
 	var cornJob batchV1Beta1.CronJob
 	err := r.LoadItem(&cornJob)
@@ -1686,6 +2828,24 @@ func (r *ComponentReconcilerTask) LoadCronJob() error {
 	return nil
 }
 
+func (r *ComponentReconcilerTask) LoadJob() error {
+	var job batchV1.Job
+
+	if err := r.Reader.Get(
+		r.ctx,
+		types.NamespacedName{
+			Namespace: r.component.Namespace,
+			Name:      GetJobNameOfRun(r.component.Name, GetJobRun(r.component)),
+		},
+		&job,
+	); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	r.job = &job
+	return nil
+}
+
 func (r *ComponentReconcilerTask) LoadDaemonSet() error {
 	var daemonSet appsV1.DaemonSet
 	err := r.LoadItem(&daemonSet)
@@ -1802,6 +2962,10 @@ func (r *ComponentReconcilerTask) prepareVolsForSTS(podTemplate *coreV1.PodTempl
 		return nil, err
 	}
 
+	if err := r.parseComponentConfigs(podTemplate, &volumes, &volumeMounts); err != nil {
+		return nil, err
+	}
+
 	for _, disk := range component.Spec.Volumes {
 		// used in volumeMount, correspond to volume's name or volClaimTemplate's name
 		volName := getVolName(component.Name, disk.Path)
@@ -1888,6 +3052,10 @@ func (r *ComponentReconcilerTask) prepareVolsForSimpleWorkload(template *coreV1.
 		return err
 	}
 
+	if err := r.parseComponentConfigs(template, &volumes, &volumeMounts); err != nil {
+		return err
+	}
+
 	for _, disk := range component.Spec.Volumes {
 
 		// used in volumeMount, correspond to volume's name or volClaimTemplate's name
@@ -4,14 +4,19 @@ import (
 	"context"
 	"fmt"
 	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/kalmhq/kalm/controller/lib/files"
 	"github.com/stretchr/testify/suite"
 	appsV1 "k8s.io/api/apps/v1"
+	batchV1 "k8s.io/api/batch/v1"
+	batchV1Beta1 "k8s.io/api/batch/v1beta1"
 	coreV1 "k8s.io/api/core/v1"
+	policyV1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"testing"
 )
@@ -341,6 +346,322 @@ func (suite *ComponentControllerSuite) TestPorts() {
 	}, "service should be deleted")
 }
 
+func (suite *ComponentControllerSuite) TestStartAfterComponents() {
+	dependency := generateEmptyComponent(suite.ns.Name)
+	suite.createComponent(dependency)
+
+	dependent := generateEmptyComponent(suite.ns.Name)
+	dependent.Spec.StartAfterComponents = []string{dependency.Name}
+	suite.createComponent(dependent)
+
+	dependencyKey := types.NamespacedName{Namespace: dependency.Namespace, Name: dependency.Name}
+	dependentKey := types.NamespacedName{Namespace: dependent.Namespace, Name: dependent.Name}
+
+	// no pods are running in the test env, so the dependency never becomes ready by itself
+	suite.Eventually(func() bool {
+		suite.reloadComponent(dependent)
+		condition := dependent.Status.GetCondition(v1alpha1.ComponentConditionDependenciesReady)
+
+		return condition != nil &&
+			condition.Status == coreV1.ConditionFalse &&
+			condition.Reason == "WaitingForDependency"
+	}, "dependent should wait for the dependency")
+
+	var deployment appsV1.Deployment
+	suite.True(errors.IsNotFound(suite.K8sClient.Get(context.Background(), dependentKey, &deployment)))
+
+	suite.Nil(suite.K8sClient.Get(context.Background(), dependencyKey, &deployment))
+	deployment.Status.ObservedGeneration = deployment.Generation
+	deployment.Status.Replicas = 1
+	deployment.Status.UpdatedReplicas = 1
+	deployment.Status.ReadyReplicas = 1
+	suite.Nil(suite.K8sClient.Status().Update(context.Background(), &deployment))
+
+	suite.Eventually(func() bool {
+		var deployment appsV1.Deployment
+		return suite.K8sClient.Get(context.Background(), dependentKey, &deployment) == nil
+	}, "dependent should start once the dependency is ready")
+}
+
+func (suite *ComponentControllerSuite) TestStartAfterComponentsTimeout() {
+	timeout := int32(1)
+
+	dependent := generateEmptyComponent(suite.ns.Name)
+	dependent.Spec.StartAfterComponents = []string{"not-exist"}
+	dependent.Spec.StartAfterComponentsTimeoutSeconds = &timeout
+	suite.createComponent(dependent)
+
+	suite.Eventually(func() bool {
+		var deployment appsV1.Deployment
+		return suite.K8sClient.Get(context.Background(), types.NamespacedName{Namespace: dependent.Namespace, Name: dependent.Name}, &deployment) == nil
+	}, "dependent should start after the timeout")
+
+	suite.reloadComponent(dependent)
+	condition := dependent.Status.GetCondition(v1alpha1.ComponentConditionDependenciesReady)
+	suite.NotNil(condition)
+	suite.Equal("WaitTimeout", condition.Reason)
+}
+
+func (suite *ComponentControllerSuite) TestLifecycleCommands() {
+	component := generateEmptyComponent(suite.ns.Name)
+	component.Spec.BeforeStart = []string{"./migrate", "./seed"}
+	component.Spec.AfterStart = []string{"./warmup"}
+	component.Spec.BeforeDestroy = []string{"sleep 5"}
+	suite.createComponent(component)
+
+	suite.Eventually(func() bool {
+		var deployment appsV1.Deployment
+
+		if err := suite.K8sClient.Get(context.Background(), types.NamespacedName{Namespace: component.Namespace, Name: component.Name}, &deployment); err != nil {
+			return false
+		}
+
+		podSpec := deployment.Spec.Template.Spec
+
+		if len(podSpec.InitContainers) != 1 || podSpec.Containers[0].Lifecycle == nil {
+			return false
+		}
+
+		initContainer := podSpec.InitContainers[0]
+		lifecycle := podSpec.Containers[0].Lifecycle
+
+		return initContainer.Name == BeforeStartContainerName &&
+			initContainer.Image == component.Spec.Image &&
+			len(initContainer.Env) == 1 &&
+			initContainer.Command[2] == "./migrate && ./seed" &&
+			lifecycle.PostStart.Exec.Command[2] == "./warmup" &&
+			lifecycle.PreStop.Exec.Command[2] == "sleep 5"
+	}, "lifecycle commands are not applied")
+}
+
+func (suite *ComponentControllerSuite) TestConfigFilesRestart() {
+	configMap := coreV1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: suite.ns.Name,
+			Name:      files.KALM_CONFIG_MAP_NAME,
+		},
+		Data: map[string]string{
+			files.KALM_SLASH_REPLACER: files.KALM_PERSISTENT_DIR_PLACEHOLDER,
+		},
+	}
+	suite.Nil(files.AddFile(&configMap, &files.File{Path: "/nginx/nginx.conf", Content: "worker_processes 1;"}))
+	suite.Nil(files.AddFile(&configMap, &files.File{Path: "/other.conf", Content: "other"}))
+	suite.createObject(&configMap)
+
+	component := generateEmptyComponent(suite.ns.Name)
+	component.Spec.Configs = []v1alpha1.Config{{Paths: []string{"/nginx"}, MountPath: "/etc"}}
+	suite.createComponent(component)
+
+	var checksum string
+
+	suite.Eventually(func() bool {
+		var deployment appsV1.Deployment
+
+		if err := suite.K8sClient.Get(context.Background(), types.NamespacedName{Namespace: component.Namespace, Name: component.Name}, &deployment); err != nil {
+			return false
+		}
+
+		checksum = deployment.Spec.Template.Annotations[AnnoConfigFilesChecksum]
+		mounts := deployment.Spec.Template.Spec.Containers[0].VolumeMounts
+
+		return checksum != "" && len(mounts) == 1 && mounts[0].MountPath == "/etc/nginx"
+	}, "config files are not mounted")
+
+	// edited contents are moved out of the index into shards
+	store, err := files.LoadStore(context.Background(), suite.K8sClient, suite.ns.Name)
+	suite.Nil(err)
+	suite.Nil(store.UpdateFile(&files.File{Path: "/nginx/nginx.conf", Content: "worker_processes 2;"}))
+	suite.Nil(store.Save(context.Background(), suite.K8sClient))
+
+	suite.Eventually(func() bool {
+		var deployment appsV1.Deployment
+
+		if err := suite.K8sClient.Get(context.Background(), types.NamespacedName{Namespace: component.Namespace, Name: component.Name}, &deployment); err != nil {
+			return false
+		}
+
+		volumes := deployment.Spec.Template.Spec.Volumes
+
+		return deployment.Spec.Template.Annotations[AnnoConfigFilesChecksum] != checksum &&
+			len(volumes) == 1 &&
+			volumes[0].Projected != nil &&
+			volumes[0].Projected.Sources[0].ConfigMap.Name == "kalm-files-1"
+	}, "component is not restarted after mounted file is edited")
+}
+
+func (suite *ComponentControllerSuite) TestDisruptionBudgetAndTopologySpread() {
+	maxUnavailable := intstr.FromInt(1)
+
+	component := generateEmptyComponent(suite.ns.Name)
+	component.Spec.DisruptionBudget = &v1alpha1.DisruptionBudget{MaxUnavailable: &maxUnavailable}
+	component.Spec.TopologySpread = []v1alpha1.TopologySpread{{Topology: v1alpha1.TopologySpreadTopologyZone}}
+	suite.createComponent(component)
+
+	key := types.NamespacedName{Namespace: component.Namespace, Name: component.Name}
+
+	suite.Eventually(func() bool {
+		var pdb policyV1beta1.PodDisruptionBudget
+
+		if err := suite.K8sClient.Get(context.Background(), key, &pdb); err != nil {
+			return false
+		}
+
+		return pdb.Spec.MaxUnavailable.IntValue() == 1 &&
+			pdb.Spec.Selector.MatchLabels[KalmLabelComponentKey] == component.Name
+	}, "pdb is not created")
+
+	suite.Eventually(func() bool {
+		var deployment appsV1.Deployment
+
+		if err := suite.K8sClient.Get(context.Background(), key, &deployment); err != nil {
+			return false
+		}
+
+		constraints := deployment.Spec.Template.Spec.TopologySpreadConstraints
+
+		return len(constraints) == 1 &&
+			constraints[0].TopologyKey == "topology.kubernetes.io/zone" &&
+			constraints[0].MaxSkew == 1 &&
+			constraints[0].WhenUnsatisfiable == coreV1.ScheduleAnyway
+	}, "topology spread constraints are not applied")
+
+	suite.reloadComponent(component)
+	component.Spec.DisruptionBudget = nil
+	suite.updateComponent(component)
+
+	suite.Eventually(func() bool {
+		var pdb policyV1beta1.PodDisruptionBudget
+		return errors.IsNotFound(suite.K8sClient.Get(context.Background(), key, &pdb))
+	}, "pdb is not deleted")
+}
+
+func (suite *ComponentControllerSuite) TestTolerationsAndNodeAffinity() {
+	component := generateEmptyComponent(suite.ns.Name)
+	component.Spec.NodeSelectorLabels = map[string]string{"disktype": "ssd"}
+	component.Spec.Tolerations = []coreV1.Toleration{
+		{Key: "dedicated", Operator: coreV1.TolerationOpEqual, Value: "batch", Effect: coreV1.TaintEffectNoSchedule},
+	}
+	component.Spec.NodeAffinity = &v1alpha1.NodeAffinity{
+		Required: []coreV1.NodeSelectorRequirement{
+			{Key: "kubernetes.io/arch", Operator: coreV1.NodeSelectorOpIn, Values: []string{"amd64"}},
+		},
+		Preferred: []v1alpha1.PreferredNodeAffinityTerm{
+			{
+				Weight: 10,
+				MatchExpressions: []coreV1.NodeSelectorRequirement{
+					{Key: "node.kubernetes.io/lifecycle", Operator: coreV1.NodeSelectorOpNotIn, Values: []string{"spot"}},
+				},
+			},
+		},
+	}
+	suite.createComponent(component)
+
+	key := types.NamespacedName{Namespace: component.Namespace, Name: component.Name}
+
+	suite.Eventually(func() bool {
+		var deployment appsV1.Deployment
+
+		if err := suite.K8sClient.Get(context.Background(), key, &deployment); err != nil {
+			return false
+		}
+
+		podSpec := deployment.Spec.Template.Spec
+
+		if len(podSpec.Tolerations) != 1 || podSpec.Tolerations[0].Key != "dedicated" {
+			return false
+		}
+
+		if podSpec.Affinity == nil || podSpec.Affinity.NodeAffinity == nil {
+			return false
+		}
+
+		nodeAffinity := podSpec.Affinity.NodeAffinity
+		required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+
+		return required != nil &&
+			len(required.NodeSelectorTerms) == 1 &&
+			len(required.NodeSelectorTerms[0].MatchExpressions) == 2 &&
+			len(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution) == 1 &&
+			nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].Weight == 10
+	}, "tolerations and node affinity are not applied")
+}
+
+func (suite *ComponentControllerSuite) TestJobRuns() {
+	completions := int32(2)
+
+	component := generateEmptyComponent(suite.ns.Name, v1alpha1.WorkloadTypeJob)
+	component.Spec.Ports = nil
+	component.Spec.Job = &v1alpha1.JobConfig{Completions: &completions}
+	suite.createComponent(component)
+
+	suite.Eventually(func() bool {
+		var job batchV1.Job
+
+		if err := suite.K8sClient.Get(context.Background(), types.NamespacedName{
+			Namespace: component.Namespace,
+			Name:      GetJobNameOfRun(component.Name, 1),
+		}, &job); err != nil {
+			return false
+		}
+
+		return *job.Spec.Completions == 2 &&
+			job.Labels[KalmLabelJobRunKey] == "1" &&
+			job.Spec.Template.Spec.RestartPolicy == coreV1.RestartPolicyOnFailure
+	}, "job of the first run is not created")
+
+	suite.reloadComponent(component)
+
+	if component.Annotations == nil {
+		component.Annotations = make(map[string]string)
+	}
+
+	component.Annotations[v1alpha1.ComponentJobRunAnnotation] = "2"
+	suite.updateComponent(component)
+
+	suite.Eventually(func() bool {
+		var jobList batchV1.JobList
+
+		if err := suite.K8sClient.List(context.Background(), &jobList, client.InNamespace(component.Namespace), client.MatchingLabels{
+			KalmLabelComponentKey: component.Name,
+		}); err != nil {
+			return false
+		}
+
+		return len(jobList.Items) == 2
+	}, "job of the second run is not created")
+}
+
+func (suite *ComponentControllerSuite) TestCronJobOptions() {
+	historyLimit := int32(1)
+
+	component := generateEmptyComponent(suite.ns.Name, v1alpha1.WorkloadTypeCronjob)
+	component.Spec.Ports = nil
+	component.Spec.Schedule = "*/5 * * * *"
+	component.Spec.CronJob = &v1alpha1.CronJobConfig{
+		Suspend:                    true,
+		ConcurrencyPolicy:          batchV1Beta1.ForbidConcurrent,
+		SuccessfulJobsHistoryLimit: &historyLimit,
+	}
+	suite.createComponent(component)
+
+	suite.Eventually(func() bool {
+		var cronJob batchV1Beta1.CronJob
+
+		if err := suite.K8sClient.Get(context.Background(), types.NamespacedName{
+			Namespace: component.Namespace,
+			Name:      component.Name,
+		}, &cronJob); err != nil {
+			return false
+		}
+
+		return *cronJob.Spec.Suspend &&
+			cronJob.Spec.ConcurrencyPolicy == batchV1Beta1.ForbidConcurrent &&
+			*cronJob.Spec.SuccessfulJobsHistoryLimit == 1 &&
+			*cronJob.Spec.FailedJobsHistoryLimit == 5 &&
+			cronJob.Spec.JobTemplate.Labels[KalmLabelComponentKey] == component.Name
+	}, "cronjob options are not applied")
+}
+
 func (suite *ComponentControllerSuite) getComponentPVCs(component *v1alpha1.Component) []coreV1.PersistentVolumeClaim {
 	var pvcList coreV1.PersistentVolumeClaimList
 	_ = suite.K8sClient.List(context.Background(), &pvcList, client.MatchingLabels{"kalm-component": component.Name})
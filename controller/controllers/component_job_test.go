@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	batchV1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestFinishedJobRunIsNotRecreated(t *testing.T) {
+	component := &corev1alpha1.Component{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "migrate"},
+		Spec:       corev1alpha1.ComponentSpec{WorkloadType: corev1alpha1.WorkloadTypeJob, Image: "busybox"},
+	}
+
+	base := newFakeBaseReconciler(component)
+	c := base.Client
+
+	task := &ComponentReconcilerTask{
+		ComponentReconciler: &ComponentReconciler{
+			BaseReconciler: base,
+		},
+		ctx:       context.Background(),
+		component: component,
+		namespace: coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "app"}},
+	}
+
+	reconcileJob := func() {
+		assert.Nil(t, task.LoadJob())
+		assert.Nil(t, task.ReconcileJob(&coreV1.PodTemplateSpec{}))
+	}
+
+	countJobs := func() int {
+		var jobList batchV1.JobList
+		assert.Nil(t, c.List(context.Background(), &jobList, client.InNamespace("app")))
+		return len(jobList.Items)
+	}
+
+	reconcileJob()
+	assert.Equal(t, 1, countJobs())
+	assert.Equal(t, 1, task.component.Status.LastJobRun)
+
+	// the finished job is deleted after TTLSecondsAfterFinished
+	assert.Nil(t, task.LoadJob())
+	assert.Nil(t, c.Delete(context.Background(), task.job))
+	task.job = nil
+
+	reconcileJob()
+	assert.Equal(t, 0, countJobs())
+
+	// a rerun starts the next run
+	task.component.Annotations = map[string]string{corev1alpha1.ComponentJobRunAnnotation: "2"}
+
+	reconcileJob()
+	assert.Equal(t, 1, countJobs())
+	assert.Equal(t, 2, task.component.Status.LastJobRun)
+}
+
+func TestGetJobNameOfRun(t *testing.T) {
+	assert.Equal(t, "migrate-3", GetJobNameOfRun("migrate", 3))
+
+	name := strings.Repeat("a", 60)
+
+	// names are kept within the label length limit, components sharing the prefix have different jobs
+	for _, run := range []int{1, 100, 100000} {
+		jobName := GetJobNameOfRun(name, run)
+		assert.Empty(t, validation.IsDNS1123Label(jobName))
+		assert.True(t, strings.HasSuffix(jobName, "-"+strconv.Itoa(run)))
+	}
+
+	assert.NotEqual(t, GetJobNameOfRun(name, 1), GetJobNameOfRun(name, 2))
+	assert.NotEqual(t, GetJobNameOfRun(name+"b", 100), GetJobNameOfRun(name+"c", 100))
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetFailedExitCode(t *testing.T) {
+	failed := coreV1.ContainerState{Terminated: &coreV1.ContainerStateTerminated{ExitCode: 1}}
+	succeeded := coreV1.ContainerState{Terminated: &coreV1.ContainerStateTerminated{ExitCode: 0}}
+	running := coreV1.ContainerState{Running: &coreV1.ContainerStateRunning{}}
+
+	assert.Equal(t, int32(1), getFailedExitCode(coreV1.ContainerStatus{State: failed}))
+
+	// retrying after a failure
+	assert.Equal(t, int32(1), getFailedExitCode(coreV1.ContainerStatus{State: running, LastTerminationState: failed}))
+
+	// recovered
+	assert.Equal(t, int32(0), getFailedExitCode(coreV1.ContainerStatus{State: succeeded, LastTerminationState: failed, Ready: true}))
+	assert.Equal(t, int32(0), getFailedExitCode(coreV1.ContainerStatus{State: running, LastTerminationState: failed, Ready: true}))
+
+	assert.Equal(t, int32(0), getFailedExitCode(coreV1.ContainerStatus{State: running}))
+}
+
+func TestGetLifecycleHookFailures(t *testing.T) {
+	pods := []coreV1.Pod{
+		{ObjectMeta: metaV1.ObjectMeta{Name: "web-1", UID: "uid-1"}},
+		{ObjectMeta: metaV1.ObjectMeta{Name: "web-2", UID: "uid-2"}},
+	}
+
+	events := []coreV1.Event{
+		{InvolvedObject: coreV1.ObjectReference{UID: "uid-2"}, Reason: "FailedPreStopHook", Message: "exit code 2"},
+		{InvolvedObject: coreV1.ObjectReference{UID: "uid-1"}, Reason: "FailedPostStartHook", Message: "exit code 1"},
+		{InvolvedObject: coreV1.ObjectReference{UID: "uid-1"}, Reason: "BackOff", Message: "back-off restarting"},
+
+		// a previous pod with the same name
+		{InvolvedObject: coreV1.ObjectReference{UID: "uid-0"}, Reason: "FailedPostStartHook", Message: "exit code 3"},
+	}
+
+	assert.Equal(t, []lifecycleHookFailure{
+		{"AfterStartFailed", "after start commands failed in pod web-1: exit code 1"},
+		{"BeforeDestroyFailed", "before destroy commands failed in pod web-2: exit code 2"},
+	}, getLifecycleHookFailures(pods, events))
+}
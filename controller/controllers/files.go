@@ -2,11 +2,10 @@ package controllers
 
 import (
 	"fmt"
+	"strings"
+
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
 	"github.com/kalmhq/kalm/controller/lib/files"
-	coreV1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
-	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 )
 
 func (r *ComponentReconcilerTask) reconcileDirectConfigs() error {
@@ -21,58 +20,43 @@ func (r *ComponentReconcilerTask) reconcileDirectConfigs() error {
 		"comp", r.component.Name,
 	)
 
-	namespace := r.component.Namespace
-
-	var cm coreV1.ConfigMap
-	err := r.Reader.Get(r.ctx, types.NamespacedName{
-		Namespace: namespace,
-		Name:      files.KALM_CONFIG_MAP_NAME,
-	}, &cm)
+	store, err := files.LoadStore(r.ctx, r.Reader, r.component.Namespace)
 
-	var myFiles []*files.File
-	for i, directConfig := range componentSpec.DirectConfigs {
-		myFiles = append(myFiles, &files.File{
-			Path:    fmt.Sprintf("/kalm-direct-configs/%s/%d", r.component.Name, i),
-			IsDir:   false,
-			Content: directConfig.Content,
-		})
+	if err != nil {
+		r.Log.Error(err, "load kalm files error")
+		return err
 	}
 
-	if errors.IsNotFound(err) {
-		cm = coreV1.ConfigMap{
-			ObjectMeta: metaV1.ObjectMeta{
-				Name:      files.KALM_CONFIG_MAP_NAME,
-				Namespace: namespace,
-			},
-			Data: map[string]string{
-				files.KALM_SLASH_REPLACER: files.KALM_PERSISTENT_DIR_PLACEHOLDER,
-			},
-		}
+	store.Author = "component/" + r.component.Name
+	changed := false
 
-		for _, f := range myFiles {
-			files.AddFile(&cm, f)
+	for i, directConfig := range componentSpec.DirectConfigs {
+		path := getPathOfDirectConfig(r.component.Name, i)
+
+		// the files are watched, only update them when something changed to avoid reconcile loops
+		if content, _, err := store.Content(path); err == nil && string(content) == directConfig.Content {
+			continue
 		}
 
-		err = r.Create(r.ctx, &cm)
-		if err != nil {
-			r.Log.Error(err, "create kalm config map error")
+		if err := store.AddFile(&files.File{
+			Path:    path,
+			IsDir:   false,
+			Content: directConfig.Content,
+		}, true); err != nil {
+			r.Log.Error(err, "add direct config error")
 			return err
 		}
-	} else if err != nil {
-		r.Log.Error(err, "get kalm config map error")
-		return err
-	} else {
 
-		// ensure
-		for _, f := range myFiles {
-			files.AddFile(&cm, f, true)
-		}
+		changed = true
+	}
 
-		err = r.Update(r.ctx, &cm)
-		if err != nil {
-			r.Log.Error(err, "update kalm config map error")
-			return err
-		}
+	if !changed {
+		return nil
+	}
+
+	if err := store.Save(r.ctx, r.Client); err != nil {
+		r.Log.Error(err, "save kalm files error")
+		return err
 	}
 
 	return nil
@@ -81,3 +65,28 @@ func (r *ComponentReconcilerTask) reconcileDirectConfigs() error {
 func getPathOfDirectConfig(componentName string, idx int) string {
 	return fmt.Sprintf("/kalm-direct-configs/%s/%d", componentName, idx)
 }
+
+// componentMountsAnyOf returns whether the component mounts any of the paths, a file in them or a dir of them
+func componentMountsAnyOf(component *corev1alpha1.Component, paths []string) bool {
+	for _, path := range paths {
+		for _, config := range component.Spec.Configs {
+			for _, configPath := range config.Paths {
+				if isFilePathUnder(path, configPath) || isFilePathUnder(configPath, path) {
+					return true
+				}
+			}
+		}
+
+		for i := range component.Spec.DirectConfigs {
+			if isFilePathUnder(getPathOfDirectConfig(component.Name, i), path) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isFilePathUnder(path, dir string) bool {
+	return path == dir || dir == "/" || strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
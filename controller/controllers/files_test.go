@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestComponentMountsAnyOf(t *testing.T) {
+	component := &corev1alpha1.Component{
+		ObjectMeta: metaV1.ObjectMeta{Name: "web"},
+		Spec: corev1alpha1.ComponentSpec{
+			Configs:       []corev1alpha1.Config{{Paths: []string{"/nginx", "/app/config.yaml"}, MountPath: "/etc/config"}},
+			DirectConfigs: []corev1alpha1.DirectConfig{{Content: "a", MountFilePath: "/etc/a"}},
+		},
+	}
+
+	// files in mounted dirs, mounted files and dirs of them
+	assert.True(t, componentMountsAnyOf(component, []string{"/nginx/nginx.conf"}))
+	assert.True(t, componentMountsAnyOf(component, []string{"/app/config.yaml"}))
+	assert.True(t, componentMountsAnyOf(component, []string{"/app"}))
+	assert.True(t, componentMountsAnyOf(component, []string{"/kalm-direct-configs/web/0"}))
+
+	assert.False(t, componentMountsAnyOf(component, []string{"/nginx-other/nginx.conf", "/app/other.yaml"}))
+	assert.False(t, componentMountsAnyOf(component, []string{"/kalm-direct-configs/api/0"}))
+	assert.False(t, componentMountsAnyOf(&corev1alpha1.Component{}, []string{"/nginx"}))
+}
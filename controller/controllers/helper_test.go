@@ -5,20 +5,24 @@ import (
 	"fmt"
 	"github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
 	v1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/kalmhq/kalm/controller/lib/snapshot"
 	"github.com/onsi/ginkgo"
 	"github.com/stretchr/testify/suite"
 	istioScheme "istio.io/client-go/pkg/clientset/versioned/scheme"
 	v1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"math/rand"
 	"path/filepath"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -204,6 +208,8 @@ func (suite *BasicSuite) SetupSuite() {
 	suite.Nil(NewGatewayReconciler(mgr).SetupWithManager(mgr))
 	suite.Nil(NewSingleSignOnConfigReconciler(mgr).SetupWithManager(mgr))
 	suite.Nil(NewProtectedEndpointReconciler(mgr).SetupWithManager(mgr))
+	suite.Nil(NewAccessTokenReconciler(mgr).SetupWithManager(mgr))
+	suite.Nil(NewRoleBindingReconciler(mgr).SetupWithManager(mgr))
 
 	mgrStopChannel := make(chan struct{})
 	suite.MgrStopChannel = mgrStopChannel
@@ -306,3 +312,22 @@ func (suite *BasicSuite) ensureNsExists(name string) v1.Namespace {
 	suite.K8sClient.Create(context.Background(), &ns)
 	return ns
 }
+
+// newFakeBaseReconciler returns a reconciler base backed by a fake client for unit tests that don't need the envtest suite
+func newFakeBaseReconciler(objs ...runtime.Object) *BaseReconciler {
+	s := runtime.NewScheme()
+	_ = scheme.AddToScheme(s)
+	_ = v1alpha1.AddToScheme(s)
+	s.AddKnownTypeWithName(snapshot.GroupVersionKind, &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(snapshot.ListGroupVersionKind, &unstructured.UnstructuredList{})
+
+	c := fake.NewFakeClientWithScheme(s, objs...)
+
+	return &BaseReconciler{
+		Client:   c,
+		Reader:   c,
+		Log:      ctrl.Log.WithName("test"),
+		Scheme:   s,
+		Recorder: record.NewFakeRecorder(10),
+	}
+}
@@ -46,6 +46,8 @@ const (
 
 const KALM_SSO_GRANTED_GROUPS_HEADER = "kalm-sso-granted-groups"
 const KALM_SSO_USERINFO_HEADER = "kalm-sso-userinfo"
+const KALM_SSO_AUTHORIZATION_RULES_HEADER = "kalm-sso-authorization-rules"
+const KALM_SSO_FORWARDED_CLAIMS_HEADER = "kalm-sso-forwarded-claims"
 const KALM_SSO_SET_COOKIE_PAYLOAD_HEADER = "kalm-set-cookie"
 const KALM_ROUTE_HEADER = "kalm-route"
 const KALM_ALLOW_TO_PASS_IF_HAS_BEARER_TOKEN_HEADER = "allow-to-pass-if-has-bearer-token"
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	coreV1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Labels follow kalm naming, an application is a namespace.
+var (
+	componentDesiredReplicasDesc = prometheus.NewDesc(
+		"kalm_component_desired_replicas",
+		"Desired replicas of the component, only for server and statefulset components",
+		[]string{"application", "component", "workload_type"}, nil,
+	)
+
+	componentPodsDesc = prometheus.NewDesc(
+		"kalm_component_pods",
+		"Number of pending or running pods of the component",
+		[]string{"application", "component"}, nil,
+	)
+
+	componentReadyPodsDesc = prometheus.NewDesc(
+		"kalm_component_ready_pods",
+		"Number of ready pods of the component",
+		[]string{"application", "component"}, nil,
+	)
+
+	httpsCertExpireTimestampDesc = prometheus.NewDesc(
+		"kalm_https_cert_expire_timestamp_seconds",
+		"Unix time when the cert expires, 0 if the cert is not issued",
+		[]string{"cert"}, nil,
+	)
+
+	httpsCertReadyDesc = prometheus.NewDesc(
+		"kalm_https_cert_ready",
+		"Whether the cert is ready",
+		[]string{"cert"}, nil,
+	)
+)
+
+// KalmMetricsCollector reads components, pods and certs from the cache on each scrape,
+// so series of deleted objects disappear without being tracked.
+// Reconcile durations and errors of each controller are served by controller-runtime on the same registry.
+type KalmMetricsCollector struct {
+	client.Reader
+	Log logr.Logger
+}
+
+func NewKalmMetricsCollector(mgr ctrl.Manager) *KalmMetricsCollector {
+	return &KalmMetricsCollector{
+		Reader: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("metrics"),
+	}
+}
+
+func (c *KalmMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- componentDesiredReplicasDesc
+	ch <- componentPodsDesc
+	ch <- componentReadyPodsDesc
+	ch <- httpsCertExpireTimestampDesc
+	ch <- httpsCertReadyDesc
+}
+
+func (c *KalmMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+
+	if err := c.collectComponents(ctx, ch); err != nil {
+		c.Log.Error(err, "collect component metrics failed")
+	}
+
+	if err := c.collectHttpsCerts(ctx, ch); err != nil {
+		c.Log.Error(err, "collect https cert metrics failed")
+	}
+}
+
+func (c *KalmMetricsCollector) collectComponents(ctx context.Context, ch chan<- prometheus.Metric) error {
+	var componentList corev1alpha1.ComponentList
+
+	if err := c.List(ctx, &componentList); err != nil {
+		return err
+	}
+
+	var podList coreV1.PodList
+
+	if err := c.List(ctx, &podList, client.HasLabels{KalmLabelComponentKey}); err != nil {
+		return err
+	}
+
+	type podCount struct {
+		pods  int
+		ready int
+	}
+
+	counts := make(map[string]*podCount)
+
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+
+		if pod.Status.Phase != coreV1.PodPending && pod.Status.Phase != coreV1.PodRunning {
+			continue
+		}
+
+		key := pod.Namespace + "/" + pod.Labels[KalmLabelComponentKey]
+		count, exist := counts[key]
+
+		if !exist {
+			count = &podCount{}
+			counts[key] = count
+		}
+
+		count.pods += 1
+
+		if isPodReady(pod) {
+			count.ready += 1
+		}
+	}
+
+	for _, component := range componentList.Items {
+		count := counts[component.Namespace+"/"+component.Name]
+
+		if count == nil {
+			count = &podCount{}
+		}
+
+		ch <- prometheus.MustNewConstMetric(componentPodsDesc, prometheus.GaugeValue, float64(count.pods), component.Namespace, component.Name)
+		ch <- prometheus.MustNewConstMetric(componentReadyPodsDesc, prometheus.GaugeValue, float64(count.ready), component.Namespace, component.Name)
+
+		switch component.Spec.WorkloadType {
+		case corev1alpha1.WorkloadTypeServer, corev1alpha1.WorkloadTypeStatefulSet, "":
+			ch <- prometheus.MustNewConstMetric(
+				componentDesiredReplicasDesc, prometheus.GaugeValue, float64(desiredReplicas(component.Spec.Replicas)),
+				component.Namespace, component.Name, string(component.Spec.WorkloadType),
+			)
+		}
+	}
+
+	return nil
+}
+
+func (c *KalmMetricsCollector) collectHttpsCerts(ctx context.Context, ch chan<- prometheus.Metric) error {
+	var certList corev1alpha1.HttpsCertList
+
+	if err := c.List(ctx, &certList); err != nil {
+		return err
+	}
+
+	for _, cert := range certList.Items {
+		ready := 0
+
+		for _, condition := range cert.Status.Conditions {
+			if condition.Type == corev1alpha1.HttpsCertConditionReady && condition.Status == coreV1.ConditionTrue {
+				ready = 1
+			}
+		}
+
+		ch <- prometheus.MustNewConstMetric(httpsCertExpireTimestampDesc, prometheus.GaugeValue, float64(cert.Status.ExpireTimestamp), cert.Name)
+		ch <- prometheus.MustNewConstMetric(httpsCertReadyDesc, prometheus.GaugeValue, float64(ready), cert.Name)
+	}
+
+	return nil
+}
+
+func isPodReady(pod *coreV1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == coreV1.PodReady {
+			return condition.Status == coreV1.ConditionTrue
+		}
+	}
+
+	return false
+}
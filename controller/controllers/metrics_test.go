@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func genComponentPod(name string, phase coreV1.PodPhase, ready coreV1.ConditionStatus) *coreV1.Pod {
+	return &coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace: "app",
+			Name:      name,
+			Labels:    map[string]string{KalmLabelComponentKey: "web"},
+		},
+		Status: coreV1.PodStatus{
+			Phase:      phase,
+			Conditions: []coreV1.PodCondition{{Type: coreV1.PodReady, Status: ready}},
+		},
+	}
+}
+
+func TestKalmMetricsCollector(t *testing.T) {
+	replicas := int32(3)
+
+	objs := []runtime.Object{
+		&corev1alpha1.Component{
+			ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "web"},
+			Spec:       corev1alpha1.ComponentSpec{WorkloadType: corev1alpha1.WorkloadTypeServer, Replicas: &replicas},
+		},
+		&corev1alpha1.Component{
+			ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "worker"},
+			Spec:       corev1alpha1.ComponentSpec{WorkloadType: corev1alpha1.WorkloadTypeDaemonSet},
+		},
+		genComponentPod("web-1", coreV1.PodRunning, coreV1.ConditionTrue),
+		genComponentPod("web-2", coreV1.PodRunning, coreV1.ConditionFalse),
+		genComponentPod("web-3", coreV1.PodSucceeded, coreV1.ConditionFalse),
+		&corev1alpha1.HttpsCert{
+			ObjectMeta: metaV1.ObjectMeta{Name: "cert"},
+			Status: corev1alpha1.HttpsCertStatus{
+				ExpireTimestamp: 1700000000,
+				Conditions: []corev1alpha1.HttpsCertCondition{
+					{Type: corev1alpha1.HttpsCertConditionReady, Status: coreV1.ConditionTrue},
+				},
+			},
+		},
+	}
+
+	collector := &KalmMetricsCollector{
+		Reader: newFakeBaseReconciler(objs...).Reader,
+		Log:    ctrl.Log.WithName("metrics"),
+	}
+
+	expected := `
+# HELP kalm_component_desired_replicas Desired replicas of the component, only for server and statefulset components
+# TYPE kalm_component_desired_replicas gauge
+kalm_component_desired_replicas{application="app",component="web",workload_type="server"} 3
+# HELP kalm_component_pods Number of pending or running pods of the component
+# TYPE kalm_component_pods gauge
+kalm_component_pods{application="app",component="web"} 2
+kalm_component_pods{application="app",component="worker"} 0
+# HELP kalm_component_ready_pods Number of ready pods of the component
+# TYPE kalm_component_ready_pods gauge
+kalm_component_ready_pods{application="app",component="web"} 1
+kalm_component_ready_pods{application="app",component="worker"} 0
+# HELP kalm_https_cert_expire_timestamp_seconds Unix time when the cert expires, 0 if the cert is not issued
+# TYPE kalm_https_cert_expire_timestamp_seconds gauge
+kalm_https_cert_expire_timestamp_seconds{cert="cert"} 1.7e+09
+# HELP kalm_https_cert_ready Whether the cert is ready
+# TYPE kalm_https_cert_ready gauge
+kalm_https_cert_ready{cert="cert"} 1
+`
+
+	assert.Nil(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+}
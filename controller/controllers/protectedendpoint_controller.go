@@ -17,6 +17,8 @@ package controllers
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -200,6 +202,31 @@ func (r *ProtectedEndpointReconcilerTask) BuildEnvoyFilterListenerPatches(req ct
 		grantedGroups = strings.Join(r.endpoint.Spec.Groups, "|")
 	}
 
+	var authorizationRules string
+	if len(r.endpoint.Spec.Rules) > 0 {
+		authorizationRules = encodeSSOHeaderPayload(r.endpoint.Spec.Rules)
+	}
+
+	var forwardedClaims string
+	if len(r.endpoint.Spec.ForwardedClaims) > 0 {
+		forwardedClaims = encodeSSOHeaderPayload(r.endpoint.Spec.ForwardedClaims)
+	}
+
+	upstreamHeaderPatterns := []interface{}{
+		map[string]interface{}{
+			"exact": KALM_SSO_SET_COOKIE_PAYLOAD_HEADER,
+		},
+		map[string]interface{}{
+			"exact": KALM_SSO_USERINFO_HEADER,
+		},
+	}
+
+	for _, forwardedClaim := range r.endpoint.Spec.ForwardedClaims {
+		upstreamHeaderPatterns = append(upstreamHeaderPatterns, map[string]interface{}{
+			"exact": strings.ToLower(forwardedClaim.Header),
+		})
+	}
+
 	patch := &v1alpha32.EnvoyFilter_Patch{
 		Operation: v1alpha32.EnvoyFilter_Patch_INSERT_BEFORE,
 		Value: golangMapToProtoStruct(map[string]interface{}{
@@ -234,18 +261,21 @@ func (r *ProtectedEndpointReconcilerTask) BuildEnvoyFilterListenerPatches(req ct
 								"key":   KALM_ALLOW_TO_PASS_IF_HAS_BEARER_TOKEN_HEADER,
 								"value": strconv.FormatBool(r.endpoint.Spec.AllowToPassIfHasBearerToken),
 							},
+
+							map[string]interface{}{
+								"key":   KALM_SSO_AUTHORIZATION_RULES_HEADER,
+								"value": authorizationRules,
+							},
+
+							map[string]interface{}{
+								"key":   KALM_SSO_FORWARDED_CLAIMS_HEADER,
+								"value": forwardedClaims,
+							},
 						},
 					},
 					"authorizationResponse": map[string]interface{}{
 						"allowedUpstreamHeaders": map[string]interface{}{
-							"patterns": []interface{}{
-								map[string]interface{}{
-									"exact": KALM_SSO_SET_COOKIE_PAYLOAD_HEADER,
-								},
-								map[string]interface{}{
-									"exact": KALM_SSO_USERINFO_HEADER,
-								},
-							},
+							"patterns": upstreamHeaderPatterns,
 						},
 					},
 				},
@@ -295,6 +325,12 @@ func (r *ProtectedEndpointReconcilerTask) BuildEnvoyFilterListenerPatches(req ct
 	return configPatches
 }
 
+// Rules and forwarded claims are passed to auth proxy as base64 encoded json in ext_authz request headers.
+func encodeSSOHeaderPayload(payload interface{}) string {
+	bts, _ := json.Marshal(payload)
+	return base64.RawURLEncoding.EncodeToString(bts)
+}
+
 func (r *ProtectedEndpointReconcilerTask) BuildEnvoyFilterHttpRoutePatches(req ctrl.Request) []*v1alpha32.EnvoyFilter_EnvoyConfigObjectPatch {
 	patch := &v1alpha32.EnvoyFilter_Patch{
 		Operation: v1alpha32.EnvoyFilter_Patch_MERGE,
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// RoleBindingReconciler removes kalm role bindings once they are expired.
+type RoleBindingReconciler struct {
+	*BaseReconciler
+	ctx context.Context
+}
+
+func NewRoleBindingReconciler(mgr ctrl.Manager) *RoleBindingReconciler {
+	return &RoleBindingReconciler{
+		BaseReconciler: NewBaseReconciler(mgr, "RoleBinding"),
+		ctx:            context.Background(),
+	}
+}
+
+func (r *RoleBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.RoleBinding{}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=core.kalm.dev,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core.kalm.dev,resources=rolebindings/status,verbs=get;update;patch
+
+func (r *RoleBindingReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	var roleBinding v1alpha1.RoleBinding
+
+	if err := r.Get(r.ctx, req.NamespacedName, &roleBinding); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	return r.deleteIfExpired(&roleBinding, roleBinding.Spec.ExpiredAt, "role binding")
+}
@@ -41,6 +41,9 @@ const KALM_DEX_NAMESPACE = "kalm-system"
 const KALM_DEX_NAME = "dex"
 const KALM_AUTH_PROXY_NAME = "auth-proxy"
 
+// auth-proxy replicas share refresh results through this config map, it's the only object they can access
+const KALM_AUTH_PROXY_REFRESH_CONFIG_MAP_NAME = "auth-proxy-refresh"
+
 // SingleSignOnConfigReconciler reconciles a SingleSignOnConfig object
 type SingleSignOnConfigReconciler struct {
 	*BaseReconciler
@@ -604,6 +607,28 @@ func (r *SingleSignOnConfigReconcilerTask) ReconcileInternalAuthProxyComponent()
 					Name:  "KALM_OIDC_AUTH_PROXY_URL",
 					Value: oidcProviderInfo.AuthProxyExternalUrl,
 				},
+				{
+					Type:  corev1alpha1.EnvVarTypeStatic,
+					Name:  "KALM_AUTH_PROXY_REFRESH_STORE",
+					Value: "kubernetes",
+				},
+				{
+					Type:  corev1alpha1.EnvVarTypeStatic,
+					Name:  "KALM_AUTH_PROXY_NAMESPACE",
+					Value: KALM_DEX_NAMESPACE,
+				},
+			},
+			// Refresh results are shared between replicas through a config map created by the controller
+			RunnerPermission: &corev1alpha1.RunnerPermission{
+				RoleType: "role",
+				Rules: []rbacV1.PolicyRule{
+					{
+						APIGroups:     []string{""},
+						Resources:     []string{"configmaps"},
+						ResourceNames: []string{KALM_AUTH_PROXY_REFRESH_CONFIG_MAP_NAME},
+						Verbs:         []string{"get", "update"},
+					},
+				},
 			},
 		},
 	}
@@ -612,6 +637,9 @@ func (r *SingleSignOnConfigReconcilerTask) ReconcileInternalAuthProxyComponent()
 		copied := r.authProxyComponent.DeepCopy()
 		copied.Spec = authProxyComponent.Spec
 
+		// auth-proxy is safe to scale, keep replicas set by users
+		copied.Spec.Replicas = r.authProxyComponent.Spec.Replicas
+
 		if err := ctrl.SetControllerReference(r.ssoConfig, copied, r.Scheme); err != nil {
 			r.EmitWarningEvent(r.ssoConfig, err, "unable to set owner for authProxyComponent")
 			return err
@@ -726,6 +754,11 @@ func (r *SingleSignOnConfigReconcilerTask) ReconcileAuthProxy() error {
 		}
 	}
 
+	if err := r.ReconcileAuthProxyRefreshConfigMap(); err != nil {
+		r.Log.Error(err, "reconcile auth proxy refresh config map failed.")
+		return err
+	}
+
 	if err := r.ReconcileInternalAuthProxyComponent(); err != nil {
 		r.Log.Error(err, "reconcile internal auth proxy failed.")
 		return err
@@ -739,6 +772,38 @@ func (r *SingleSignOnConfigReconcilerTask) ReconcileAuthProxy() error {
 	return nil
 }
 
+// ReconcileAuthProxyRefreshConfigMap creates the config map, its data is only written by auth-proxy
+func (r *SingleSignOnConfigReconcilerTask) ReconcileAuthProxyRefreshConfigMap() error {
+	var configMap coreV1.ConfigMap
+
+	err := r.Reader.Get(r.ctx, types.NamespacedName{
+		Name:      KALM_AUTH_PROXY_REFRESH_CONFIG_MAP_NAME,
+		Namespace: KALM_DEX_NAMESPACE,
+	}, &configMap)
+
+	if !errors.IsNotFound(err) {
+		return err
+	}
+
+	configMap = coreV1.ConfigMap{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      KALM_AUTH_PROXY_REFRESH_CONFIG_MAP_NAME,
+			Namespace: KALM_DEX_NAMESPACE,
+		},
+	}
+
+	if err := ctrl.SetControllerReference(r.ssoConfig, &configMap, r.Scheme); err != nil {
+		r.EmitWarningEvent(r.ssoConfig, err, "unable to set owner for auth proxy refresh config map")
+		return err
+	}
+
+	if err := r.Create(r.ctx, &configMap); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return nil
+}
+
 func (r *SingleSignOnConfigReconcilerTask) ReconcileDexRouteCert() error {
 	if r.ssoConfig.Spec.UseHttp {
 		return nil
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	coreV1 "k8s.io/api/core/v1"
+	storageV1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const annotationIsDefaultStorageClass = "storageclass.kubernetes.io/is-default-class"
+
+// GetPVCsOfVolume returns the existing claims of a pvc or pvcTemplate volume of the component.
+// Claims of a pvcTemplate volume are created by the statefulset, one for each replica.
+func GetPVCsOfVolume(ctx context.Context, reader client.Reader, component *corev1alpha1.Component, vol corev1alpha1.Volume) ([]coreV1.PersistentVolumeClaim, error) {
+	switch vol.Type {
+	case corev1alpha1.VolumeTypePersistentVolumeClaim:
+		var pvc coreV1.PersistentVolumeClaim
+
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: component.Namespace, Name: vol.PVC}, &pvc); err != nil {
+			if errors.IsNotFound(err) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		return []coreV1.PersistentVolumeClaim{pvc}, nil
+	case corev1alpha1.VolumeTypePersistentVolumeClaimTemplate:
+		var pvcList coreV1.PersistentVolumeClaimList
+
+		if err := reader.List(ctx, &pvcList, client.InNamespace(component.Namespace), client.MatchingLabels{
+			KalmLabelComponentKey:         component.Name,
+			KalmLabelVolClaimTemplateName: vol.PVC,
+		}); err != nil {
+			return nil, err
+		}
+
+		return pvcList.Items, nil
+	}
+
+	return nil, nil
+}
+
+// GetStorageClassOfPVC returns the default storage class if the pvc doesn't name one, nil if there is none.
+func GetStorageClassOfPVC(ctx context.Context, reader client.Reader, pvc *coreV1.PersistentVolumeClaim) (*storageV1.StorageClass, error) {
+	if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName != "" {
+		var sc storageV1.StorageClass
+
+		if err := reader.Get(ctx, client.ObjectKey{Name: *pvc.Spec.StorageClassName}, &sc); err != nil {
+			return nil, client.IgnoreNotFound(err)
+		}
+
+		return &sc, nil
+	}
+
+	var scList storageV1.StorageClassList
+
+	if err := reader.List(ctx, &scList); err != nil {
+		return nil, err
+	}
+
+	for i := range scList.Items {
+		if scList.Items[i].Annotations[annotationIsDefaultStorageClass] == "true" {
+			return &scList.Items[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// CheckVolumeExpansion returns a clear error if the storage class of the pvc can't expand it to the size.
+// Shrinking is rejected by the component webhook, a claim larger than the size is not checked.
+func CheckVolumeExpansion(ctx context.Context, reader client.Reader, pvc *coreV1.PersistentVolumeClaim, size resource.Quantity) error {
+	current := pvc.Spec.Resources.Requests[coreV1.ResourceStorage]
+
+	if size.Cmp(current) <= 0 {
+		return nil
+	}
+
+	sc, err := GetStorageClassOfPVC(ctx, reader, pvc)
+
+	if err != nil {
+		return err
+	}
+
+	if sc == nil {
+		return fmt.Errorf("volume %s can't be expanded, its storage class is not found", pvc.Name)
+	}
+
+	if sc.AllowVolumeExpansion == nil || !*sc.AllowVolumeExpansion {
+		return fmt.Errorf("volume %s can't be expanded, storage class %s doesn't allow volume expansion", pvc.Name, sc.Name)
+	}
+
+	return nil
+}
+
+// ReconcileVolumeExpansion applies size increases of pvc and pvcTemplate volumes to bound claims.
+// Claim templates of a statefulset are immutable, claims of new replicas are created with the original size
+// and expanded on later reconciles.
+func (r *ComponentReconcilerTask) ReconcileVolumeExpansion() error {
+	for _, vol := range r.component.Spec.Volumes {
+		pvcs, err := GetPVCsOfVolume(r.ctx, r.Client, r.component, vol)
+
+		if err != nil {
+			return err
+		}
+
+		for i := range pvcs {
+			pvc := &pvcs[i]
+
+			if pvc.Status.Phase != coreV1.ClaimBound {
+				continue
+			}
+
+			current := pvc.Spec.Resources.Requests[coreV1.ResourceStorage]
+
+			// a claim larger than the volume, e.g. a re-used one, is left as it is
+			if vol.Size.Cmp(current) <= 0 {
+				continue
+			}
+
+			if err := CheckVolumeExpansion(r.ctx, r.Client, pvc, vol.Size); err != nil {
+				r.Recorder.Event(r.component, coreV1.EventTypeWarning, "VolumeExpansionFailed", err.Error())
+				continue
+			}
+
+			copied := pvc.DeepCopy()
+			copied.Spec.Resources.Requests[coreV1.ResourceStorage] = vol.Size
+
+			if err := r.Patch(r.ctx, copied, client.MergeFrom(pvc)); err != nil {
+				r.WarningEvent(err, "unable to expand volume %s", pvc.Name)
+				return err
+			}
+
+			r.NormalEvent("VolumeExpanding", "Volume %s is expanding from %s to %s", pvc.Name, current.String(), vol.Size.String())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	storageV1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func genBoundPVC(name, storageClassName, size string, labels map[string]string) *coreV1.PersistentVolumeClaim {
+	return &coreV1.PersistentVolumeClaim{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: name, Labels: labels},
+		Spec: coreV1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClassName,
+			Resources: coreV1.ResourceRequirements{
+				Requests: coreV1.ResourceList{coreV1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+		Status: coreV1.PersistentVolumeClaimStatus{Phase: coreV1.ClaimBound},
+	}
+}
+
+func TestReconcileVolumeExpansion(t *testing.T) {
+	allowed := true
+	expandable := &storageV1.StorageClass{ObjectMeta: metaV1.ObjectMeta{Name: "expandable"}, AllowVolumeExpansion: &allowed}
+	fixed := &storageV1.StorageClass{ObjectMeta: metaV1.ObjectMeta{Name: "fixed"}}
+
+	component := &corev1alpha1.Component{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "db"},
+		Spec: corev1alpha1.ComponentSpec{
+			WorkloadType: corev1alpha1.WorkloadTypeStatefulSet,
+			Volumes: []corev1alpha1.Volume{
+				{Path: "/data", Size: resource.MustParse("2Gi"), Type: corev1alpha1.VolumeTypePersistentVolumeClaimTemplate, PVC: "data"},
+				{Path: "/logs", Size: resource.MustParse("2Gi"), Type: corev1alpha1.VolumeTypePersistentVolumeClaimTemplate, PVC: "logs"},
+			},
+		},
+	}
+
+	base := newFakeBaseReconciler(expandable, fixed, component,
+		genBoundPVC("data-db-0", "expandable", "1Gi", map[string]string{KalmLabelComponentKey: "db", KalmLabelVolClaimTemplateName: "data"}),
+		genBoundPVC("data-db-1", "expandable", "1Gi", map[string]string{KalmLabelComponentKey: "db", KalmLabelVolClaimTemplateName: "data"}),
+		genBoundPVC("logs-db-0", "fixed", "1Gi", map[string]string{KalmLabelComponentKey: "db", KalmLabelVolClaimTemplateName: "logs"}),
+	)
+
+	c := base.Client
+	recorder := base.Recorder.(*record.FakeRecorder)
+
+	task := &ComponentReconcilerTask{
+		ComponentReconciler: &ComponentReconciler{
+			BaseReconciler: base,
+		},
+		ctx:       context.Background(),
+		component: component,
+	}
+
+	assert.Nil(t, task.ReconcileVolumeExpansion())
+
+	var pvc coreV1.PersistentVolumeClaim
+
+	for _, name := range []string{"data-db-0", "data-db-1"} {
+		assert.Nil(t, c.Get(context.Background(), client.ObjectKey{Namespace: "app", Name: name}, &pvc))
+		assert.Equal(t, "2Gi", pvc.Spec.Resources.Requests.Storage().String())
+	}
+
+	assert.Nil(t, c.Get(context.Background(), client.ObjectKey{Namespace: "app", Name: "logs-db-0"}, &pvc))
+	assert.Equal(t, "1Gi", pvc.Spec.Resources.Requests.Storage().String())
+
+	var events []string
+
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+
+	assert.Contains(t, events, "Warning VolumeExpansionFailed volume logs-db-0 can't be expanded, storage class fixed doesn't allow volume expansion")
+}
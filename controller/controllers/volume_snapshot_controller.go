@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/kalmhq/kalm/controller/lib/snapshot"
+	"github.com/robfig/cron"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// how often a scheduled snapshot is checked until it's ready
+const SnapshotReadyCheckInterval = time.Minute
+
+// VolumeSnapshotScheduleReconciler takes snapshots of component volumes with a snapshot schedule.
+// Snapshots are not owned by the component, they are kept after the component is deleted.
+type VolumeSnapshotScheduleReconciler struct {
+	*BaseReconciler
+	ctx context.Context
+}
+
+func NewVolumeSnapshotScheduleReconciler(mgr ctrl.Manager) *VolumeSnapshotScheduleReconciler {
+	return &VolumeSnapshotScheduleReconciler{
+		BaseReconciler: NewBaseReconciler(mgr, "VolumeSnapshotSchedule"),
+		ctx:            context.Background(),
+	}
+}
+
+func (r *VolumeSnapshotScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("volumesnapshotschedule").
+		For(&corev1alpha1.Component{}).
+		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create;delete
+
+func (r *VolumeSnapshotScheduleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	var component corev1alpha1.Component
+
+	if err := r.Get(r.ctx, req.NamespacedName, &component); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if component.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+
+	now := time.Now()
+	var requeueAfter time.Duration
+
+	for _, vol := range component.Spec.Volumes {
+		if vol.SnapshotSchedule == nil {
+			continue
+		}
+
+		schedule, err := cron.ParseStandard(vol.SnapshotSchedule.Schedule)
+
+		if err != nil {
+			r.Log.Error(err, "invalid snapshot schedule", "component", req.NamespacedName, "pvc", vol.PVC)
+			continue
+		}
+
+		pvcs, err := GetPVCsOfVolume(r.ctx, r.Client, &component, vol)
+
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		for _, pvc := range pvcs {
+			next, err := r.reconcileScheduledSnapshots(&component, pvc.Name, vol.SnapshotSchedule, schedule, now)
+
+			if snapshot.IsNotSupported(err) {
+				r.Log.Info("skip snapshot schedules, " + snapshot.ErrNotSupported.Error())
+				return ctrl.Result{}, nil
+			}
+
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+
+			if requeueAfter == 0 || next < requeueAfter {
+				requeueAfter = next
+			}
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// takes a snapshot if one is due, deletes the oldest scheduled ones beyond the retention,
+// returns how long until the next snapshot is due
+func (r *VolumeSnapshotScheduleReconciler) reconcileScheduledSnapshots(
+	component *corev1alpha1.Component,
+	pvcName string,
+	snapshotSchedule *corev1alpha1.VolumeSnapshotSchedule,
+	schedule cron.Schedule,
+	now time.Time,
+) (time.Duration, error) {
+	snapshotList := snapshot.NewList()
+
+	if err := r.List(r.ctx, snapshotList, client.InNamespace(component.Namespace), client.MatchingLabels{
+		snapshot.LabelPVC:       pvcName,
+		snapshot.LabelScheduled: "true",
+	}); err != nil {
+		return 0, err
+	}
+
+	existing := snapshotList.Items
+
+	sort.Slice(existing, func(i, j int) bool {
+		a, b := existing[i].GetCreationTimestamp(), existing[j].GetCreationTimestamp()
+		return a.Before(&b)
+	})
+
+	if err := r.pruneScheduledSnapshots(existing, snapshotSchedule.Retention); err != nil {
+		return 0, err
+	}
+
+	// without any snapshot, the first one is due at the first schedule after the component is created
+	last := component.CreationTimestamp.Time
+	pending := false
+
+	if len(existing) > 0 {
+		latest := &existing[len(existing)-1]
+		last = latest.GetCreationTimestamp().Time
+		info := snapshot.GetInfo(latest)
+		pending = !info.ReadyToUse && info.Error == ""
+	}
+
+	if next := schedule.Next(last); next.After(now) {
+		return snapshotRequeueAfter(next.Sub(now), pending), nil
+	}
+
+	newSnapshot := snapshot.New(
+		component.Namespace,
+		fmt.Sprintf("%s-%s", pvcName, now.UTC().Format("20060102-150405")),
+		pvcName,
+		snapshotSchedule.VolumeSnapshotClassName,
+		map[string]string{
+			KalmLabelManaged:        "true",
+			KalmLabelComponentKey:   component.Name,
+			snapshot.LabelPVC:       pvcName,
+			snapshot.LabelScheduled: "true",
+		},
+	)
+
+	if err := r.Create(r.ctx, newSnapshot); err != nil && !errors.IsAlreadyExists(err) {
+		return 0, err
+	}
+
+	r.EmitNormalEvent(component, "SnapshotCreated", "Volume snapshot %s of pvc %s is created", newSnapshot.GetName(), pvcName)
+
+	return snapshotRequeueAfter(schedule.Next(now).Sub(now), true), nil
+}
+
+// a pending snapshot is checked again soon, older ones are pruned once it's ready
+func snapshotRequeueAfter(untilNext time.Duration, pending bool) time.Duration {
+	if pending && untilNext > SnapshotReadyCheckInterval {
+		return SnapshotReadyCheckInterval
+	}
+
+	return untilNext
+}
+
+// pruneScheduledSnapshots keeps the latest ready snapshots of the retention, and snapshots newer than them.
+// Snapshots not ready yet don't count, a snapshot which may still fail never replaces a good one.
+// Older snapshots are deleted, including failed ones.
+func (r *VolumeSnapshotScheduleReconciler) pruneScheduledSnapshots(sorted []unstructured.Unstructured, retention int) error {
+	var ready []int
+
+	for i := range sorted {
+		if snapshot.GetInfo(&sorted[i]).ReadyToUse {
+			ready = append(ready, i)
+		}
+	}
+
+	// retention is at least 1, the webhook validates it
+	if len(ready) <= retention {
+		return nil
+	}
+
+	oldestKept := ready[len(ready)-retention]
+
+	for i := 0; i < oldestKept; i++ {
+		if err := r.Delete(r.ctx, &sorted[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	return nil
+}
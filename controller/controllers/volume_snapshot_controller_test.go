@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
+	"github.com/kalmhq/kalm/controller/lib/snapshot"
+	"github.com/stretchr/testify/assert"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestVolumeSnapshotScheduleReconcile(t *testing.T) {
+	now := time.Now()
+
+	component := &corev1alpha1.Component{
+		ObjectMeta: metaV1.ObjectMeta{
+			Namespace:         "app",
+			Name:              "db",
+			CreationTimestamp: metaV1.NewTime(now.Add(-72 * time.Hour)),
+		},
+		Spec: corev1alpha1.ComponentSpec{
+			Volumes: []corev1alpha1.Volume{
+				{
+					Path: "/data",
+					Size: resource.MustParse("1Gi"),
+					Type: corev1alpha1.VolumeTypePersistentVolumeClaim,
+					PVC:  "db-data",
+					SnapshotSchedule: &corev1alpha1.VolumeSnapshotSchedule{
+						Schedule:  "0 3 * * *",
+						Retention: 2,
+					},
+				},
+			},
+		},
+	}
+
+	pvc := &coreV1.PersistentVolumeClaim{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "app", Name: "db-data"},
+	}
+
+	objs := []runtime.Object{component, pvc}
+
+	for i := 1; i <= 3; i++ {
+		s := snapshot.New("app", fmt.Sprintf("db-data-%d", i), "db-data", nil, map[string]string{
+			snapshot.LabelPVC:       "db-data",
+			snapshot.LabelScheduled: "true",
+		})
+		s.SetCreationTimestamp(metaV1.NewTime(now.Add(-time.Duration(4-i) * 24 * time.Hour)))
+		assert.Nil(t, unstructured.SetNestedField(s.Object, true, "status", "readyToUse"))
+		objs = append(objs, s)
+	}
+
+	// manual snapshots are never deleted by schedules
+	objs = append(objs, snapshot.New("app", "db-data-manual", "db-data", nil, map[string]string{snapshot.LabelPVC: "db-data"}))
+
+	base := newFakeBaseReconciler(objs...)
+	c := base.Client
+
+	r := &VolumeSnapshotScheduleReconciler{
+		BaseReconciler: base,
+		ctx:            context.Background(),
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "app", Name: "db"}}
+
+	listNames := func() []string {
+		snapshotList := snapshot.NewList()
+		assert.Nil(t, c.List(context.Background(), snapshotList, client.InNamespace("app")))
+
+		var names []string
+
+		for i := range snapshotList.Items {
+			names = append(names, snapshotList.Items[i].GetName())
+		}
+
+		return names
+	}
+
+	result, err := r.Reconcile(req)
+	assert.Nil(t, err)
+	// the new snapshot is checked until it's ready
+	assert.Equal(t, SnapshotReadyCheckInterval, result.RequeueAfter)
+
+	// the new snapshot is not ready, it doesn't take a place of the retention yet
+	names := listNames()
+	assert.Len(t, names, 4)
+	assert.Contains(t, names, "db-data-2")
+	assert.Contains(t, names, "db-data-3")
+	assert.Contains(t, names, "db-data-manual")
+	assert.NotContains(t, names, "db-data-1")
+
+	newName := fmt.Sprintf("db-data-%s", now.UTC().Format("20060102"))
+	var newSnapshot *unstructured.Unstructured
+	snapshotList := snapshot.NewList()
+	assert.Nil(t, c.List(context.Background(), snapshotList, client.InNamespace("app")))
+
+	for i := range snapshotList.Items {
+		if strings.HasPrefix(snapshotList.Items[i].GetName(), newName) {
+			newSnapshot = &snapshotList.Items[i]
+		}
+	}
+
+	assert.NotNil(t, newSnapshot)
+	info := snapshot.GetInfo(newSnapshot)
+	assert.Equal(t, "db-data", info.PVC)
+	assert.False(t, info.ReadyToUse)
+
+	// the fake client doesn't set creation timestamps
+	newSnapshot.SetCreationTimestamp(metaV1.NewTime(now))
+	assert.Nil(t, c.Update(context.Background(), newSnapshot))
+
+	// not due yet, the pending snapshot is checked again
+	result, err = r.Reconcile(req)
+	assert.Nil(t, err)
+	assert.Equal(t, SnapshotReadyCheckInterval, result.RequeueAfter)
+	assert.Len(t, listNames(), 4)
+
+	// once it's ready, the oldest one beyond the retention is deleted
+	assert.Nil(t, c.Get(context.Background(), types.NamespacedName{Namespace: "app", Name: newSnapshot.GetName()}, newSnapshot))
+	assert.Nil(t, unstructured.SetNestedField(newSnapshot.Object, true, "status", "readyToUse"))
+	assert.Nil(t, c.Update(context.Background(), newSnapshot))
+
+	result, err = r.Reconcile(req)
+	assert.Nil(t, err)
+	assert.True(t, result.RequeueAfter > SnapshotReadyCheckInterval && result.RequeueAfter <= 24*time.Hour)
+
+	names = listNames()
+	assert.Len(t, names, 3)
+	assert.Contains(t, names, "db-data-3")
+	assert.Contains(t, names, newSnapshot.GetName())
+	assert.Contains(t, names, "db-data-manual")
+}
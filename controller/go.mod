@@ -17,6 +17,7 @@ require (
 	github.com/joho/godotenv v1.3.0
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
+	github.com/prometheus/client_golang v1.7.1
 	github.com/robfig/cron v1.2.0
 	github.com/stretchr/testify v1.6.1
 	github.com/xeipuuv/gojsonschema v1.2.0
@@ -28,5 +29,6 @@ require (
 	k8s.io/apimachinery v0.18.6
 	k8s.io/client-go v0.18.4
 	k8s.io/kube-aggregator v0.17.2
+	k8s.io/metrics v0.18.4
 	sigs.k8s.io/controller-runtime v0.6.1
 )
@@ -0,0 +1,256 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type NotificationState string
+
+const (
+	NotificationStateFiring   NotificationState = "firing"
+	NotificationStateResolved NotificationState = "resolved"
+)
+
+// Notification is the json body posted to webhook receivers
+type Notification struct {
+	State       NotificationState `json:"state"`
+	Application string            `json:"application"`
+	Component   string            `json:"component,omitempty"`
+	Rule        string            `json:"rule"`
+	Metric      string            `json:"metric"`
+	Value       string            `json:"value"`
+	Threshold   string            `json:"threshold"`
+	Time        time.Time         `json:"time"`
+}
+
+func (n *Notification) Summary() string {
+	target := n.Application
+
+	if n.Component != "" {
+		target = n.Application + "/" + n.Component
+	}
+
+	if n.State == NotificationStateResolved {
+		return fmt.Sprintf("[RESOLVED] %s: %s of %s is %s, back under %s", n.Rule, n.Metric, target, n.Value, n.Threshold)
+	}
+
+	return fmt.Sprintf("[FIRING] %s: %s of %s is %s, above %s", n.Rule, n.Metric, target, n.Value, n.Threshold)
+}
+
+// Receiver urls are given by users, the controller should not be used to reach the cluster network or the cloud metadata service.
+var blockedNetworks = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+// IsPublicIP returns false for loopback, link-local, private and other reserved addresses
+func IsPublicIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckDialAddress is called with the resolved address of every connection, including the ones of redirects.
+// It is replaced in tests, which post to servers on the loopback address.
+var CheckDialAddress = func(address string) error {
+	host, _, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("address %s is not allowed", address)
+	}
+
+	return nil
+}
+
+// The transport has no proxy, connections are always checked against the real targets.
+var httpClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				return CheckDialAddress(address)
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+func SendWebhook(ctx context.Context, url string, n *Notification) error {
+	return postJSON(ctx, url, n)
+}
+
+// SendSlack posts a message to a slack incoming webhook, or any service accepting the same payload
+func SendSlack(ctx context.Context, url string, n *Notification) error {
+	return postJSON(ctx, url, map[string]string{"text": n.Summary()})
+}
+
+func postJSON(ctx context.Context, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("post to %s failed with status %d", req.URL.Host, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// SMTPConfig is read from the kalm-alert-smtp secret in kalm-system
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func ParseSMTPConfig(data map[string][]byte) (*SMTPConfig, error) {
+	config := &SMTPConfig{
+		Host:     string(data["host"]),
+		Port:     587,
+		Username: string(data["username"]),
+		Password: string(data["password"]),
+		From:     string(data["from"]),
+	}
+
+	if config.Host == "" || config.From == "" {
+		return nil, fmt.Errorf("host and from of smtp are required")
+	}
+
+	if port, exist := data["port"]; exist {
+		p, err := strconv.Atoi(string(port))
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid smtp port %s", port)
+		}
+
+		config.Port = p
+	}
+
+	return config, nil
+}
+
+type sendMailFunc func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+// replaced in tests
+var sendMail sendMailFunc = smtp.SendMail
+
+func SendEmail(config *SMTPConfig, to []string, n *Notification) error {
+	var auth smtp.Auth
+
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	// to may be in the form of "Name <address>", only the address is valid in RCPT commands
+	recipients := make([]string, 0, len(to))
+	headers := make([]string, 0, len(to))
+
+	for _, t := range to {
+		addr, err := mail.ParseAddress(t)
+
+		if err != nil {
+			return err
+		}
+
+		recipients = append(recipients, addr.Address)
+		headers = append(headers, addr.String())
+	}
+
+	return sendMail(net.JoinHostPort(config.Host, strconv.Itoa(config.Port)), auth, config.From, recipients, buildEmail(config.From, headers, n))
+}
+
+func buildEmail(from string, to []string, n *Notification) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", n.Summary())
+	fmt.Fprintf(&b, "Date: %s\r\n", n.Time.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&b, "Application: %s\r\n", n.Application)
+
+	if n.Component != "" {
+		fmt.Fprintf(&b, "Component: %s\r\n", n.Component)
+	}
+
+	fmt.Fprintf(&b, "Rule: %s\r\n", n.Rule)
+	fmt.Fprintf(&b, "Metric: %s\r\n", n.Metric)
+	fmt.Fprintf(&b, "Value: %s\r\n", n.Value)
+	fmt.Fprintf(&b, "Threshold: %s\r\n", n.Threshold)
+	fmt.Fprintf(&b, "State: %s\r\n", n.State)
+	fmt.Fprintf(&b, "Time: %s\r\n", n.Time.Format(time.RFC3339))
+
+	return []byte(b.String())
+}
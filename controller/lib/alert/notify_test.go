@@ -0,0 +1,111 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func genNotification() *Notification {
+	return &Notification{
+		State:       NotificationStateFiring,
+		Application: "app",
+		Component:   "web",
+		Rule:        "high-cpu",
+		Metric:      "cpu",
+		Value:       "800m",
+		Threshold:   "500m",
+		Time:        time.Date(2020, 8, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSendWebhookAndSlack(t *testing.T) {
+	var bodies []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		var body map[string]interface{}
+		_ = json.Unmarshal(data, &body)
+		bodies = append(bodies, body)
+
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	n := genNotification()
+
+	// servers on the loopback address are not allowed by default
+	assert.NotNil(t, SendWebhook(context.Background(), server.URL+"/hook", n))
+	assert.Len(t, bodies, 0)
+
+	defer func(check func(string) error) { CheckDialAddress = check }(CheckDialAddress)
+	CheckDialAddress = func(string) error { return nil }
+
+	assert.Nil(t, SendWebhook(context.Background(), server.URL+"/hook", n))
+	assert.Nil(t, SendSlack(context.Background(), server.URL+"/slack", n))
+	assert.NotNil(t, SendWebhook(context.Background(), server.URL+"/fail", n))
+
+	assert.Equal(t, "firing", bodies[0]["state"])
+	assert.Equal(t, "app", bodies[0]["application"])
+	assert.Equal(t, "800m", bodies[0]["value"])
+	assert.Equal(t, "[FIRING] high-cpu: cpu of app/web is 800m, above 500m", bodies[1]["text"])
+}
+
+func TestIsPublicIP(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "10.96.0.1", "169.254.169.254", "172.20.0.1", "192.168.1.1", "100.64.0.1", "0.0.0.0", "::1", "fe80::1", "fd00::1", "::ffff:127.0.0.1"} {
+		assert.False(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+
+	for _, ip := range []string{"8.8.8.8", "1.1.1.1", "2001:4860:4860::8888"} {
+		assert.True(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+
+	assert.NotNil(t, CheckDialAddress("169.254.169.254:80"))
+	assert.Nil(t, CheckDialAddress("8.8.8.8:443"))
+}
+
+func TestSendEmail(t *testing.T) {
+	_, err := ParseSMTPConfig(map[string][]byte{"host": []byte("smtp.example.com")})
+	assert.NotNil(t, err)
+
+	config, err := ParseSMTPConfig(map[string][]byte{
+		"host":     []byte("smtp.example.com"),
+		"port":     []byte("25"),
+		"username": []byte("kalm"),
+		"password": []byte("secret"),
+		"from":     []byte("kalm@example.com"),
+	})
+	assert.Nil(t, err)
+
+	defer func() { sendMail = smtp.SendMail }()
+
+	var addr, from, msg string
+	var to []string
+
+	sendMail = func(a string, auth smtp.Auth, f string, t []string, m []byte) error {
+		addr, from, to, msg = a, f, t, string(m)
+		return nil
+	}
+
+	n := genNotification()
+	n.State = NotificationStateResolved
+
+	assert.Nil(t, SendEmail(config, []string{"ops@example.com", "Dev Team <dev@example.com>"}, n))
+	assert.Equal(t, "smtp.example.com:25", addr)
+	assert.Equal(t, "kalm@example.com", from)
+	assert.Equal(t, []string{"ops@example.com", "dev@example.com"}, to)
+	assert.True(t, strings.Contains(msg, "To: <ops@example.com>, \"Dev Team\" <dev@example.com>\r\n"))
+	assert.True(t, strings.Contains(msg, "Subject: [RESOLVED] high-cpu: cpu of app/web is 800m, back under 500m\r\n"))
+	assert.True(t, strings.Contains(msg, "State: resolved\r\n"))
+}
@@ -6,15 +6,20 @@ import (
 	"strings"
 
 	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 type File struct {
 	Path    string `json:"path"`
 	IsDir   bool   `json:"isDir"`
 	Content string `json:"content"`
-}
 
-// TODO validate path
+	// content is base64 encoded binary data
+	Base64 bool `json:"base64,omitempty"`
+
+	// content is stored in a secret instead of a config map
+	Secret bool `json:"secret,omitempty"`
+}
 
 // will use this a config-map called kalm-files to store files in each namespace
 const KALM_CONFIG_MAP_NAME = "kalm-files"
@@ -33,6 +38,8 @@ type FileItem struct {
 	AbsPath  string      `json:"path"`
 	IsDir    bool        `json:"isDir"`
 	Content  string      `json:"content"`
+	Base64   bool        `json:"base64,omitempty"`
+	Secret   bool        `json:"secret,omitempty"`
 	Children []*FileItem `json:"children,omitempty"`
 }
 
@@ -46,7 +53,7 @@ func FindParentNode(parentAbsPath string, subRoot *FileItem) *FileItem {
 	}
 
 	for _, item := range subRoot.Children {
-		if item.IsDir && strings.HasPrefix(parentAbsPath, item.AbsPath) {
+		if item.IsDir && (parentAbsPath == item.AbsPath || strings.HasPrefix(parentAbsPath, item.AbsPath+"/")) {
 			return FindParentNode(parentAbsPath, item)
 		}
 	}
@@ -67,6 +74,65 @@ func EncodeFilePath(path string) string {
 	return strings.ReplaceAll(path, "/", KALM_SLASH_REPLACER)
 }
 
+// ChangedFilePaths returns paths of files and dirs which are added, removed or changed between the two versions of
+// the kalm-files config map. Either of them can be nil. Edits of sharded files always change their refs in the index.
+func ChangedFilePaths(oldIndex, newIndex *coreV1.ConfigMap) []string {
+	var oldData, newData map[string]string
+
+	if oldIndex != nil {
+		oldData = oldIndex.Data
+	}
+
+	if newIndex != nil {
+		newData = newIndex.Data
+	}
+
+	var paths []string
+
+	for key, value := range newData {
+		if oldValue, exist := oldData[key]; !exist || oldValue != value {
+			paths = append(paths, DecodeFilePath(key))
+		}
+	}
+
+	for key := range oldData {
+		if _, exist := newData[key]; !exist {
+			paths = append(paths, DecodeFilePath(key))
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// ValidateFilePath checks the path is absolute, clean and can be stored as a config map key
+func ValidateFilePath(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("path %s must start with /", path)
+	}
+
+	if path == "/" {
+		return nil
+	}
+
+	for _, part := range strings.Split(path[1:], "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("path %s is not clean", path)
+		}
+
+		if strings.Contains(part, KALM_SLASH_REPLACER) {
+			return fmt.Errorf("path %s can't contain %s", path, KALM_SLASH_REPLACER)
+		}
+	}
+
+	if errs := validation.IsConfigMapKey(EncodeFilePath(path)); len(errs) > 0 {
+		return fmt.Errorf("invalid path %s: %s", path, errs[0])
+	}
+
+	return nil
+}
+
 func AddFile(configMap *coreV1.ConfigMap, file *File, replaceExistingOpt ...bool) error {
 	fileKey := EncodeFilePath(file.Path)
 
@@ -124,6 +190,20 @@ func UpdateFile(configMap *coreV1.ConfigMap, file *File) error {
 
 func MoveFile(configMap *coreV1.ConfigMap, root *FileItem, newPath string) error {
 	if root.IsDir {
+		if strings.HasPrefix(newPath, root.AbsPath+"/") {
+			return fmt.Errorf("Can't move %s into itself", root.AbsPath)
+		}
+
+		// keep the dir even if it's empty
+		err := AddFile(configMap, &File{
+			Path:  newPath,
+			IsDir: true,
+		})
+
+		if err != nil {
+			return err
+		}
+
 		for _, child := range root.Children {
 			np := fmt.Sprintf("%s/%s", newPath, child.Name)
 			err := MoveFile(configMap, child, np)
@@ -131,6 +211,8 @@ func MoveFile(configMap *coreV1.ConfigMap, root *FileItem, newPath string) error
 				return err
 			}
 		}
+
+		return DeleteFile(configMap, &File{Path: root.AbsPath})
 	} else {
 		err := AddFile(configMap, &File{
 			Path:    newPath,
@@ -200,7 +282,8 @@ func GetFileItemTree(configMap *coreV1.ConfigMap, basePath string) (*FileItem, e
 	}
 
 	for encodedFilePath := range configMap.Data {
-		if strings.HasPrefix(encodedFilePath, encodedBasePath) {
+		// "/a" must not match its sibling "/ab"
+		if basePath == "/" || encodedFilePath == encodedBasePath || strings.HasPrefix(encodedFilePath, encodedBasePath+KALM_SLASH_REPLACER) {
 			filePaths = append(filePaths, encodedFilePath)
 		}
 	}
@@ -94,6 +94,68 @@ func (suite *FilesTestSuite) TestResolveMountPaths() {
 	// }
 }
 
+func (suite *FilesTestSuite) TestGetFileItemTreeOfSiblings() {
+	suite.Nil(AddFile(suite.cm, &File{Path: "/a/x", Content: "x"}))
+	suite.Nil(AddFile(suite.cm, &File{Path: "/ab/y", Content: "y"}))
+
+	root, err := GetFileItemTree(suite.cm, "/a")
+	suite.Nil(err)
+	suite.Len(root.Children, 1)
+	suite.Equal("/a/x", root.Children[0].AbsPath)
+
+	root, err = GetFileItemTree(suite.cm, "/")
+	suite.Nil(err)
+	suite.Len(root.Children, 2)
+	suite.Equal("/ab/y", root.Children[1].Children[0].AbsPath)
+}
+
+func (suite *FilesTestSuite) TestMoveDir() {
+	suite.Nil(AddFile(suite.cm, &File{Path: "/nginx/conf.d/default.conf", Content: "content"}))
+	suite.Nil(AddFile(suite.cm, &File{Path: "/nginx/empty", IsDir: true}))
+
+	root, err := GetFileItemTree(suite.cm, "/nginx")
+	suite.Nil(err)
+	suite.NotNil(MoveFile(suite.cm, root, "/nginx/sub"))
+	suite.Nil(MoveFile(suite.cm, root, "/etc/nginx"))
+	CleanUpConfigMap(suite.cm)
+
+	_, err = GetFileItemTree(suite.cm, "/nginx")
+	suite.NotNil(err)
+
+	root, err = GetFileItemTree(suite.cm, "/etc/nginx")
+	suite.Nil(err)
+	suite.Len(root.Children, 2)
+	suite.Equal("content", suite.cm.Data[EncodeFilePath("/etc/nginx/conf.d/default.conf")])
+	suite.Equal(KALM_PERSISTENT_DIR_PLACEHOLDER, suite.cm.Data[EncodeFilePath("/etc/nginx/empty")])
+}
+
+func (suite *FilesTestSuite) TestValidateFilePath() {
+	suite.Nil(ValidateFilePath("/"))
+	suite.Nil(ValidateFilePath("/nginx/conf.d/default.conf"))
+	suite.NotNil(ValidateFilePath("nginx.conf"))
+	suite.NotNil(ValidateFilePath("/nginx/"))
+	suite.NotNil(ValidateFilePath("/nginx//a"))
+	suite.NotNil(ValidateFilePath("/nginx/../a"))
+	suite.NotNil(ValidateFilePath("/a b"))
+	suite.NotNil(ValidateFilePath("/a__D__b"))
+}
+
+func (suite *FilesTestSuite) TestChangedFilePaths() {
+	old := suite.cm.DeepCopy()
+
+	suite.Nil(AddFile(suite.cm, &File{Path: "/a/b", Content: "b"}))
+	suite.Nil(AddFile(suite.cm, &File{Path: "/c", Content: "c"}))
+	suite.Equal([]string{"/a", "/a/b", "/c"}, ChangedFilePaths(old, suite.cm))
+
+	old = suite.cm.DeepCopy()
+	suite.Nil(UpdateFile(suite.cm, &File{Path: "/c", Content: "c2"}))
+	suite.Nil(DeleteFile(suite.cm, &File{Path: "/a"}))
+	suite.Equal([]string{"/a", "/a/b", "/c"}, ChangedFilePaths(old, suite.cm))
+
+	suite.Equal([]string{"/", "/c"}, ChangedFilePaths(nil, suite.cm))
+	suite.Nil(ChangedFilePaths(suite.cm, suite.cm.DeepCopy()))
+}
+
 func TestFilesTestSuite(t *testing.T) {
 	suite.Run(t, new(FilesTestSuite))
 }
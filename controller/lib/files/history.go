@@ -0,0 +1,302 @@
+package files
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Revisions of files are kept in this config map, keys are encoded paths, values are json lists of revisions.
+// Contents of revisions are stored in shards like the current contents.
+const KALM_FILES_HISTORY_CONFIG_MAP_NAME = "kalm-files-history"
+
+// how many revisions are kept for each file
+const KALM_FILES_REVISION_LIMIT = 10
+
+type FileRevision struct {
+	Revision int `json:"revision"`
+
+	// empty for the content before revisions are recorded
+	Author    string      `json:"author,omitempty"`
+	Timestamp metaV1.Time `json:"timestamp,omitempty"`
+
+	// the content, inline or a ref
+	Ref string `json:"ref"`
+
+	// set if the revision is made by rolling back to an earlier one
+	RollbackOf int `json:"rollbackOf,omitempty"`
+}
+
+func (store *Store) loadHistory(ctx context.Context, reader client.Reader, namespace string) error {
+	store.history = &coreV1.ConfigMap{}
+	store.historyExists = true
+
+	err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: KALM_FILES_HISTORY_CONFIG_MAP_NAME}, store.history)
+
+	if errors.IsNotFound(err) {
+		store.historyExists = false
+		store.history = &coreV1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: namespace,
+				Name:      KALM_FILES_HISTORY_CONFIG_MAP_NAME,
+			},
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if store.history.Data == nil {
+		store.history.Data = make(map[string]string)
+	}
+
+	store.historyOriginal = make(map[string]string, len(store.history.Data))
+
+	for key, value := range store.history.Data {
+		store.historyOriginal[key] = value
+	}
+
+	return nil
+}
+
+func (store *Store) saveHistory(ctx context.Context, writer client.Writer) error {
+	if reflect.DeepEqual(store.history.Data, store.historyOriginal) {
+		return nil
+	}
+
+	if !store.historyExists {
+		if err := writer.Create(ctx, store.history); err != nil {
+			return err
+		}
+
+		store.historyExists = true
+	} else if err := writer.Update(ctx, store.history); err != nil {
+		return err
+	}
+
+	store.historyOriginal = make(map[string]string, len(store.history.Data))
+
+	for key, value := range store.history.Data {
+		store.historyOriginal[key] = value
+	}
+
+	return nil
+}
+
+// Revisions of a file, the latest is the last one
+func (store *Store) Revisions(path string) ([]*FileRevision, error) {
+	data, exist := store.history.Data[EncodeFilePath(path)]
+
+	if !exist {
+		return []*FileRevision{}, nil
+	}
+
+	var revisions []*FileRevision
+
+	if err := json.Unmarshal([]byte(data), &revisions); err != nil {
+		return nil, fmt.Errorf("invalid revisions of file %s: %s", path, err.Error())
+	}
+
+	return revisions, nil
+}
+
+// RevisionContent returns the content of a revision, and the ref if it's stored in a shard
+func (store *Store) RevisionContent(path string, revision *FileRevision) ([]byte, *FileRef, error) {
+	// revisions are always stored in shards
+	ref := ParseFileRef(revision.Ref)
+
+	if ref == nil {
+		return nil, nil, fmt.Errorf("invalid revision %d of file %s", revision.Revision, path)
+	}
+
+	return store.resolve(path, ref)
+}
+
+func (store *Store) allRevisions() map[string][]*FileRevision {
+	res := make(map[string][]*FileRevision, len(store.history.Data))
+
+	for key := range store.history.Data {
+		// invalid revisions are ignored
+		if revisions, err := store.Revisions(DecodeFilePath(key)); err == nil {
+			res[key] = revisions
+		}
+	}
+
+	return res
+}
+
+func (store *Store) setRevisions(path string, revisions []*FileRevision) error {
+	if len(revisions) > KALM_FILES_REVISION_LIMIT {
+		revisions = revisions[len(revisions)-KALM_FILES_REVISION_LIMIT:]
+	}
+
+	data, err := json.Marshal(revisions)
+
+	if err != nil {
+		return err
+	}
+
+	store.history.Data[EncodeFilePath(path)] = string(data)
+
+	return nil
+}
+
+// recordRevision appends the current content of a file to its revisions, the oldest ones are dropped
+func (store *Store) recordRevision(path, ref string, rollbackOf int) error {
+	revisions, err := store.Revisions(path)
+
+	if err != nil {
+		return err
+	}
+
+	revision := &FileRevision{
+		Revision:   1,
+		Author:     store.Author,
+		Timestamp:  metaV1.Now(),
+		Ref:        ref,
+		RollbackOf: rollbackOf,
+	}
+
+	if len(revisions) > 0 {
+		revision.Revision = revisions[len(revisions)-1].Revision + 1
+	}
+
+	return store.setRevisions(path, append(revisions, revision))
+}
+
+// keepInlineRevision keeps the content written before revisions are recorded as the first revision,
+// so it can be restored after the first edit.
+func (store *Store) keepInlineRevision(path string) error {
+	data, exist := store.Index.Data[EncodeFilePath(path)]
+
+	if !exist || data == KALM_DIR_PLACEHOLDER || data == KALM_PERSISTENT_DIR_PLACEHOLDER || store.refKeys[EncodeFilePath(path)] {
+		return nil
+	}
+
+	if _, exist := store.history.Data[EncodeFilePath(path)]; exist {
+		return nil
+	}
+
+	ref, err := store.put(&File{Path: path, Content: data})
+
+	if err != nil {
+		return err
+	}
+
+	return store.setRevisions(path, []*FileRevision{{Revision: 1, Ref: ref.String()}})
+}
+
+// moveHistory moves revisions of a file or all files in a dir, they are deleted if the new path is empty
+func (store *Store) moveHistory(oldPath, newPath string) {
+	oldKey := EncodeFilePath(oldPath)
+
+	for key, data := range store.history.Data {
+		if key != oldKey && !strings.HasPrefix(key, oldKey+KALM_SLASH_REPLACER) {
+			continue
+		}
+
+		delete(store.history.Data, key)
+
+		if newPath != "" {
+			store.history.Data[EncodeFilePath(newPath)+key[len(oldKey):]] = data
+		}
+	}
+}
+
+// RollbackFile restores the content of a revision, which is recorded as a new revision
+func (store *Store) RollbackFile(path string, revision int) error {
+	if _, _, err := store.Content(path); err != nil {
+		return err
+	}
+
+	revisions, err := store.Revisions(path)
+
+	if err != nil {
+		return err
+	}
+
+	for _, r := range revisions {
+		if r.Revision == revision {
+			return store.rollbackTo(path, r)
+		}
+	}
+
+	return fmt.Errorf("revision %d of file %s doesn't exist", revision, path)
+}
+
+// RollbackDir restores all files in a dir to their latest revisions at the time.
+// Files created after the time are left as they are. Paths of restored files are returned.
+func (store *Store) RollbackDir(path string, to time.Time) ([]string, error) {
+	root, err := GetFileItemTree(store.Index, path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var filePaths []string
+	collectFilePaths(root, &filePaths)
+	sort.Strings(filePaths)
+
+	var restored []string
+
+	for _, filePath := range filePaths {
+		revisions, err := store.Revisions(filePath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var target *FileRevision
+
+		for _, r := range revisions {
+			if !r.Timestamp.Time.After(to) {
+				target = r
+			}
+		}
+
+		if target == nil || target.Ref == store.Index.Data[EncodeFilePath(filePath)] {
+			continue
+		}
+
+		if err := store.rollbackTo(filePath, target); err != nil {
+			return nil, err
+		}
+
+		restored = append(restored, filePath)
+	}
+
+	return restored, nil
+}
+
+func (store *Store) rollbackTo(path string, revision *FileRevision) error {
+	key := EncodeFilePath(path)
+
+	if store.refKeys[key] && revision.Ref == store.Index.Data[key] {
+		return nil
+	}
+
+	store.Index.Data[key] = revision.Ref
+	store.refKeys[key] = true
+
+	return store.recordRevision(path, revision.Ref, revision.Revision)
+}
+
+func collectFilePaths(item *FileItem, paths *[]string) {
+	if !item.IsDir {
+		*paths = append(*paths, item.AbsPath)
+		return
+	}
+
+	for _, child := range item.Children {
+		collectFilePaths(child, paths)
+	}
+}
@@ -0,0 +1,768 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The kalm-files config map is an index of the file tree. To lift the size limit of a single object,
+// file contents are stored in shard config maps, or secrets for sensitive files, and the index only keeps refs to them.
+// Contents written before sharding are still inline in the index.
+
+// prefix of refs in the index, e.g. __REF__ConfigMap/kalm-files-1/__D__nginx.conf
+const KALM_FILE_REF_PREFIX = "__REF__"
+
+// annotation of the index, a json list of keys whose values are refs. Inline contents may look like refs,
+// so only the listed keys are parsed as refs.
+const KALM_FILES_REFS_ANNOTATION = "kalm.dev/files-refs"
+
+// label of shard objects, value is the index config map name
+const KALM_FILES_SHARD_LABEL = "kalm.dev/files-shard"
+
+// objects are limited to 1MiB, leave room for metadata
+const KALM_FILES_SHARD_SIZE_LIMIT = 768 * 1024
+
+type ShardKind string
+
+const (
+	ShardKindConfigMap ShardKind = "ConfigMap"
+	ShardKindSecret    ShardKind = "Secret"
+)
+
+// FileRef locates the content of a file in a shard
+type FileRef struct {
+	Kind   ShardKind
+	Name   string
+	Key    string
+	Binary bool
+}
+
+func (ref *FileRef) String() string {
+	s := fmt.Sprintf("%s%s/%s/%s", KALM_FILE_REF_PREFIX, ref.Kind, ref.Name, ref.Key)
+
+	if ref.Binary {
+		s += "/binary"
+	}
+
+	return s
+}
+
+// ParseFileRef returns nil if the data is not a ref. Data of the index is parsed only if the key is a ref key.
+func ParseFileRef(data string) *FileRef {
+	if !strings.HasPrefix(data, KALM_FILE_REF_PREFIX) {
+		return nil
+	}
+
+	parts := strings.Split(data[len(KALM_FILE_REF_PREFIX):], "/")
+
+	if len(parts) < 3 || (parts[0] != string(ShardKindConfigMap) && parts[0] != string(ShardKindSecret)) {
+		return nil
+	}
+
+	return &FileRef{
+		Kind:   ShardKind(parts[0]),
+		Name:   parts[1],
+		Key:    parts[2],
+		Binary: len(parts) > 3 && parts[3] == "binary",
+	}
+}
+
+type shard struct {
+	kind      ShardKind
+	configMap *coreV1.ConfigMap
+	secret    *coreV1.Secret
+	exists    bool
+
+	original map[string][]byte
+	data     map[string][]byte
+	binary   map[string]bool
+}
+
+func (s *shard) name() string {
+	if s.kind == ShardKindSecret {
+		return s.secret.Name
+	}
+
+	return s.configMap.Name
+}
+
+func (s *shard) size() int {
+	size := 0
+
+	for key, value := range s.data {
+		size += len(key) + len(value)
+	}
+
+	return size
+}
+
+func (s *shard) object(data map[string][]byte) runtime.Object {
+	if s.kind == ShardKindSecret {
+		s.secret.Data = data
+		return s.secret
+	}
+
+	s.configMap.Data = nil
+	s.configMap.BinaryData = nil
+
+	for key, value := range data {
+		if s.binary[key] {
+			if s.configMap.BinaryData == nil {
+				s.configMap.BinaryData = make(map[string][]byte)
+			}
+
+			s.configMap.BinaryData[key] = value
+		} else {
+			if s.configMap.Data == nil {
+				s.configMap.Data = make(map[string]string)
+			}
+
+			s.configMap.Data[key] = string(value)
+		}
+	}
+
+	return s.configMap
+}
+
+// Store holds the index and shards of a namespace. Changes are made in memory and written by Save.
+type Store struct {
+	Index       *coreV1.ConfigMap
+	indexExists bool
+	shards      []*shard
+
+	// keys of the index whose values are refs
+	refKeys map[string]bool
+
+	// who makes the changes, recorded in revisions
+	Author string
+
+	history         *coreV1.ConfigMap
+	historyExists   bool
+	historyOriginal map[string]string
+}
+
+func LoadStore(ctx context.Context, reader client.Reader, namespace string) (*Store, error) {
+	store := &Store{Index: &coreV1.ConfigMap{}, indexExists: true}
+
+	err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: KALM_CONFIG_MAP_NAME}, store.Index)
+
+	if errors.IsNotFound(err) {
+		store.indexExists = false
+		store.Index = &coreV1.ConfigMap{
+			ObjectMeta: metaV1.ObjectMeta{
+				Namespace: namespace,
+				Name:      KALM_CONFIG_MAP_NAME,
+			},
+			Data: map[string]string{
+				KALM_SLASH_REPLACER: KALM_PERSISTENT_DIR_PLACEHOLDER,
+			},
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if store.Index.Data == nil {
+		store.Index.Data = make(map[string]string)
+	}
+
+	if err := store.loadHistory(ctx, reader, namespace); err != nil {
+		return nil, err
+	}
+
+	opts := []client.ListOption{client.InNamespace(namespace), client.MatchingLabels{KALM_FILES_SHARD_LABEL: KALM_CONFIG_MAP_NAME}}
+
+	var configMapList coreV1.ConfigMapList
+
+	if err := reader.List(ctx, &configMapList, opts...); err != nil {
+		return nil, err
+	}
+
+	for i := range configMapList.Items {
+		configMap := &configMapList.Items[i]
+		s := &shard{kind: ShardKindConfigMap, configMap: configMap, exists: true, data: make(map[string][]byte), binary: make(map[string]bool)}
+
+		for key, value := range configMap.Data {
+			s.data[key] = []byte(value)
+		}
+
+		for key, value := range configMap.BinaryData {
+			s.data[key] = value
+			s.binary[key] = true
+		}
+
+		store.addShard(s)
+	}
+
+	var secretList coreV1.SecretList
+
+	if err := reader.List(ctx, &secretList, opts...); err != nil {
+		return nil, err
+	}
+
+	for i := range secretList.Items {
+		secret := &secretList.Items[i]
+		s := &shard{kind: ShardKindSecret, secret: secret, exists: true, data: make(map[string][]byte), binary: make(map[string]bool)}
+
+		for key, value := range secret.Data {
+			s.data[key] = value
+		}
+
+		store.addShard(s)
+	}
+
+	if err := store.loadRefKeys(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (store *Store) loadRefKeys() error {
+	store.refKeys = make(map[string]bool)
+
+	if data, exist := store.Index.Annotations[KALM_FILES_REFS_ANNOTATION]; exist {
+		var keys []string
+
+		if err := json.Unmarshal([]byte(data), &keys); err != nil {
+			return fmt.Errorf("invalid %s annotation of %s: %s", KALM_FILES_REFS_ANNOTATION, KALM_CONFIG_MAP_NAME, err.Error())
+		}
+
+		for _, key := range keys {
+			store.refKeys[key] = true
+		}
+
+		return nil
+	}
+
+	// Without shards, all contents are inline. Otherwise the index is written before the annotation,
+	// when contents are only written to shards, so the refs are detected by the prefix. The annotation is written on save.
+	if len(store.shards) == 0 {
+		return nil
+	}
+
+	for key, data := range store.Index.Data {
+		if ParseFileRef(data) != nil {
+			store.refKeys[key] = true
+		}
+	}
+
+	return nil
+}
+
+// indexRef returns the ref of a key in the index, nil if the content is inline
+func (store *Store) indexRef(key string) *FileRef {
+	if !store.refKeys[key] {
+		return nil
+	}
+
+	return ParseFileRef(store.Index.Data[key])
+}
+
+func (store *Store) saveRefKeys() error {
+	keys := make([]string, 0, len(store.refKeys))
+
+	for key := range store.refKeys {
+		if _, exist := store.Index.Data[key]; exist {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	data, err := json.Marshal(keys)
+
+	if err != nil {
+		return err
+	}
+
+	if store.Index.Annotations == nil {
+		store.Index.Annotations = make(map[string]string)
+	}
+
+	store.Index.Annotations[KALM_FILES_REFS_ANNOTATION] = string(data)
+
+	return nil
+}
+
+// moveRefKeys moves ref keys of a file or all files in a dir, they are deleted if the new path is empty
+func (store *Store) moveRefKeys(oldPath, newPath string) {
+	oldKey := EncodeFilePath(oldPath)
+
+	for key := range store.refKeys {
+		if key != oldKey && !strings.HasPrefix(key, oldKey+KALM_SLASH_REPLACER) {
+			continue
+		}
+
+		delete(store.refKeys, key)
+
+		if newPath != "" {
+			store.refKeys[EncodeFilePath(newPath)+key[len(oldKey):]] = true
+		}
+	}
+}
+
+func (store *Store) addShard(s *shard) {
+	s.original = make(map[string][]byte, len(s.data))
+
+	for key, value := range s.data {
+		s.original[key] = value
+	}
+
+	store.shards = append(store.shards, s)
+}
+
+func (store *Store) getShard(kind ShardKind, name string) *shard {
+	for _, s := range store.shards {
+		if s.kind == kind && s.name() == name {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// Content returns the content of a file, and the ref if it's stored in a shard
+func (store *Store) Content(path string) ([]byte, *FileRef, error) {
+	data, exist := store.Index.Data[EncodeFilePath(path)]
+
+	if !exist {
+		return nil, nil, fmt.Errorf("File or dir doesn't exist")
+	}
+
+	if data == KALM_DIR_PLACEHOLDER || data == KALM_PERSISTENT_DIR_PLACEHOLDER {
+		return nil, nil, fmt.Errorf("%s is a dir, not a file", path)
+	}
+
+	ref := store.indexRef(EncodeFilePath(path))
+
+	if ref == nil {
+		return []byte(data), nil, nil
+	}
+
+	return store.resolve(path, ref)
+}
+
+// resolve returns the content of a ref in index or history
+func (store *Store) resolve(path string, ref *FileRef) ([]byte, *FileRef, error) {
+	s := store.getShard(ref.Kind, ref.Name)
+
+	if s == nil {
+		return nil, nil, fmt.Errorf("can't find %s %s of file %s", ref.Kind, ref.Name, path)
+	}
+
+	content, exist := s.data[ref.Key]
+
+	if !exist {
+		return nil, nil, fmt.Errorf("can't find content of file %s in %s %s", path, ref.Kind, ref.Name)
+	}
+
+	return content, ref, nil
+}
+
+// GetFileItemTree is the same as the package level one, with contents of files resolved.
+// Binary contents are base64 encoded.
+func (store *Store) GetFileItemTree(basePath string) (*FileItem, error) {
+	root, err := GetFileItemTree(store.Index, basePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.resolveFileItem(root); err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+func (store *Store) resolveFileItem(item *FileItem) error {
+	if item.IsDir {
+		for _, child := range item.Children {
+			if err := store.resolveFileItem(child); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	content, ref, err := store.Content(item.AbsPath)
+
+	if err != nil {
+		return err
+	}
+
+	item.Content = string(content)
+
+	if ref != nil {
+		item.Secret = ref.Kind == ShardKindSecret
+		item.Base64 = ref.Binary
+
+		if ref.Binary {
+			item.Content = base64.StdEncoding.EncodeToString(content)
+		}
+	}
+
+	return nil
+}
+
+func (store *Store) AddFile(file *File, replaceExistingOpt ...bool) error {
+	if file.IsDir {
+		return AddFile(store.Index, file, replaceExistingOpt...)
+	}
+
+	fileKey := EncodeFilePath(file.Path)
+
+	if _, exist := store.Index.Data[fileKey]; exist && (len(replaceExistingOpt) == 0 || replaceExistingOpt[0] == false) {
+		return fmt.Errorf("File or dir Exist")
+	}
+
+	if err := store.keepInlineRevision(file.Path); err != nil {
+		return err
+	}
+
+	ref, err := store.put(file)
+
+	if err != nil {
+		return err
+	}
+
+	if err := AddFile(store.Index, &File{Path: file.Path, Content: ref.String()}, replaceExistingOpt...); err != nil {
+		return err
+	}
+
+	store.refKeys[fileKey] = true
+
+	return store.recordRevision(file.Path, ref.String(), 0)
+}
+
+func (store *Store) UpdateFile(file *File) error {
+	if _, _, err := store.Content(file.Path); err != nil {
+		return err
+	}
+
+	if err := store.keepInlineRevision(file.Path); err != nil {
+		return err
+	}
+
+	ref, err := store.put(file)
+
+	if err != nil {
+		return err
+	}
+
+	if err := UpdateFile(store.Index, &File{Path: file.Path, Content: ref.String()}); err != nil {
+		return err
+	}
+
+	store.refKeys[EncodeFilePath(file.Path)] = true
+
+	return store.recordRevision(file.Path, ref.String(), 0)
+}
+
+// MoveFile moves a file or dir, contents stay where they are as the refs are moved. Revisions are moved as well.
+func (store *Store) MoveFile(oldPath, newPath string) error {
+	root, err := GetFileItemTree(store.Index, oldPath)
+
+	if err != nil {
+		return err
+	}
+
+	if err := MoveFile(store.Index, root, newPath); err != nil {
+		return err
+	}
+
+	CleanUpConfigMap(store.Index)
+	store.moveHistory(oldPath, newPath)
+	store.moveRefKeys(oldPath, newPath)
+
+	return nil
+}
+
+// DeleteFile deletes a file or dir with its revisions
+func (store *Store) DeleteFile(path string) error {
+	if err := DeleteFile(store.Index, &File{Path: path}); err != nil {
+		return err
+	}
+
+	CleanUpConfigMap(store.Index)
+	store.moveHistory(path, "")
+	store.moveRefKeys(path, "")
+
+	return nil
+}
+
+// put stores the content of a file in a shard with enough room
+func (store *Store) put(file *File) (*FileRef, error) {
+	content := []byte(file.Content)
+
+	if file.Base64 {
+		decoded, err := base64.StdEncoding.DecodeString(file.Content)
+
+		if err != nil {
+			return nil, fmt.Errorf("content of file %s is not valid base64: %s", file.Path, err.Error())
+		}
+
+		content = decoded
+	}
+
+	kind := ShardKindConfigMap
+
+	if file.Secret {
+		kind = ShardKindSecret
+	}
+
+	// the replaced content is no longer referenced
+	store.collectGarbage(EncodeFilePath(file.Path))
+
+	key := EncodeFilePath(file.Path)
+	size := len(key) + len(content)
+
+	if size > KALM_FILES_SHARD_SIZE_LIMIT {
+		return nil, fmt.Errorf("file %s is too large, the limit is %d bytes", file.Path, KALM_FILES_SHARD_SIZE_LIMIT)
+	}
+
+	var target *shard
+
+	for _, s := range store.shards {
+		if s.kind == kind && s.size()+size <= KALM_FILES_SHARD_SIZE_LIMIT {
+			target = s
+			break
+		}
+	}
+
+	if target == nil {
+		target = store.newShard(kind)
+	}
+
+	// a moved file may still refer to the content stored under its old path
+	for i := 1; ; i++ {
+		if _, exist := target.data[key]; !exist {
+			break
+		}
+
+		key = fmt.Sprintf("%s.%d", EncodeFilePath(file.Path), i)
+	}
+
+	target.data[key] = content
+	target.binary[key] = file.Base64
+
+	return &FileRef{Kind: kind, Name: target.name(), Key: key, Binary: file.Base64}, nil
+}
+
+func (store *Store) newShard(kind ShardKind) *shard {
+	prefix := KALM_CONFIG_MAP_NAME
+
+	if kind == ShardKindSecret {
+		prefix = KALM_CONFIG_MAP_NAME + "-secret"
+	}
+
+	var name string
+
+	for i := 1; ; i++ {
+		name = fmt.Sprintf("%s-%d", prefix, i)
+
+		if store.getShard(kind, name) == nil {
+			break
+		}
+	}
+
+	objectMeta := metaV1.ObjectMeta{
+		Namespace: store.Index.Namespace,
+		Name:      name,
+		Labels:    map[string]string{KALM_FILES_SHARD_LABEL: KALM_CONFIG_MAP_NAME},
+	}
+
+	s := &shard{kind: kind, data: make(map[string][]byte), binary: make(map[string]bool)}
+
+	if kind == ShardKindSecret {
+		s.secret = &coreV1.Secret{ObjectMeta: objectMeta, Type: coreV1.SecretTypeOpaque}
+	} else {
+		s.configMap = &coreV1.ConfigMap{ObjectMeta: objectMeta}
+	}
+
+	store.addShard(s)
+
+	return s
+}
+
+// collectGarbage removes contents which are not referenced by the index, ignoring the ref at the excluded key
+func (store *Store) collectGarbage(excludedKeys ...string) {
+	excluded := make(map[string]bool, len(excludedKeys))
+
+	for _, key := range excludedKeys {
+		excluded[key] = true
+	}
+
+	referenced := make(map[string]bool)
+
+	for key := range store.Index.Data {
+		if excluded[key] {
+			continue
+		}
+
+		if ref := store.indexRef(key); ref != nil {
+			referenced[ref.String()] = true
+		}
+	}
+
+	for _, revisions := range store.allRevisions() {
+		for _, revision := range revisions {
+			if ref := ParseFileRef(revision.Ref); ref != nil {
+				referenced[ref.String()] = true
+			}
+		}
+	}
+
+	for _, s := range store.shards {
+		for key := range s.data {
+			ref := FileRef{Kind: s.kind, Name: s.name(), Key: key, Binary: s.binary[key]}
+
+			if !referenced[ref.String()] {
+				delete(s.data, key)
+				delete(s.binary, key)
+			}
+		}
+	}
+}
+
+// Save writes the changes. New contents are written before the index and history, and contents no longer
+// referenced are removed after them, so they never refer to a missing content.
+func (store *Store) Save(ctx context.Context, writer client.Writer) error {
+	store.collectGarbage()
+
+	merged := make([]map[string][]byte, len(store.shards))
+
+	for i, s := range store.shards {
+		merged[i] = make(map[string][]byte, len(s.original)+len(s.data))
+
+		for key, value := range s.original {
+			merged[i][key] = value
+		}
+
+		for key, value := range s.data {
+			merged[i][key] = value
+		}
+
+		if s.exists && dataEqual(merged[i], s.original) {
+			continue
+		}
+
+		if !s.exists {
+			if len(s.data) == 0 {
+				continue
+			}
+
+			if err := writer.Create(ctx, s.object(merged[i])); err != nil {
+				return err
+			}
+
+			s.exists = true
+		} else if err := writer.Update(ctx, s.object(merged[i])); err != nil {
+			return err
+		}
+	}
+
+	if err := store.saveRefKeys(); err != nil {
+		return err
+	}
+
+	if !store.indexExists {
+		if err := writer.Create(ctx, store.Index); err != nil {
+			return err
+		}
+
+		store.indexExists = true
+	} else if err := writer.Update(ctx, store.Index); err != nil {
+		return err
+	}
+
+	if err := store.saveHistory(ctx, writer); err != nil {
+		return err
+	}
+
+	for i, s := range store.shards {
+		if !s.exists || dataEqual(s.data, merged[i]) {
+			continue
+		}
+
+		if len(s.data) == 0 {
+			if err := writer.Delete(ctx, s.object(s.data)); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+
+			s.exists = false
+		} else if err := writer.Update(ctx, s.object(s.data)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MountSource returns where the content of a file is, to be projected into pods
+func (store *Store) MountSource(path string) (kind ShardKind, name, key string) {
+	fileKey := EncodeFilePath(path)
+
+	if ref := store.indexRef(fileKey); ref != nil {
+		return ref.Kind, ref.Name, ref.Key
+	}
+
+	return ShardKindConfigMap, KALM_CONFIG_MAP_NAME, fileKey
+}
+
+// Checksum of the mounted files, changes once any of them is edited.
+// Every edit is stored under a new ref, as the ref of the previous revision is kept in the history,
+// so refs are hashed instead of versions of the shards, which hold unrelated files as well.
+// Files still inline in the index only have the version of the index.
+// It's readable by anyone who can read the workload, so contents are not hashed.
+func (store *Store) Checksum(paths []string) string {
+	sorted := append([]string{}, paths...)
+	sort.Strings(sorted)
+
+	hash := sha256.New()
+
+	for _, path := range sorted {
+		key := EncodeFilePath(path)
+		version := string(store.Index.UID) + "/" + store.Index.ResourceVersion
+
+		if ref := store.indexRef(key); ref != nil {
+			version = ref.String()
+		}
+
+		hash.Write([]byte(path))
+		hash.Write([]byte{0})
+		hash.Write([]byte(version))
+		hash.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func dataEqual(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for key, value := range a {
+		if v, exist := b[key]; !exist || !bytes.Equal(v, value) {
+			return false
+		}
+	}
+
+	return true
+}
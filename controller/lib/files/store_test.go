@@ -0,0 +1,289 @@
+package files
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type StoreTestSuite struct {
+	suite.Suite
+	client client.Client
+	ctx    context.Context
+}
+
+func (suite *StoreTestSuite) SetupTest() {
+	suite.ctx = context.Background()
+	suite.client = fake.NewFakeClientWithScheme(scheme.Scheme, &coreV1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      KALM_CONFIG_MAP_NAME,
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			KALM_SLASH_REPLACER:       KALM_PERSISTENT_DIR_PLACEHOLDER,
+			EncodeFilePath("/a.conf"): "inline",
+		},
+	})
+}
+
+func (suite *StoreTestSuite) load() *Store {
+	store, err := LoadStore(suite.ctx, suite.client, "default")
+	suite.Require().Nil(err)
+	return store
+}
+
+func (suite *StoreTestSuite) TestShardedFiles() {
+	store := suite.load()
+	suite.Nil(store.AddFile(&File{Path: "/nginx/nginx.conf", Content: "worker_processes 1;"}))
+	suite.Nil(store.AddFile(&File{Path: "/tls/key.pem", Content: "private", Secret: true}))
+	suite.Nil(store.AddFile(&File{Path: "/logo.png", Content: base64.StdEncoding.EncodeToString([]byte{0, 1, 2}), Base64: true}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	var secret coreV1.Secret
+	suite.Nil(suite.client.Get(suite.ctx, types.NamespacedName{Namespace: "default", Name: "kalm-files-secret-1"}, &secret))
+	suite.Equal("private", string(secret.Data[EncodeFilePath("/tls/key.pem")]))
+
+	var shard coreV1.ConfigMap
+	suite.Nil(suite.client.Get(suite.ctx, types.NamespacedName{Namespace: "default", Name: "kalm-files-1"}, &shard))
+	suite.Equal("worker_processes 1;", shard.Data[EncodeFilePath("/nginx/nginx.conf")])
+	suite.Equal([]byte{0, 1, 2}, shard.BinaryData[EncodeFilePath("/logo.png")])
+
+	store = suite.load()
+	root, err := store.GetFileItemTree("/")
+	suite.Nil(err)
+	suite.Len(root.Children, 4)
+
+	content, ref, err := store.Content("/a.conf")
+	suite.Nil(err)
+	suite.Nil(ref)
+	suite.Equal("inline", string(content))
+
+	item, err := store.GetFileItemTree("/logo.png")
+	suite.Nil(err)
+	suite.True(item.Base64)
+	suite.Equal(base64.StdEncoding.EncodeToString([]byte{0, 1, 2}), item.Content)
+
+	item, err = store.GetFileItemTree("/tls/key.pem")
+	suite.Nil(err)
+	suite.True(item.Secret)
+
+	kind, name, key := store.MountSource("/a.conf")
+	suite.Equal(ShardKindConfigMap, kind)
+	suite.Equal(KALM_CONFIG_MAP_NAME, name)
+	suite.Equal(EncodeFilePath("/a.conf"), key)
+
+	kind, name, _ = store.MountSource("/tls/key.pem")
+	suite.Equal(ShardKindSecret, kind)
+	suite.Equal("kalm-files-secret-1", name)
+}
+
+func (suite *StoreTestSuite) TestLargeFilesAreSplit() {
+	store := suite.load()
+	content := strings.Repeat("a", KALM_FILES_SHARD_SIZE_LIMIT/2)
+	suite.Nil(store.AddFile(&File{Path: "/1", Content: content}))
+	suite.Nil(store.AddFile(&File{Path: "/2", Content: content}))
+	suite.NotNil(store.AddFile(&File{Path: "/3", Content: content + content}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	var shard coreV1.ConfigMap
+	suite.Nil(suite.client.Get(suite.ctx, types.NamespacedName{Namespace: "default", Name: "kalm-files-2"}, &shard))
+	suite.Len(shard.Data, 1)
+}
+
+func (suite *StoreTestSuite) TestMoveAndDelete() {
+	store := suite.load()
+	suite.Nil(store.AddFile(&File{Path: "/nginx/nginx.conf", Content: "v1"}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	store = suite.load()
+	suite.Nil(store.MoveFile("/nginx", "/etc/nginx"))
+	suite.Nil(store.AddFile(&File{Path: "/nginx/nginx.conf", Content: "v2"}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	store = suite.load()
+	content, _, err := store.Content("/etc/nginx/nginx.conf")
+	suite.Nil(err)
+	suite.Equal("v1", string(content))
+	content, _, err = store.Content("/nginx/nginx.conf")
+	suite.Nil(err)
+	suite.Equal("v2", string(content))
+
+	suite.Nil(store.UpdateFile(&File{Path: "/etc/nginx/nginx.conf", Content: "v3"}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	var shard coreV1.ConfigMap
+	suite.Nil(suite.client.Get(suite.ctx, types.NamespacedName{Namespace: "default", Name: "kalm-files-1"}, &shard))
+	// v1 is kept as a revision
+	suite.Len(shard.Data, 3)
+
+	store = suite.load()
+	suite.Nil(store.DeleteFile("/etc"))
+	suite.Nil(store.DeleteFile("/nginx"))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	err = suite.client.Get(suite.ctx, types.NamespacedName{Namespace: "default", Name: "kalm-files-1"}, &shard)
+	suite.True(errors.IsNotFound(err))
+}
+
+func (suite *StoreTestSuite) TestRevisions() {
+	store := suite.load()
+	store.Author = "foo@bar"
+	suite.Nil(store.UpdateFile(&File{Path: "/a.conf", Content: "v2"}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	store = suite.load()
+	store.Author = "bar@foo"
+	suite.Nil(store.UpdateFile(&File{Path: "/a.conf", Content: "v3"}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	store = suite.load()
+	revisions, err := store.Revisions("/a.conf")
+	suite.Nil(err)
+	suite.Len(revisions, 3)
+
+	// the inline content before any revision
+	suite.Equal("", revisions[0].Author)
+	content, _, err := store.RevisionContent("/a.conf", revisions[0])
+	suite.Nil(err)
+	suite.Equal("inline", string(content))
+	suite.Equal("foo@bar", revisions[1].Author)
+	suite.Equal(3, revisions[2].Revision)
+
+	suite.NotNil(store.RollbackFile("/a.conf", 4))
+	suite.Nil(store.RollbackFile("/a.conf", 1))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	store = suite.load()
+	content, _, err = store.Content("/a.conf")
+	suite.Nil(err)
+	suite.Equal("inline", string(content))
+	revisions, err = store.Revisions("/a.conf")
+	suite.Nil(err)
+	suite.Equal(1, revisions[3].RollbackOf)
+
+	for i := 0; i < KALM_FILES_REVISION_LIMIT; i++ {
+		suite.Nil(store.UpdateFile(&File{Path: "/a.conf", Content: fmt.Sprintf("v%d", i+5)}))
+	}
+
+	revisions, err = store.Revisions("/a.conf")
+	suite.Nil(err)
+	suite.Len(revisions, KALM_FILES_REVISION_LIMIT)
+	suite.Equal(5, revisions[0].Revision)
+
+	suite.Nil(store.MoveFile("/a.conf", "/b.conf"))
+	revisions, err = store.Revisions("/b.conf")
+	suite.Nil(err)
+	suite.Len(revisions, KALM_FILES_REVISION_LIMIT)
+}
+
+func (suite *StoreTestSuite) TestRollbackDir() {
+	store := suite.load()
+	suite.Nil(store.AddFile(&File{Path: "/nginx/nginx.conf", Content: "v1"}))
+	suite.Nil(store.AddFile(&File{Path: "/nginx/mime.types", Content: "v1"}))
+
+	// pretend nginx.conf was written an hour ago
+	revisions, _ := store.Revisions("/nginx/nginx.conf")
+	revisions[0].Timestamp = v1.NewTime(time.Now().Add(-time.Hour))
+	suite.Nil(store.setRevisions("/nginx/nginx.conf", revisions))
+	suite.Nil(store.UpdateFile(&File{Path: "/nginx/nginx.conf", Content: "v2"}))
+
+	restored, err := store.RollbackDir("/nginx", time.Now().Add(-time.Minute))
+	suite.Nil(err)
+	suite.Equal([]string{"/nginx/nginx.conf"}, restored)
+
+	content, _, err := store.Content("/nginx/nginx.conf")
+	suite.Nil(err)
+	suite.Equal("v1", string(content))
+}
+
+func (suite *StoreTestSuite) TestInlineContentLikeRef() {
+	fakeRef := KALM_FILE_REF_PREFIX + "Secret/kalm-files-secret-1/" + EncodeFilePath("/tls/key.pem")
+
+	suite.client = fake.NewFakeClientWithScheme(scheme.Scheme, &coreV1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      KALM_CONFIG_MAP_NAME,
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			KALM_SLASH_REPLACER:        KALM_PERSISTENT_DIR_PLACEHOLDER,
+			EncodeFilePath("/ref.txt"): fakeRef,
+		},
+	})
+
+	store := suite.load()
+	suite.Nil(store.AddFile(&File{Path: "/tls/key.pem", Content: "private", Secret: true}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	// the shard exists now, the inline content is still not a ref
+	store = suite.load()
+	content, ref, err := store.Content("/ref.txt")
+	suite.Nil(err)
+	suite.Nil(ref)
+	suite.Equal(fakeRef, string(content))
+
+	kind, name, _ := store.MountSource("/ref.txt")
+	suite.Equal(ShardKindConfigMap, kind)
+	suite.Equal(KALM_CONFIG_MAP_NAME, name)
+
+	content, ref, err = store.Content("/tls/key.pem")
+	suite.Nil(err)
+	suite.NotNil(ref)
+	suite.Equal("private", string(content))
+}
+
+func (suite *StoreTestSuite) TestChecksum() {
+	store := suite.load()
+	suite.Nil(store.AddFile(&File{Path: "/tls/key.pem", Content: "private", Secret: true}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	store = suite.load()
+	checksum := store.Checksum([]string{"/tls/key.pem", "/a.conf"})
+	suite.Equal(checksum, store.Checksum([]string{"/a.conf", "/tls/key.pem"}))
+
+	suite.Nil(store.UpdateFile(&File{Path: "/tls/key.pem", Content: "rotated", Secret: true}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	store = suite.load()
+	suite.NotEqual(checksum, store.Checksum([]string{"/tls/key.pem", "/a.conf"}))
+}
+
+func (suite *StoreTestSuite) TestChecksumOfUneditedFile() {
+	store := suite.load()
+	suite.Nil(store.AddFile(&File{Path: "/a.yaml", Content: "a"}))
+	suite.Nil(store.AddFile(&File{Path: "/b.yaml", Content: "b"}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	store = suite.load()
+	checksumA := store.Checksum([]string{"/a.yaml"})
+	checksumB := store.Checksum([]string{"/b.yaml"})
+
+	// both files are in the same shard, editing a doesn't change the checksum of b
+	suite.Nil(store.UpdateFile(&File{Path: "/a.yaml", Content: "a2"}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	store = suite.load()
+	suite.NotEqual(checksumA, store.Checksum([]string{"/a.yaml"}))
+	suite.Equal(checksumB, store.Checksum([]string{"/b.yaml"}))
+
+	suite.Nil(store.UpdateFile(&File{Path: "/a.yaml", Content: "a3"}))
+	suite.Nil(store.Save(suite.ctx, suite.client))
+
+	store = suite.load()
+	suite.Equal(checksumB, store.Checksum([]string{"/b.yaml"}))
+}
+
+func TestStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(StoreTestSuite))
+}
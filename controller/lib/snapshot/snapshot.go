@@ -0,0 +1,116 @@
+package snapshot
+
+import (
+	"fmt"
+
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The csi external-snapshotter client is not a dependency,
+// volume snapshots are read and written as unstructured objects of the v1beta1 api.
+const Group = "snapshot.storage.k8s.io"
+
+var (
+	GroupVersionKind     = schema.GroupVersionKind{Group: Group, Version: "v1beta1", Kind: "VolumeSnapshot"}
+	ListGroupVersionKind = schema.GroupVersionKind{Group: Group, Version: "v1beta1", Kind: "VolumeSnapshotList"}
+)
+
+const (
+	// name of the pvc a snapshot is taken from
+	LabelPVC = "kalm-snapshot-pvc"
+
+	// snapshots taken by a schedule of a component volume, they are deleted when exceeding the retention
+	LabelScheduled = "kalm-snapshot-scheduled"
+)
+
+var ErrNotSupported = fmt.Errorf("volume snapshots are not supported, the CSI VolumeSnapshot api is not installed in the cluster")
+
+// IsNotSupported tells whether the error is caused by the VolumeSnapshot api missing in the cluster
+func IsNotSupported(err error) bool {
+	return meta.IsNoMatchError(err)
+}
+
+func New(namespace, name, pvcName string, className *string, labels map[string]string) *unstructured.Unstructured {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(GroupVersionKind)
+	snapshot.SetNamespace(namespace)
+	snapshot.SetName(name)
+	snapshot.SetLabels(labels)
+
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": pvcName,
+		},
+	}
+
+	if className != nil && *className != "" {
+		spec["volumeSnapshotClassName"] = *className
+	}
+
+	snapshot.Object["spec"] = spec
+
+	return snapshot
+}
+
+func NewEmpty() *unstructured.Unstructured {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(GroupVersionKind)
+	return snapshot
+}
+
+func NewList() *unstructured.UnstructuredList {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(ListGroupVersionKind)
+	return list
+}
+
+// Info is the part of a VolumeSnapshot kalm cares about
+type Info struct {
+	Name                    string             `json:"name"`
+	Namespace               string             `json:"namespace"`
+	PVC                     string             `json:"pvc"`
+	VolumeSnapshotClassName string             `json:"volumeSnapshotClassName,omitempty"`
+	Scheduled               bool               `json:"scheduled"`
+	ReadyToUse              bool               `json:"readyToUse"`
+	RestoreSize             *resource.Quantity `json:"restoreSize,omitempty"`
+	CreationTimestamp       metaV1.Time        `json:"creationTimestamp"`
+	Error                   string             `json:"error,omitempty"`
+}
+
+func GetInfo(snapshot *unstructured.Unstructured) *Info {
+	info := &Info{
+		Name:              snapshot.GetName(),
+		Namespace:         snapshot.GetNamespace(),
+		Scheduled:         snapshot.GetLabels()[LabelScheduled] == "true",
+		CreationTimestamp: snapshot.GetCreationTimestamp(),
+	}
+
+	info.PVC, _, _ = unstructured.NestedString(snapshot.Object, "spec", "source", "persistentVolumeClaimName")
+	info.VolumeSnapshotClassName, _, _ = unstructured.NestedString(snapshot.Object, "spec", "volumeSnapshotClassName")
+	info.ReadyToUse, _, _ = unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+	info.Error, _, _ = unstructured.NestedString(snapshot.Object, "status", "error", "message")
+
+	if size, exist, _ := unstructured.NestedString(snapshot.Object, "status", "restoreSize"); exist {
+		if quantity, err := resource.ParseQuantity(size); err == nil {
+			info.RestoreSize = &quantity
+		}
+	}
+
+	return info
+}
+
+// DataSource of a pvc restored from the snapshot, the pvc must be in the same namespace
+func DataSource(snapshotName string) *coreV1.TypedLocalObjectReference {
+	group := Group
+
+	return &coreV1.TypedLocalObjectReference{
+		APIGroup: &group,
+		Kind:     GroupVersionKind.Kind,
+		Name:     snapshotName,
+	}
+}
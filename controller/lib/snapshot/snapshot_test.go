@@ -0,0 +1,35 @@
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetInfo(t *testing.T) {
+	className := "csi-hostpath-snapclass"
+	s := New("app", "db-data-1", "db-data", &className, map[string]string{LabelScheduled: "true"})
+
+	s.Object["status"] = map[string]interface{}{
+		"readyToUse":  true,
+		"restoreSize": "1Gi",
+	}
+
+	info := GetInfo(s)
+	assert.Equal(t, "db-data", info.PVC)
+	assert.Equal(t, className, info.VolumeSnapshotClassName)
+	assert.True(t, info.Scheduled)
+	assert.True(t, info.ReadyToUse)
+	assert.Equal(t, "1Gi", info.RestoreSize.String())
+	assert.Empty(t, info.Error)
+
+	s.Object["status"] = map[string]interface{}{
+		"readyToUse": false,
+		"error":      map[string]interface{}{"message": "failed to take snapshot"},
+	}
+
+	info = GetInfo(s)
+	assert.False(t, info.ReadyToUse)
+	assert.Nil(t, info.RestoreSize)
+	assert.Equal(t, "failed to take snapshot", info.Error)
+}
@@ -31,12 +31,14 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	corev1alpha1 "github.com/kalmhq/kalm/controller/api/v1alpha1"
 	"github.com/kalmhq/kalm/controller/controllers"
 
 	cmv1alpha2 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
 	apiregistration "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -68,6 +70,9 @@ func init() {
 	kibanav1.AddToScheme(scheme)
 	istioScheme.AddToScheme(scheme)
 
+	// pod metrics of metrics-server, used to evaluate alert rules
+	_ = metricsv1beta1.AddToScheme(scheme)
+
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -175,6 +180,29 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = (controllers.NewAccessTokenReconciler(mgr)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AccessToken")
+		os.Exit(1)
+	}
+
+	if err = (controllers.NewRoleBindingReconciler(mgr)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RoleBinding")
+		os.Exit(1)
+	}
+
+	if err = (controllers.NewAlertRuleReconciler(mgr)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AlertRule")
+		os.Exit(1)
+	}
+
+	if err = (controllers.NewVolumeSnapshotScheduleReconciler(mgr)).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VolumeSnapshotSchedule")
+		os.Exit(1)
+	}
+
+	// served at --metrics-addr along with metrics of controller-runtime
+	metrics.Registry.MustRegister(controllers.NewKalmMetricsCollector(mgr))
+
 	// only run webhook if explicitly declared
 	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
 		if err = (&corev1alpha1.AccessToken{}).SetupWebhookWithManager(mgr); err != nil {
@@ -187,6 +215,16 @@ func main() {
 			os.Exit(1)
 		}
 
+		if err = (&corev1alpha1.AlertRule{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "AlertRule")
+			os.Exit(1)
+		}
+
+		if err = (&corev1alpha1.CustomRole{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "CustomRole")
+			os.Exit(1)
+		}
+
 		if err = (&corev1alpha1.Component{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Component")
 			os.Exit(1)